@@ -0,0 +1,130 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Formats of ACCESS_LOG_FORMAT.
+const (
+	// accessLogJSON logs each request as a structured record, in LOG_FORMAT.
+	accessLogJSON = "json"
+	// accessLogCombined writes Apache combined log lines, with the API key
+	// ID as the user.
+	accessLogCombined = "combined"
+)
+
+// Render outcomes reported by the access log.
+const (
+	renderOutcomeSuccess = "success"
+	renderOutcomeFailure = "failure"
+	// renderOutcomeAbandoned is a render whose client disconnected.
+	renderOutcomeAbandoned = "abandoned"
+)
+
+// accessLogOut receives the combined log lines.
+var accessLogOut io.Writer = logRingWriter{out: os.Stderr, recent: recentLogs}
+
+// countingBody counts the request body bytes read by the handlers.
+type countingBody struct {
+	io.ReadCloser
+	n int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+// orDash is value, or "-" for an empty combined log field.
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// isProbePath reports whether path is a health probe, whose successful
+// requests ACCESS_LOG_SKIP_PROBES leaves out of the access log.
+func isProbePath(path string) bool {
+	return path == pathHealthz || path == pathLivez || path == pathReadyz
+}
+
+// setRenderOutcome records the outcome of the render of a request for the
+// access log.
+func setRenderOutcome(w http.ResponseWriter, ctx context.Context, err error) {
+	rw, ok := w.(*responseWriter)
+	if !ok {
+		return
+	}
+	switch {
+	case err == nil:
+		rw.outcome = renderOutcomeSuccess
+	case ctx.Err() != nil:
+		rw.outcome = renderOutcomeAbandoned
+	default:
+		rw.outcome = renderOutcomeFailure
+	}
+}
+
+// accessLog logs one request. The client IP is the peer address; a
+// X-Forwarded-For header is logged as it was received.
+func accessLog(format string, r *http.Request, rw *responseWriter, requestID string, requestBytes int64, elapsed time.Duration) {
+	clientIP := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		clientIP = host
+	}
+
+	if format == accessLogCombined {
+		user, size := "-", "-"
+		if rw.keyID != "" {
+			user = rw.keyID
+		}
+		if rw.bytes > 0 {
+			size = strconv.FormatInt(rw.bytes, 10)
+		}
+		line := fmt.Sprintf("%s - %s [%s] %q %d %s %q %q\n", clientIP, user, time.Now().Format("02/Jan/2006:15:04:05 -0700"),
+			r.Method+" "+r.URL.RequestURI()+" "+r.Proto, rw.status, size, orDash(r.Referer()), orDash(r.UserAgent()))
+		_, _ = io.WriteString(accessLogOut, line)
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("request_id", requestID),
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+		slog.Int("status", rw.status),
+		slog.Float64("duration_ms", float64(elapsed.Microseconds())/1000),
+		slog.String("client_ip", clientIP),
+		slog.String("user_agent", r.UserAgent()),
+		slog.Int64("request_bytes", requestBytes),
+		slog.Int64("response_bytes", rw.bytes),
+	}
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		attrs = append(attrs, slog.String("forwarded_for", forwarded))
+	}
+	if rw.keyID != "" {
+		attrs = append(attrs, slog.String("key_id", rw.keyID))
+	}
+	if rw.outcome != "" {
+		attrs = append(attrs, slog.String("outcome", rw.outcome))
+	}
+	if r.URL.Path == pathPDF && r.Method != http.MethodOptions {
+		attrs = append(attrs, slog.Int64("pdf_bytes", rw.bytes))
+		if rw.pdfTimeSet {
+			attrs = append(attrs, slog.Float64("pdf_time_ms", float64(rw.pdfTime.Microseconds())/1000))
+		}
+	}
+	slog.LogAttrs(r.Context(), slog.LevelInfo, fmt.Sprintf("%s %s %d", r.Method, r.URL.Path, rw.status), attrs...)
+}
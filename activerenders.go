@@ -0,0 +1,113 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// errRenderCancelled fails a render cancelled through /admin/renders.
+var errRenderCancelled = errors.New("render cancelled by an operator")
+
+// activeRender is a render in flight, as listed by GET /admin/renders.
+type activeRender struct {
+	ID        string
+	RequestID string
+	Caller    string
+	Started   time.Time
+
+	cancel context.CancelCauseFunc
+
+	mu       sync.Mutex
+	targetID string
+}
+
+// activeRenderView is the JSON form of an activeRender.
+type activeRenderView struct {
+	ID         string    `json:"id"`
+	RequestID  string    `json:"request_id,omitempty"`
+	Caller     string    `json:"caller,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	AgeSeconds float64   `json:"age_seconds"`
+	TargetID   string    `json:"target_id,omitempty"`
+}
+
+type activeRenderContextKey struct{}
+
+// setRenderTarget records the Chrome target of the render of ctx, once it is
+// open.
+func setRenderTarget(ctx context.Context, targetID string) {
+	if render, ok := ctx.Value(activeRenderContextKey{}).(*activeRender); ok {
+		render.mu.Lock()
+		render.targetID = targetID
+		render.mu.Unlock()
+	}
+}
+
+func (r *activeRender) view(now time.Time) activeRenderView {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return activeRenderView{ID: r.ID, RequestID: r.RequestID, Caller: r.Caller, StartedAt: r.Started.UTC(),
+		AgeSeconds: now.Sub(r.Started).Seconds(), TargetID: r.targetID}
+}
+
+// list returns the renders in flight, oldest first.
+func (t *renderTracker) list() []activeRenderView {
+	now := time.Now()
+	t.mu.Lock()
+	views := make([]activeRenderView, 0, len(t.renders))
+	for _, render := range t.renders {
+		views = append(views, render.view(now))
+	}
+	t.mu.Unlock()
+	sort.Slice(views, func(i, j int) bool { return views[i].StartedAt.Before(views[j].StartedAt) })
+	return views
+}
+
+// cancel interrupts the render with id: its Chrome calls are abandoned and
+// its target closed. It reports false when no such render is in flight.
+func (t *renderTracker) cancel(id string) bool {
+	t.mu.Lock()
+	render, ok := t.renders[id]
+	t.mu.Unlock()
+	if !ok {
+		return false
+	}
+	render.cancel(errRenderCancelled)
+	return true
+}
+
+// activeRendersHandler serves GET /admin/renders: the renders in flight.
+func activeRendersHandler(tracker *renderTracker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"renders": tracker.list()})
+	}
+}
+
+// cancelRenderHandler serves DELETE /admin/renders/{id}: it cancels the
+// render.
+func cancelRenderHandler(tracker *renderTracker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		id := r.PathValue("id")
+		if !tracker.cancel(id) {
+			http.Error(w, "render not found", http.StatusNotFound)
+			return
+		}
+		Warnf("render %s cancelled by an operator", id)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,244 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// Bundle layout produced by /admin/export and accepted by /admin/import.
+const (
+	bundleManifestName = "manifest.json"
+	bundlePoliciesName = "policies.json"
+	bundleTemplatesDir = "templates/"
+)
+
+// bundleManifest describes an export bundle.
+type bundleManifest struct {
+	Format     int       `json:"format"`
+	ExportedAt time.Time `json:"exported_at"`
+	Version    string    `json:"version"`
+	Templates  int       `json:"templates"`
+}
+
+// importResult is the response of /admin/import.
+type importResult struct {
+	TemplateVersions int  `json:"template_versions"`
+	Policies         bool `json:"policies"`
+}
+
+// flushResult is the response of /admin/flush.
+type flushResult struct {
+	Endpoints       int `json:"endpoints"`
+	Healthy         int `json:"healthy"`
+	DetachedRenders int `json:"detached_renders"`
+}
+
+// adminMiddleware protects admin endpoints with a bearer token. Without
+// ADMIN_TOKEN the admin endpoints are disabled.
+func adminMiddleware(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token == "" {
+			http.NotFound(w, r)
+			return
+		}
+		presented, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="pdfrest-admin"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// exportHandler serves GET /admin/export: a ZIP bundle with every live
+// template version, the key/tenant policies (including inlined branding
+// assets) and a manifest.
+func exportHandler(templates *templateStore, policies *policyStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		versions := templates.export()
+		var buf bytes.Buffer
+		archive := zip.NewWriter(&buf)
+		write := func(name string, v any) error {
+			file, err := archive.Create(name)
+			if err != nil {
+				return err
+			}
+			encoder := json.NewEncoder(file)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(v)
+		}
+
+		err := write(bundleManifestName, bundleManifest{
+			Format:     1,
+			ExportedAt: time.Now().UTC(),
+			Version:    currentBuildInfo().Version,
+			Templates:  len(versions),
+		})
+		for _, tmpl := range versions {
+			if err != nil {
+				break
+			}
+			err = write(fmt.Sprintf("%s%s/v%d.json", bundleTemplatesDir, tmpl.Name, tmpl.Version), tmpl)
+		}
+		// Without policies the bundle has no policies.json, so importing it
+		// leaves the target's policies alone.
+		if snapshot := policies.snapshot(); err == nil && (len(snapshot.Keys) > 0 || len(snapshot.Tenants) > 0 || len(snapshot.Presets) > 0) {
+			err = write(bundlePoliciesName, snapshot)
+		}
+		if err == nil {
+			err = archive.Close()
+		}
+		if err != nil {
+			Errorf("export error: %v", err)
+			http.Error(w, "export failed", http.StatusInternalServerError)
+			return
+		}
+
+		Infof("admin export: %d template versions", len(versions))
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="pdfrest-export-%s.zip"`, time.Now().UTC().Format("20060102-150405")))
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(buf.Bytes())
+	}
+}
+
+// importHandler serves POST /admin/import with a bundle produced by
+// /admin/export. Templates are merged version by version; policies, when
+// present in the bundle, replace the current ones.
+func importHandler(templates *templateStore, policies *policyStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, defaultImportMaxBytes)
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "invalid request body", mapBodyReadErrorToStatus(err))
+			return
+		}
+		archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+		if err != nil {
+			http.Error(w, "invalid bundle", http.StatusBadRequest)
+			return
+		}
+
+		var (
+			versions    []storedTemplate
+			imported    *policyFile
+			hasManifest bool
+		)
+		for _, file := range archive.File {
+			switch {
+			case file.Name == bundleManifestName:
+				hasManifest = true
+			case file.Name == bundlePoliciesName:
+				imported = &policyFile{}
+				err = readBundleJSON(file, imported)
+			case strings.HasPrefix(file.Name, bundleTemplatesDir) && path.Ext(file.Name) == ".json":
+				var tmpl storedTemplate
+				if err = readBundleJSON(file, &tmpl); err == nil {
+					versions = append(versions, tmpl)
+				}
+			}
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid bundle entry %s", file.Name), http.StatusBadRequest)
+				return
+			}
+		}
+		if !hasManifest {
+			http.Error(w, "invalid bundle: missing manifest", http.StatusBadRequest)
+			return
+		}
+
+		// Validate the policies before touching the templates, so a bad
+		// bundle does not leave a half-applied import behind.
+		if imported != nil {
+			// Bundles carry branding assets inline; never read local files
+			// named by an uploaded bundle.
+			for name, tenant := range imported.Tenants {
+				if tenant.Branding != nil && tenant.Branding.Logo != "" && !strings.HasPrefix(tenant.Branding.Logo, "data:") {
+					http.Error(w, fmt.Sprintf("invalid policies: tenant %s: logo must be a data URI", name), http.StatusBadRequest)
+					return
+				}
+			}
+			if _, err := resolvePolicies(*imported); err != nil {
+				http.Error(w, fmt.Sprintf("invalid policies: %v", err), http.StatusBadRequest)
+				return
+			}
+		}
+
+		result := importResult{}
+		result.TemplateVersions, err = templates.importVersions(versions)
+		if err != nil {
+			writeTemplateError(w, err)
+			return
+		}
+		if imported != nil {
+			if err := policies.replace(*imported); err != nil {
+				Errorf("import policies: %v", err)
+				http.Error(w, "policy import failed", http.StatusInternalServerError)
+				return
+			}
+			result.Policies = true
+		}
+
+		Infof("admin import: %d template versions, policies=%t", result.TemplateVersions, result.Policies)
+		writeJSON(w, http.StatusOK, result)
+	}
+}
+
+// readBundleJSON decodes a JSON file from a bundle.
+func readBundleJSON(file *zip.File, v any) error {
+	rc, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := rc.Close(); err != nil {
+			Warnf("bundle entry close error: %v", err)
+		}
+	}()
+	if err := json.NewDecoder(rc).Decode(v); err != nil {
+		return fmt.Errorf("decode %s: %w", file.Name, err)
+	}
+	return nil
+}
+
+// flushHandler serves POST /admin/flush: it drops the cached Chrome websocket
+// URLs and the health of the pool, stops sharing in-flight renders, and
+// probes every endpoint again, for when Chrome was replaced behind the
+// service.
+func flushHandler(resolver *chromePool, dedup *renderDedup) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		resolver.reset()
+		result := flushResult{Endpoints: len(resolver.members), DetachedRenders: dedup.detach()}
+		resolver.probe(r.Context())
+		result.Healthy = resolver.healthy()
+		Infof("flushed chrome discovery: %d of %d endpoints healthy, %d renders detached", result.Healthy, result.Endpoints, result.DetachedRenders)
+		writeJSON(w, http.StatusOK, result)
+	}
+}
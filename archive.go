@@ -0,0 +1,270 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+var (
+	archiveIDRe = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+	// Volatile PDF fields that differ between otherwise identical renders.
+	pdfVolatileRes = []*regexp.Regexp{
+		regexp.MustCompile(`/(CreationDate|ModDate)\s*\([^)]*\)`),
+		regexp.MustCompile(`/ID\s*\[\s*<[0-9A-Fa-f]*>\s*<[0-9A-Fa-f]*>\s*\]`),
+		regexp.MustCompile(`<xmp:(CreateDate|ModifyDate|MetadataDate)>[^<]*</xmp:(CreateDate|ModifyDate|MetadataDate)>`),
+	}
+)
+
+// renderRecord is an archived render: the exact input (raw HTML, or the
+// template request with its stored version pinned), the resolved options and
+// the hashes of the PDF that was returned.
+type renderRecord struct {
+	ID       string           `json:"id"`
+	Time     time.Time        `json:"time"`
+	KeyID    string           `json:"key_id,omitempty"`
+	HTML     string           `json:"html,omitempty"`
+	Template *templateRequest `json:"template,omitempty"`
+	Options  pdfOptions       `json:"options"`
+	Output   pdfDigest        `json:"output"`
+}
+
+// pdfDigest identifies a rendered PDF. ContentSHA256 ignores the fields that
+// change on every render (creation/modification dates, document ID), so two
+// renders of the same input compare equal.
+type pdfDigest struct {
+	SHA256        string `json:"sha256"`
+	ContentSHA256 string `json:"content_sha256"`
+	Bytes         int    `json:"bytes"`
+}
+
+// replayResult is the response of /admin/replay/{id}.
+type replayResult struct {
+	ID         string    `json:"id"`
+	Original   pdfDigest `json:"original"`
+	Replay     pdfDigest `json:"replay"`
+	Match      bool      `json:"match"`
+	ExactMatch bool      `json:"exact_match"`
+	DurationMS float64   `json:"duration_ms"`
+}
+
+func digestPDF(pdf []byte) pdfDigest {
+	sum := sha256.Sum256(pdf)
+	content := pdf
+	for _, re := range pdfVolatileRes {
+		content = re.ReplaceAll(content, nil)
+	}
+	contentSum := sha256.Sum256(content)
+	return pdfDigest{
+		SHA256:        hex.EncodeToString(sum[:]),
+		ContentSHA256: hex.EncodeToString(contentSum[:]),
+		Bytes:         len(pdf),
+	}
+}
+
+// renderArchive stores render records as <dir>/<request id>.json. A nil
+// archive records nothing.
+type renderArchive struct {
+	dir       string
+	retention time.Duration
+}
+
+// newRenderArchive returns nil when dir is empty (archiving disabled).
+func newRenderArchive(dir string, retention time.Duration) (*renderArchive, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("create archive dir: %w", err)
+	}
+	return &renderArchive{dir: dir, retention: retention}, nil
+}
+
+func (a *renderArchive) save(record renderRecord) error {
+	if a == nil {
+		return nil
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(a.dir, record.ID+".json"), data, 0o640)
+}
+
+// load returns the record for id; os.ErrNotExist when there is none.
+func (a *renderArchive) load(id string) (renderRecord, error) {
+	var record renderRecord
+	if a == nil || !archiveIDRe.MatchString(id) {
+		return record, os.ErrNotExist
+	}
+	data, err := os.ReadFile(filepath.Join(a.dir, id+".json"))
+	if err != nil {
+		return record, err
+	}
+	err = json.Unmarshal(data, &record)
+	return record, err
+}
+
+// prune removes records older than the retention window.
+func (a *renderArchive) prune(now time.Time) int {
+	if a == nil || a.retention <= 0 {
+		return 0
+	}
+	files, err := filepath.Glob(filepath.Join(a.dir, "*.json"))
+	if err != nil {
+		return 0
+	}
+	removed := 0
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil || now.Sub(info.ModTime()) < a.retention {
+			continue
+		}
+		if err := os.Remove(file); err != nil {
+			Warnf("archive prune: %v", err)
+			continue
+		}
+		removed++
+	}
+	return removed
+}
+
+// runPruner prunes the archive periodically until ctx is done.
+func (a *renderArchive) runPruner(ctx context.Context, interval time.Duration) {
+	if a == nil || a.retention <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if removed := a.prune(now); removed > 0 {
+				Infof("archive: pruned %d records", removed)
+			}
+		}
+	}
+}
+
+// archiveRender records a successful render under the request ID.
+func (s *pdfService) archiveRender(r *http.Request, html string, tmplReq *templateRequest, options pdfOptions, pdf []byte) {
+	if s.archive == nil {
+		return
+	}
+	record := renderRecord{
+		ID:       requestIDFromContext(r.Context()),
+		Time:     time.Now().UTC(),
+		Template: tmplReq,
+		Options:  options,
+		Output:   digestPDF(pdf),
+	}
+	if record.ID == "" {
+		record.ID = newRequestID()
+	}
+	if tmplReq == nil {
+		record.HTML = html
+	}
+	if policy, ok := policyFromContext(r.Context()); ok {
+		record.KeyID = policy.ID
+	}
+	if err := s.archive.save(record); err != nil {
+		Warnf("archive render %s: %v", record.ID, err)
+	}
+}
+
+// serveReplay serves POST /admin/replay/{id}: it re-renders an archived
+// request with the same input, options and pinned template version and
+// compares the output with the original. With ?download=true the replayed PDF
+// is returned instead, with the comparison in X-Replay-* headers.
+func (s *pdfService) serveReplay(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	record, err := s.archive.load(r.PathValue("id"))
+	if errors.Is(err, os.ErrNotExist) {
+		http.Error(w, "render not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		Errorf("replay load error: %v", err)
+		http.Error(w, "replay failed", http.StatusInternalServerError)
+		return
+	}
+
+	html := record.HTML
+	if record.Template != nil {
+		req := *record.Template
+		if req.TemplateName != "" {
+			// Soft-deleted versions can still be replayed until purged.
+			tmpl, ok := s.templates.pinned(req.TemplateName, req.TemplateVersion)
+			if !ok {
+				http.Error(w, "template version no longer available", http.StatusGone)
+				return
+			}
+			req.Template, req.TemplateName = tmpl.Source, ""
+		}
+		html, _, err = renderTemplate(s.templates, req, record.Options.Branding)
+		if err != nil {
+			writeTemplateError(w, err)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.cfg.RequestTimeout)
+	defer cancel()
+	wsURL, err := s.resolver.wsURL(ctx)
+	if err != nil {
+		Errorf("chrome ws error: %v", err)
+		http.Error(w, "chrome unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	start := time.Now()
+	pdf, _, err := s.renderer(ctx, wsURL, html, s.cfg.PDFWait, record.Options)
+	if err != nil {
+		status, msg := renderErrorStatus(err)
+		http.Error(w, msg, status)
+		return
+	}
+
+	replay := digestPDF(pdf)
+	result := replayResult{
+		ID:         record.ID,
+		Original:   record.Output,
+		Replay:     replay,
+		Match:      replay.ContentSHA256 == record.Output.ContentSHA256,
+		ExactMatch: replay.SHA256 == record.Output.SHA256,
+		DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	Infof("replay %s: match=%t exact=%t", record.ID, result.Match, result.ExactMatch)
+
+	if download, _ := strconv.ParseBool(r.URL.Query().Get("download")); download {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", "replay-"+record.ID+".pdf"))
+		w.Header().Set("X-Replay-Match", strconv.FormatBool(result.Match))
+		w.Header().Set("X-Replay-Exact-Match", strconv.FormatBool(result.ExactMatch))
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Content-Length", strconv.Itoa(len(pdf)))
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(pdf)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
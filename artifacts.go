@@ -0,0 +1,162 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// artifactStore keeps async job results on disk as <dir>/<job id>.pdf. A
+// background collector evicts artifacts older than ttl and, when the
+// directory grows past maxBytes, the oldest ones first. A nil store keeps
+// results in memory.
+type artifactStore struct {
+	dir      string
+	ttl      time.Duration
+	maxBytes int64
+
+	// gcMu serializes collections.
+	gcMu sync.Mutex
+}
+
+// newArtifactStore returns nil when dir is empty (artifacts disabled).
+func newArtifactStore(dir string, ttl time.Duration, maxBytes int64) (*artifactStore, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("create artifact dir: %w", err)
+	}
+	return &artifactStore{dir: dir, ttl: ttl, maxBytes: maxBytes}, nil
+}
+
+func (a *artifactStore) path(id string) string {
+	return filepath.Join(a.dir, id+".pdf")
+}
+
+// save writes the artifact of id and enforces the disk-usage cap.
+func (a *artifactStore) save(id string, pdf []byte) (string, error) {
+	path := a.path(id)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, pdf, 0o640); err != nil {
+		_ = os.Remove(tmp)
+		return "", err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return "", err
+	}
+	if a.maxBytes > 0 {
+		a.collect(time.Now())
+	}
+	return path, nil
+}
+
+// open returns the artifact at path; os.ErrNotExist once it was evicted.
+func (a *artifactStore) open(path string) (*os.File, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, 0, err
+	}
+	return f, info.Size(), nil
+}
+
+func (a *artifactStore) exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// remove deletes the artifact at path, if still there.
+func (a *artifactStore) remove(path string) {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		Warnf("artifact remove: %v", err)
+	}
+}
+
+// collect evicts expired artifacts, then the oldest ones until the directory
+// fits in maxBytes. It returns the number of artifacts removed.
+func (a *artifactStore) collect(now time.Time) int {
+	a.gcMu.Lock()
+	defer a.gcMu.Unlock()
+
+	type artifact struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	files, err := filepath.Glob(filepath.Join(a.dir, "*.pdf"))
+	if err != nil {
+		return 0
+	}
+	var (
+		artifacts []artifact
+		total     int64
+		removed   int
+	)
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		if a.ttl > 0 && now.Sub(info.ModTime()) > a.ttl {
+			a.remove(file)
+			removed++
+			continue
+		}
+		artifacts = append(artifacts, artifact{path: file, size: info.Size(), modTime: info.ModTime()})
+		total += info.Size()
+	}
+	if a.maxBytes > 0 && total > a.maxBytes {
+		sort.Slice(artifacts, func(i, j int) bool { return artifacts[i].modTime.Before(artifacts[j].modTime) })
+		for _, artifact := range artifacts {
+			if total <= a.maxBytes {
+				break
+			}
+			a.remove(artifact.path)
+			total -= artifact.size
+			removed++
+		}
+	}
+	return removed
+}
+
+// runCollector collects periodically until ctx is done.
+func (a *artifactStore) runCollector(ctx context.Context, interval time.Duration) {
+	if a == nil {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if removed := a.collect(now); removed > 0 {
+				Infof("artifacts: evicted %d", removed)
+			}
+		}
+	}
+}
+
+// copyArtifact streams the artifact at path to w.
+func copyArtifact(w io.Writer, f *os.File) {
+	defer func() { _ = f.Close() }()
+	if _, err := io.Copy(w, f); err != nil {
+		Warnf("artifact write error: %v", err)
+	}
+}
@@ -0,0 +1,276 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var (
+	linkTagRe   = regexp.MustCompile(`(?is)<link\b[^>]*>`)
+	imgSrcRe    = regexp.MustCompile(`(?is)(<img\b[^>]*?\bsrc\s*=\s*)("[^"]*"|'[^']*'|[^\s>]+)`)
+	styleBlkRe  = regexp.MustCompile(`(?is)(<style\b[^>]*>)(.*?)(</style\s*>)`)
+	styleAttrRe = regexp.MustCompile(`(?is)(\bstyle\s*=\s*)("[^"]*"|'[^']*')`)
+	cssURLRe    = regexp.MustCompile(`(?i)url\(\s*("[^"]*"|'[^']*'|[^)\s]+)\s*\)`)
+	tagAttrRe   = regexp.MustCompile(`(?is)\b([a-z-]+)\s*=\s*("[^"]*"|'[^']*'|[^\s>]+)`)
+)
+
+// hostAllowlist matches URLs against a list of host patterns. A pattern is
+// either an exact host ("cdn.example.com", optionally with ":port") or a
+// wildcard for subdomains ("*.example.com"). Only http and https are allowed.
+type hostAllowlist struct {
+	patterns []string
+}
+
+func newHostAllowlist(patterns []string) hostAllowlist {
+	list := hostAllowlist{}
+	for _, pattern := range patterns {
+		if pattern = strings.ToLower(strings.TrimSpace(pattern)); pattern != "" {
+			list.patterns = append(list.patterns, pattern)
+		}
+	}
+	return list
+}
+
+// checkRedirect is the CheckRedirect of clients bound to the list: every
+// redirect hop must stay on the allowed hosts, or an allowed host could
+// send the client to an internal address.
+func (l hostAllowlist) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxAssetRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxAssetRedirects)
+	}
+	if !l.allowed(req.URL) {
+		return fmt.Errorf("redirect to host %s not allowed", req.URL.Hostname())
+	}
+	return nil
+}
+
+func (l hostAllowlist) allowed(u *url.URL) bool {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+	host := strings.ToLower(u.Host)
+	hostname := strings.ToLower(u.Hostname())
+	for _, pattern := range l.patterns {
+		switch {
+		case strings.HasPrefix(pattern, "*."):
+			if strings.HasSuffix(hostname, pattern[1:]) {
+				return true
+			}
+		case pattern == host || pattern == hostname:
+			return true
+		}
+	}
+	return false
+}
+
+// assetInliner fetches stylesheets and images referenced by a document and
+// replaces the references with inline content (data URIs), so the render does
+// not depend on Chrome's network access.
+type assetInliner struct {
+	client   *http.Client
+	allow    hostAllowlist
+	maxBytes int64
+}
+
+func newAssetInliner(cfg config) *assetInliner {
+	allow := newHostAllowlist(cfg.AssetAllowedHosts)
+	return &assetInliner{
+		client:   &http.Client{Timeout: cfg.AssetFetchTimeout, CheckRedirect: allow.checkRedirect},
+		allow:    allow,
+		maxBytes: cfg.AssetMaxBytes,
+	}
+}
+
+// process implements the inline_assets pre-processing stage.
+func (a *assetInliner) process(ctx context.Context, input string, options pdfOptions) (string, error) {
+	var base *url.URL
+	if options.BaseURL != "" {
+		parsed, err := url.Parse(options.BaseURL)
+		if err != nil {
+			return "", fmt.Errorf("invalid base url: %w", err)
+		}
+		base = parsed
+	}
+
+	run := &inlineRun{inliner: a, ctx: ctx, cache: map[string]string{}}
+
+	out := linkTagRe.ReplaceAllStringFunc(input, func(tag string) string {
+		attrs := parseTagAttrs(tag)
+		if !strings.EqualFold(attrs["rel"], "stylesheet") || attrs["href"] == "" {
+			return tag
+		}
+		target := run.resolve(base, attrs["href"])
+		if target == nil {
+			return tag
+		}
+		css, _, ok := run.fetch(target)
+		if !ok {
+			return tag
+		}
+		media := ""
+		if attrs["media"] != "" {
+			media = fmt.Sprintf(` media="%s"`, html.EscapeString(attrs["media"]))
+		}
+		return fmt.Sprintf("<style%s>%s</style>", media, run.inlineCSS(target, string(css)))
+	})
+
+	out = styleBlkRe.ReplaceAllStringFunc(out, func(block string) string {
+		parts := styleBlkRe.FindStringSubmatch(block)
+		return parts[1] + run.inlineCSS(base, parts[2]) + parts[3]
+	})
+
+	out = styleAttrRe.ReplaceAllStringFunc(out, func(attr string) string {
+		parts := styleAttrRe.FindStringSubmatch(attr)
+		quote := parts[2][:1]
+		return parts[1] + quote + run.inlineCSS(base, parts[2][1:len(parts[2])-1]) + quote
+	})
+
+	out = imgSrcRe.ReplaceAllStringFunc(out, func(img string) string {
+		parts := imgSrcRe.FindStringSubmatch(img)
+		target := run.resolve(base, unquoteAttr(parts[2]))
+		if target == nil {
+			return img
+		}
+		if uri, ok := run.dataURI(target); ok {
+			return parts[1] + `"` + uri + `"`
+		}
+		return img
+	})
+
+	return out, nil
+}
+
+// inlineRun holds per-document state so every asset is fetched at most once.
+type inlineRun struct {
+	inliner *assetInliner
+	ctx     context.Context
+	cache   map[string]string
+}
+
+// resolve turns a reference into an absolute, allowed URL, or nil if the
+// reference must be left untouched.
+func (r *inlineRun) resolve(base *url.URL, ref string) *url.URL {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || strings.HasPrefix(strings.ToLower(ref), "data:") || strings.HasPrefix(ref, "#") {
+		return nil
+	}
+	parsed, err := url.Parse(ref)
+	if err != nil {
+		return nil
+	}
+	if base != nil {
+		parsed = base.ResolveReference(parsed)
+	}
+	if !parsed.IsAbs() {
+		return nil
+	}
+	if !r.inliner.allow.allowed(parsed) {
+		Debugf("inline assets: %s not in allowlist", parsed.Redacted())
+		return nil
+	}
+	return parsed
+}
+
+// fetch downloads target, enforcing the size limit.
+func (r *inlineRun) fetch(target *url.URL) ([]byte, string, bool) {
+	req, err := http.NewRequestWithContext(r.ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, "", false
+	}
+	resp, err := r.inliner.client.Do(req)
+	if err != nil {
+		Warnf("inline assets: fetch %s: %v", target.Redacted(), err)
+		return nil, "", false
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			Warnf("inline assets: body close error: %v", err)
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		Warnf("inline assets: fetch %s: %s", target.Redacted(), resp.Status)
+		return nil, "", false
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, r.inliner.maxBytes+1))
+	if err != nil {
+		Warnf("inline assets: read %s: %v", target.Redacted(), err)
+		return nil, "", false
+	}
+	if int64(len(data)) > r.inliner.maxBytes {
+		Warnf("inline assets: %s exceeds %d bytes", target.Redacted(), r.inliner.maxBytes)
+		return nil, "", false
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(target.Path))
+	}
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	// Keep only the media type: data URIs are emitted unquoted.
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		contentType = mediaType
+	}
+	return data, contentType, true
+}
+
+// dataURI fetches target and encodes it as a data URI.
+func (r *inlineRun) dataURI(target *url.URL) (string, bool) {
+	key := target.String()
+	if uri, ok := r.cache[key]; ok {
+		return uri, uri != ""
+	}
+	data, contentType, ok := r.fetch(target)
+	if !ok {
+		r.cache[key] = ""
+		return "", false
+	}
+	uri := "data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(data)
+	r.cache[key] = uri
+	return uri, true
+}
+
+// inlineCSS replaces url(...) references in css, resolved against base.
+func (r *inlineRun) inlineCSS(base *url.URL, css string) string {
+	return cssURLRe.ReplaceAllStringFunc(css, func(ref string) string {
+		parts := cssURLRe.FindStringSubmatch(ref)
+		target := r.resolve(base, unquoteAttr(parts[1]))
+		if target == nil {
+			return ref
+		}
+		if uri, ok := r.dataURI(target); ok {
+			return "url(" + uri + ")"
+		}
+		return ref
+	})
+}
+
+// parseTagAttrs extracts lower-cased attribute names and unquoted values.
+func parseTagAttrs(tag string) map[string]string {
+	attrs := map[string]string{}
+	for _, match := range tagAttrRe.FindAllStringSubmatch(tag, -1) {
+		attrs[strings.ToLower(match[1])] = unquoteAttr(match[2])
+	}
+	return attrs
+}
+
+func unquoteAttr(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
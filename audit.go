@@ -0,0 +1,144 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Outcomes of an audited render.
+const (
+	auditOutcomeSuccess = "success"
+	auditOutcomeFailure = "failure"
+)
+
+// auditRecord is the audit trail entry of one render: who asked for which
+// document, with what options, and what came of it. The HTML itself is only
+// recorded as a hash.
+type auditRecord struct {
+	Time       time.Time  `json:"time"`
+	RequestID  string     `json:"request_id,omitempty"`
+	Caller     string     `json:"caller,omitempty"`
+	KeyID      string     `json:"key_id,omitempty"`
+	Tenant     string     `json:"tenant,omitempty"`
+	HTMLSHA256 string     `json:"html_sha256,omitempty"`
+	Options    pdfOptions `json:"options"`
+	Outcome    string     `json:"outcome"`
+	Error      string     `json:"error,omitempty"`
+	DurationMS float64    `json:"duration_ms"`
+	Bytes      int        `json:"bytes"`
+}
+
+// auditLog writes audit records to AUDIT_LOG: appended as JSON lines to a
+// file, or posted as JSON to an http(s) URL. A nil log records nothing.
+type auditLog struct {
+	mu     sync.Mutex
+	file   *os.File
+	url    string
+	client *http.Client
+}
+
+// isAuditURL reports whether an AUDIT_LOG value is a URL rather than a file.
+func isAuditURL(target string) bool {
+	return strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://")
+}
+
+// newAuditLog returns nil when AUDIT_LOG is empty (auditing disabled).
+func newAuditLog(cfg config) (*auditLog, error) {
+	switch {
+	case cfg.AuditLog == "":
+		return nil, nil
+	case isAuditURL(cfg.AuditLog):
+		return &auditLog{url: cfg.AuditLog, client: &http.Client{Timeout: cfg.AuditTimeout}}, nil
+	}
+	file, err := os.OpenFile(cfg.AuditLog, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+	return &auditLog{file: file}, nil
+}
+
+// record writes record to the sink. Failures are logged: the render has
+// already happened and its result is still returned.
+func (a *auditLog) record(ctx context.Context, record auditRecord) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		Errorf("audit record error: %v", err)
+		return
+	}
+	if a.file != nil {
+		a.mu.Lock()
+		_, err = a.file.Write(append(data, '\n'))
+		a.mu.Unlock()
+	} else {
+		err = a.post(ctx, data)
+	}
+	if err != nil {
+		Errorf("audit log error: %v (request %s)", err, record.RequestID)
+	}
+}
+
+// post sends one record to the audit URL.
+func (a *auditLog) post(ctx context.Context, data []byte) error {
+	req, err := http.NewRequestWithContext(context.WithoutCancel(ctx), http.MethodPost, a.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("audit sink answered %s", resp.Status)
+	}
+	return nil
+}
+
+// auditRenderer records every render that goes through next, successful or
+// not, with the caller of its context.
+func auditRenderer(audit *auditLog, next pdfRenderer) pdfRenderer {
+	if audit == nil {
+		return next
+	}
+	return func(ctx context.Context, wsURL, html string, wait time.Duration, options pdfOptions) ([]byte, time.Duration, error) {
+		start := time.Now()
+		pdf, pdfTime, err := next(ctx, wsURL, html, wait, options)
+
+		record := auditRecord{
+			Time:       start.UTC(),
+			RequestID:  requestIDFromContext(ctx),
+			Caller:     jobOwner(ctx),
+			Options:    options,
+			Outcome:    auditOutcomeSuccess,
+			DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+			Bytes:      len(pdf),
+		}
+		// Email parts and branding logos are content, not options.
+		record.Options.EmailParts, record.Options.Branding = nil, nil
+		if html != "" {
+			sum := sha256.Sum256([]byte(html))
+			record.HTMLSHA256 = hex.EncodeToString(sum[:])
+		}
+		if policy, ok := policyFromContext(ctx); ok {
+			record.KeyID, record.Tenant = policy.ID, policy.Tenant
+		}
+		if err != nil {
+			record.Outcome, record.Error, record.Bytes = auditOutcomeFailure, err.Error(), 0
+		}
+		audit.record(ctx, record)
+		return pdf, pdfTime, err
+	}
+}
@@ -0,0 +1,468 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers used by the built-in authentication providers.
+const (
+	headerAuthKeyID     = "X-Auth-Key-ID"
+	headerAuthTimestamp = "X-Auth-Timestamp"
+	headerAuthSignature = "X-Auth-Signature"
+)
+
+// principal is the authenticated caller.
+type principal struct {
+	ID       string `json:"id"`
+	Provider string `json:"provider"`
+}
+
+// authProvider authenticates API requests. Implementations return an
+// *authError to choose the status the client gets (401 by default).
+type authProvider interface {
+	authenticate(r *http.Request) (principal, error)
+}
+
+// authProviders maps AUTH_PROVIDER values to provider constructors. Adding a
+// provider only takes registering it here.
+var authProviders = map[string]func(cfg config) (authProvider, error){
+	"static":  newStaticKeyAuth,
+	"jwt":     newJWTAuth,
+	"hmac":    newHMACAuth,
+	"webhook": newWebhookAuth,
+}
+
+// authError is an authentication failure with the HTTP status to answer.
+type authError struct {
+	status int
+	msg    string
+}
+
+func (e *authError) Error() string {
+	return e.msg
+}
+
+func unauthorized(msg string) error {
+	return &authError{status: http.StatusUnauthorized, msg: msg}
+}
+
+type principalContextKey struct{}
+
+// newAuthProvider returns the provider selected by AUTH_PROVIDER, or nil when
+// authentication is disabled.
+func newAuthProvider(cfg config) (authProvider, error) {
+	if cfg.AuthProvider == "" || cfg.AuthProvider == "none" {
+		return nil, nil
+	}
+	constructor, ok := authProviders[cfg.AuthProvider]
+	if !ok {
+		names := make([]string, 0, len(authProviders))
+		for name := range authProviders {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown AUTH_PROVIDER %q (want none, %s)", cfg.AuthProvider, strings.Join(names, ", "))
+	}
+	return constructor(cfg)
+}
+
+// authMiddleware authenticates every request but the probes, the admin
+// endpoints (which have their own token) and the signed downloads and render
+// links, and attaches the principal to the request context. A nil provider
+// lets everything through.
+func authMiddleware(provider authProvider, next http.Handler) http.Handler {
+	if provider == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == pathHealthz, r.URL.Path == pathLivez, r.URL.Path == pathReadyz, r.URL.Path == pathLoadz,
+			strings.HasPrefix(r.URL.Path, "/admin/"), strings.HasPrefix(r.URL.Path, pathDownloads+"/"),
+			strings.HasPrefix(r.URL.Path, pathRenderLinks+"/"):
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		p, err := provider.authenticate(r)
+		if err != nil {
+			var authErr *authError
+			if !errors.As(err, &authErr) {
+				authErr = &authError{status: http.StatusUnauthorized, msg: "unauthorized"}
+			}
+			Warnf("auth rejected %s %s: %v", r.Method, r.URL.Path, err)
+			if authErr.status == http.StatusUnauthorized {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="pdfrest"`)
+			}
+			http.Error(w, authErr.msg, authErr.status)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalContextKey{}, p)))
+	})
+}
+
+// principalFromContext returns the principal attached by authMiddleware.
+func principalFromContext(ctx context.Context) (principal, bool) {
+	p, ok := ctx.Value(principalContextKey{}).(principal)
+	return p, ok
+}
+
+// parseKeyList parses "id=secret" pairs.
+func parseKeyList(env string, list []string) (map[string]string, error) {
+	keys := map[string]string{}
+	for _, item := range list {
+		id, secret, ok := strings.Cut(item, "=")
+		if !ok || id == "" || secret == "" {
+			return nil, fmt.Errorf("%s: want id=secret pairs", env)
+		}
+		keys[id] = secret
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%s is empty", env)
+	}
+	return keys, nil
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header.
+func bearerToken(r *http.Request) string {
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return strings.TrimSpace(token)
+}
+
+// staticKeyAuth accepts a fixed set of API keys (AUTH_STATIC_KEYS), presented
+// in X-API-Key or as a bearer token.
+type staticKeyAuth struct {
+	keys map[string]string // id -> key
+}
+
+func newStaticKeyAuth(cfg config) (authProvider, error) {
+	keys, err := parseKeyList("AUTH_STATIC_KEYS", cfg.AuthStaticKeys)
+	if err != nil {
+		return nil, err
+	}
+	return &staticKeyAuth{keys: keys}, nil
+}
+
+func (a *staticKeyAuth) authenticate(r *http.Request) (principal, error) {
+	presented := r.Header.Get(headerAPIKey)
+	if presented == "" {
+		presented = bearerToken(r)
+	}
+	if presented == "" {
+		return principal{}, unauthorized("missing api key")
+	}
+	// Compare against every key so timing does not reveal which one matched.
+	matched := ""
+	for id, key := range a.keys {
+		if subtle.ConstantTimeCompare([]byte(presented), []byte(key)) == 1 {
+			matched = id
+		}
+	}
+	if matched == "" {
+		return principal{}, unauthorized("invalid api key")
+	}
+	return principal{ID: matched, Provider: "static"}, nil
+}
+
+// jwtAuth accepts bearer JWTs signed with HS256 (AUTH_JWT_SECRET) or with
+// RS256/ES256 (AUTH_JWT_PUBLIC_KEY_FILE), optionally checking the issuer and
+// audience. The principal is the "sub" claim.
+type jwtAuth struct {
+	secret    []byte
+	publicKey crypto.PublicKey
+	issuer    string
+	audience  string
+	now       func() time.Time
+}
+
+// jwtLeeway tolerates clock skew when checking exp/nbf.
+const jwtLeeway = time.Minute
+
+func newJWTAuth(cfg config) (authProvider, error) {
+	a := &jwtAuth{secret: []byte(cfg.AuthJWTSecret), issuer: cfg.AuthJWTIssuer, audience: cfg.AuthJWTAudience, now: time.Now}
+	if cfg.AuthJWTPublicKeyFile != "" {
+		data, err := os.ReadFile(cfg.AuthJWTPublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read AUTH_JWT_PUBLIC_KEY_FILE: %w", err)
+		}
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, errors.New("AUTH_JWT_PUBLIC_KEY_FILE: no PEM block")
+		}
+		if a.publicKey, err = x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+			return nil, fmt.Errorf("AUTH_JWT_PUBLIC_KEY_FILE: %w", err)
+		}
+	}
+	if len(a.secret) == 0 && a.publicKey == nil {
+		return nil, errors.New("jwt auth needs AUTH_JWT_SECRET or AUTH_JWT_PUBLIC_KEY_FILE")
+	}
+	return a, nil
+}
+
+func (a *jwtAuth) authenticate(r *http.Request) (principal, error) {
+	token := bearerToken(r)
+	if token == "" {
+		return principal{}, unauthorized("missing bearer token")
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return principal{}, unauthorized("malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return principal{}, unauthorized("malformed token")
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return principal{}, unauthorized("malformed token")
+	}
+	if !a.verify(header.Alg, parts[0]+"."+parts[1], sig) {
+		return principal{}, unauthorized("invalid token signature")
+	}
+
+	var claims struct {
+		Sub string          `json:"sub"`
+		Iss string          `json:"iss"`
+		Aud json.RawMessage `json:"aud"`
+		Exp *float64        `json:"exp"`
+		Nbf *float64        `json:"nbf"`
+	}
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return principal{}, unauthorized("malformed token claims")
+	}
+	now := a.now()
+	if claims.Exp != nil && now.After(time.Unix(int64(*claims.Exp), 0).Add(jwtLeeway)) {
+		return principal{}, unauthorized("token expired")
+	}
+	if claims.Nbf != nil && now.Add(jwtLeeway).Before(time.Unix(int64(*claims.Nbf), 0)) {
+		return principal{}, unauthorized("token not yet valid")
+	}
+	if a.issuer != "" && claims.Iss != a.issuer {
+		return principal{}, unauthorized("unexpected token issuer")
+	}
+	if a.audience != "" && !jwtHasAudience(claims.Aud, a.audience) {
+		return principal{}, unauthorized("unexpected token audience")
+	}
+	if claims.Sub == "" {
+		return principal{}, unauthorized("token has no subject")
+	}
+	return principal{ID: claims.Sub, Provider: "jwt"}, nil
+}
+
+// verify checks sig over signed with the key matching alg. "none" and
+// algorithms without a configured key are rejected.
+func (a *jwtAuth) verify(alg, signed string, sig []byte) bool {
+	digest := sha256.Sum256([]byte(signed))
+	switch alg {
+	case "HS256":
+		if len(a.secret) == 0 {
+			return false
+		}
+		mac := hmac.New(sha256.New, a.secret)
+		mac.Write([]byte(signed))
+		return hmac.Equal(mac.Sum(nil), sig)
+	case "RS256":
+		key, ok := a.publicKey.(*rsa.PublicKey)
+		return ok && rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) == nil
+	case "ES256":
+		key, ok := a.publicKey.(*ecdsa.PublicKey)
+		if !ok || len(sig) != 64 {
+			return false
+		}
+		return ecdsa.Verify(key, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:]))
+	default:
+		return false
+	}
+}
+
+func decodeJWTPart(part string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// jwtHasAudience reports whether the aud claim (string or list) contains want.
+func jwtHasAudience(aud json.RawMessage, want string) bool {
+	var single string
+	if json.Unmarshal(aud, &single) == nil {
+		return single == want
+	}
+	var list []string
+	if json.Unmarshal(aud, &list) == nil {
+		for _, item := range list {
+			if item == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hmacAuth accepts requests signed with a shared secret (AUTH_HMAC_KEYS). The
+// client sends X-Auth-Key-ID, X-Auth-Timestamp (unix seconds) and
+// X-Auth-Signature, the hex HMAC-SHA256 of
+//
+//	<timestamp>\n<method>\n<request URI>\n<hex sha256 of the body>
+//
+// Timestamps older or newer than AUTH_HMAC_MAX_SKEW are rejected.
+type hmacAuth struct {
+	keys     map[string]string
+	maxSkew  time.Duration
+	maxBytes int64
+	now      func() time.Time
+}
+
+func newHMACAuth(cfg config) (authProvider, error) {
+	keys, err := parseKeyList("AUTH_HMAC_KEYS", cfg.AuthHMACKeys)
+	if err != nil {
+		return nil, err
+	}
+	return &hmacAuth{keys: keys, maxSkew: cfg.AuthHMACMaxSkew, maxBytes: cfg.MaxBodyBytes, now: time.Now}, nil
+}
+
+func (a *hmacAuth) authenticate(r *http.Request) (principal, error) {
+	id := r.Header.Get(headerAuthKeyID)
+	secret, ok := a.keys[id]
+	if id == "" || !ok {
+		return principal{}, unauthorized("unknown key id")
+	}
+	ts, err := strconv.ParseInt(r.Header.Get(headerAuthTimestamp), 10, 64)
+	if err != nil {
+		return principal{}, unauthorized("invalid timestamp")
+	}
+	if skew := a.now().Sub(time.Unix(ts, 0)); skew > a.maxSkew || skew < -a.maxSkew {
+		return principal{}, unauthorized("timestamp outside the allowed window")
+	}
+	sig, err := hex.DecodeString(r.Header.Get(headerAuthSignature))
+	if err != nil {
+		return principal{}, unauthorized("invalid signature")
+	}
+
+	// Hash the body and hand an identical copy to the handler. Bodies over
+	// MAX_BODY_BYTES are cut one byte past the limit, so the handler still
+	// rejects them.
+	var body []byte
+	if r.Body != nil {
+		body, err = io.ReadAll(io.LimitReader(r.Body, a.maxBytes+1))
+		if err != nil {
+			return principal{}, &authError{status: http.StatusBadRequest, msg: "invalid request body"}
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	bodyHash := sha256.Sum256(body)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d\n%s\n%s\n%s", ts, r.Method, r.URL.RequestURI(), hex.EncodeToString(bodyHash[:]))
+	if !hmac.Equal(mac.Sum(nil), sig) {
+		return principal{}, unauthorized("invalid signature")
+	}
+	return principal{ID: id, Provider: "hmac"}, nil
+}
+
+// webhookAuth delegates the decision to an external authorizer
+// (AUTH_WEBHOOK_URL). It POSTs the request's method, path, remote address and
+// credential headers as JSON; a 2xx answer allows the request (optionally
+// naming the principal as {"principal": "..."}), 401/403 are passed on, and
+// anything else, including timeouts, is answered with 503.
+type webhookAuth struct {
+	url    string
+	client *http.Client
+}
+
+// webhookAuthRequest is the body sent to the authorizer.
+type webhookAuthRequest struct {
+	Method     string            `json:"method"`
+	Path       string            `json:"path"`
+	Query      string            `json:"query,omitempty"`
+	RemoteAddr string            `json:"remote_addr"`
+	Headers    map[string]string `json:"headers"`
+}
+
+// webhookAuthHeaders are forwarded to the authorizer when present.
+var webhookAuthHeaders = []string{"Authorization", headerAPIKey, headerRequestID, "X-Forwarded-For"}
+
+func newWebhookAuth(cfg config) (authProvider, error) {
+	if cfg.AuthWebhookURL == "" {
+		return nil, errors.New("webhook auth needs AUTH_WEBHOOK_URL")
+	}
+	return &webhookAuth{url: cfg.AuthWebhookURL, client: &http.Client{Timeout: cfg.AuthWebhookTimeout}}, nil
+}
+
+func (a *webhookAuth) authenticate(r *http.Request) (principal, error) {
+	payload := webhookAuthRequest{
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		Query:      r.URL.RawQuery,
+		RemoteAddr: r.RemoteAddr,
+		Headers:    map[string]string{},
+	}
+	for _, name := range webhookAuthHeaders {
+		if value := r.Header.Get(name); value != "" {
+			payload.Headers[name] = value
+		}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return principal{}, err
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodPost, a.url, bytes.NewReader(body))
+	if err != nil {
+		return principal{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := a.client.Do(req)
+	if err != nil {
+		Errorf("auth webhook error: %v", err)
+		return principal{}, &authError{status: http.StatusServiceUnavailable, msg: "authorization service unavailable"}
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			Warnf("auth webhook body close error: %v", err)
+		}
+	}()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return principal{}, unauthorized("unauthorized")
+	case resp.StatusCode == http.StatusForbidden:
+		return principal{}, &authError{status: http.StatusForbidden, msg: "forbidden"}
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		Errorf("auth webhook answered %s", resp.Status)
+		return principal{}, &authError{status: http.StatusServiceUnavailable, msg: "authorization service unavailable"}
+	}
+
+	var decision struct {
+		Principal string `json:"principal"`
+	}
+	_ = json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&decision)
+	return principal{ID: decision.Principal, Provider: "webhook"}, nil
+}
@@ -0,0 +1,172 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Render modes reported to the policy engine.
+const (
+	renderModeHTML     = "html"
+	renderModeTemplate = "template"
+	renderModeURL      = "url"
+)
+
+// authzInput describes a render request to the policy engine: who is asking
+// (principal, key policy, tenant) and what they ask for.
+type authzInput struct {
+	Method       string   `json:"method"`
+	Path         string   `json:"path"`
+	Principal    string   `json:"principal,omitempty"`
+	AuthProvider string   `json:"auth_provider,omitempty"`
+	KeyID        string   `json:"key_id,omitempty"`
+	Tenant       string   `json:"tenant,omitempty"`
+	Mode         string   `json:"mode"`
+	URL          string   `json:"url,omitempty"`
+	Template     string   `json:"template,omitempty"`
+	PreProcess   []string `json:"pre_process"`
+	PostProcess  []string `json:"post_process"`
+	PDFA         string   `json:"pdfa,omitempty"`
+	Watermark    bool     `json:"watermark"`
+	TraceNetwork bool     `json:"trace_network"`
+	Batch        bool     `json:"batch"`
+}
+
+// authzDecision is the policy engine's answer.
+type authzDecision struct {
+	Allow  bool   `json:"allow"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// requestAuthorizer decides whether a render request may proceed.
+type requestAuthorizer interface {
+	authorize(ctx context.Context, input authzInput) (authzDecision, error)
+}
+
+// opaAuthorizer asks an Open Policy Agent server through its data API
+// (OPA_URL, e.g. http://opa:8181/v1/data/pdfrest/allow). The rule may
+// evaluate to a boolean or to an object {"allow": bool, "reason": string}; an
+// undefined result denies.
+type opaAuthorizer struct {
+	url    string
+	client *http.Client
+}
+
+func newOPAAuthorizer(cfg config) *opaAuthorizer {
+	if cfg.OPAURL == "" {
+		return nil
+	}
+	return &opaAuthorizer{url: cfg.OPAURL, client: &http.Client{Timeout: cfg.OPATimeout}}
+}
+
+func (a *opaAuthorizer) authorize(ctx context.Context, input authzInput) (authzDecision, error) {
+	body, err := json.Marshal(map[string]authzInput{"input": input})
+	if err != nil {
+		return authzDecision{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, bytes.NewReader(body))
+	if err != nil {
+		return authzDecision{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return authzDecision{}, err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			Warnf("opa body close error: %v", err)
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return authzDecision{}, fmt.Errorf("opa answered %s", resp.Status)
+	}
+
+	var answer struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&answer); err != nil {
+		return authzDecision{}, fmt.Errorf("decode opa answer: %w", err)
+	}
+	if len(answer.Result) == 0 {
+		return authzDecision{Reason: "policy undefined"}, nil
+	}
+	var allow bool
+	if err := json.Unmarshal(answer.Result, &allow); err == nil {
+		return authzDecision{Allow: allow}, nil
+	}
+	var decision authzDecision
+	if err := json.Unmarshal(answer.Result, &decision); err != nil {
+		return authzDecision{}, fmt.Errorf("unexpected opa result: %s", answer.Result)
+	}
+	return decision, nil
+}
+
+// authorize asks the policy engine about a render. It returns an *authError
+// (403 when denied, 503 when the engine cannot be reached and OPA_FAIL_OPEN
+// is off), or nil to proceed.
+func (s *pdfService) authorize(r *http.Request, input authzInput, options pdfOptions) error {
+	if s.authz == nil {
+		return nil
+	}
+	input.Method, input.Path = r.Method, r.URL.Path
+	if p, ok := principalFromContext(r.Context()); ok {
+		input.Principal, input.AuthProvider = p.ID, p.Provider
+	}
+	if policy, ok := policyFromContext(r.Context()); ok {
+		input.KeyID, input.Tenant = policy.ID, policy.Tenant
+	}
+	input.PreProcess, input.PostProcess = nonNil(options.PreProcess), nonNil(options.PostProcess)
+	input.PDFA = options.PDFA
+	input.Watermark = options.Watermark != nil
+	input.TraceNetwork = options.TraceNetwork
+	if options.URL != "" {
+		input.Mode, input.URL = renderModeURL, options.URL
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.cfg.OPATimeout)
+	defer cancel()
+	decision, err := s.authz.authorize(ctx, input)
+	if err != nil {
+		if s.cfg.OPAFailOpen {
+			Warnf("authz unavailable, allowing (OPA_FAIL_OPEN): %v", err)
+			return nil
+		}
+		Errorf("authz error: %v", err)
+		return &authError{status: http.StatusServiceUnavailable, msg: "authorization service unavailable"}
+	}
+	if !decision.Allow {
+		msg := "forbidden"
+		if decision.Reason != "" {
+			msg = "forbidden: " + decision.Reason
+		}
+		Warnf("authz denied %s mode=%s key=%s: %s", input.Path, input.Mode, input.KeyID, decision.Reason)
+		return &authError{status: http.StatusForbidden, msg: msg}
+	}
+	return nil
+}
+
+// writeAuthzError answers with the status of an *authError, or 500.
+func writeAuthzError(w http.ResponseWriter, err error) {
+	var authErr *authError
+	if errors.As(err, &authErr) {
+		http.Error(w, authErr.msg, authErr.status)
+		return
+	}
+	http.Error(w, "authorization failed", http.StatusInternalServerError)
+}
+
+func nonNil(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
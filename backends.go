@@ -0,0 +1,203 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// backendChrome is the built-in backend: Chrome through CDP.
+const backendChrome = "chrome"
+
+// Environment variables configuring the render backends:
+// RENDER_BACKEND_<NAME>_CMD defines a command backend.
+const (
+	backendCmdPrefix = "RENDER_BACKEND_"
+	backendCmdSuffix = "_CMD"
+)
+
+// backendError reports a render the selected backend cannot do. Status is the
+// HTTP status the handler should answer with.
+type backendError struct {
+	status int
+	err    error
+}
+
+func (e *backendError) Error() string {
+	return e.err.Error()
+}
+
+func (e *backendError) Unwrap() error {
+	return e.err
+}
+
+// loadBackendCommands reads the RENDER_BACKEND_<NAME>_CMD variables of
+// environ (KEY=value pairs), keyed by lowercased name.
+func loadBackendCommands(environ []string) map[string]string {
+	commands := map[string]string{}
+	for _, entry := range environ {
+		key, value, _ := strings.Cut(entry, "=")
+		if !strings.HasPrefix(key, backendCmdPrefix) || !strings.HasSuffix(key, backendCmdSuffix) || value == "" {
+			continue
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(key, backendCmdPrefix), backendCmdSuffix)
+		if name != "" {
+			commands[strings.ToLower(name)] = value
+		}
+	}
+	return commands
+}
+
+// chromeOnlyOption returns the first option of options only the Chrome
+// backend implements, or "".
+func chromeOnlyOption(options pdfOptions) string {
+	checks := []struct {
+		name string
+		set  bool
+	}{
+		{"url", options.URL != ""},
+		{"scale", options.Scale != nil},
+		{"page_ranges", options.PageRanges != ""},
+		{"trace_network", options.TraceNetwork},
+		{"inject_css", options.InjectCSS != ""},
+		{"inject_js", options.InjectJS != ""},
+		{"freeze_time", options.FreezeTime != nil},
+		{"random_seed", options.RandomSeed != nil},
+		{"geolocation", options.Geolocation != nil},
+		{"emulate_media", options.EmulateMedia != ""},
+		{"color_scheme", options.ColorScheme != ""},
+		{"reduced_motion", options.ReducedMotion != ""},
+		{"user_agent", options.UserAgent != ""},
+		{"block_remote", options.BlockRemote},
+		{"fail_on_js_error", options.FailOnJSError},
+		{"strict_assets", options.StrictAssets},
+		{"lazy_load", options.LazyLoad},
+		{"running_elements", options.RunningElements},
+		{"image", options.Image != nil},
+	}
+	for _, check := range checks {
+		if check.set {
+			return check.name
+		}
+	}
+	return ""
+}
+
+// commandBackend renders with an external HTML-to-PDF command, such as
+// wkhtmltopdf or WeasyPrint. The HTML goes in through {in} (a .html file) or
+// stdin and the PDF comes out through {out} or stdout, as for
+// POST_PROCESS_<STAGE>_CMD. {paper_width}, {paper_height}, {margin_top},
+// {margin_bottom}, {margin_left} and {margin_right} are replaced with lengths
+// in inches ("8.5in"), {orientation} with portrait or landscape and
+// {print_background} with true or false.
+func commandBackend(command string, maxPDFBytes int64) pdfRenderer {
+	return func(ctx context.Context, _, html string, _ time.Duration, options pdfOptions) ([]byte, time.Duration, error) {
+		if options.CSS != "" {
+			html = insertIntoHead(html, "<style>"+options.CSS+"</style>")
+		}
+		start := time.Now()
+		pdf, err := runCommand(ctx, command, []byte(html), "in.html", backendPlaceholders(options))
+		elapsed := time.Since(start)
+		if err != nil {
+			return nil, elapsed, err
+		}
+		if err := checkPDFLimits(pdf, maxPDFBytes, options.MaxPages); err != nil {
+			return nil, elapsed, err
+		}
+		return pdf, elapsed, nil
+	}
+}
+
+// backendPlaceholders returns the page layout of options as command line
+// placeholders, with Chrome's defaults for what the request leaves out.
+func backendPlaceholders(options pdfOptions) map[string]string {
+	length := func(value *float64, fallback float64) string {
+		if value != nil {
+			fallback = *value
+		}
+		return strconv.FormatFloat(fallback, 'f', -1, 64) + "in"
+	}
+	orientation := "portrait"
+	if options.Landscape != nil && *options.Landscape {
+		orientation = "landscape"
+	}
+	printBackground := true
+	if options.PrintBackground != nil {
+		printBackground = *options.PrintBackground
+	}
+	return map[string]string{
+		"{paper_width}":      length(options.PaperWidth, defaultPaperWidth),
+		"{paper_height}":     length(options.PaperHeight, defaultPaperHeight),
+		"{margin_top}":       length(options.MarginTop, defaultMargin),
+		"{margin_bottom}":    length(options.MarginBottom, defaultMargin),
+		"{margin_left}":      length(options.MarginLeft, defaultMargin),
+		"{margin_right}":     length(options.MarginRight, defaultMargin),
+		"{orientation}":      orientation,
+		"{print_background}": strconv.FormatBool(printBackground),
+	}
+}
+
+// backendRenderer sends each render to the backend the request selects
+// (options.Backend), else to the default one (RENDER_BACKEND). chrome renders
+// through next. A render meant for Chrome while Chrome is unavailable (an
+// empty wsURL, see fallbackResolver) goes to the fallback backend when one is
+// configured and the request uses no Chrome-only option.
+func backendRenderer(backends map[string]pdfRenderer, defaultBackend, fallback string, next pdfRenderer) pdfRenderer {
+	return func(ctx context.Context, wsURL, html string, wait time.Duration, options pdfOptions) ([]byte, time.Duration, error) {
+		name := options.Backend
+		if name == "" {
+			name = defaultBackend
+		}
+		if name == "" || name == backendChrome {
+			if wsURL != "" {
+				return next(ctx, wsURL, html, wait, options)
+			}
+			if fallback == "" || chromeOnlyOption(options) != "" {
+				return nil, 0, &backendError{status: http.StatusServiceUnavailable, err: errors.New("chrome unavailable")}
+			}
+			Warnf("chrome unavailable, rendering with the %s backend", fallback)
+			name = fallback
+		}
+		backend, ok := backends[name]
+		if !ok {
+			return nil, 0, &backendError{status: http.StatusBadRequest, err: fmt.Errorf("unknown backend %q", name)}
+		}
+		if option := chromeOnlyOption(options); option != "" {
+			return nil, 0, &backendError{status: http.StatusBadRequest, err: fmt.Errorf("%s requires the chrome backend", option)}
+		}
+		return backend(ctx, wsURL, html, wait, options)
+	}
+}
+
+// newBackendRenderer returns chrome behind backendRenderer, with the command
+// backends of cfg.
+func newBackendRenderer(cfg config, chrome pdfRenderer) pdfRenderer {
+	commands := map[string]pdfRenderer{}
+	for name, command := range cfg.BackendCommands {
+		commands[name] = commandBackend(command, cfg.MaxPDFBytes)
+	}
+	return backendRenderer(commands, cfg.RenderBackend, cfg.RenderFallbackBackend, chrome)
+}
+
+// fallbackResolver answers an empty websocket URL instead of an error when
+// Chrome is unavailable, so renders reach backendRenderer, which sends them
+// to another backend or fails them.
+type fallbackResolver struct {
+	wsResolver
+}
+
+func (r fallbackResolver) wsURL(ctx context.Context) (string, error) {
+	ws, err := r.wsResolver.wsURL(ctx)
+	if err != nil {
+		Warnf("chrome ws error: %v", err)
+		return "", nil
+	}
+	return ws, nil
+}
@@ -0,0 +1,313 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const batchManifestName = "manifest.json"
+
+// batchRequest is the body of POST /api/v1/pdf/batch.
+type batchRequest struct {
+	Items []batchItem `json:"items"`
+}
+
+// batchItem is one document of a batch: inline HTML, a URL or a template
+// (inline or stored) with data. Options use the same names and values as the
+// /api/v1/pdf query parameters and override the batch request's query.
+type batchItem struct {
+	Name string `json:"name,omitempty"`
+	HTML string `json:"html,omitempty"`
+	URL  string `json:"url,omitempty"`
+	templateRequest
+	Options map[string]string `json:"options,omitempty"`
+
+	// Headers and Auth are sent when navigating to URL.
+	Headers map[string]string `json:"headers,omitempty"`
+	Auth    *basicAuth        `json:"auth,omitempty"`
+}
+
+// batchEntry is the manifest record of one rendered (or failed) item.
+type batchEntry struct {
+	Name       string  `json:"name"`
+	Status     int     `json:"status"`
+	Error      string  `json:"error,omitempty"`
+	Bytes      int     `json:"bytes,omitempty"`
+	Pages      int     `json:"pages,omitempty"`
+	DurationMS float64 `json:"duration_ms"`
+
+	html    string
+	options pdfOptions
+	pdf     []byte
+}
+
+// serveBatch renders every item of a batch concurrently (bounded by
+// BATCH_CONCURRENCY and the global render limiter) and returns a ZIP archive
+// with one PDF per item plus a manifest.json describing each result.
+func (s *pdfService) serveBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	r = withFreshDiscovery(r)
+
+	// A batch may legitimately take longer than a single render.
+	ctx, cancel := context.WithTimeout(r.Context(), s.cfg.BatchTimeout)
+	defer cancel()
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(s.cfg.BatchTimeout + 5*time.Second)); err != nil {
+		Debugf("batch: cannot extend write deadline: %v", err)
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, s.cfg.MaxBodyBytes)
+	defer func() {
+		if err := r.Body.Close(); err != nil {
+			Warnf("request body close error: %v", err)
+		}
+	}()
+
+	body, release, err := readRequestBody(r.Body)
+	if err != nil {
+		http.Error(w, "invalid request body", mapBodyReadErrorToStatus(err))
+		return
+	}
+	var req batchRequest
+	err = json.Unmarshal(body, &req)
+	release()
+	if err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Items) == 0 {
+		http.Error(w, "empty batch", http.StatusBadRequest)
+		return
+	}
+	if s.cfg.BatchMaxItems > 0 && len(req.Items) > s.cfg.BatchMaxItems {
+		http.Error(w, fmt.Sprintf("too many items (max %d)", s.cfg.BatchMaxItems), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	// Validate every item before rendering anything.
+	entries, err := s.prepareBatch(r, req.Items)
+	if err != nil {
+		var authErr *authError
+		if errors.As(err, &authErr) {
+			http.Error(w, authErr.msg, authErr.status)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	wsURL, err := s.resolver.wsURL(ctx)
+	if err != nil {
+		Errorf("chrome ws error: %v", err)
+		http.Error(w, "chrome unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	s.renderBatch(ctx, wsURL, entries)
+
+	failed := 0
+	for _, entry := range entries {
+		if entry.Error != "" {
+			failed++
+		}
+	}
+	Infof("batch: %d items, %d failed", len(entries), failed)
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="batch.zip"`)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Batch-Failed", strconv.Itoa(failed))
+	w.WriteHeader(http.StatusOK)
+
+	if err := writeBatchZip(w, entries); err != nil {
+		Warnf("batch: zip write error: %v", err)
+	}
+}
+
+// prepareBatch turns the request items into entries with resolved HTML,
+// options and unique file names.
+func (s *pdfService) prepareBatch(r *http.Request, items []batchItem) ([]*batchEntry, error) {
+	allowedURLs := newHostAllowlist(s.cfg.URLAllowedHosts)
+	names := map[string]bool{batchManifestName: true}
+	entries := make([]*batchEntry, 0, len(items))
+
+	for i, item := range items {
+		entry := &batchEntry{Name: uniqueBatchName(names, item.Name, i)}
+
+		values := url.Values{}
+		for key, list := range r.URL.Query() {
+			values[key] = list
+		}
+		for key, value := range item.Options {
+			values.Set(key, value)
+		}
+		values, err := s.optionValues(values, s.cfg.DefaultOptions)
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %v", i, err)
+		}
+		options, err := parsePDFOptions(values)
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %v", i, err)
+		}
+		if options.Output != "" || len(options.Deliver) > 0 {
+			return nil, fmt.Errorf("item %d: output and deliver are not supported in batches", i)
+		}
+		applyPolicyDefaults(r.Context(), s.cfg, &options)
+		// Batches are background work: they queue behind interactive
+		// renders unless they ask otherwise.
+		if options.Priority, err = requestPriority(r, options.Priority, priorityLow); err != nil {
+			return nil, fmt.Errorf("item %d: %v", i, err)
+		}
+
+		sources := 0
+		for _, set := range []bool{item.HTML != "", item.URL != "", item.Template != "" || item.TemplateName != ""} {
+			if set {
+				sources++
+			}
+		}
+		if sources != 1 {
+			return nil, fmt.Errorf("item %d: exactly one of html, url, template or template_name is required", i)
+		}
+
+		switch {
+		case item.URL != "":
+			parsed, err := url.Parse(item.URL)
+			if err != nil || !allowedURLs.allowed(parsed) {
+				return nil, fmt.Errorf("item %d: url not allowed", i)
+			}
+			if err := validateNavigationHeaders(item.Headers); err != nil {
+				return nil, fmt.Errorf("item %d: %v", i, err)
+			}
+			options.URL, options.Headers, options.Auth = parsed.String(), item.Headers, item.Auth
+		case len(item.Headers) > 0 || item.Auth != nil:
+			return nil, fmt.Errorf("item %d: headers and auth require a url", i)
+		case item.HTML != "":
+			entry.html = item.HTML
+		default:
+			html, _, err := renderTemplate(s.templates, item.templateRequest, options.Branding)
+			if err != nil {
+				return nil, fmt.Errorf("item %d: %v", i, err)
+			}
+			entry.html = html
+		}
+
+		input := authzInput{Mode: renderModeHTML, Template: item.TemplateName, Batch: true}
+		if item.Template != "" || item.TemplateName != "" {
+			input.Mode = renderModeTemplate
+		}
+		if err := s.authorize(r, input, options); err != nil {
+			var authErr *authError
+			if errors.As(err, &authErr) {
+				authErr.msg = fmt.Sprintf("item %d: %s", i, authErr.msg)
+			}
+			return nil, err
+		}
+
+		entry.options = options
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// renderBatch renders the entries with at most BATCH_CONCURRENCY in flight.
+func (s *pdfService) renderBatch(ctx context.Context, wsURL string, entries []*batchEntry) {
+	concurrency := s.cfg.BatchConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	slots := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+	for _, entry := range entries {
+		wg.Add(1)
+		go func(entry *batchEntry) {
+			defer wg.Done()
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				entry.Status, entry.Error = http.StatusServiceUnavailable, "batch timed out"
+				return
+			}
+			defer func() { <-slots }()
+
+			itemCtx, cancel := context.WithTimeout(ctx, s.cfg.RequestTimeout)
+			defer cancel()
+
+			start := time.Now()
+			pdf, _, err := s.renderer(itemCtx, wsURL, entry.html, s.cfg.PDFWait, entry.options)
+			entry.DurationMS = float64(time.Since(start).Microseconds()) / 1000
+			if err != nil {
+				entry.Status, entry.Error = renderErrorStatus(err)
+				return
+			}
+			entry.Status, entry.pdf, entry.Bytes = http.StatusOK, pdf, len(pdf)
+			entry.Pages = countPDFPages(pdf)
+		}(entry)
+	}
+	wg.Wait()
+}
+
+// writeBatchZip writes the rendered PDFs and the manifest as a ZIP archive.
+// PDFs are already compressed, so entries are stored rather than deflated.
+func writeBatchZip(w http.ResponseWriter, entries []*batchEntry) error {
+	archive := zip.NewWriter(w)
+	now := time.Now()
+
+	for _, entry := range entries {
+		if entry.pdf == nil {
+			continue
+		}
+		file, err := archive.CreateHeader(&zip.FileHeader{Name: entry.Name, Method: zip.Store, Modified: now})
+		if err != nil {
+			return err
+		}
+		if _, err := file.Write(entry.pdf); err != nil {
+			return err
+		}
+	}
+
+	manifest, err := archive.CreateHeader(&zip.FileHeader{Name: batchManifestName, Method: zip.Deflate, Modified: now})
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(manifest)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(map[string]any{"items": entries}); err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+// uniqueBatchName returns a safe, unique "*.pdf" file name for item i.
+func uniqueBatchName(used map[string]bool, name string, i int) string {
+	name = path.Base(strings.ReplaceAll(strings.TrimSpace(name), `\`, "/"))
+	if name == "" || name == "." || name == "/" || name == ".." {
+		name = fmt.Sprintf("document-%03d", i+1)
+	}
+	if !strings.HasSuffix(strings.ToLower(name), ".pdf") {
+		name += ".pdf"
+	}
+
+	unique := name
+	for n := 2; used[unique]; n++ {
+		unique = fmt.Sprintf("%s-%d.pdf", strings.TrimSuffix(name, path.Ext(name)), n)
+	}
+	used[unique] = true
+	return unique
+}
@@ -0,0 +1,114 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// tenantBranding holds a tenant's default branding. It is applied to every
+// render of the tenant (CSS via the brand pre-processing stage, logo and
+// footer via Chrome's print header/footer) and is available to templates as
+// {{brand}}.
+type tenantBranding struct {
+	// Logo is a file path or a data: URI; files are inlined at load time
+	// because Chrome does not load remote resources in print headers.
+	Logo         string `json:"logo,omitempty"`
+	FooterText   string `json:"footer_text,omitempty"`
+	FontFamily   string `json:"font_family,omitempty"`
+	PrimaryColor string `json:"primary_color,omitempty"`
+	TextColor    string `json:"text_color,omitempty"`
+}
+
+// resolveLogo turns a logo file path into a data URI.
+func (b *tenantBranding) resolveLogo() error {
+	if b.Logo == "" || strings.HasPrefix(b.Logo, "data:") {
+		return nil
+	}
+	data, err := os.ReadFile(b.Logo)
+	if err != nil {
+		return fmt.Errorf("read logo: %w", err)
+	}
+	contentType := mime.TypeByExtension(filepath.Ext(b.Logo))
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	b.Logo = "data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(data)
+	return nil
+}
+
+// css returns the stylesheet injected by the brand stage. Brand colors are
+// also exposed as custom properties for documents and templates to use.
+func (b *tenantBranding) css() string {
+	var vars, rules strings.Builder
+	if b.PrimaryColor != "" {
+		fmt.Fprintf(&vars, "--brand-primary:%s;", b.PrimaryColor)
+		fmt.Fprintf(&rules, "h1,h2,h3,h4,h5,h6{color:%s}", b.PrimaryColor)
+	}
+	var body strings.Builder
+	if b.TextColor != "" {
+		fmt.Fprintf(&vars, "--brand-text:%s;", b.TextColor)
+		fmt.Fprintf(&body, "color:%s;", b.TextColor)
+	}
+	if b.FontFamily != "" {
+		fmt.Fprintf(&vars, "--brand-font:%s;", b.FontFamily)
+		fmt.Fprintf(&body, "font-family:%s;", b.FontFamily)
+	}
+	if vars.Len() == 0 {
+		return ""
+	}
+	css := ":root{" + vars.String() + "}"
+	if body.Len() > 0 {
+		css += "body{" + body.String() + "}"
+	}
+	return css + rules.String()
+}
+
+// hasHeaderFooter reports whether the branding needs Chrome's print
+// header/footer.
+func (b *tenantBranding) hasHeaderFooter() bool {
+	return b != nil && (b.Logo != "" || b.FooterText != "")
+}
+
+// headerTemplate returns Chrome's print header with the logo, if any.
+func (b *tenantBranding) headerTemplate() string {
+	if b.Logo == "" {
+		return "<span></span>"
+	}
+	return fmt.Sprintf(`<div style="width:100%%;padding:0 0.4in"><img src="%s" style="max-height:0.4in"></div>`, html.EscapeString(b.Logo))
+}
+
+// footerTemplate returns Chrome's print footer with the footer text and page
+// numbers.
+func (b *tenantBranding) footerTemplate() string {
+	style := "width:100%;padding:0 0.4in;font-size:8pt;display:flex;justify-content:space-between"
+	if b.FontFamily != "" {
+		style += ";font-family:" + html.EscapeString(b.FontFamily)
+	}
+	if b.TextColor != "" {
+		style += ";color:" + html.EscapeString(b.TextColor)
+	}
+	return fmt.Sprintf(`<div style="%s"><span>%s</span><span><span class="pageNumber"></span> / <span class="totalPages"></span></span></div>`,
+		style, html.EscapeString(b.FooterText))
+}
+
+// applyBranding implements the brand pre-processing stage.
+func applyBranding(_ context.Context, input string, options pdfOptions) (string, error) {
+	if options.Branding == nil {
+		return input, nil
+	}
+	css := options.Branding.css()
+	if css == "" {
+		return input, nil
+	}
+	return insertIntoHead(input, "<style>"+css+"</style>"), nil
+}
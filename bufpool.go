@@ -0,0 +1,34 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"bytes"
+	"sync"
+)
+
+// bufferPool recycles the byte buffers used for request bodies and CDP
+// websocket messages.
+var bufferPool = sync.Pool{
+	New: func() any {
+		return new(bytes.Buffer)
+	},
+}
+
+// getBuffer returns an empty buffer from the pool.
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+// putBuffer returns buf to the pool. Buffers that grew past
+// maxPooledBufferBytes are dropped, so a single huge document does not keep
+// its memory alive in the pool.
+func putBuffer(buf *bytes.Buffer) {
+	if buf == nil || buf.Cap() > maxPooledBufferBytes {
+		return
+	}
+	bufferPool.Put(buf)
+}
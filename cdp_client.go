@@ -6,6 +6,7 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"crypto/rand"
 	"crypto/sha1"
@@ -29,6 +30,34 @@ const (
 	cdpPollInterval = 100 * time.Millisecond
 )
 
+// cdpStats holds process-wide gauges of the protocol layer, reported by
+// /status: open WebSocket connections, attached target sessions and CDP
+// calls in flight or waiting for their connection.
+var cdpStats struct {
+	connections  atomic.Int64
+	sessions     atomic.Int64
+	pendingCalls atomic.Int64
+}
+
+// cdpStatus is a snapshot of cdpStats.
+type cdpStatus struct {
+	Connections  int64 `json:"connections"`
+	Sessions     int64 `json:"sessions"`
+	PendingCalls int64 `json:"pending_calls"`
+
+	// Calls has the latency histogram of each method called so far.
+	Calls map[string]cdpCallStatus `json:"calls,omitempty"`
+}
+
+func currentCDPStatus() cdpStatus {
+	return cdpStatus{
+		Connections:  cdpStats.connections.Load(),
+		Sessions:     cdpStats.sessions.Load(),
+		PendingCalls: cdpStats.pendingCalls.Load(),
+		Calls:        cdpCallStatuses(),
+	}
+}
+
 // cdpClient manages the connection to the Chrome DevTools Protocol.
 // It holds the necessary fields for communication with the CDP.
 type cdpClient struct {
@@ -36,8 +65,62 @@ type cdpClient struct {
 	nextID int64
 	mu     sync.Mutex
 	br     *bufio.Reader
+
+	// Pooled buffers reused by every message on this connection: rbuf holds
+	// the message being read, wbuf the frame being written.
+	rbuf           *bytes.Buffer
+	wbuf           *bytes.Buffer
+	header         [8]byte
+	controlPayload [125]byte
+
+	// onEvent, when set, receives the protocol events read while Call waits
+	// for its response. It runs on the calling goroutine and must not block.
+	onEvent func(event cdpEvent)
+
+	// tracked is set for connections counted in cdpStats.
+	tracked bool
+
+	// interrupted is set when a cancelled context stopped a read, possibly
+	// in the middle of a message: later calls fail with errCDPInterrupted.
+	interrupted bool
+
+	// keepaliveInterval, when positive, is the idle time after which a
+	// waiting read pings Chrome; a pong must follow within keepaliveTimeout.
+	// pingSent is the time (UnixNano) of the unanswered ping, if any, and
+	// pongLost is set once one went unanswered too long.
+	keepaliveInterval time.Duration
+	keepaliveTimeout  time.Duration
+	pingSent          atomic.Int64
+	pongLost          atomic.Bool
+
+	// maxFrameBytes and maxMessageBytes, when positive, bound the frames and
+	// the assembled (inflated) messages read, before anything is allocated.
+	maxFrameBytes   int
+	maxMessageBytes int
+
+	// deflate is set when permessage-deflate was negotiated: messages whose
+	// first frame has RSV1 set are inflated into zbuf.
+	deflate  bool
+	inflater io.ReadCloser
+	zbuf     *bytes.Buffer
+
+	// wmu serializes frame writes: keepalive pings are written by a timer
+	// while the reading goroutine may answer pings or events.
+	wmu sync.Mutex
 }
 
+// errCDPInterrupted fails the calls on a connection whose read was
+// interrupted by a cancelled context.
+var errCDPInterrupted = errors.New("cdp connection interrupted")
+
+// errCDPMessageTooLarge fails a read whose frame or message exceeds
+// CDP_MAX_FRAME_BYTES or CDP_MAX_MESSAGE_BYTES. The rest of the message is
+// left unread, so the connection is not used again.
+var errCDPMessageTooLarge = errors.New("cdp message too large")
+
+// errCDPPongTimeout fails a read whose keepalive ping went unanswered.
+var errCDPPongTimeout = errors.New("cdp connection lost: keepalive ping unanswered")
+
 // cdpRequest represents a request sent to the Chrome DevTools Protocol.
 // It contains the method and parameters for the request.
 type cdpRequest struct {
@@ -55,6 +138,18 @@ type cdpResponse struct {
 	Error  *cdpError       `json:"error,omitempty"`
 	// cdpError represents an error response from the Chrome DevTools Protocol.
 	// It contains the error code and message.
+
+	// Event fields (ID == 0).
+	Method    string          `json:"method,omitempty"`
+	Params    json.RawMessage `json:"params,omitempty"`
+	SessionID string          `json:"sessionId,omitempty"`
+}
+
+// cdpEvent is a protocol event (a message without an ID).
+type cdpEvent struct {
+	Method    string
+	SessionID string
+	Params    json.RawMessage
 }
 
 type cdpError struct {
@@ -62,6 +157,13 @@ type cdpError struct {
 	Message string `json:"message"`
 }
 
+// JSON-RPC code of a command rejected for its parameters.
+const cdpInvalidParams = -32602
+
+func (e *cdpError) Error() string {
+	return fmt.Sprintf("error %d: %s", e.Code, e.Message)
+}
+
 const websocketMagicGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
 
 // newCDPClient establishes a new WebSocket connection to the specified URL
@@ -75,16 +177,34 @@ const websocketMagicGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
 //   - A pointer to a cdpClient if the connection is successful.
 //   - An error if the connection fails.
 func newCDPClient(ctx context.Context, wsURL string) (*cdpClient, error) {
-	conn, br, err := dialWebSocket(ctx, wsURL)
+	conn, br, deflate, err := dialWebSocket(ctx, wsURL)
 	if err != nil {
 		return nil, err
 	}
-	return &cdpClient{conn: conn, br: br}, nil
+	cdpStats.connections.Add(1)
+	return &cdpClient{conn: conn, br: br, rbuf: getBuffer(), wbuf: getBuffer(), tracked: true, deflate: deflate,
+		keepaliveInterval: chromeConn.keepaliveInterval, keepaliveTimeout: chromeConn.keepaliveTimeout,
+		maxFrameBytes: chromeConn.maxFrameBytes, maxMessageBytes: chromeConn.maxMessageBytes}, nil
 }
 
 // Close terminates the WebSocket connection and cleans up resources.
 func (c *cdpClient) Close() error {
-	return c.conn.Close()
+	err := c.conn.Close()
+	// Closing the connection unblocks a pending read, so Call releases the
+	// lock before the buffers go back to the pool.
+	c.mu.Lock()
+	c.wmu.Lock()
+	putBuffer(c.rbuf)
+	putBuffer(c.wbuf)
+	putBuffer(c.zbuf)
+	c.rbuf, c.wbuf, c.zbuf = nil, nil, nil
+	c.wmu.Unlock()
+	if c.tracked {
+		c.tracked = false
+		cdpStats.connections.Add(-1)
+	}
+	c.mu.Unlock()
+	return err
 }
 
 // Call sends a single Chrome DevTools Protocol (CDP) request and blocks until the
@@ -96,17 +216,27 @@ func (c *cdpClient) Close() error {
 // occurs at a time on the underlying transport.
 //
 // While waiting, Call reads messages and ignores those that are not responses
-// (resp.ID == 0) or whose ID does not match the current request. If the response
-// contains a protocol error, Call returns it as a formatted Go error.
+// (resp.ID == 0, handed to onEvent if set) or whose ID does not match the
+// current request. If the response contains a protocol error, Call returns it
+// as a formatted Go error.
 //
 // If result is non-nil and the response includes a non-empty Result payload,
 // Call unmarshals the payload into result.
 //
 // Returns any marshaling, transport read/write, unmarshaling, context, or CDP
 // protocol error encountered.
-func (c *cdpClient) Call(ctx context.Context, sessionID, method string, params any, result any) error {
+func (c *cdpClient) Call(ctx context.Context, sessionID, method string, params any, result any) (err error) {
+	cdpStats.pendingCalls.Add(1)
+	defer cdpStats.pendingCalls.Add(-1)
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	if c.interrupted {
+		return errCDPInterrupted
+	}
+	// Timed once the connection is ours: waiting for it is not the method's.
+	start := time.Now()
+	defer func() { observeCDPCall(ctx, method, time.Since(start), err) }()
 
 	id := atomic.AddInt64(&c.nextID, 1)
 	req := cdpRequest{
@@ -134,13 +264,16 @@ func (c *cdpClient) Call(ctx context.Context, sessionID, method string, params a
 			return err
 		}
 		if resp.ID == 0 {
+			if c.onEvent != nil && resp.Method != "" {
+				c.onEvent(cdpEvent{Method: resp.Method, SessionID: resp.SessionID, Params: resp.Params})
+			}
 			continue
 		}
 		if resp.ID != id {
 			continue
 		}
 		if resp.Error != nil {
-			return fmt.Errorf("cdp %s error %d: %s", method, resp.Error.Code, resp.Error.Message)
+			return fmt.Errorf("cdp %s %w", method, resp.Error)
 		}
 		if result != nil && len(resp.Result) > 0 {
 			if err := json.Unmarshal(resp.Result, result); err != nil {
@@ -151,6 +284,35 @@ func (c *cdpClient) Call(ctx context.Context, sessionID, method string, params a
 	}
 }
 
+// send writes a command without waiting for its response. It is meant for
+// event handlers that answer events (they run inside Call, which holds the
+// connection): the response is skipped by the Call that reads it.
+func (c *cdpClient) send(ctx context.Context, sessionID, method string, params any) error {
+	payload, err := json.Marshal(cdpRequest{
+		ID:        atomic.AddInt64(&c.nextID, 1),
+		Method:    method,
+		Params:    params,
+		SessionID: sessionID,
+	})
+	if err != nil {
+		return err
+	}
+	return c.write(ctx, payload)
+}
+
+// addEventHandler adds handler to the ones receiving events (see onEvent).
+func (c *cdpClient) addEventHandler(handler func(event cdpEvent)) {
+	previous := c.onEvent
+	if previous == nil {
+		c.onEvent = handler
+		return
+	}
+	c.onEvent = func(event cdpEvent) {
+		previous(event)
+		handler(event)
+	}
+}
+
 // read reads the next complete WebSocket message payload from the server.
 //
 // The call respects ctx for cancellation and deadlines. If ctx has a deadline,
@@ -161,7 +323,18 @@ func (c *cdpClient) Call(ctx context.Context, sessionID, method string, params a
 // On a successful read, it returns the message payload bytes. If a read times
 // out and ctx is still active, it retries. Any non-timeout read error, or any
 // context cancellation/deadline error, is returned.
+//
+// A long wait (e.g. printToPDF of a large document) pings Chrome, so that
+// idle-sensitive load balancers keep the connection; an unanswered ping fails
+// the read with errCDPPongTimeout.
 func (c *cdpClient) read(ctx context.Context) ([]byte, error) {
+	// Cancelling ctx (e.g. the client went away) interrupts a pending read
+	// at once instead of at the deadline, so Chrome work is abandoned early.
+	stop := context.AfterFunc(ctx, func() {
+		_ = c.conn.SetReadDeadline(time.Now())
+	})
+	defer stop()
+	defer c.keepalive()()
 	for {
 		if err := ctx.Err(); err != nil {
 			return nil, err
@@ -175,17 +348,89 @@ func (c *cdpClient) read(ctx context.Context) ([]byte, error) {
 				return nil, err
 			}
 		}
+		// The deadline set above may have replaced the one of the
+		// cancellation or of a lost pong.
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if c.pongLost.Load() {
+			c.interrupted = true
+			return nil, errCDPPongTimeout
+		}
 		data, err := c.readMessage()
 		if err == nil {
 			return data, nil
 		}
+		if c.pongLost.Load() {
+			c.interrupted = true
+			return nil, errCDPPongTimeout
+		}
 		if isTimeout(err) && ctx.Err() == nil {
 			continue
 		}
+		if ctx.Err() != nil || errors.Is(err, errCDPMessageTooLarge) {
+			c.interrupted = true
+		}
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return nil, ctx.Err()
+		}
 		return nil, err
 	}
 }
 
+// keepalive pings Chrome every keepaliveInterval until the returned function
+// is called, and interrupts the read when a ping is not answered within
+// keepaliveTimeout. The pong itself is consumed by readMessage.
+func (c *cdpClient) keepalive() (stop func()) {
+	if c.keepaliveInterval <= 0 {
+		return func() {}
+	}
+	var timer *time.Timer
+	var mu sync.Mutex
+	stopped := false
+	mu.Lock()
+	defer mu.Unlock()
+	timer = time.AfterFunc(c.keepaliveInterval, func() {
+		next := c.keepaliveInterval
+		if sent := c.pingSent.Load(); sent != 0 {
+			waited := time.Since(time.Unix(0, sent))
+			if waited >= c.keepaliveTimeout {
+				c.pongLost.Store(true)
+				_ = c.conn.SetReadDeadline(time.Now())
+				return
+			}
+			next = c.keepaliveTimeout - waited
+		} else {
+			// Recorded first: the pong may be read before the write returns.
+			c.pingSent.Store(time.Now().UnixNano())
+			next = c.keepaliveTimeout
+			if err := c.writeControlFrame(0x9, nil); err != nil {
+				c.pingSent.Store(0)
+				next = c.keepaliveInterval
+			}
+		}
+		mu.Lock()
+		if !stopped {
+			timer.Reset(next)
+		}
+		mu.Unlock()
+	})
+	return func() {
+		mu.Lock()
+		stopped = true
+		timer.Stop()
+		mu.Unlock()
+	}
+}
+
+// isInterrupted reports whether a cancelled read left the connection
+// unusable.
+func (c *cdpClient) isInterrupted() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.interrupted
+}
+
 // write sends a payload to the CDP server over the WebSocket connection.
 // It respects the context deadline if one is set and returns an error if the
 // context is already cancelled or if the write operation fails.
@@ -251,13 +496,13 @@ func isTimeout(err error) bool {
 //
 // Returns a connected net.Conn and a bufio.Reader tied to that connection, or an
 // error if dialing, TLS, request writing, or handshake validation fails.
-func dialWebSocket(ctx context.Context, wsURL string) (net.Conn, *bufio.Reader, error) {
+func dialWebSocket(ctx context.Context, wsURL string) (net.Conn, *bufio.Reader, bool, error) {
 	parsed, err := url.Parse(wsURL)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, false, err
 	}
 	if parsed.Scheme != "ws" && parsed.Scheme != "wss" {
-		return nil, nil, fmt.Errorf("unsupported websocket scheme: %s", parsed.Scheme)
+		return nil, nil, false, fmt.Errorf("unsupported websocket scheme: %s", parsed.Scheme)
 	}
 
 	host := parsed.Hostname()
@@ -271,18 +516,17 @@ func dialWebSocket(ctx context.Context, wsURL string) (net.Conn, *bufio.Reader,
 	}
 	address := net.JoinHostPort(host, port)
 
-	dialer := &net.Dialer{}
-	rawConn, err := dialer.DialContext(ctx, "tcp", address)
+	rawConn, err := chromeConn.dial(ctx, parsed.Scheme, address)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, false, err
 	}
 
 	conn := rawConn
 	if parsed.Scheme == "wss" {
-		tlsConn := tls.Client(rawConn, &tls.Config{ServerName: host})
+		tlsConn := tls.Client(rawConn, chromeConn.tlsConfig(host))
 		if err := tlsConn.HandshakeContext(ctx); err != nil {
 			_ = rawConn.Close()
-			return nil, nil, err
+			return nil, nil, false, err
 		}
 		conn = tlsConn
 	}
@@ -290,13 +534,13 @@ func dialWebSocket(ctx context.Context, wsURL string) (net.Conn, *bufio.Reader,
 	key, err := generateWebSocketKey()
 	if err != nil {
 		_ = conn.Close()
-		return nil, nil, err
+		return nil, nil, false, err
 	}
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wsURL, nil)
 	if err != nil {
 		_ = conn.Close()
-		return nil, nil, err
+		return nil, nil, false, err
 	}
 	if parsed.Port() == "" {
 		req.Host = host
@@ -305,24 +549,28 @@ func dialWebSocket(ctx context.Context, wsURL string) (net.Conn, *bufio.Reader,
 	req.Header.Set("Connection", "Upgrade")
 	req.Header.Set("Sec-WebSocket-Version", "13")
 	req.Header.Set("Sec-WebSocket-Key", key)
+	if chromeConn.compression {
+		req.Header.Set("Sec-WebSocket-Extensions", webSocketDeflateOffer)
+	}
+	chromeConn.authorize(req)
 
 	if deadline, ok := ctx.Deadline(); ok {
 		if err := conn.SetDeadline(deadline); err != nil {
 			_ = conn.Close()
-			return nil, nil, err
+			return nil, nil, false, err
 		}
 	}
 
 	if err := req.Write(conn); err != nil {
 		_ = conn.Close()
-		return nil, nil, err
+		return nil, nil, false, err
 	}
 
 	br := bufio.NewReader(conn)
 	resp, err := http.ReadResponse(br, req)
 	if err != nil {
 		_ = conn.Close()
-		return nil, nil, err
+		return nil, nil, false, err
 	}
 	if resp.Body != nil {
 		_ = resp.Body.Close()
@@ -330,28 +578,33 @@ func dialWebSocket(ctx context.Context, wsURL string) (net.Conn, *bufio.Reader,
 
 	if resp.StatusCode != http.StatusSwitchingProtocols {
 		_ = conn.Close()
-		return nil, nil, fmt.Errorf("websocket handshake failed: %s", resp.Status)
+		return nil, nil, false, fmt.Errorf("websocket handshake failed: %s", resp.Status)
 	}
 	if !strings.Contains(strings.ToLower(resp.Header.Get("Connection")), "upgrade") {
 		_ = conn.Close()
-		return nil, nil, errors.New("websocket handshake failed: missing connection upgrade")
+		return nil, nil, false, errors.New("websocket handshake failed: missing connection upgrade")
 	}
 	if !strings.Contains(strings.ToLower(resp.Header.Get("Upgrade")), "websocket") {
 		_ = conn.Close()
-		return nil, nil, errors.New("websocket handshake failed: missing upgrade websocket")
+		return nil, nil, false, errors.New("websocket handshake failed: missing upgrade websocket")
 	}
 	expectedAccept := computeWebSocketAccept(key)
 	if resp.Header.Get("Sec-WebSocket-Accept") != expectedAccept {
 		_ = conn.Close()
-		return nil, nil, errors.New("websocket handshake failed: invalid accept key")
+		return nil, nil, false, errors.New("websocket handshake failed: invalid accept key")
+	}
+	deflate, err := acceptedDeflate(resp.Header.Values("Sec-WebSocket-Extensions"))
+	if err != nil {
+		_ = conn.Close()
+		return nil, nil, false, fmt.Errorf("websocket handshake failed: %w", err)
 	}
 
 	if err := conn.SetDeadline(time.Time{}); err != nil {
 		_ = conn.Close()
-		return nil, nil, err
+		return nil, nil, false, err
 	}
 
-	return conn, br, nil
+	return conn, br, deflate, nil
 }
 
 // generateWebSocketKey creates and returns a base64-encoded 16-byte random value suitable
@@ -386,16 +639,25 @@ func computeWebSocketAccept(key string) string {
 // - The connection is closed (0x8 opcode returns io.EOF)
 //
 // Control frames (ping 0x9, pong 0xA) are handled transparently and do not
-// affect message assembly.
+// affect message assembly. Compressed messages (permessage-deflate) are
+// returned inflated.
 func (c *cdpClient) readMessage() ([]byte, error) {
-	var message []byte
+	if c.rbuf == nil {
+		return nil, net.ErrClosed
+	}
+	c.rbuf.Reset()
 	collecting := false
+	compressed := false
 
 	for {
-		fin, opcode, payload, err := c.readFrame()
+		fin, opcode, rsv1, control, err := c.readFrame(c.rbuf)
 		if err != nil {
 			return nil, err
 		}
+		// RSV1 marks a compressed message, on its first frame only.
+		if rsv1 && (!c.deflate || opcode != 0x1) {
+			return nil, errors.New("unexpected websocket RSV1 bit")
+		}
 
 		switch opcode {
 		// Continuation frame
@@ -403,33 +665,29 @@ func (c *cdpClient) readMessage() ([]byte, error) {
 			if !collecting {
 				return nil, errors.New("websocket continuation without start frame")
 			}
-			message = append(message, payload...)
 		// Text frame
 		case 0x1:
 			if collecting {
 				return nil, errors.New("websocket data frame while continuation pending")
 			}
 			collecting = true
-			message = append(message, payload...)
+			compressed = rsv1
 		// Binary frame
 		case 0x2:
 			return nil, errors.New("unexpected binary websocket frame")
 		// Connection close
 		case 0x8:
-			closePayload := payload
-			if len(closePayload) > 125 {
-				closePayload = nil
-			}
-			_ = c.writeControlFrame(0x8, closePayload)
+			_ = c.writeControlFrame(0x8, control)
 			return nil, io.EOF
 		// Ping frame
 		case 0x9:
-			if err := c.writeControlFrame(0xA, payload); err != nil {
+			if err := c.writeControlFrame(0xA, control); err != nil {
 				return nil, err
 			}
 			continue
-		// Pong frame
+		// Pong frame, answering a keepalive ping
 		case 0xA:
+			c.pingSent.Store(0)
 			continue
 		// Unsupported opcode
 		default:
@@ -437,7 +695,10 @@ func (c *cdpClient) readMessage() ([]byte, error) {
 		}
 
 		if fin {
-			return message, nil
+			if compressed {
+				return c.inflate(c.rbuf)
+			}
+			return c.rbuf.Bytes(), nil
 		}
 	}
 }
@@ -447,41 +708,48 @@ func (c *cdpClient) readMessage() ([]byte, error) {
 // It reads the 2-byte base header, extracts FIN, opcode, MASK, and the initial
 // payload length, then reads any extended length bytes (16-bit for 126, 64-bit
 // for 127) as defined by RFC 6455. If the 64-bit length does not fit into an
-// int on the current platform, it returns an error.
+// int on the current platform, it returns an error. Masked server frames are
+// rejected, and so are frames over maxFrameBytes or data frames that would
+// take the message over maxMessageBytes, before their payload is allocated.
 //
-// If the MASK bit is set, it reads the 4-byte masking key, reads the payload,
-// and applies the masking operation in-place.
+// Data frame payloads (opcodes below 0x8) are appended to data, so messages
+// are assembled without an intermediate copy. Control frame payloads, at most
+// 125 bytes, are returned and stay valid until the next call.
 //
-// It returns the FIN flag, opcode, unmasked payload bytes, and any I/O or
-// protocol/size error encountered.
-func (c *cdpClient) readFrame() (bool, byte, []byte, error) {
-	header := make([]byte, 2)
+// It returns the FIN flag, opcode, RSV1 flag (permessage-deflate), control
+// payload, and any I/O or protocol/size error encountered.
+func (c *cdpClient) readFrame(data *bytes.Buffer) (bool, byte, bool, []byte, error) {
+	header := c.header[:2]
 	if _, err := io.ReadFull(c.br, header); err != nil {
-		return false, 0, nil, err
+		return false, 0, false, nil, err
 	}
 
 	fin := (header[0] & 0x80) != 0      // FIN bit
+	rsv1 := (header[0] & 0x40) != 0     // RSV1 bit
 	opcode := header[0] & 0x0F          // Opcode
 	masked := (header[1] & 0x80) != 0   // MASK bit
 	payloadLen := int(header[1] & 0x7F) // Payload length
 
 	if masked {
-		return false, 0, nil, errors.New("server websocket frames must not be masked")
+		return false, 0, false, nil, errors.New("server websocket frames must not be masked")
+	}
+	if header[0]&0x30 != 0 {
+		return false, 0, false, nil, errors.New("unexpected websocket RSV2/RSV3 bits")
 	}
 
 	switch payloadLen {
 	// Extended payload length: 16-bit
 	case 126:
-		ext := make([]byte, 2)
+		ext := c.header[:2]
 		if _, err := io.ReadFull(c.br, ext); err != nil {
-			return false, 0, nil, err
+			return false, 0, false, nil, err
 		}
 		payloadLen = int(ext[0])<<8 | int(ext[1])
 	// Extended payload length: 64-bit
 	case 127:
-		ext := make([]byte, 8)
+		ext := c.header[:8]
 		if _, err := io.ReadFull(c.br, ext); err != nil {
-			return false, 0, nil, err
+			return false, 0, false, nil, err
 		}
 		// Reconstructs a 64-bit unsigned length value from the first 8 bytes of ext,
 		// interpreting them as a big-endian (network-order) integer by shifting each
@@ -490,19 +758,38 @@ func (c *cdpClient) readFrame() (bool, byte, []byte, error) {
 		length := uint64(ext[0])<<56 | uint64(ext[1])<<48 | uint64(ext[2])<<40 | uint64(ext[3])<<32 |
 			uint64(ext[4])<<24 | uint64(ext[5])<<16 | uint64(ext[6])<<8 | uint64(ext[7])
 		if length > uint64(int(^uint(0)>>1)) {
-			return false, 0, nil, errors.New("websocket frame too large")
+			return false, 0, false, nil, errors.New("websocket frame too large")
 		}
 		payloadLen = int(length)
 	}
 
-	payload := make([]byte, payloadLen)
+	if c.maxFrameBytes > 0 && payloadLen > c.maxFrameBytes {
+		return false, 0, false, nil, fmt.Errorf("%w: %d-byte frame exceeds %d bytes", errCDPMessageTooLarge, payloadLen, c.maxFrameBytes)
+	}
+	if opcode >= 0x8 {
+		if payloadLen > 125 {
+			return false, 0, false, nil, errors.New("websocket control frame too large")
+		}
+		control := c.controlPayload[:payloadLen]
+		if _, err := io.ReadFull(c.br, control); err != nil {
+			return false, 0, false, nil, err
+		}
+		return fin, opcode, rsv1, control, nil
+	}
+
+	if c.maxMessageBytes > 0 && data.Len()+payloadLen > c.maxMessageBytes {
+		return false, 0, false, nil, fmt.Errorf("%w: message exceeds %d bytes", errCDPMessageTooLarge, c.maxMessageBytes)
+	}
 	if payloadLen > 0 {
+		data.Grow(payloadLen)
+		payload := data.AvailableBuffer()[:payloadLen]
 		if _, err := io.ReadFull(c.br, payload); err != nil {
-			return false, 0, nil, err
+			return false, 0, false, nil, err
 		}
+		data.Write(payload)
 	}
 
-	return fin, opcode, payload, nil
+	return fin, opcode, rsv1, nil, nil
 }
 
 func (c *cdpClient) writeTextMessage(payload []byte) error {
@@ -542,7 +829,14 @@ func (c *cdpClient) writeFrame(opcode byte, payload []byte, fin bool) error {
 	}
 	headerLen += 4
 
-	frame := make([]byte, headerLen+payloadLen)
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.wbuf == nil {
+		return net.ErrClosed
+	}
+	c.wbuf.Reset()
+	c.wbuf.Grow(headerLen + payloadLen)
+	frame := c.wbuf.AvailableBuffer()[:headerLen+payloadLen]
 	if fin {
 		frame[0] = 0x80 | opcode
 	} else {
@@ -593,17 +887,63 @@ func isPageWebSocket(wsURL string) bool {
 	return strings.Contains(wsURL, "/devtools/page/")
 }
 
+// targetFragmentPrefix marks the tabs opened by this service.
+const targetFragmentPrefix = "pdfrest-"
+
+// targetURL is the blank page a render starts from. Its fragment carries the
+// request ID, so tabs in /json/list or chrome://inspect can be mapped back to
+// requests.
+func targetURL(ctx context.Context) string {
+	id := requestIDFromContext(ctx)
+	if id == "" {
+		return "about:blank"
+	}
+	return (&url.URL{Scheme: "about", Opaque: "blank", Fragment: targetFragmentPrefix + id}).String()
+}
+
+// createBrowserContext creates an isolated browser context, the equivalent of
+// an incognito window: cookies, cache, storage and service workers are not
+// shared with other contexts. Chrome disposes of it if the connection drops
+// before disposeBrowserContext is called.
+func createBrowserContext(ctx context.Context, client *cdpClient) (string, error) {
+	var created struct {
+		BrowserContextID string `json:"browserContextId"`
+	}
+	if err := client.Call(ctx, "", "Target.createBrowserContext", map[string]any{
+		"disposeOnDetach": true,
+	}, &created); err != nil {
+		return "", err
+	}
+	if created.BrowserContextID == "" {
+		return "", errors.New("cdp browser context id missing")
+	}
+	return created.BrowserContextID, nil
+}
+
+// disposeBrowserContext closes a browser context and everything it stored.
+// If browserContextID is empty, it returns nil without making any API call.
+func disposeBrowserContext(ctx context.Context, client *cdpClient, browserContextID string) error {
+	if browserContextID == "" {
+		return nil
+	}
+	return client.Call(ctx, "", "Target.disposeBrowserContext", map[string]any{
+		"browserContextId": browserContextID,
+	}, nil)
+}
+
 // openTargetSession creates a new target and attaches to it, returning the session ID and target ID.
-// It first creates a target with a blank URL using Target.createTarget, then attaches to the created
-// target using Target.attachToTarget with flattening enabled. Returns an error if target ID or session ID
-// is missing or if either CDP protocol call fails.
-func openTargetSession(ctx context.Context, client *cdpClient) (string, string, error) {
+// It first creates a target with a blank URL (see targetURL) using Target.createTarget, in browserContextID when
+// set, then attaches to the created target using Target.attachToTarget with flattening enabled. Returns an error
+// if target ID or session ID is missing or if either CDP protocol call fails.
+func openTargetSession(ctx context.Context, client *cdpClient, browserContextID string) (string, string, error) {
+	params := map[string]any{"url": targetURL(ctx)}
+	if browserContextID != "" {
+		params["browserContextId"] = browserContextID
+	}
 	var created struct {
 		TargetID string `json:"targetId"`
 	}
-	if err := client.Call(ctx, "", "Target.createTarget", map[string]any{
-		"url": "about:blank",
-	}, &created); err != nil {
+	if err := client.Call(ctx, "", "Target.createTarget", params, &created); err != nil {
 		return "", "", err
 	}
 	if created.TargetID == "" {
@@ -623,6 +963,7 @@ func openTargetSession(ctx context.Context, client *cdpClient) (string, string,
 		return "", "", errors.New("cdp session id missing")
 	}
 
+	cdpStats.sessions.Add(1)
 	return attached.SessionID, created.TargetID, nil
 }
 
@@ -633,6 +974,7 @@ func closeTarget(ctx context.Context, client *cdpClient, targetID string) error
 	if targetID == "" {
 		return nil
 	}
+	defer cdpStats.sessions.Add(-1)
 	return client.Call(ctx, "", "Target.closeTarget", map[string]any{
 		"targetId": targetID,
 	}, nil)
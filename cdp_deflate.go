@@ -0,0 +1,85 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// webSocketDeflateOffer asks for permessage-deflate (RFC 7692) without
+// context takeover, so that every message inflates on its own. Only Chrome's
+// messages are compressed: commands are small and sent as they are.
+const webSocketDeflateOffer = "permessage-deflate; client_no_context_takeover; server_no_context_takeover"
+
+// deflateTail ends a permessage-deflate payload: the empty stored block the
+// sender stripped, then a final empty block so the reader sees a clean end.
+var deflateTail = []byte{0x00, 0x00, 0xff, 0xff, 0x01, 0x00, 0x00, 0xff, 0xff}
+
+// acceptedDeflate reports whether the Sec-WebSocket-Extensions response
+// headers accept the offer. Extensions that were not offered, or a server
+// that keeps its compression context, fail the handshake.
+func acceptedDeflate(headers []string) (bool, error) {
+	accepted := false
+	for _, header := range headers {
+		for _, extension := range strings.Split(header, ",") {
+			params := strings.Split(extension, ";")
+			if name := strings.TrimSpace(params[0]); name != "permessage-deflate" {
+				if name == "" {
+					continue
+				}
+				return false, fmt.Errorf("unexpected extension %q", name)
+			}
+			if accepted {
+				return false, fmt.Errorf("duplicate extension %q", "permessage-deflate")
+			}
+			noContextTakeover := false
+			for _, param := range params[1:] {
+				name, _, _ := strings.Cut(strings.TrimSpace(param), "=")
+				switch name {
+				case "server_no_context_takeover":
+					noContextTakeover = true
+				case "client_no_context_takeover", "server_max_window_bits", "client_max_window_bits":
+				default:
+					return false, fmt.Errorf("unexpected permessage-deflate parameter %q", name)
+				}
+			}
+			if !noContextTakeover {
+				return false, errors.New("permessage-deflate without server_no_context_takeover")
+			}
+			accepted = true
+		}
+	}
+	return accepted, nil
+}
+
+// inflate decompresses the permessage-deflate payload in data. The result is
+// valid until the next message is read.
+func (c *cdpClient) inflate(data *bytes.Buffer) ([]byte, error) {
+	data.Write(deflateTail)
+	if c.inflater == nil {
+		c.inflater = flate.NewReader(data)
+	} else if err := c.inflater.(flate.Resetter).Reset(data, nil); err != nil {
+		return nil, err
+	}
+	if c.zbuf == nil {
+		c.zbuf = getBuffer()
+	}
+	c.zbuf.Reset()
+	var inflated io.Reader = c.inflater
+	if c.maxMessageBytes > 0 {
+		inflated = io.LimitReader(c.inflater, int64(c.maxMessageBytes)+1)
+	}
+	if _, err := c.zbuf.ReadFrom(inflated); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("inflate websocket message: %w", err)
+	}
+	if c.maxMessageBytes > 0 && c.zbuf.Len() > c.maxMessageBytes {
+		return nil, fmt.Errorf("%w: inflated message exceeds %d bytes", errCDPMessageTooLarge, c.maxMessageBytes)
+	}
+	return c.zbuf.Bytes(), nil
+}
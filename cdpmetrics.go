@@ -0,0 +1,101 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// cdpLatencyBuckets are the upper bounds of the CDP call latency histograms.
+var cdpLatencyBuckets = []time.Duration{
+	10 * time.Millisecond, 50 * time.Millisecond, 100 * time.Millisecond, 250 * time.Millisecond,
+	500 * time.Millisecond, time.Second, 2500 * time.Millisecond, 5 * time.Second,
+	10 * time.Second, 30 * time.Second, time.Minute,
+}
+
+// cdpMethodStats accumulates the calls of one CDP method.
+type cdpMethodStats struct {
+	count   int64
+	errors  int64
+	total   time.Duration
+	max     time.Duration
+	buckets []int64 // per cdpLatencyBuckets entry, not cumulative
+}
+
+// cdpCallStats holds the latency of every CDP method called since startup,
+// reported by /status, so a slow render can be traced to its phase.
+var cdpCallStats struct {
+	mu      sync.Mutex
+	methods map[string]*cdpMethodStats
+}
+
+// cdpCallStatus is the /status form of cdpMethodStats. Buckets are
+// cumulative, as in Prometheus: each counts the calls that took at most
+// le_seconds; slower calls are only in count.
+type cdpCallStatus struct {
+	Count        int64              `json:"count"`
+	Errors       int64              `json:"errors"`
+	TotalSeconds float64            `json:"total_seconds"`
+	MaxSeconds   float64            `json:"max_seconds"`
+	Buckets      []cdpLatencyBucket `json:"buckets"`
+}
+
+type cdpLatencyBucket struct {
+	LESeconds float64 `json:"le_seconds"`
+	Count     int64   `json:"count"`
+}
+
+// observeCDPCall records one call of method, and logs it when it took
+// longer than CDP_SLOW_CALL.
+func observeCDPCall(ctx context.Context, method string, elapsed time.Duration, err error) {
+	cdpCallStats.mu.Lock()
+	if cdpCallStats.methods == nil {
+		cdpCallStats.methods = make(map[string]*cdpMethodStats)
+	}
+	stats := cdpCallStats.methods[method]
+	if stats == nil {
+		stats = &cdpMethodStats{buckets: make([]int64, len(cdpLatencyBuckets))}
+		cdpCallStats.methods[method] = stats
+	}
+	stats.count++
+	if err != nil {
+		stats.errors++
+	}
+	stats.total += elapsed
+	stats.max = max(stats.max, elapsed)
+	for i, bound := range cdpLatencyBuckets {
+		if elapsed <= bound {
+			stats.buckets[i]++
+			break
+		}
+	}
+	cdpCallStats.mu.Unlock()
+
+	if slow := chromeConn.slowCall; slow > 0 && elapsed >= slow {
+		Debugf("slow cdp call %s took %s (request %s)", method, elapsed.Round(time.Millisecond), requestIDFromContext(ctx))
+	}
+}
+
+// cdpCallStatuses is a snapshot of cdpCallStats.
+func cdpCallStatuses() map[string]cdpCallStatus {
+	cdpCallStats.mu.Lock()
+	defer cdpCallStats.mu.Unlock()
+	if len(cdpCallStats.methods) == 0 {
+		return nil
+	}
+	statuses := make(map[string]cdpCallStatus, len(cdpCallStats.methods))
+	for method, stats := range cdpCallStats.methods {
+		status := cdpCallStatus{Count: stats.count, Errors: stats.errors, TotalSeconds: stats.total.Seconds(),
+			MaxSeconds: stats.max.Seconds(), Buckets: make([]cdpLatencyBucket, len(cdpLatencyBuckets))}
+		var cumulative int64
+		for i, bound := range cdpLatencyBuckets {
+			cumulative += stats.buckets[i]
+			status.Buckets[i] = cdpLatencyBucket{LESeconds: bound.Seconds(), Count: cumulative}
+		}
+		statuses[method] = status
+	}
+	return statuses
+}
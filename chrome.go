@@ -4,15 +4,47 @@
 package main
 
 import (
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 )
 
+// Largest /json/version body read (decompressed).
+const maxChromeVersionBytes = 64 * 1024
+
+// headerFreshDiscovery (or the fresh_discovery query parameter) makes a
+// request skip the cached websocket URL, e.g. right after Chrome restarted.
+const headerFreshDiscovery = "X-Fresh-Discovery"
+
+type freshDiscoveryContextKey struct{}
+
+// withFreshDiscovery marks the request context when the caller asked for a
+// fresh discovery.
+func withFreshDiscovery(r *http.Request) *http.Request {
+	value := r.URL.Query().Get("fresh_discovery")
+	if value == "" {
+		value = r.Header.Get(headerFreshDiscovery)
+	}
+	if fresh, _ := strconv.ParseBool(value); !fresh {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), freshDiscoveryContextKey{}, true))
+}
+
+// freshDiscovery reports whether ctx asks to bypass the discovery cache.
+func freshDiscovery(ctx context.Context) bool {
+	fresh, _ := ctx.Value(freshDiscoveryContextKey{}).(bool)
+	return fresh
+}
+
 // chromeResolver resolves the remote Chrome DevTools websocket URL.
 // It supports:
 // - Explicit websocket URL via env (CHROME_WS)
@@ -32,16 +64,33 @@ func newChromeResolver(cfg config) *chromeResolver {
 	return &chromeResolver{
 		endpoint: cfg.ChromeEndpoint,
 		ws:       cfg.ChromeWS,
-		client: &http.Client{
-			Timeout: defaultChromeClientTimeout,
+		client:   newChromeHTTPClient(),
+		cacheTTL: cfg.ChromeWSCacheTTL,
+	}
+}
+
+// newChromeHTTPClient returns the client used for discovery calls. It keeps
+// connections to the Chrome endpoint alive between calls, and compression is
+// negotiated by fetchVersion itself.
+func newChromeHTTPClient() *http.Client {
+	return &http.Client{
+		Timeout: defaultChromeClientTimeout,
+		Transport: &http.Transport{
+			Proxy:               chromeConn.proxyFunc(),
+			DialContext:         (&net.Dialer{Timeout: defaultChromeClientTimeout, KeepAlive: 30 * time.Second}).DialContext,
+			MaxIdleConns:        defaultChromeMaxIdleConns,
+			MaxIdleConnsPerHost: defaultChromeMaxIdleConns,
+			IdleConnTimeout:     defaultChromeIdleConnTimeout,
+			DisableCompression:  true,
+			TLSClientConfig:     chromeConn.tls.Clone(),
 		},
-		cacheTTL: defaultWSTTL,
 	}
 }
 
 // wsURL returns the Chrome DevTools websocket URL.
 // If CHROME_WS is configured, it is returned directly.
-// Otherwise, it discovers it via /json/version and caches the result.
+// Otherwise, it discovers it via /json/version and caches the result; the
+// cache is skipped for requests marked by withFreshDiscovery.
 func (c *chromeResolver) wsURL(ctx context.Context) (string, error) {
 	// Explicit override always wins.
 	if c.ws != "" {
@@ -49,91 +98,79 @@ func (c *chromeResolver) wsURL(ctx context.Context) (string, error) {
 	}
 
 	// Fast-path cache (locked).
-	if ws := c.getCachedWS(); ws != "" {
+	if ws := c.getCachedWS(); ws != "" && !freshDiscovery(ctx) {
 		return ws, nil
 	}
 
 	// Discover via /json/version.
+	payload, err := c.fetchVersion(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	// Store in cache.
+	c.setCachedWS(payload.WebSocketDebuggerURL)
+
+	return payload.WebSocketDebuggerURL, nil
+}
+
+// fetchVersion queries the /json/version endpoint of the Chrome instance.
+func (c *chromeResolver) fetchVersion(ctx context.Context) (versionResponse, error) {
+	var payload versionResponse
+
 	endpoint := fmt.Sprintf("%s/json/version", c.endpoint)
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
 	if err != nil {
-		return "", err
+		return payload, err
 	}
+	req.Header.Set("Accept-Encoding", "gzip")
+	chromeConn.authorize(req)
 
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return "", err
+		return payload, err
 	}
 	defer func() {
+		// Drain the body so the connection goes back to the pool.
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxChromeVersionBytes))
 		if err := resp.Body.Close(); err != nil {
 			Warnf("chrome version body close error: %v", err)
 		}
 	}()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("unexpected chrome status: %s", resp.Status)
+		return payload, fmt.Errorf("unexpected chrome status: %s", resp.Status)
 	}
 
-	var payload versionResponse
-	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
-		return "", err
+	var body io.Reader = resp.Body
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return payload, fmt.Errorf("chrome version: %w", err)
+		}
+		defer func() { _ = gz.Close() }()
+		body = gz
+	}
+	if err := json.NewDecoder(io.LimitReader(body, maxChromeVersionBytes)).Decode(&payload); err != nil {
+		return payload, err
 	}
 	if payload.WebSocketDebuggerURL == "" {
-		return "", errors.New("missing websocket debugger url")
+		return payload, errors.New("missing websocket debugger url")
 	}
-
-	// Store in cache.
-	c.setCachedWS(payload.WebSocketDebuggerURL)
-
-	return payload.WebSocketDebuggerURL, nil
+	return payload, nil
 }
 
 // checkChrome verifies connectivity to Chrome without relying on cached websocket values.
 func (c *chromeResolver) checkChrome(ctx context.Context) error {
 	if c.ws != "" {
-		client, err := newCDPClient(ctx, c.ws)
-		if err != nil {
-			return err
-		}
-		defer func() {
-			if err := client.Close(); err != nil {
-				Warnf("chrome websocket close error: %v", err)
-			}
-		}()
-
-		var version struct {
-			ProtocolVersion string `json:"protocolVersion"`
-		}
-		return client.Call(ctx, "", "Browser.getVersion", nil, &version)
-	}
-
-	endpoint := fmt.Sprintf("%s/json/version", c.endpoint)
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
-	if err != nil {
+		_, err := browserVersion(ctx, c.ws)
 		return err
 	}
 
-	resp, err := c.client.Do(req)
+	payload, err := c.fetchVersion(ctx)
 	if err != nil {
 		return err
 	}
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			Warnf("chrome health body close error: %v", err)
-		}
-	}()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected chrome status: %s", resp.Status)
-	}
-
-	var payload versionResponse
-	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
-		return err
-	}
-	if payload.WebSocketDebuggerURL == "" {
-		return errors.New("missing websocket debugger url")
-	}
 
 	c.setCachedWS(payload.WebSocketDebuggerURL)
 	return nil
@@ -160,3 +197,69 @@ func (c *chromeResolver) setCachedWS(ws string) {
 	c.cachedAt = time.Now()
 	c.mu.Unlock()
 }
+
+// cachedWSAge returns how long ago the websocket URL was discovered, or false
+// when nothing is cached.
+func (c *chromeResolver) cachedWSAge() (time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cachedWS == "" {
+		return 0, false
+	}
+	return time.Since(c.cachedAt), true
+}
+
+// status gathers detailed Chrome diagnostics for the health report. Unlike
+// checkChrome it never fails: problems are reported in the result.
+func (c *chromeResolver) status(ctx context.Context) chromeStatus {
+	status := chromeStatus{}
+
+	ws := c.ws
+	if ws == "" {
+		payload, err := c.fetchVersion(ctx)
+		if err != nil {
+			status.Error = err.Error()
+			return status
+		}
+		status.Reachable = true
+		status.Browser = payload.Browser
+		status.ProtocolVersion = payload.ProtocolVersion
+		c.setCachedWS(payload.WebSocketDebuggerURL)
+		ws = payload.WebSocketDebuggerURL
+	}
+
+	version, err := browserVersion(ctx, ws)
+	if err != nil {
+		status.Error = err.Error()
+	} else {
+		status.Reachable = true
+		status.WebSocketReachable = true
+		status.Browser = version.Product
+		status.ProtocolVersion = version.ProtocolVersion
+	}
+
+	if age, ok := c.cachedWSAge(); ok {
+		seconds := age.Seconds()
+		status.CachedWSAgeSeconds = &seconds
+	}
+	return status
+}
+
+// browserVersion connects to the DevTools websocket and calls Browser.getVersion.
+func browserVersion(ctx context.Context, wsURL string) (browserVersionResult, error) {
+	var version browserVersionResult
+
+	client, err := newCDPClient(ctx, wsURL)
+	if err != nil {
+		return version, err
+	}
+	defer func() {
+		if err := client.Close(); err != nil {
+			Warnf("chrome websocket close error: %v", err)
+		}
+	}()
+
+	err = client.Call(ctx, "", "Browser.getVersion", nil, &version)
+	return version, err
+}
@@ -0,0 +1,314 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// chromeConnSettings is how the service reaches remote Chrome endpoints, for
+// both /json/version discovery and the DevTools websocket handshake. It is
+// set once at startup, before any connection is made.
+type chromeConnSettings struct {
+	// authHeader and authValue carry CHROME_TOKEN, for managed browser
+	// providers that require token auth.
+	authHeader string
+	authValue  string
+
+	// proxy is CHROME_PROXY; without it the standard proxy variables
+	// (HTTP_PROXY, HTTPS_PROXY, NO_PROXY) apply.
+	proxy *url.URL
+
+	// tls is the CHROME_TLS_* configuration, nil for the system roots.
+	tls *tls.Config
+
+	// keepaliveInterval and keepaliveTimeout are CDP_KEEPALIVE_INTERVAL
+	// and CDP_KEEPALIVE_TIMEOUT (see cdpClient.keepalive).
+	keepaliveInterval time.Duration
+	keepaliveTimeout  time.Duration
+
+	// compression offers permessage-deflate in websocket handshakes
+	// (CDP_COMPRESSION).
+	compression bool
+
+	// maxFrameBytes and maxMessageBytes are CDP_MAX_FRAME_BYTES and
+	// CDP_MAX_MESSAGE_BYTES.
+	maxFrameBytes   int
+	maxMessageBytes int
+
+	// slowCall is CDP_SLOW_CALL: CDP calls taking longer are logged.
+	slowCall time.Duration
+}
+
+var chromeConn chromeConnSettings
+
+// configureChromeConn applies the Chrome connection settings of cfg.
+func configureChromeConn(cfg config) error {
+	settings, err := newChromeConnSettings(cfg)
+	if err != nil {
+		return err
+	}
+	chromeConn = settings
+	return nil
+}
+
+func newChromeConnSettings(cfg config) (chromeConnSettings, error) {
+	settings := chromeConnSettings{keepaliveInterval: cfg.CDPKeepaliveInterval, keepaliveTimeout: cfg.CDPKeepaliveTimeout,
+		compression: cfg.CDPCompression, maxFrameBytes: cfg.CDPMaxFrameBytes, maxMessageBytes: cfg.CDPMaxMessageBytes,
+		slowCall: cfg.CDPSlowCall}
+	if cfg.ChromeToken != "" {
+		settings.authHeader = cfg.ChromeAuthHeader
+		settings.authValue = cfg.ChromeToken
+		// A bare token in Authorization is sent as a bearer token.
+		if strings.EqualFold(settings.authHeader, "Authorization") && !strings.Contains(settings.authValue, " ") {
+			settings.authValue = "Bearer " + settings.authValue
+		}
+	}
+	if cfg.ChromeProxy != "" {
+		// loadConfig has validated the URL.
+		settings.proxy, _ = url.Parse(cfg.ChromeProxy)
+	}
+	var err error
+	if settings.tls, err = loadChromeTLSConfig(cfg); err != nil {
+		return settings, err
+	}
+	if cfg.ChromeTLSInsecureSkipVerify {
+		Warnf("CHROME_TLS_INSECURE_SKIP_VERIFY is set: Chrome TLS certificates are not verified")
+	}
+	return settings, nil
+}
+
+// tlsConfig returns the TLS configuration of a connection to host.
+func (s chromeConnSettings) tlsConfig(host string) *tls.Config {
+	if s.tls == nil {
+		return &tls.Config{ServerName: host}
+	}
+	config := s.tls.Clone()
+	config.ServerName = host
+	return config
+}
+
+// authorize adds the Chrome credentials, if any, to a discovery or
+// handshake request.
+func (s chromeConnSettings) authorize(req *http.Request) {
+	if s.authValue != "" {
+		req.Header.Set(s.authHeader, s.authValue)
+	}
+}
+
+// proxyFunc returns the proxy selection of the discovery client, which the
+// websocket dialer follows too.
+func (s chromeConnSettings) proxyFunc() func(*http.Request) (*url.URL, error) {
+	if s.proxy != nil {
+		return http.ProxyURL(s.proxy)
+	}
+	return http.ProxyFromEnvironment
+}
+
+// dial opens a TCP connection to the websocket address (host:port) of a ws or
+// wss URL, through the proxy when one applies: an HTTP(S) proxy is asked to
+// CONNECT, a SOCKS5 proxy to open a stream.
+func (s chromeConnSettings) dial(ctx context.Context, scheme, address string) (net.Conn, error) {
+	// Proxy selection goes by the HTTP scheme the websocket one maps to.
+	httpScheme := "http"
+	if scheme == "wss" {
+		httpScheme = "https"
+	}
+	proxy, err := s.proxyFunc()(&http.Request{URL: &url.URL{Scheme: httpScheme, Host: address}})
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{}
+	if proxy == nil {
+		return dialer.DialContext(ctx, "tcp", address)
+	}
+
+	conn, err := dialer.DialContext(ctx, "tcp", proxyAddress(proxy))
+	if err != nil {
+		return nil, fmt.Errorf("chrome proxy: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	switch proxy.Scheme {
+	case "https":
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: proxy.Hostname()})
+		if err = tlsConn.HandshakeContext(ctx); err == nil {
+			conn = tlsConn
+			err = proxyConnect(conn, proxy, address)
+		}
+	case "http":
+		err = proxyConnect(conn, proxy, address)
+	case "socks5", "socks5h":
+		err = socks5Connect(conn, proxy, address)
+	default:
+		err = fmt.Errorf("unsupported proxy scheme %q", proxy.Scheme)
+	}
+	if err == nil {
+		err = conn.SetDeadline(time.Time{})
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("chrome proxy: %w", err)
+	}
+	return conn, nil
+}
+
+// proxyAddress is the host:port of a proxy URL, with the default port of its
+// scheme.
+func proxyAddress(proxy *url.URL) string {
+	if proxy.Port() != "" {
+		return proxy.Host
+	}
+	port := "1080"
+	switch proxy.Scheme {
+	case "http":
+		port = "80"
+	case "https":
+		port = "443"
+	}
+	return net.JoinHostPort(proxy.Hostname(), port)
+}
+
+// proxyConnect asks an HTTP proxy to tunnel conn to address.
+func proxyConnect(conn net.Conn, proxy *url.URL, address string) error {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: make(http.Header),
+	}
+	if proxy.User != nil {
+		password, _ := proxy.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(proxy.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		return err
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("CONNECT %s: %s", address, resp.Status)
+	}
+	if br.Buffered() > 0 {
+		return errors.New("unexpected data after the CONNECT response")
+	}
+	return nil
+}
+
+// SOCKS5 protocol values (RFC 1928, RFC 1929).
+const (
+	socks5Version        = 5
+	socks5NoAuth         = 0
+	socks5PasswordAuth   = 2
+	socks5CommandConnect = 1
+	socks5AddressIPv4    = 1
+	socks5AddressDomain  = 3
+	socks5AddressIPv6    = 4
+)
+
+// socks5Connect asks a SOCKS5 proxy to open a stream from conn to address.
+// Host names are resolved by the proxy.
+func socks5Connect(conn net.Conn, proxy *url.URL, address string) error {
+	host, portText, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.ParseUint(portText, 10, 16)
+	if err != nil {
+		return fmt.Errorf("invalid port %q", portText)
+	}
+
+	methods := []byte{socks5NoAuth}
+	if proxy.User != nil {
+		methods = []byte{socks5PasswordAuth}
+	}
+	if _, err := conn.Write(append([]byte{socks5Version, byte(len(methods))}, methods...)); err != nil {
+		return err
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[0] != socks5Version || reply[1] != methods[0] {
+		return errors.New("socks5: no acceptable authentication method")
+	}
+	if reply[1] == socks5PasswordAuth {
+		username := proxy.User.Username()
+		password, _ := proxy.User.Password()
+		if len(username) > 255 || len(password) > 255 {
+			return errors.New("socks5: credentials too long")
+		}
+		auth := append([]byte{1, byte(len(username))}, username...)
+		auth = append(append(auth, byte(len(password))), password...)
+		if _, err := conn.Write(auth); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return err
+		}
+		if reply[1] != 0 {
+			return errors.New("socks5: authentication failed")
+		}
+	}
+
+	request := []byte{socks5Version, socks5CommandConnect, 0}
+	if ip := net.ParseIP(host); ip == nil {
+		if len(host) > 255 {
+			return errors.New("socks5: host name too long")
+		}
+		request = append(append(request, socks5AddressDomain, byte(len(host))), host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		request = append(append(request, socks5AddressIPv4), ip4...)
+	} else {
+		request = append(append(request, socks5AddressIPv6), ip...)
+	}
+	request = binary.BigEndian.AppendUint16(request, uint16(port))
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+
+	// The reply echoes a bound address, whose length depends on its type.
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+	if header[1] != 0 {
+		return fmt.Errorf("socks5: connect %s failed with code %d", address, header[1])
+	}
+	var skip int
+	switch header[3] {
+	case socks5AddressIPv4:
+		skip = net.IPv4len
+	case socks5AddressIPv6:
+		skip = net.IPv6len
+	case socks5AddressDomain:
+		if _, err := io.ReadFull(conn, header[:1]); err != nil {
+			return err
+		}
+		skip = int(header[0])
+	default:
+		return fmt.Errorf("socks5: unknown address type %d", header[3])
+	}
+	_, err = io.ReadFull(conn, make([]byte, skip+2))
+	return err
+}
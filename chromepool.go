@@ -0,0 +1,362 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// chromePool spreads renders over the configured Chrome endpoints
+// (CHROME_ENDPOINT may list several) and fails over between them. A
+// background prober takes endpoints that stop answering out of rotation and
+// re-admits them once they recover.
+//
+// Each endpoint can be capped to a number of concurrent renders
+// (CHROME_MAX_SESSIONS), independently of MAX_CONCURRENT_RENDERS, so small
+// and large browsers share the load by their size.
+type chromePool struct {
+	members []*chromeMember
+	// slotWait bounds the wait for a free session slot.
+	slotWait time.Duration
+
+	mu   sync.Mutex
+	next int
+	// released is closed, and replaced, whenever a session slot frees up.
+	released chan struct{}
+}
+
+// chromeMember is one endpoint of the pool and its last known health.
+type chromeMember struct {
+	resolver *chromeResolver
+	label    string
+	// maxSessions caps the concurrent renders on the endpoint (0 = no cap).
+	maxSessions int
+
+	healthy   bool
+	lastError string
+	lastProbe time.Time
+	active    int
+}
+
+// chromeEndpointStatus is the health of one endpoint in the health report.
+type chromeEndpointStatus struct {
+	Endpoint  string     `json:"endpoint"`
+	Healthy   bool       `json:"healthy"`
+	Error     string     `json:"error,omitempty"`
+	LastProbe *time.Time `json:"last_probe,omitempty"`
+	// Sessions are the renders running on the endpoint, MaxSessions its cap;
+	// both are only counted when some endpoint has a cap.
+	Sessions    *int `json:"sessions,omitempty"`
+	MaxSessions int  `json:"max_sessions,omitempty"`
+}
+
+// newChromePool has one member per CHROME_ENDPOINT entry, or a single one
+// for CHROME_WS. Every member starts in rotation.
+func newChromePool(cfg config) *chromePool {
+	if cfg.ChromeWS != "" || len(cfg.ChromeEndpoints) == 0 {
+		resolver := newChromeResolver(cfg)
+		label := resolver.endpoint
+		if resolver.ws != "" {
+			label = resolver.ws
+		}
+		member := &chromeMember{resolver: resolver, label: label, healthy: true, maxSessions: cfg.chromeMaxSessions(label)}
+		return &chromePool{members: []*chromeMember{member}, slotWait: cfg.RenderQueueTimeout, released: make(chan struct{})}
+	}
+	pool := &chromePool{slotWait: cfg.RenderQueueTimeout, released: make(chan struct{})}
+	for _, endpoint := range cfg.ChromeEndpoints {
+		resolver := newChromeResolver(cfg)
+		resolver.endpoint = endpoint
+		pool.members = append(pool.members, &chromeMember{resolver: resolver, label: endpoint, healthy: true, maxSessions: cfg.chromeMaxSessions(endpoint)})
+	}
+	return pool
+}
+
+// chromeMaxSessions returns the session cap of endpoint: its entry in
+// CHROME_ENDPOINT_MAX_SESSIONS, or CHROME_MAX_SESSIONS.
+func (c config) chromeMaxSessions(endpoint string) int {
+	if limit, ok := c.ChromeEndpointMaxSessions[endpoint]; ok {
+		return limit
+	}
+	return c.ChromeMaxSessions
+}
+
+// capped reports whether an endpoint has a session cap.
+func (p *chromePool) capped() bool {
+	for _, member := range p.members {
+		if member.maxSessions > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// acquire picks the next endpoint in rotation with a free session slot and
+// returns its websocket URL and the function that frees the slot. When every
+// endpoint is at its cap it waits up to slotWait for a slot.
+func (p *chromePool) acquire(ctx context.Context) (string, func(), error) {
+	var timeout <-chan time.Time
+	if p.slotWait > 0 {
+		timer := time.NewTimer(p.slotWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	for {
+		// Taken before the scan, so a slot freed during it is not missed.
+		p.mu.Lock()
+		released := p.released
+		p.mu.Unlock()
+
+		var lastErr error
+		for _, member := range p.candidates() {
+			if !p.reserve(member) {
+				continue
+			}
+			ws, err := member.resolver.wsURL(ctx)
+			if err == nil {
+				if !member.healthy {
+					p.mark(member, nil)
+				}
+				return ws, func() { p.release(member) }, nil
+			}
+			p.release(member)
+			lastErr = err
+			if ctx.Err() != nil {
+				return "", nil, err
+			}
+			p.mark(member, err)
+		}
+		if lastErr != nil {
+			return "", nil, lastErr
+		}
+		select {
+		case <-released:
+		case <-timeout:
+			return "", nil, &queueError{reason: "chrome endpoints busy", retryAfter: time.Second}
+		case <-ctx.Done():
+			return "", nil, ctx.Err()
+		}
+	}
+}
+
+// reserve takes a session slot of member, if it has one free.
+func (p *chromePool) reserve(member *chromeMember) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if member.maxSessions > 0 && member.active >= member.maxSessions {
+		return false
+	}
+	member.active++
+	return true
+}
+
+// release frees a session slot of member and wakes up the waiting renders.
+func (p *chromePool) release(member *chromeMember) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	member.active--
+	close(p.released)
+	p.released = make(chan struct{})
+}
+
+// sessionRenderer runs each render on an endpoint with a free session slot
+// (CHROME_MAX_SESSIONS), re-resolving the websocket URL since the endpoint
+// wsURL came from may be at its cap. Without caps it returns next.
+func sessionRenderer(pool *chromePool, next pdfRenderer) pdfRenderer {
+	if pool == nil || !pool.capped() {
+		return next
+	}
+	return func(ctx context.Context, wsURL, html string, wait time.Duration, options pdfOptions) ([]byte, time.Duration, error) {
+		wsURL, release, err := pool.acquire(ctx)
+		if err != nil {
+			return nil, 0, err
+		}
+		defer release()
+		return next(ctx, wsURL, html, wait, options)
+	}
+}
+
+// candidates returns the members to try, in order: the healthy ones
+// round-robin, then the unhealthy ones as a last resort (the prober may not
+// have noticed a recovery yet).
+func (p *chromePool) candidates() []*chromeMember {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var healthy, unhealthy []*chromeMember
+	for i := range p.members {
+		member := p.members[(p.next+i)%len(p.members)]
+		if member.healthy {
+			healthy = append(healthy, member)
+		} else {
+			unhealthy = append(unhealthy, member)
+		}
+	}
+	p.next = (p.next + 1) % len(p.members)
+	return append(healthy, unhealthy...)
+}
+
+// mark records the outcome of a probe or discovery of member, logging when
+// it leaves or rejoins the rotation.
+func (p *chromePool) mark(member *chromeMember, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	member.lastProbe = time.Now()
+	switch {
+	case err == nil && !member.healthy:
+		Infof("chrome %s: back in rotation", member.label)
+		member.healthy, member.lastError = true, ""
+	case err == nil:
+		member.lastError = ""
+	case member.healthy:
+		Warnf("chrome %s: out of rotation: %v", member.label, err)
+		errorReports.report(context.Background(), errorKindChrome, err, map[string]string{"endpoint": member.label})
+		member.healthy, member.lastError = false, err.Error()
+		member.resolver.setCachedWS("")
+	default:
+		member.lastError = err.Error()
+	}
+}
+
+// wsURL returns the websocket URL of the next endpoint in rotation, failing
+// over to the others when discovery fails.
+func (p *chromePool) wsURL(ctx context.Context) (string, error) {
+	var lastErr error
+	for _, member := range p.candidates() {
+		ws, err := member.resolver.wsURL(ctx)
+		if err == nil {
+			if !member.healthy {
+				p.mark(member, nil)
+			}
+			return ws, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+		p.mark(member, err)
+	}
+	return "", lastErr
+}
+
+// checkChrome succeeds when at least one endpoint answers.
+func (p *chromePool) checkChrome(ctx context.Context) error {
+	var lastErr error
+	for _, member := range p.candidates() {
+		err := member.resolver.checkChrome(ctx)
+		if ctx.Err() == nil {
+			p.mark(member, err)
+		}
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+	}
+	return lastErr
+}
+
+// status reports the first endpoint that answers and, with several
+// endpoints, the health of each one as last probed.
+func (p *chromePool) status(ctx context.Context) chromeStatus {
+	var status chromeStatus
+	for i, member := range p.candidates() {
+		current := member.resolver.status(ctx)
+		if i == 0 || current.Reachable && !status.Reachable {
+			status = current
+		}
+		if status.Reachable {
+			break
+		}
+	}
+	if len(p.members) > 1 {
+		capped := p.capped()
+		p.mu.Lock()
+		for _, member := range p.members {
+			endpoint := chromeEndpointStatus{Endpoint: member.label, Healthy: member.healthy, Error: member.lastError,
+				MaxSessions: member.maxSessions}
+			if capped {
+				sessions := member.active
+				endpoint.Sessions = &sessions
+			}
+			if !member.lastProbe.IsZero() {
+				lastProbe := member.lastProbe.UTC()
+				endpoint.LastProbe = &lastProbe
+			}
+			status.Endpoints = append(status.Endpoints, endpoint)
+		}
+		p.mu.Unlock()
+	}
+	return status
+}
+
+// invalidate drops the cached websocket URLs (e.g. after a managed Chrome
+// restart).
+func (p *chromePool) invalidate() {
+	for _, member := range p.members {
+		member.resolver.setCachedWS("")
+	}
+}
+
+// reset drops the cached websocket URLs and puts every endpoint back in
+// rotation, so the next renders rediscover Chrome from scratch.
+func (p *chromePool) reset() {
+	p.invalidate()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, member := range p.members {
+		member.healthy, member.lastError = true, ""
+	}
+	p.next = 0
+}
+
+// healthy returns the number of endpoints in rotation.
+func (p *chromePool) healthy() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	healthy := 0
+	for _, member := range p.members {
+		if member.healthy {
+			healthy++
+		}
+	}
+	return healthy
+}
+
+// probe checks every endpoint once.
+func (p *chromePool) probe(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, member := range p.members {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			probeCtx, cancel := context.WithTimeout(ctx, defaultChromeClientTimeout)
+			defer cancel()
+			err := member.resolver.checkChrome(probeCtx)
+			if ctx.Err() == nil {
+				p.mark(member, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// runProber probes the endpoints every interval until ctx is done. A zero
+// interval disables probing.
+func (p *chromePool) runProber(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.probe(ctx)
+		}
+	}
+}
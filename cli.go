@@ -0,0 +1,221 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The one-shot renderer runs as "pdfrest render" or, through a link named
+// pdfrest-cli to the binary, as "pdfrest-cli".
+const (
+	cliCommand = "render"
+	cliName    = "pdfrest-cli"
+)
+
+// isCLIInvocation reports whether argv0 is the pdfrest-cli link.
+func isCLIInvocation(argv0 string) bool {
+	return strings.TrimSuffix(filepath.Base(argv0), ".exe") == cliName
+}
+
+// optionFlags collects repeated -opt key=value flags.
+type optionFlags url.Values
+
+func (o optionFlags) String() string {
+	return url.Values(o).Encode()
+}
+
+func (o optionFlags) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("expected key=value, got %q", value)
+	}
+	url.Values(o).Add(key, val)
+	return nil
+}
+
+// runCLI renders one document: HTML from a file (or stdin, when the file is
+// "-" or missing) to a PDF file (or stdout), with the render options of the
+// API given as -opt key=value. With -server it posts to a running service;
+// otherwise it renders through the Chrome endpoint of the configuration
+// (CHROME_ENDPOINT, or -chrome). It returns the exit status.
+func runCLI(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet(cliName, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	options := optionFlags{}
+	out := fs.String("out", "-", "PDF `file` to write (- for stdout)")
+	server := fs.String("server", "", "`URL` of a pdfrest service to render with, instead of Chrome")
+	apiKey := fs.String("api-key", "", "API `key` sent to -server in X-API-Key")
+	chrome := fs.String("chrome", "", "Chrome debugging `endpoint` (overrides CHROME_ENDPOINT)")
+	timeout := fs.Duration("timeout", 0, "render timeout (default REQUEST_TIMEOUT)")
+	fs.Var(options, "opt", "render option as `key=value`, as in the API query string (repeatable)")
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: %s [flags] [input.html]\n\n"+
+			"Renders the HTML in input.html (or stdin) to PDF.\n\n", cliName)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return 2
+	}
+
+	html, err := readCLIInput(fs.Arg(0), stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", cliName, err)
+		return 1
+	}
+	values := url.Values(options)
+
+	var pdf []byte
+	if *server != "" {
+		pdf, err = renderWithServer(*server, *apiKey, *timeout, html, values)
+	} else {
+		pdf, err = renderWithChrome(*chrome, *timeout, html, values)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", cliName, err)
+		return 1
+	}
+
+	if *out == "-" {
+		_, err = stdout.Write(pdf)
+	} else {
+		err = os.WriteFile(*out, pdf, 0o644)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: write pdf: %v\n", cliName, err)
+		return 1
+	}
+	return 0
+}
+
+// readCLIInput reads the HTML of path, or stdin for "" and "-".
+func readCLIInput(path string, stdin io.Reader) ([]byte, error) {
+	var (
+		html []byte
+		err  error
+	)
+	if path == "" || path == "-" {
+		html, err = io.ReadAll(stdin)
+	} else {
+		html, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read html: %w", err)
+	}
+	if len(bytes.TrimSpace(html)) == 0 {
+		return nil, errors.New("empty html")
+	}
+	return html, nil
+}
+
+// renderWithServer posts html to the PDF endpoint of server.
+func renderWithServer(server, apiKey string, timeout time.Duration, html []byte, values url.Values) ([]byte, error) {
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	endpoint := strings.TrimSuffix(server, "/") + pathPDF
+	if len(values) > 0 {
+		endpoint += "?" + values.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(html))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "text/html; charset=utf-8")
+	if apiKey != "" {
+		req.Header.Set(headerAPIKey, apiKey)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/pdf") {
+		return nil, fmt.Errorf("expected a PDF, got %s (use the API for output=s3 and deliver)", resp.Header.Get("Content-Type"))
+	}
+	return body, nil
+}
+
+// renderWithChrome renders html with the Chrome endpoint of the
+// configuration, or the render backend the options select, through the same
+// pre- and post-processing stages as the service. Results the service sends
+// elsewhere (output=s3, deliver) are not supported.
+func renderWithChrome(chrome string, timeout time.Duration, html []byte, values url.Values) ([]byte, error) {
+	if chrome != "" {
+		if err := os.Setenv("CHROME_ENDPOINT", chrome); err != nil {
+			return nil, err
+		}
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	configureLogging(cfg)
+	if timeout <= 0 {
+		timeout = cfg.RequestTimeout
+	}
+
+	options, err := parsePDFOptions(mergeOptions(cfg.DefaultOptions, values))
+	if err != nil {
+		return nil, err
+	}
+	if options.Output != "" || len(options.Deliver) > 0 {
+		return nil, fmt.Errorf("output and deliver need -server")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	applyPolicyDefaults(ctx, cfg, &options)
+
+	pageRenderer := newChromeRenderer(cfg)
+	if pageRenderer.subresources, err = newSubresourcePolicy(cfg); err != nil {
+		return nil, err
+	}
+	renderer := outputLimitRenderer(cfg.MaxPDFBytes, postProcessRenderer(newPostProcessPipeline(cfg), preProcessRenderer(newPreProcessPipeline(cfg),
+		newBackendRenderer(cfg, emptyPDFRetryRenderer(cfg.EmptyPDFRetries, pageRenderer.render)))))
+
+	if err := configureChromeConn(cfg); err != nil {
+		return nil, err
+	}
+	var resolver wsResolver = newChromePool(cfg)
+	if len(cfg.BackendCommands) > 0 {
+		resolver = fallbackResolver{resolver}
+	}
+	wsURL, err := resolver.wsURL(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("chrome unavailable: %w", err)
+	}
+	pdf, _, err := renderer(ctx, wsURL, string(html), cfg.PDFWait, options)
+	if err != nil {
+		return nil, err
+	}
+	return pdf, nil
+}
@@ -4,55 +4,582 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 )
 
-func loadConfig() config {
+// loadConfig reads the configuration from the environment. Invalid values are
+// errors rather than falling back to the defaults, so a typo does not go
+// unnoticed: the returned error lists every problem found.
+func loadConfig() (config, error) {
+	env := &envReader{}
 	cfg := config{
-		Addr:           getEnv("ADDR", ":8080"),
-		ChromeEndpoint: getEnv("CHROME_ENDPOINT", "http://127.0.0.1:9222"),
+		Addr:           env.value("ADDR", ":8080"),
+		ChromeEndpoint: env.value("CHROME_ENDPOINT", "http://127.0.0.1:9222"),
 		ChromeWS:       os.Getenv("CHROME_WS"),
-		RequestTimeout: getEnvDuration("REQUEST_TIMEOUT", 30*time.Second),
-		MaxBodyBytes:   getEnvInt64("MAX_BODY_BYTES", 5*1024*1024),
-		PDFWait:        getEnvDuration("PDF_WAIT", 0),
+
+		ChromeProbeInterval: env.duration("CHROME_PROBE_INTERVAL", defaultChromeProbeInterval),
+		ChromeWSCacheTTL:    env.duration("CHROME_WS_CACHE_TTL", defaultWSTTL),
+
+		ChromeToken:      os.Getenv("CHROME_TOKEN"),
+		ChromeAuthHeader: env.value("CHROME_AUTH_HEADER", "Authorization"),
+		ChromeProxy:      os.Getenv("CHROME_PROXY"),
+
+		ChromeTLSCAFile:             os.Getenv("CHROME_TLS_CA_FILE"),
+		ChromeTLSCertFile:           os.Getenv("CHROME_TLS_CERT_FILE"),
+		ChromeTLSKeyFile:            os.Getenv("CHROME_TLS_KEY_FILE"),
+		ChromeTLSInsecureSkipVerify: env.bool("CHROME_TLS_INSECURE_SKIP_VERIFY", false),
+
+		CDPKeepaliveInterval: env.duration("CDP_KEEPALIVE_INTERVAL", defaultCDPKeepaliveInterval),
+		CDPKeepaliveTimeout:  env.duration("CDP_KEEPALIVE_TIMEOUT", defaultCDPKeepaliveTimeout),
+		CDPCompression:       env.bool("CDP_COMPRESSION", true),
+		CDPMaxFrameBytes:     env.int("CDP_MAX_FRAME_BYTES", defaultCDPMaxFrameBytes),
+		CDPMaxMessageBytes:   env.int("CDP_MAX_MESSAGE_BYTES", defaultCDPMaxMessageBytes),
+		CDPSlowCall:          env.duration("CDP_SLOW_CALL", defaultCDPSlowCall),
+
+		ChromeMaxSessions: env.int("CHROME_MAX_SESSIONS", 0),
+
+		ChromeIsolateContexts: env.bool("CHROME_ISOLATE_CONTEXTS", true),
+		ChromeOrphanTargetAge: env.duration("CHROME_ORPHAN_TARGET_AGE", defaultChromeOrphanTargetAge),
+
+		ChromeGrantPermissions: env.list("CHROME_GRANT_PERMISSIONS"),
+
+		ChromeMode:         env.value("CHROME_MODE", chromeModeRemote),
+		ChromePath:         env.value("CHROME_PATH", "chromium"),
+		ChromeArgs:         strings.Fields(os.Getenv("CHROME_ARGS")),
+		ChromeDebugPort:    env.int("CHROME_DEBUG_PORT", defaultChromeDebugPort),
+		ChromeUserDataDir:  env.value("CHROME_USER_DATA_DIR", filepath.Join(os.TempDir(), "pdfrest-chrome")),
+		ChromeCgroup:       env.value("CHROME_CGROUP", defaultChromeCgroup),
+		ChromeMemoryLimit:  env.int64("CHROME_MEMORY_LIMIT", 0),
+		ChromeCPULimit:     env.float("CHROME_CPU_LIMIT", 0),
+		ChromeMaxProcesses: env.int("CHROME_MAX_PROCESSES", 0),
+		ChromeMaxOpenFiles: env.int64("CHROME_MAX_OPEN_FILES", 0),
+
+		ChromeUserNamespace: env.bool("CHROME_USER_NAMESPACE", false),
+		ChromeReadOnlyFS:    env.bool("CHROME_READ_ONLY_FS", false),
+		ChromeWritablePaths: env.list("CHROME_WRITABLE_PATHS"),
+		ChromeSeccompFilter: os.Getenv("CHROME_SECCOMP_FILTER"),
+
+		ChromeRecycleRenders: env.int("CHROME_RECYCLE_RENDERS", 0),
+		ChromeRecycleAfter:   env.duration("CHROME_RECYCLE_AFTER", 0),
+
+		RequestTimeout: env.duration("REQUEST_TIMEOUT", 30*time.Second),
+		MaxBodyBytes:   env.int64("MAX_BODY_BYTES", 5*1024*1024),
+		MaxPDFBytes:    env.int64("MAX_PDF_BYTES", 0),
+		PDFWait:        env.duration("PDF_WAIT", 0),
+
+		MaxConcurrentRenders: env.int("MAX_CONCURRENT_RENDERS", 0),
+		MaxRenderQueue:       env.int("MAX_RENDER_QUEUE", defaultMaxRenderQueue),
+		RenderQueueTimeout:   env.duration("RENDER_QUEUE_TIMEOUT", defaultRenderQueueTimeout),
+
+		ShutdownDrainTimeout: env.duration("SHUTDOWN_DRAIN_TIMEOUT", defaultShutdownDrainTimeout),
+
+		TLSCertFile:     os.Getenv("TLS_CERT_FILE"),
+		TLSKeyFile:      os.Getenv("TLS_KEY_FILE"),
+		TLSClientCAFile: os.Getenv("TLS_CLIENT_CA_FILE"),
+
+		PoliciesFile:        os.Getenv("POLICIES_FILE"),
+		PostProcess:         parseStageList(os.Getenv("POST_PROCESS")),
+		PostProcessCommands: map[string]string{},
+		PreProcess:          parseStageList(os.Getenv("PRE_PROCESS")),
+
+		AssetAllowedHosts: env.list("ASSET_ALLOWED_HOSTS"),
+		AssetMaxBytes:     env.int64("ASSET_MAX_BYTES", defaultAssetMaxBytes),
+		AssetFetchTimeout: env.duration("ASSET_FETCH_TIMEOUT", defaultAssetFetchTimeout),
+
+		PDFAICCProfile: os.Getenv("PDFA_ICC_PROFILE"),
+
+		AdminToken: os.Getenv("ADMIN_TOKEN"),
+
+		AuthProvider:         os.Getenv("AUTH_PROVIDER"),
+		AuthStaticKeys:       env.list("AUTH_STATIC_KEYS"),
+		AuthJWTSecret:        os.Getenv("AUTH_JWT_SECRET"),
+		AuthJWTPublicKeyFile: os.Getenv("AUTH_JWT_PUBLIC_KEY_FILE"),
+		AuthJWTIssuer:        os.Getenv("AUTH_JWT_ISSUER"),
+		AuthJWTAudience:      os.Getenv("AUTH_JWT_AUDIENCE"),
+		AuthHMACKeys:         env.list("AUTH_HMAC_KEYS"),
+		AuthHMACMaxSkew:      env.duration("AUTH_HMAC_MAX_SKEW", defaultAuthHMACMaxSkew),
+		AuthWebhookURL:       os.Getenv("AUTH_WEBHOOK_URL"),
+		AuthWebhookTimeout:   env.duration("AUTH_WEBHOOK_TIMEOUT", defaultAuthWebhookTimeout),
+
+		OPAURL:      os.Getenv("OPA_URL"),
+		OPATimeout:  env.duration("OPA_TIMEOUT", defaultOPATimeout),
+		OPAFailOpen: env.bool("OPA_FAIL_OPEN", false),
+
+		ArchiveDir:       os.Getenv("RENDER_ARCHIVE_DIR"),
+		ArchiveRetention: env.duration("RENDER_ARCHIVE_RETENTION", defaultArchiveRetention),
+
+		AuditLog:     os.Getenv("AUDIT_LOG"),
+		AuditTimeout: env.duration("AUDIT_TIMEOUT", defaultAuditTimeout),
+
+		ErrorReportDSN:         os.Getenv("ERROR_REPORT_DSN"),
+		ErrorReportEnvironment: os.Getenv("ERROR_REPORT_ENVIRONMENT"),
+		ErrorReportTimeout:     env.duration("ERROR_REPORT_TIMEOUT", defaultErrorReportTimeout),
+
+		GoldenFile: os.Getenv("GOLDEN_FILE"),
+
+		DedupRenders: env.bool("DEDUP_RENDERS", true),
+
+		JobRetention: env.duration("JOB_RETENTION", defaultJobRetention),
+		MaxJobs:      env.int("MAX_JOBS", defaultMaxJobs),
+
+		ArtifactDir:      os.Getenv("ARTIFACT_DIR"),
+		ArtifactTTL:      env.duration("ARTIFACT_TTL", defaultArtifactTTL),
+		ArtifactMaxBytes: env.int64("ARTIFACT_MAX_BYTES", defaultArtifactMaxBytes),
+
+		S3Endpoint:        os.Getenv("S3_ENDPOINT"),
+		S3Region:          env.value("S3_REGION", "us-east-1"),
+		S3Bucket:          os.Getenv("S3_BUCKET"),
+		S3AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+		S3SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+		S3SessionToken:    os.Getenv("S3_SESSION_TOKEN"),
+		S3PathStyle:       env.bool("S3_PATH_STYLE", false),
+		S3KeyTemplate:     env.value("S3_KEY_TEMPLATE", defaultS3KeyTemplate),
+		S3ContentType:     env.value("S3_CONTENT_TYPE", "application/pdf"),
+		S3SSE:             os.Getenv("S3_SSE"),
+		S3SSEKMSKeyID:     os.Getenv("S3_SSE_KMS_KEY_ID"),
+		S3CacheControl:    os.Getenv("S3_CACHE_CONTROL"),
+
+		DownloadURLSecret: os.Getenv("DOWNLOAD_URL_SECRET"),
+		DownloadURLExpiry: env.duration("DOWNLOAD_URL_EXPIRY", defaultDownloadURLExpiry),
+
+		RenderLinkExpiry:    env.duration("RENDER_LINK_EXPIRY", defaultRenderLinkExpiry),
+		RenderLinkMaxExpiry: env.duration("RENDER_LINK_MAX_EXPIRY", defaultRenderLinkMaxExpiry),
+
+		WebhookAllowedHosts: env.list("WEBHOOK_ALLOWED_HOSTS"),
+		WebhookSecret:       os.Getenv("WEBHOOK_SECRET"),
+		WebhookTimeout:      env.duration("WEBHOOK_TIMEOUT", defaultWebhookTimeout),
+		WebhookAttempts:     env.int("WEBHOOK_ATTEMPTS", defaultWebhookAttempts),
+		WebhookRetryBackoff: env.duration("WEBHOOK_RETRY_BACKOFF", defaultSinkRetryBackoff),
+
+		S3Timeout:      env.duration("S3_TIMEOUT", defaultS3Timeout),
+		S3Attempts:     env.int("S3_ATTEMPTS", defaultS3Attempts),
+		S3RetryBackoff: env.duration("S3_RETRY_BACKOFF", defaultSinkRetryBackoff),
+
+		TemplateDir:       os.Getenv("TEMPLATE_DIR"),
+		TemplateRetention: env.duration("TEMPLATE_RETENTION", defaultTemplateRetention),
+
+		PDFDecodeMode:   env.value("PDF_DECODE_MODE", decodeModeStream),
+		PDFTransferMode: env.value("PDF_TRANSFER_MODE", transferModeStream),
+
+		URLAllowedHosts:    env.list("URL_ALLOWED_HOSTS"),
+		PDFGetCacheControl: env.value("PDF_GET_CACHE_CONTROL", defaultPDFGetCacheControl),
+		PDFGetETagWindow:   env.duration("PDF_GET_ETAG_WINDOW", 0),
+		BatchMaxItems:      env.int("BATCH_MAX_ITEMS", defaultBatchMaxItems),
+		BatchConcurrency:   env.int("BATCH_CONCURRENCY", defaultBatchConcurrency),
+		BatchTimeout:       env.duration("BATCH_TIMEOUT", defaultBatchTimeout),
+
+		BlockRemoteRequests:  env.bool("BLOCK_REMOTE_REQUESTS", false),
+		RemoteAllowedHosts:   env.list("REMOTE_ALLOWED_HOSTS"),
+		SubresourceAllow:     env.list("SUBRESOURCE_ALLOW"),
+		SubresourceDeny:      env.list("SUBRESOURCE_DENY"),
+		LogPageConsole:       env.bool("LOG_PAGE_CONSOLE", true),
+		LogLevel:             env.value("LOG_LEVEL", "info"),
+		LogFormat:            strings.ToLower(env.value("LOG_FORMAT", logFormatJSON)),
+		CORSAllowedOrigins:   env.list("CORS_ALLOWED_ORIGINS"),
+		CORSAllowedMethods:   env.list("CORS_ALLOWED_METHODS"),
+		CORSAllowedHeaders:   env.list("CORS_ALLOWED_HEADERS"),
+		CORSExposedHeaders:   env.list("CORS_EXPOSED_HEADERS"),
+		CORSMaxAge:           env.duration("CORS_MAX_AGE", defaultCORSMaxAge),
+		CORSAllowCredentials: env.bool("CORS_ALLOW_CREDENTIALS", false),
+		AccessLogFormat:      strings.ToLower(env.value("ACCESS_LOG_FORMAT", accessLogJSON)),
+		AccessLogSkipProbes:  env.bool("ACCESS_LOG_SKIP_PROBES", false),
+
+		EmptyPDFRetries: env.int("EMPTY_PDF_RETRIES", 0),
+
+		RenderBackend:         strings.ToLower(env.value("RENDER_BACKEND", backendChrome)),
+		RenderFallbackBackend: strings.ToLower(os.Getenv("RENDER_FALLBACK_BACKEND")),
+		BackendCommands:       loadBackendCommands(os.Environ()),
 	}
 
-	Infof("configuration loaded: %+v", cfg)
+	for _, stage := range postProcessStages {
+		if command := os.Getenv("POST_PROCESS_" + strings.ToUpper(stage) + "_CMD"); command != "" {
+			cfg.PostProcessCommands[stage] = command
+		}
+	}
+
+	if cfg.ChromeMode == chromeModeLaunch {
+		cfg.ChromeMode = chromeModeManaged
+	}
+	switch cfg.ChromeMode {
+	case chromeModeManaged:
+		// The managed browser only listens on loopback.
+		cfg.ChromeEndpoint = fmt.Sprintf("http://127.0.0.1:%d", cfg.ChromeDebugPort)
+		cfg.ChromeWS = ""
+	case chromeModeRemote:
+		if cfg.chromeLimits() != (sandboxLimits{}) || cfg.chromeHardening().enabled() {
+			Warnf("CHROME_* sandbox options only apply with CHROME_MODE=%s", chromeModeManaged)
+		}
+		if cfg.ChromeRecycleRenders > 0 || cfg.ChromeRecycleAfter > 0 {
+			Warnf("CHROME_RECYCLE_* options only apply with CHROME_MODE=%s", chromeModeManaged)
+		}
+	default:
+		env.fail("invalid CHROME_MODE %q: expected %s or %s", cfg.ChromeMode, chromeModeRemote, chromeModeManaged)
+	}
+	cfg.SocketMode = defaultSocketMode
+	if value := os.Getenv("SOCKET_MODE"); value != "" {
+		mode, err := strconv.ParseUint(value, 8, 32)
+		if err != nil || mode > 0o777 {
+			env.fail("invalid SOCKET_MODE %q: expected octal permissions such as 0660", value)
+		}
+		cfg.SocketMode = os.FileMode(mode)
+	}
+	if strings.HasPrefix(cfg.Addr, unixAddrPrefix) && socketPath(cfg.Addr) == "" {
+		env.fail("invalid ADDR %q: expected unix:///path/to/socket", cfg.Addr)
+	}
+	if cfg.ChromeDebugPort < 1 || cfg.ChromeDebugPort > 65535 {
+		env.fail("invalid CHROME_DEBUG_PORT %d: expected a port between 1 and 65535", cfg.ChromeDebugPort)
+	}
+	for _, endpoint := range strings.Split(cfg.ChromeEndpoint, ",") {
+		if endpoint = strings.TrimSpace(endpoint); endpoint != "" {
+			if err := checkChromeURL("CHROME_ENDPOINT", endpoint, "http", "https"); err != nil {
+				env.errs = append(env.errs, err)
+			}
+			cfg.ChromeEndpoints = append(cfg.ChromeEndpoints, endpoint)
+		}
+	}
+	if len(cfg.ChromeEndpoints) > 0 {
+		cfg.ChromeEndpoint = cfg.ChromeEndpoints[0]
+	}
+	if cfg.ChromeWS != "" {
+		if err := checkChromeURL("CHROME_WS", cfg.ChromeWS, "ws", "wss"); err != nil {
+			env.errs = append(env.errs, err)
+		}
+	}
+	if cfg.ChromeProxy != "" {
+		if err := checkChromeURL("CHROME_PROXY", cfg.ChromeProxy, "http", "https", "socks5", "socks5h"); err != nil {
+			env.errs = append(env.errs, err)
+		}
+	}
+	if err := validateNavigationHeaders(map[string]string{cfg.ChromeAuthHeader: cfg.ChromeToken}); err != nil {
+		env.fail("invalid CHROME_AUTH_HEADER or CHROME_TOKEN: %v", err)
+	}
+	for _, entry := range env.list("CHROME_ENDPOINT_MAX_SESSIONS") {
+		// Endpoints contain colons, so the limit follows the last '='.
+		separator := strings.LastIndex(entry, "=")
+		limit, err := strconv.Atoi(entry[separator+1:])
+		if separator <= 0 || err != nil || limit < 0 {
+			env.fail("invalid CHROME_ENDPOINT_MAX_SESSIONS entry %q: expected endpoint=N", entry)
+			continue
+		}
+		if cfg.ChromeEndpointMaxSessions == nil {
+			cfg.ChromeEndpointMaxSessions = map[string]int{}
+		}
+		cfg.ChromeEndpointMaxSessions[entry[:separator]] = limit
+	}
+
+	if isAuditURL(cfg.AuditLog) {
+		if parsed, err := url.Parse(cfg.AuditLog); err != nil || parsed.Host == "" {
+			env.fail("invalid AUDIT_LOG %q: expected a file path or an http(s) URL", cfg.AuditLog)
+		}
+	}
+
+	if cfg.ErrorReportDSN != "" {
+		if _, _, err := parseErrorReportDSN(cfg.ErrorReportDSN); err != nil {
+			env.fail("invalid ERROR_REPORT_DSN: %v", err)
+		}
+	}
+
+	for _, origin := range cfg.CORSAllowedOrigins {
+		if err := checkCORSOrigin(origin); err != nil {
+			env.fail("invalid CORS_ALLOWED_ORIGINS entry %q: %v", origin, err)
+		} else if origin == "*" && cfg.CORSAllowCredentials {
+			env.fail("invalid CORS_ALLOWED_ORIGINS: * cannot be combined with CORS_ALLOW_CREDENTIALS")
+		}
+	}
 
-	return cfg
+	defaults, err := loadDefaultOptions(os.Environ())
+	if err != nil {
+		env.errs = append(env.errs, err)
+	}
+	cfg.DefaultOptions = defaults
+
+	for _, backend := range []struct{ key, name string }{
+		{"RENDER_BACKEND", cfg.RenderBackend},
+		{"RENDER_FALLBACK_BACKEND", cfg.RenderFallbackBackend},
+	} {
+		if backend.name != "" && backend.name != backendChrome && cfg.BackendCommands[backend.name] == "" {
+			env.fail("invalid %s %q: expected chrome or a backend defined by RENDER_BACKEND_<NAME>_CMD", backend.key, backend.name)
+		}
+	}
+	if cfg.RenderFallbackBackend == backendChrome {
+		env.fail("invalid RENDER_FALLBACK_BACKEND: chrome cannot be its own fallback")
+	}
+
+	for _, name := range cfg.ChromeGrantPermissions {
+		if !containsString(promptPermissions, name) {
+			env.fail("invalid CHROME_GRANT_PERMISSIONS entry %q: expected one of %s", name, strings.Join(promptPermissions, ", "))
+		}
+	}
+
+	// Zero would fail every request, so these are not "disabled" switches.
+	if os.Getenv("REQUEST_TIMEOUT") != "" && cfg.RequestTimeout == 0 {
+		env.fail("invalid REQUEST_TIMEOUT: must be greater than zero")
+	}
+	if os.Getenv("MAX_BODY_BYTES") != "" && cfg.MaxBodyBytes == 0 {
+		env.fail("invalid MAX_BODY_BYTES: must be greater than zero")
+	}
+	if os.Getenv("BATCH_TIMEOUT") != "" && cfg.BatchTimeout == 0 {
+		env.fail("invalid BATCH_TIMEOUT: must be greater than zero")
+	}
+	if os.Getenv("CDP_KEEPALIVE_TIMEOUT") != "" && cfg.CDPKeepaliveTimeout == 0 {
+		env.fail("invalid CDP_KEEPALIVE_TIMEOUT: must be greater than zero")
+	}
+
+	if _, ok := parseLogLevel(cfg.LogLevel); !ok {
+		env.fail("invalid LOG_LEVEL %q: expected debug, info, warning or error", cfg.LogLevel)
+	}
+	if cfg.LogFormat != logFormatJSON && cfg.LogFormat != logFormatText {
+		env.fail("invalid LOG_FORMAT %q: expected %s or %s", cfg.LogFormat, logFormatJSON, logFormatText)
+	}
+	if cfg.AccessLogFormat != accessLogJSON && cfg.AccessLogFormat != accessLogCombined {
+		env.fail("invalid ACCESS_LOG_FORMAT %q: expected %s or %s", cfg.AccessLogFormat, accessLogJSON, accessLogCombined)
+	}
+	if cfg.PDFDecodeMode != decodeModeStream && cfg.PDFDecodeMode != decodeModeString {
+		env.fail("invalid PDF_DECODE_MODE %q: expected %s or %s", cfg.PDFDecodeMode, decodeModeStream, decodeModeString)
+	}
+	if cfg.PDFTransferMode != transferModeStream && cfg.PDFTransferMode != transferModeBase64 {
+		env.fail("invalid PDF_TRANSFER_MODE %q: expected %s or %s", cfg.PDFTransferMode, transferModeStream, transferModeBase64)
+	}
+	if err := env.err(); err != nil {
+		return cfg, err
+	}
+
+	Infof("configuration loaded: %+v", cfg.redacted())
+
+	return cfg, nil
+}
+
+// chromeLimits returns the sandbox limits of managed Chrome.
+func (c config) chromeLimits() sandboxLimits {
+	limits := sandboxLimits{
+		MemoryBytes:  c.ChromeMemoryLimit,
+		CPUs:         c.ChromeCPULimit,
+		MaxProcesses: c.ChromeMaxProcesses,
+	}
+	if c.ChromeMaxOpenFiles > 0 {
+		limits.MaxOpenFiles = uint64(c.ChromeMaxOpenFiles)
+	}
+	return limits
+}
+
+// chromeHardening returns the isolation options of managed Chrome. The
+// profile directory and the temporary directory always stay writable.
+func (c config) chromeHardening() chromeHardening {
+	return chromeHardening{
+		UserNamespace: c.ChromeUserNamespace,
+		ReadOnlyFS:    c.ChromeReadOnlyFS,
+		Writable:      append([]string{c.ChromeUserDataDir, os.TempDir()}, c.ChromeWritablePaths...),
+		SeccompFilter: c.ChromeSeccompFilter,
+	}
+}
+
+// redacted returns a copy of the configuration that is safe to log.
+func (c config) redacted() config {
+	if c.AdminToken != "" {
+		c.AdminToken = "****"
+	}
+	if c.AuthJWTSecret != "" {
+		c.AuthJWTSecret = "****"
+	}
+	if c.S3SecretAccessKey != "" {
+		c.S3SecretAccessKey = "****"
+	}
+	if c.S3SessionToken != "" {
+		c.S3SessionToken = "****"
+	}
+	if c.DownloadURLSecret != "" {
+		c.DownloadURLSecret = "****"
+	}
+	if c.WebhookSecret != "" {
+		c.WebhookSecret = "****"
+	}
+	if c.ErrorReportDSN != "" {
+		c.ErrorReportDSN = "****"
+	}
+	if c.ChromeToken != "" {
+		c.ChromeToken = "****"
+	}
+	// URLs may carry credentials, or a token in their query (browserless
+	// wss://...?token=).
+	c.ChromeProxy = redactURL(c.ChromeProxy)
+	c.ChromeEndpoint = redactURL(c.ChromeEndpoint)
+	c.ChromeWS = redactURL(c.ChromeWS)
+	if c.ChromeEndpoints != nil {
+		endpoints := make([]string, len(c.ChromeEndpoints))
+		for i, endpoint := range c.ChromeEndpoints {
+			endpoints[i] = redactURL(endpoint)
+		}
+		c.ChromeEndpoints = endpoints
+	}
+	c.AuthWebhookURL = redactURL(c.AuthWebhookURL)
+	c.OPAURL = redactURL(c.OPAURL)
+	c.S3Endpoint = redactURL(c.S3Endpoint)
+	if isAuditURL(c.AuditLog) {
+		c.AuditLog = redactURL(c.AuditLog)
+	}
+	c.AuthStaticKeys = redactKeyList(c.AuthStaticKeys)
+	c.AuthHMACKeys = redactKeyList(c.AuthHMACKeys)
+	c.PostProcessCommands = redactCommands(c.PostProcessCommands)
+	c.BackendCommands = redactCommands(c.BackendCommands)
+	return c
+}
+
+// redactCommands keeps the programs of external commands, whose arguments
+// may carry passwords or key paths.
+func redactCommands(commands map[string]string) map[string]string {
+	if commands == nil {
+		return nil
+	}
+	redacted := make(map[string]string, len(commands))
+	for name, command := range commands {
+		if fields := strings.Fields(command); len(fields) > 1 {
+			command = fields[0] + " ****"
+		}
+		redacted[name] = command
+	}
+	return redacted
 }
 
-func getEnv(key, fallback string) string {
+// redactURL masks the user info and the query of a URL, either of which may
+// be a token; other values are returned as they are.
+func redactURL(value string) string {
+	parsed, err := url.Parse(value)
+	if err != nil || parsed.Host == "" {
+		return value
+	}
+	if parsed.User != nil {
+		parsed.User = url.User("****")
+	}
+	if parsed.RawQuery != "" {
+		parsed.RawQuery = "****"
+	}
+	return parsed.String()
+}
+
+// redactKeyList keeps the ids of "id=secret" pairs.
+func redactKeyList(list []string) []string {
+	if list == nil {
+		return nil
+	}
+	redacted := make([]string, len(list))
+	for i, item := range list {
+		id, _, _ := strings.Cut(item, "=")
+		redacted[i] = id + "=****"
+	}
+	return redacted
+}
+
+// envReader reads typed environment variables, collecting the errors of the
+// invalid ones. Numbers and durations cannot be negative.
+type envReader struct {
+	errs []error
+}
+
+func (e *envReader) fail(format string, args ...any) {
+	e.errs = append(e.errs, fmt.Errorf(format, args...))
+}
+
+func (e *envReader) err() error {
+	return errors.Join(e.errs...)
+}
+
+func (e *envReader) value(key, fallback string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
 	}
 	return fallback
 }
 
-func getEnvDuration(key string, fallback time.Duration) time.Duration {
+func (e *envReader) duration(key string, fallback time.Duration) time.Duration {
 	value := os.Getenv(key)
 	if value == "" {
 		return fallback
 	}
 	parsed, err := time.ParseDuration(value)
-	if err != nil {
-		Warnf("invalid %s, using default: %v", key, err)
+	if err != nil || parsed < 0 {
+		e.fail("invalid %s %q: expected a non-negative duration such as 30s or 5m", key, value)
 		return fallback
 	}
 	return parsed
 }
 
-func getEnvInt64(key string, fallback int64) int64 {
+func (e *envReader) int64(key string, fallback int64) int64 {
 	value := os.Getenv(key)
 	if value == "" {
 		return fallback
 	}
 	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || parsed < 0 {
+		e.fail("invalid %s %q: expected a non-negative integer", key, value)
+		return fallback
+	}
+	return parsed
+}
+
+func (e *envReader) int(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 0 {
+		e.fail("invalid %s %q: expected a non-negative integer", key, value)
+		return fallback
+	}
+	return parsed
+}
+
+func (e *envReader) float(key string, fallback float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil || parsed < 0 || math.IsNaN(parsed) || math.IsInf(parsed, 0) {
+		e.fail("invalid %s %q: expected a non-negative number", key, value)
+		return fallback
+	}
+	return parsed
+}
+
+func (e *envReader) bool(key string, fallback bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.ParseBool(value)
 	if err != nil {
-		Warnf("invalid %s, using default: %v", key, err)
+		e.fail("invalid %s %q: expected true or false", key, value)
 		return fallback
 	}
 	return parsed
 }
+
+// list parses a comma-separated list, dropping empty items.
+func (e *envReader) list(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// checkChromeURL checks an entry of CHROME_ENDPOINT (http or https) or
+// CHROME_WS (ws or wss).
+func checkChromeURL(key, raw string, schemes ...string) error {
+	parsed, err := url.Parse(raw)
+	if err != nil || !containsString(schemes, parsed.Scheme) || parsed.Host == "" {
+		return fmt.Errorf("invalid %s %q: expected a %s URL", key, raw, strings.Join(schemes, " or "))
+	}
+	return nil
+}
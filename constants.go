@@ -5,13 +5,36 @@ package main
 
 import (
 	"context"
+	"net/url"
+	"os"
 	"time"
 )
 
 const (
 	// API paths.
-	pathPDF     = "/api/v1/pdf"
-	pathHealthz = "/healthz"
+	pathPDF      = "/api/v1/pdf"
+	pathPDFBatch = "/api/v1/pdf/batch"
+	pathImage    = "/api/v1/image"
+	pathHealthz  = "/healthz"
+	pathLivez    = "/livez"
+	pathReadyz   = "/readyz"
+	pathVersion  = "/api/v1/version"
+	pathStatus   = "/status"
+	pathLoadz    = "/loadz"
+
+	pathTemplates = "/api/v1/templates"
+	pathJobs      = "/api/v1/jobs"
+	pathDownloads = "/api/v1/downloads"
+
+	pathRenderLinks = "/api/v1/render-links"
+
+	pathAdminExport  = "/admin/export"
+	pathAdminImport  = "/admin/import"
+	pathAdminReplay  = "/admin/replay/{id}"
+	pathAdminSupport = "/admin/support-bundle"
+	pathAdminGolden  = "/admin/golden"
+	pathAdminFlush   = "/admin/flush"
+	pathAdminRenders = "/admin/renders"
 
 	// Default server-level timeouts.
 	defaultReadHeaderTimeout = 5 * time.Second
@@ -21,23 +44,382 @@ const (
 	// Shutdown timeout (graceful).
 	defaultShutdownTimeout = 10 * time.Second
 
+	// Longest wait for in-flight renders on shutdown (SHUTDOWN_DRAIN_TIMEOUT).
+	defaultShutdownDrainTimeout = 60 * time.Second
+
 	// Client timeout for the Chrome /json/version endpoint.
 	defaultChromeClientTimeout = 5 * time.Second
 
-	// Cache TTL for Chrome websocket discovery.
+	// Keep-alive pool of the Chrome discovery client.
+	defaultChromeMaxIdleConns    = 4
+	defaultChromeIdleConnTimeout = 90 * time.Second
+
+	// Managed Chrome (CHROME_MODE=managed) defaults.
+	defaultChromeDebugPort           = 9222
+	defaultChromeCgroup              = "/sys/fs/cgroup/pdfrest-chrome"
+	defaultManagedChromeStartTimeout = 30 * time.Second
+	defaultManagedChromeRestartDelay = time.Second
+
+	// Interval of the Chrome endpoint health prober.
+	defaultChromeProbeInterval = 10 * time.Second
+
+	// Orphaned tab sweeper (CHROME_ORPHAN_TARGET_AGE).
+	defaultChromeOrphanTargetAge = 5 * time.Minute
+	defaultChromeSweepInterval   = time.Minute
+
+	// Cache TTL for Chrome websocket discovery (CHROME_WS_CACHE_TTL).
 	defaultWSTTL = 1 * time.Minute
 
+	// Websocket keepalive pings during long CDP calls
+	// (CDP_KEEPALIVE_INTERVAL, CDP_KEEPALIVE_TIMEOUT).
+	defaultCDPKeepaliveInterval = 20 * time.Second
+	defaultCDPKeepaliveTimeout  = 10 * time.Second
+
+	// Largest websocket frame and message read from Chrome
+	// (CDP_MAX_FRAME_BYTES, CDP_MAX_MESSAGE_BYTES): room for the base64
+	// encoding of a large PDF or screenshot.
+	defaultCDPMaxFrameBytes   = 256 << 20
+	defaultCDPMaxMessageBytes = 256 << 20
+
+	// CDP calls logged as slow (CDP_SLOW_CALL).
+	defaultCDPSlowCall = 5 * time.Second
+
+	// Asset inlining defaults.
+	defaultAssetMaxBytes     = 5 * 1024 * 1024
+	defaultAssetFetchTimeout = 10 * time.Second
+	// Redirects followed by an asset fetch, each checked against the
+	// allowed hosts.
+	maxAssetRedirects = 5
+
+	// Render concurrency limiter defaults.
+	defaultMaxRenderQueue     = 100
+	defaultRenderQueueTimeout = 10 * time.Second
+
+	// Largest buffer kept in the buffer pool.
+	maxPooledBufferBytes = 8 * 1024 * 1024
+
+	// Bytes requested per IO.read when the PDF is transferred as a stream.
+	defaultPDFStreamChunkBytes = 512 * 1024
+
+	// Render archive defaults.
+	defaultArchiveRetention     = 7 * 24 * time.Hour
+	defaultArchivePruneInterval = time.Hour
+
+	// Timeout of a record posted to an AUDIT_LOG URL.
+	defaultAuditTimeout = 5 * time.Second
+
+	// Error reporting (ERROR_REPORT_DSN): timeout of an event post, and
+	// events waiting to be sent before new ones are dropped.
+	defaultErrorReportTimeout = 5 * time.Second
+	defaultErrorReportQueue   = 100
+
+	// GET renders may be stored, but are revalidated with their ETag.
+	defaultPDFGetCacheControl = "no-cache"
+
+	// How long browsers may cache a CORS preflight answer.
+	defaultCORSMaxAge = 10 * time.Minute
+
+	// Async render jobs (Prefer: respond-async).
+	defaultJobRetention     = time.Hour
+	defaultMaxJobs          = 100
+	defaultJobPruneInterval = time.Minute
+
+	// Artifact store defaults (ARTIFACT_DIR).
+	defaultArtifactTTL        = time.Hour
+	defaultArtifactMaxBytes   = 1 << 30
+	defaultArtifactGCInterval = time.Minute
+
+	// Validity of signed download URLs.
+	defaultDownloadURLExpiry = 15 * time.Minute
+
+	// Validity of render links (default and longest allowed).
+	defaultRenderLinkExpiry    = 15 * time.Minute
+	defaultRenderLinkMaxExpiry = 24 * time.Hour
+
+	// Render webhook defaults (WEBHOOK_ALLOWED_HOSTS).
+	defaultWebhookTimeout  = 10 * time.Second
+	defaultWebhookAttempts = 3
+
+	// Retry policy defaults of the delivery sinks (S3 uploads, webhooks).
+	defaultS3Timeout        = 30 * time.Second
+	defaultS3Attempts       = 3
+	defaultSinkRetryBackoff = time.Second
+
+	// Max size of an /admin/import bundle.
+	defaultImportMaxBytes = 64 * 1024 * 1024
+
+	// Authentication provider defaults.
+	defaultAuthHMACMaxSkew    = 5 * time.Minute
+	defaultAuthWebhookTimeout = 5 * time.Second
+
+	// Timeout of a policy decision from OPA_URL.
+	defaultOPATimeout = 2 * time.Second
+
+	// Log lines kept in memory for /admin/support-bundle.
+	defaultRecentLogLines = 1000
+
+	// How long soft-deleted templates can be restored.
+	defaultTemplateRetention = 30 * 24 * time.Hour
+
+	// Page margin (inches) reserved for the branding header/footer.
+	brandingMarginInches = 0.75
+
+	// Batch rendering defaults.
+	defaultBatchMaxItems    = 200
+	defaultBatchConcurrency = 4
+	defaultBatchTimeout     = 5 * time.Minute
+
 	// Response header.
 	pdfFilename = "document.pdf"
+
+	// Attachment name of the network trace in diagnostics responses.
+	networkTraceFilename = "network-trace.json"
 )
 
 type config struct {
 	Addr           string
 	ChromeEndpoint string
 	ChromeWS       string
+
+	// ChromeEndpoints are the entries of CHROME_ENDPOINT; ChromeEndpoint is
+	// the first one.
+	ChromeEndpoints     []string
+	ChromeProbeInterval time.Duration
+	ChromeWSCacheTTL    time.Duration
+
+	// ChromeToken authenticates to the Chrome endpoints, sent in the
+	// ChromeAuthHeader header of discovery and websocket handshake requests.
+	ChromeToken      string
+	ChromeAuthHeader string
+
+	// ChromeProxy is the HTTP(S) or SOCKS5 proxy of Chrome connections.
+	ChromeProxy string
+
+	// ChromeTLS* configure wss:// and https:// Chrome connections: a CA
+	// bundle, a client certificate, or (opted in) no verification.
+	ChromeTLSCAFile             string
+	ChromeTLSCertFile           string
+	ChromeTLSKeyFile            string
+	ChromeTLSInsecureSkipVerify bool
+
+	// CDPKeepaliveInterval is the idle time after which a waiting CDP call
+	// pings Chrome (0 = no pings); CDPKeepaliveTimeout bounds the pong wait.
+	CDPKeepaliveInterval time.Duration
+	CDPKeepaliveTimeout  time.Duration
+
+	// CDPCompression negotiates permessage-deflate on CDP websockets.
+	CDPCompression bool
+
+	// CDPMaxFrameBytes and CDPMaxMessageBytes bound the websocket frames
+	// and messages read from Chrome (0 = no limit).
+	CDPMaxFrameBytes   int
+	CDPMaxMessageBytes int
+
+	// CDPSlowCall logs CDP calls taking longer (0 = no logging).
+	CDPSlowCall time.Duration
+
+	// ChromeMaxSessions caps the concurrent renders of each endpoint (0 = no
+	// cap); ChromeEndpointMaxSessions overrides it per endpoint.
+	ChromeMaxSessions         int
+	ChromeEndpointMaxSessions map[string]int
+
+	// ChromeIsolateContexts renders each request in its own browser context.
+	ChromeIsolateContexts bool
+
+	// ChromeOrphanTargetAge is how long an unattached tab of this service
+	// stays open before the sweeper closes it (0 = never).
+	ChromeOrphanTargetAge time.Duration
+
+	// ChromeGrantPermissions are granted to rendered pages; the other
+	// permissions they could prompt for are denied.
+	ChromeGrantPermissions []string
+
+	ChromeMode         string
+	ChromePath         string
+	ChromeArgs         []string
+	ChromeDebugPort    int
+	ChromeUserDataDir  string
+	ChromeCgroup       string
+	ChromeMemoryLimit  int64
+	ChromeCPULimit     float64
+	ChromeMaxProcesses int
+	ChromeMaxOpenFiles int64
+
+	ChromeUserNamespace bool
+	ChromeReadOnlyFS    bool
+	ChromeWritablePaths []string
+	ChromeSeccompFilter string
+
+	ChromeRecycleRenders int
+	ChromeRecycleAfter   time.Duration
+
 	RequestTimeout time.Duration
 	MaxBodyBytes   int64
+	MaxPDFBytes    int64
 	PDFWait        time.Duration
+
+	MaxConcurrentRenders int
+	MaxRenderQueue       int
+	RenderQueueTimeout   time.Duration
+
+	// RenderBackend is the default render backend, RenderFallbackBackend the
+	// one used when Chrome is unavailable, and BackendCommands the command
+	// backends (RENDER_BACKEND_<NAME>_CMD).
+	RenderBackend         string
+	RenderFallbackBackend string
+	BackendCommands       map[string]string
+
+	// SocketMode is the permissions of the Unix domain socket of a
+	// unix:// Addr.
+	SocketMode os.FileMode
+
+	// ShutdownDrainTimeout bounds the wait for in-flight requests and
+	// renders on SIGTERM.
+	ShutdownDrainTimeout time.Duration
+
+	TLSCertFile     string
+	TLSKeyFile      string
+	TLSClientCAFile string
+
+	PoliciesFile        string
+	PostProcess         []string
+	PostProcessCommands map[string]string
+	PreProcess          []string
+
+	// DefaultOptions are the render options requests and presets leave out
+	// (DEFAULT_* variables).
+	DefaultOptions url.Values
+
+	AssetAllowedHosts []string
+	AssetMaxBytes     int64
+	AssetFetchTimeout time.Duration
+
+	PDFAICCProfile string
+
+	AdminToken string
+
+	AuthProvider         string
+	AuthStaticKeys       []string
+	AuthJWTSecret        string
+	AuthJWTPublicKeyFile string
+	AuthJWTIssuer        string
+	AuthJWTAudience      string
+	AuthHMACKeys         []string
+	AuthHMACMaxSkew      time.Duration
+	AuthWebhookURL       string
+	AuthWebhookTimeout   time.Duration
+
+	OPAURL      string
+	OPATimeout  time.Duration
+	OPAFailOpen bool
+
+	ArchiveDir       string
+	ArchiveRetention time.Duration
+
+	AuditLog     string
+	AuditTimeout time.Duration
+
+	// ErrorReportDSN is the Sentry-compatible DSN errors are reported to
+	// (empty disables reporting), tagged with ErrorReportEnvironment.
+	ErrorReportDSN         string
+	ErrorReportEnvironment string
+	ErrorReportTimeout     time.Duration
+
+	GoldenFile string
+
+	DedupRenders bool
+
+	JobRetention time.Duration
+	MaxJobs      int
+
+	ArtifactDir      string
+	ArtifactTTL      time.Duration
+	ArtifactMaxBytes int64
+
+	S3Endpoint        string
+	S3Region          string
+	S3Bucket          string
+	S3AccessKeyID     string
+	S3SecretAccessKey string
+	S3SessionToken    string
+	S3PathStyle       bool
+	S3KeyTemplate     string
+	S3ContentType     string
+	S3SSE             string
+	S3SSEKMSKeyID     string
+	S3CacheControl    string
+
+	DownloadURLSecret string
+	DownloadURLExpiry time.Duration
+
+	RenderLinkExpiry    time.Duration
+	RenderLinkMaxExpiry time.Duration
+
+	WebhookAllowedHosts []string
+	WebhookSecret       string
+	WebhookTimeout      time.Duration
+	WebhookAttempts     int
+	// WebhookRetryBackoff, S3RetryBackoff: delay before the second attempt,
+	// growing linearly with each further one.
+	WebhookRetryBackoff time.Duration
+
+	// S3Timeout bounds one upload attempt; uploads run detached from the
+	// client request.
+	S3Timeout      time.Duration
+	S3Attempts     int
+	S3RetryBackoff time.Duration
+
+	TemplateDir       string
+	TemplateRetention time.Duration
+
+	PDFDecodeMode   string
+	PDFTransferMode string
+
+	URLAllowedHosts []string
+	// PDFGetCacheControl is the Cache-Control of GET /api/v1/pdf renders.
+	PDFGetCacheControl string
+	// PDFGetETagWindow is how long the ETag of a GET render stays the same;
+	// 0 tags the rendered document instead.
+	PDFGetETagWindow time.Duration
+
+	BatchMaxItems    int
+	BatchConcurrency int
+	BatchTimeout     time.Duration
+
+	// BlockRemoteRequests blocks the network requests of every render, as
+	// block_remote does, except to RemoteAllowedHosts.
+	BlockRemoteRequests bool
+	RemoteAllowedHosts  []string
+	// SubresourceAllow and SubresourceDeny are the URL rules of the pages'
+	// requests (see urlRule); a nil SubresourceDeny means the default list.
+	SubresourceAllow []string
+	SubresourceDeny  []string
+	// CORSAllowedOrigins are the browser origins allowed to call the API
+	// ("*" for any; empty disables CORS). Empty method and header lists
+	// mean the defaults of cors.go.
+	CORSAllowedOrigins   []string
+	CORSAllowedMethods   []string
+	CORSAllowedHeaders   []string
+	CORSExposedHeaders   []string
+	CORSMaxAge           time.Duration
+	CORSAllowCredentials bool
+
+	// AccessLogFormat is ACCESS_LOG_FORMAT (json or combined), and
+	// AccessLogSkipProbes leaves successful health probes out of it.
+	AccessLogFormat     string
+	AccessLogSkipProbes bool
+
+	// LogLevel and LogFormat are LOG_LEVEL (debug, info, warning, error)
+	// and LOG_FORMAT (json or text).
+	LogLevel  string
+	LogFormat string
+
+	// LogPageConsole logs the console messages and uncaught exceptions of
+	// rendered pages with the request ID.
+	LogPageConsole bool
+
+	// EmptyPDFRetries renders again when Chrome returns no usable PDF.
+	EmptyPDFRetries int
 }
 
 type pdfOptions struct {
@@ -51,6 +433,128 @@ type pdfOptions struct {
 	MarginRight     *float64
 	PrintBackground *bool
 	PageRanges      string
+
+	// PostProcess selects post-processing stages; nil means "use the defaults".
+	PostProcess []string
+	Metadata    pdfMetadata
+
+	// PreProcess selects HTML pre-processing stages; nil means "use the defaults".
+	PreProcess   []string
+	InlineAssets bool
+
+	// CSS is a stylesheet added to the page before printing (see
+	// injectStyleSheet).
+	CSS string
+
+	// EmailMode renders the input as an email message (see
+	// processEmailHTML); EmailParts are the parts its cid: URLs refer to.
+	EmailMode  bool
+	EmailParts map[string]emailPart
+
+	// TraceNetwork records the sub-resources fetched by the page, returned
+	// as TraceFormat (multipart when empty).
+	TraceNetwork bool
+	TraceFormat  string
+	BaseURL      string
+	InjectCSS    string
+	InjectJS     string
+
+	// URL, when set, is navigated to instead of rendering the HTML input.
+	// Headers are sent with its requests and Auth answers its basic auth
+	// challenges; both are secrets, kept out of archives and traces.
+	URL     string
+	Headers map[string]string `json:"-"`
+	Auth    *basicAuth        `json:"-"`
+
+	// Branding is the tenant branding applied to the render; NoBranding
+	// opts a request out of it.
+	Branding   *tenantBranding
+	NoBranding bool
+
+	// Watermark is stamped on the pages by the watermark post-processing stage.
+	Watermark *watermarkOptions
+
+	// PDFA is the requested PDF/A conformance level (e.g. "2b").
+	PDFA string
+
+	// MaxPages rejects renders with more pages (0 = no limit).
+	MaxPages int
+
+	// FreezeTime pins Date to this instant and RandomSeed seeds Math.random,
+	// so documents that print "generated at" values or random IDs render
+	// the same every time.
+	FreezeTime *time.Time
+	RandomSeed *uint32
+
+	// Geolocation is the position reported to the page, for pages that
+	// serve a regional variant.
+	Geolocation *geolocationOptions
+
+	// EmulateMedia is the CSS media type the page is laid out for ("screen"
+	// or "print"); empty keeps Chrome's print media. ColorScheme and
+	// ReducedMotion emulate the prefers-color-scheme and
+	// prefers-reduced-motion media features.
+	EmulateMedia  string
+	ColorScheme   string
+	ReducedMotion string
+
+	// UserAgent overrides the user agent of the page.
+	UserAgent string
+
+	// BlockRemote fails the page's network requests, except to
+	// REMOTE_ALLOWED_HOSTS.
+	BlockRemote bool
+
+	// FailOnJSError fails the render when the page threw an uncaught
+	// exception (see jsErrorsError).
+	FailOnJSError bool
+
+	// StrictAssets fails the render when a stylesheet, image or font did
+	// not load (see brokenAssetsError).
+	StrictAssets bool
+
+	// LazyLoad scrolls through the page before printing, so lazily loaded
+	// images and content are there (see lazyLoadScript).
+	LazyLoad bool
+
+	// RunningElements turns data-running elements into the print header
+	// and footer (see runningElementsScript).
+	RunningElements bool
+
+	// Output selects where the PDF goes: "" (the response) or "s3".
+	Output string
+
+	// Deliver lists the sinks the PDF goes to ("response", "s3",
+	// "webhook"); it replaces Output. WebhookURL receives the notification.
+	Deliver    []string
+	WebhookURL string
+
+	// PreviewPages renders only these pages in the response while the full
+	// document renders as a job. Preview marks that quick render, which is
+	// not charged to the page quota.
+	PreviewPages string
+	Preview      bool
+
+	// Backend is the render backend selected by the request; empty uses
+	// RENDER_BACKEND (see backendRenderer).
+	Backend string
+
+	// Priority orders the render in the limiter queue (priorityHigh,
+	// priorityNormal or priorityLow); empty is normal.
+	Priority string
+
+	// Image, when set, captures the page as an image instead of printing
+	// it (POST /api/v1/image).
+	Image *imageOptions
+}
+
+// pdfMetadata holds document information dictionary values.
+type pdfMetadata struct {
+	Title    string
+	Author   string
+	Subject  string
+	Keywords string
+	Creator  string
 }
 
 type wsResolver interface {
@@ -60,5 +564,13 @@ type wsResolver interface {
 type pdfRenderer func(ctx context.Context, wsURL, html string, wait time.Duration, options pdfOptions) ([]byte, time.Duration, error)
 
 type versionResponse struct {
+	Browser              string `json:"Browser"`
+	ProtocolVersion      string `json:"Protocol-Version"`
 	WebSocketDebuggerURL string `json:"webSocketDebuggerUrl"`
 }
+
+type browserVersionResult struct {
+	ProtocolVersion string `json:"protocolVersion"`
+	Product         string `json:"product"`
+	UserAgent       string `json:"userAgent"`
+}
@@ -0,0 +1,141 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Defaults of the CORS lists: the methods and request headers of the API,
+// and the response headers browser clients may read.
+var (
+	defaultCORSMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodDelete}
+	defaultCORSHeaders = []string{"Content-Type", "Authorization", headerAPIKey, "Prefer", headerPriority,
+		headerAuthKeyID, headerAuthTimestamp, headerAuthSignature}
+	defaultCORSExposedHeaders = []string{"Content-Disposition", "Location", "Retry-After", headerRequestID, headerPDFPages,
+		headerNetworkSummary, headerRenderUtilization, headerRenderQueueDepth, headerQueueDepth,
+		"ETag", headerFullDocumentJob, headerS3ObjectURL}
+)
+
+// corsPolicy answers the cross-origin requests of browser clients
+// (CORS_ALLOWED_ORIGINS). Preflight requests are answered here, before
+// authentication, since browsers send them without credentials.
+type corsPolicy struct {
+	origins     []string
+	anyOrigin   bool
+	methods     string
+	headers     string
+	exposed     string
+	maxAge      string
+	credentials bool
+}
+
+// newCORSPolicy returns nil when no origin is allowed (CORS disabled).
+func newCORSPolicy(cfg config) *corsPolicy {
+	if len(cfg.CORSAllowedOrigins) == 0 {
+		return nil
+	}
+	policy := &corsPolicy{
+		methods:     strings.Join(orDefault(cfg.CORSAllowedMethods, defaultCORSMethods), ", "),
+		headers:     strings.Join(orDefault(cfg.CORSAllowedHeaders, defaultCORSHeaders), ", "),
+		exposed:     strings.Join(orDefault(cfg.CORSExposedHeaders, defaultCORSExposedHeaders), ", "),
+		maxAge:      strconv.Itoa(int(cfg.CORSMaxAge.Seconds())),
+		credentials: cfg.CORSAllowCredentials,
+	}
+	for _, origin := range cfg.CORSAllowedOrigins {
+		if origin == "*" {
+			policy.anyOrigin = true
+			continue
+		}
+		policy.origins = append(policy.origins, strings.ToLower(strings.TrimSuffix(origin, "/")))
+	}
+	return policy
+}
+
+// orDefault is list, or fallback when list is empty.
+func orDefault(list, fallback []string) []string {
+	if len(list) == 0 {
+		return fallback
+	}
+	return list
+}
+
+// checkCORSOrigin validates a CORS_ALLOWED_ORIGINS entry: "*" or a bare
+// origin such as https://app.example.com.
+func checkCORSOrigin(origin string) error {
+	if origin == "*" {
+		return nil
+	}
+	parsed, err := url.Parse(strings.TrimSuffix(origin, "/"))
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" ||
+		parsed.Path != "" || parsed.RawQuery != "" || parsed.User != nil {
+		return fmt.Errorf("expected * or an origin such as https://app.example.com")
+	}
+	return nil
+}
+
+// allows reports whether requests from origin are allowed.
+func (p *corsPolicy) allows(origin string) bool {
+	return p.anyOrigin || slices.Contains(p.origins, strings.ToLower(origin))
+}
+
+// corsMiddleware adds the CORS headers to the responses to allowed origins
+// and answers their preflight requests with 204. Preflights from other
+// origins get 403; their other requests are served without CORS headers,
+// so browsers hide the responses from the calling page. Unless any origin
+// is allowed, every response varies on Origin, so a shared cache does not
+// hand a response without CORS headers to an allowed origin.
+func corsMiddleware(policy *corsPolicy, next http.Handler) http.Handler {
+	if policy == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := w.Header()
+		if !policy.anyOrigin {
+			header.Add("Vary", "Origin")
+		}
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if !policy.allows(origin) {
+			if preflight {
+				Debugf("cors: preflight from %q rejected", origin)
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if policy.anyOrigin {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Set("Access-Control-Allow-Origin", origin)
+		}
+		if policy.credentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+		if !preflight {
+			header.Set("Access-Control-Expose-Headers", policy.exposed)
+			next.ServeHTTP(w, r)
+			return
+		}
+		header.Add("Vary", "Access-Control-Request-Method")
+		header.Add("Vary", "Access-Control-Request-Headers")
+		header.Set("Access-Control-Allow-Methods", policy.methods)
+		header.Set("Access-Control-Allow-Headers", policy.headers)
+		if policy.maxAge != "0" {
+			header.Set("Access-Control-Max-Age", policy.maxAge)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
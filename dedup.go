@@ -0,0 +1,132 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// inflightRender is a render shared by every caller that asked for the same
+// document while it was running.
+type inflightRender struct {
+	done    chan struct{}
+	cancel  context.CancelFunc
+	waiters int
+
+	pdf     []byte
+	pdfTime time.Duration
+	err     error
+}
+
+// renderDedup runs identical concurrent renders once (singleflight).
+type renderDedup struct {
+	mu       sync.Mutex
+	inflight map[string]*inflightRender
+}
+
+func newRenderDedup() *renderDedup {
+	return &renderDedup{inflight: map[string]*inflightRender{}}
+}
+
+// forget removes call from the in-flight renders, unless a newer render
+// already took its key. The caller holds d.mu.
+func (d *renderDedup) forget(key string, call *inflightRender) {
+	if d.inflight[key] == call {
+		delete(d.inflight, key)
+	}
+}
+
+// detach stops sharing the in-flight renders: their current callers still
+// receive the result, later identical requests render again. It returns the
+// number of renders detached.
+func (d *renderDedup) detach() int {
+	if d == nil {
+		return 0
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	detached := len(d.inflight)
+	d.inflight = map[string]*inflightRender{}
+	return detached
+}
+
+// renderKey identifies a render by its input and resolved options; ok is
+// false for renders that cannot be shared.
+func renderKey(wsURL, html string, wait time.Duration, options pdfOptions) (string, bool) {
+	// Network traces are collected into the caller's own diagnostics, and
+	// credentials, kept out of the encoded options, must not share renders.
+	if options.TraceNetwork || len(options.Headers) > 0 || options.Auth != nil {
+		return "", false
+	}
+	encoded, err := json.Marshal(options)
+	if err != nil {
+		return "", false
+	}
+	sum := sha256.New()
+	for _, part := range []string{wsURL, html, wait.String(), string(encoded)} {
+		sum.Write([]byte(part))
+		sum.Write([]byte{0})
+	}
+	return hex.EncodeToString(sum.Sum(nil)), true
+}
+
+// dedupRenderer fans out the result of one render to every caller that
+// requests the same document (same HTML and options) while it is in flight.
+// The shared render has no deadline of its own: it is cancelled when every
+// caller has gone, so it runs until the latest deadline of its callers,
+// not the first one's. Callers receive the same PDF slice and must not
+// modify it. A nil dedup disables sharing.
+func dedupRenderer(dedup *renderDedup, next pdfRenderer) pdfRenderer {
+	if dedup == nil {
+		return next
+	}
+	return func(ctx context.Context, wsURL, html string, wait time.Duration, options pdfOptions) ([]byte, time.Duration, error) {
+		key, ok := renderKey(wsURL, html, wait, options)
+		if !ok {
+			return next(ctx, wsURL, html, wait, options)
+		}
+
+		dedup.mu.Lock()
+		call, shared := dedup.inflight[key]
+		if shared {
+			call.waiters++
+			dedup.mu.Unlock()
+			Debugf("render %s: sharing an in-flight render", key[:12])
+		} else {
+			renderCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+			call = &inflightRender{done: make(chan struct{}), cancel: cancel, waiters: 1}
+			dedup.inflight[key] = call
+			dedup.mu.Unlock()
+
+			go func() {
+				defer cancel()
+				call.pdf, call.pdfTime, call.err = next(renderCtx, wsURL, html, wait, options)
+				dedup.mu.Lock()
+				dedup.forget(key, call)
+				dedup.mu.Unlock()
+				close(call.done)
+			}()
+		}
+
+		select {
+		case <-call.done:
+			return call.pdf, call.pdfTime, call.err
+		case <-ctx.Done():
+			dedup.mu.Lock()
+			call.waiters--
+			if call.waiters == 0 {
+				// Nobody is left to receive the result.
+				dedup.forget(key, call)
+				call.cancel()
+			}
+			dedup.mu.Unlock()
+			return nil, 0, ctx.Err()
+		}
+	}
+}
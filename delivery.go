@@ -0,0 +1,241 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Delivery sinks of the deliver option: a render can go to several at once.
+const (
+	sinkResponse = "response"
+	sinkS3       = "s3"
+	sinkWebhook  = "webhook"
+)
+
+const (
+	// headerS3ObjectURL carries the uploaded object when the PDF is also
+	// returned in the response.
+	headerS3ObjectURL = "X-S3-Object-URL"
+
+	headerWebhookEvent     = "X-PDFRest-Event"
+	headerWebhookSignature = "X-PDFRest-Signature"
+
+	webhookEventRenderCompleted = "render.completed"
+)
+
+// deliveryReport describes where a render was delivered. It is the response
+// body when the PDF itself is not returned, and the webhook payload.
+type deliveryReport struct {
+	RequestID string    `json:"request_id,omitempty"`
+	Bytes     int       `json:"bytes"`
+	Pages     int       `json:"pages"`
+	SHA256    string    `json:"sha256"`
+	S3        *s3Object `json:"s3,omitempty"`
+	Webhook   string    `json:"webhook,omitempty"`
+}
+
+// webhookEvent is the body of a webhook notification.
+type webhookEvent struct {
+	Event string `json:"event"`
+	deliveryReport
+}
+
+// retryPolicy bounds the calls of a delivery sink (S3 uploads, webhooks).
+// Every attempt runs with its own timeout, detached from the client request,
+// so a client that disconnects does not abort a delivery; failed attempts
+// are retried with a linear backoff.
+type retryPolicy struct {
+	attempts int
+	timeout  time.Duration
+	backoff  time.Duration
+}
+
+// do runs call until it succeeds, fails with a status that retrying cannot
+// fix, or the attempts run out. ctx only passes on its values (request ID,
+// key policy); a zero timeout leaves attempts unbounded.
+func (p retryPolicy) do(ctx context.Context, name string, call func(ctx context.Context) error) error {
+	ctx = context.WithoutCancel(ctx)
+	attempts := max(p.attempts, 1)
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if p.timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, p.timeout)
+		}
+		err = call(attemptCtx)
+		cancel()
+		var statusErr *sinkStatusError
+		if err == nil || (errors.As(err, &statusErr) && !statusErr.temporary()) {
+			return err
+		}
+		if attempt < attempts {
+			Warnf("%s: attempt %d/%d: %v", name, attempt, attempts, err)
+			time.Sleep(time.Duration(attempt) * p.backoff)
+		}
+	}
+	return err
+}
+
+// sinkStatusError is an unexpected HTTP status answered by a sink.
+type sinkStatusError struct {
+	status int
+	msg    string
+}
+
+func (e *sinkStatusError) Error() string {
+	return e.msg
+}
+
+// temporary reports whether another attempt may succeed.
+func (e *sinkStatusError) temporary() bool {
+	return e.status >= http.StatusInternalServerError || e.status == http.StatusRequestTimeout || e.status == http.StatusTooManyRequests
+}
+
+// parseDeliver parses the deliver option: a comma-separated list of sinks.
+func parseDeliver(value string) ([]string, error) {
+	var sinks []string
+	for _, sink := range strings.Split(value, ",") {
+		sink = strings.TrimSpace(sink)
+		switch sink {
+		case sinkResponse, sinkS3, sinkWebhook:
+		default:
+			return nil, fmt.Errorf("invalid deliver sink %q", sink)
+		}
+		if containsString(sinks, sink) {
+			return nil, fmt.Errorf("duplicate deliver sink %q", sink)
+		}
+		sinks = append(sinks, sink)
+	}
+	return sinks, nil
+}
+
+// checkDelivery verifies that the sinks requested by options are configured.
+func (s *pdfService) checkDelivery(options pdfOptions) error {
+	if containsString(options.Deliver, sinkS3) && s.s3 == nil {
+		return errors.New("s3 output is not configured")
+	}
+	if containsString(options.Deliver, sinkWebhook) {
+		if s.webhooks == nil {
+			return errors.New("webhooks are not configured")
+		}
+		target, err := url.Parse(options.WebhookURL)
+		if err != nil || !s.webhooks.allowed.allowed(target) {
+			return errors.New("webhook_url not allowed")
+		}
+	}
+	return nil
+}
+
+// deliver sends a finished render to the S3 and webhook sinks of options.
+// The upload happens first so the notification can point at the object;
+// the notification itself is sent in the background.
+func (s *pdfService) deliver(ctx context.Context, options pdfOptions, pdf []byte) (*deliveryReport, error) {
+	sum := sha256.Sum256(pdf)
+	report := &deliveryReport{
+		RequestID: requestIDFromContext(ctx),
+		Bytes:     len(pdf),
+		Pages:     countPDFPages(pdf),
+		SHA256:    hex.EncodeToString(sum[:]),
+	}
+	if containsString(options.Deliver, sinkS3) {
+		object, err := s.s3.upload(ctx, pdf)
+		if err != nil {
+			return nil, err
+		}
+		if s.downloads != nil {
+			s.downloads.objectURL(s.s3, object)
+		}
+		report.S3 = object
+	}
+	if containsString(options.Deliver, sinkWebhook) {
+		report.Webhook = "queued"
+		s.webhooks.notify(ctx, options.WebhookURL, *report)
+	}
+	return report, nil
+}
+
+// webhookNotifier posts render notifications to the hosts allowed by
+// WEBHOOK_ALLOWED_HOSTS, signed with WEBHOOK_SECRET when set.
+type webhookNotifier struct {
+	allowed hostAllowlist
+	secret  []byte
+	retry   retryPolicy
+	client  *http.Client
+}
+
+// newWebhookNotifier returns nil when no host is allowed (webhooks disabled).
+func newWebhookNotifier(cfg config) *webhookNotifier {
+	if len(cfg.WebhookAllowedHosts) == 0 {
+		return nil
+	}
+	return &webhookNotifier{
+		allowed: newHostAllowlist(cfg.WebhookAllowedHosts),
+		secret:  []byte(cfg.WebhookSecret),
+		retry:   retryPolicy{attempts: cfg.WebhookAttempts, timeout: cfg.WebhookTimeout, backoff: cfg.WebhookRetryBackoff},
+		client: &http.Client{
+			// A redirect could leave the allowed hosts.
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+	}
+}
+
+// notify posts report to target in the background, so a slow receiver
+// holds neither the response nor a render slot.
+func (n *webhookNotifier) notify(ctx context.Context, target string, report deliveryReport) {
+	body, err := json.Marshal(webhookEvent{Event: webhookEventRenderCompleted, deliveryReport: report})
+	if err != nil {
+		Errorf("webhook encode error: %v", err)
+		return
+	}
+	go func() {
+		err := n.retry.do(ctx, "webhook "+report.RequestID, func(ctx context.Context) error {
+			return n.post(ctx, target, report.RequestID, body)
+		})
+		if err != nil {
+			Errorf("webhook %s: giving up: %v", report.RequestID, err)
+			return
+		}
+		Debugf("webhook %s: delivered", report.RequestID)
+	}()
+}
+
+func (n *webhookNotifier) post(ctx context.Context, target, requestID string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(headerWebhookEvent, webhookEventRenderCompleted)
+	if requestID != "" {
+		req.Header.Set(headerRequestID, requestID)
+	}
+	if len(n.secret) > 0 {
+		mac := hmac.New(sha256.New, n.secret)
+		mac.Write(body)
+		req.Header.Set(headerWebhookSignature, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &sinkStatusError{status: resp.StatusCode, msg: "unexpected status " + resp.Status}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// freezeTimeScript replaces Date so that the current time is always the
+// frozen one. Dates built from explicit values are unaffected.
+const freezeTimeScript = `(() => {
+  const frozen = %d;
+  const NativeDate = Date;
+  class FrozenDate extends NativeDate {
+    constructor(...args) { if (args.length === 0) { super(frozen); } else { super(...args); } }
+    static now() { return frozen; }
+  }
+  globalThis.Date = new Proxy(FrozenDate, { apply() { return new NativeDate(frozen).toString(); } });
+})();
+`
+
+// seedRandomScript replaces Math.random with a seeded generator (mulberry32).
+const seedRandomScript = `(() => {
+  let state = %d;
+  Math.random = () => {
+    state = (state + 0x6D2B79F5) | 0;
+    let t = Math.imul(state ^ (state >>> 15), 1 | state);
+    t = (t + Math.imul(t ^ (t >>> 7), 61 | t)) ^ t;
+    return ((t ^ (t >>> 14)) >>> 0) / 4294967296;
+  };
+})();
+`
+
+// deterministicScript returns the overrides requested by freeze_time and
+// random_seed, or "" when the render is not pinned.
+func deterministicScript(options pdfOptions) string {
+	var script strings.Builder
+	if options.FreezeTime != nil {
+		fmt.Fprintf(&script, freezeTimeScript, options.FreezeTime.UnixMilli())
+	}
+	if options.RandomSeed != nil {
+		fmt.Fprintf(&script, seedRandomScript, *options.RandomSeed)
+	}
+	return script.String()
+}
+
+// installDeterministicScript makes the overrides run before any script of the
+// documents loaded in the session. The returned function removes them again,
+// since a page websocket (CHROME_WS) is reused across renders.
+func installDeterministicScript(ctx context.Context, client *cdpClient, sessionID string, options pdfOptions) (func(), error) {
+	script := deterministicScript(options)
+	if script == "" {
+		return func() {}, nil
+	}
+	var added struct {
+		Identifier string `json:"identifier"`
+	}
+	if err := client.Call(ctx, sessionID, "Page.addScriptToEvaluateOnNewDocument", map[string]any{
+		"source": script,
+	}, &added); err != nil {
+		return nil, err
+	}
+	return func() {
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		if err := client.Call(cleanupCtx, sessionID, "Page.removeScriptToEvaluateOnNewDocument", map[string]any{
+			"identifier": added.Identifier,
+		}, nil); err != nil {
+			Warnf("chrome remove script error: %v", err)
+		}
+	}, nil
+}
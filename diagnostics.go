@@ -0,0 +1,265 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Formats of the network trace (trace_format).
+const (
+	// traceFormatMultipart answers with the PDF and the trace as parts of a
+	// multipart/mixed body.
+	traceFormatMultipart = "multipart"
+	// traceFormatJSON answers with a JSON envelope holding both.
+	traceFormatJSON = "json"
+	// traceFormatHeader answers with the PDF and a summary of the trace in
+	// the X-Network-Summary header.
+	traceFormatHeader = "header"
+)
+
+// headerNetworkSummary carries the summary of trace_format=header.
+const headerNetworkSummary = "X-Network-Summary"
+
+// Hosts listed in X-Network-Summary, so the header stays small.
+const maxSummaryHosts = 20
+
+// renderDiagnostics collects optional information about a render (such as the
+// network activity of the page). The handler attaches it to the request
+// context; renderers fill it in when the corresponding option is enabled.
+type renderDiagnostics struct {
+	mu      sync.Mutex
+	network []*networkEntry
+}
+
+// networkEntry describes one sub-resource request made by the page.
+type networkEntry struct {
+	URL        string  `json:"url"`
+	Method     string  `json:"method"`
+	Type       string  `json:"type,omitempty"`
+	Status     int     `json:"status,omitempty"`
+	MimeType   string  `json:"mime_type,omitempty"`
+	Bytes      int64   `json:"bytes"`
+	DurationMS float64 `json:"duration_ms"`
+	FromCache  bool    `json:"from_cache,omitempty"`
+	Error      string  `json:"error,omitempty"`
+
+	started float64
+}
+
+// networkReport is the JSON document returned as the network trace.
+type networkReport struct {
+	Summary  networkSummary  `json:"summary"`
+	Requests []*networkEntry `json:"requests"`
+}
+
+// networkSummary totals the network activity of a page. Hosts are the
+// distinct hosts it contacted, sorted.
+type networkSummary struct {
+	Requests   int      `json:"requests"`
+	Failed     int      `json:"failed"`
+	Bytes      int64    `json:"bytes"`
+	DurationMS float64  `json:"duration_ms"`
+	Hosts      []string `json:"hosts"`
+}
+
+// header formats the summary for X-Network-Summary.
+func (s networkSummary) header() string {
+	hosts := s.Hosts
+	if len(hosts) > maxSummaryHosts {
+		hosts = append(hosts[:maxSummaryHosts:maxSummaryHosts], "...")
+	}
+	return fmt.Sprintf("requests=%d; failed=%d; bytes=%d; duration_ms=%s; hosts=%s",
+		s.Requests, s.Failed, s.Bytes, strconv.FormatFloat(s.DurationMS, 'f', -1, 64), strings.Join(hosts, ","))
+}
+
+// pdfEnvelope is the response body of trace_format=json.
+type pdfEnvelope struct {
+	PDF     []byte        `json:"pdf"`
+	Pages   int           `json:"pages"`
+	Network networkReport `json:"network"`
+}
+
+type diagnosticsContextKey struct{}
+
+func withRenderDiagnostics(ctx context.Context, diag *renderDiagnostics) context.Context {
+	return context.WithValue(ctx, diagnosticsContextKey{}, diag)
+}
+
+// diagnosticsFromContext returns the collector attached to ctx, or nil.
+func diagnosticsFromContext(ctx context.Context) *renderDiagnostics {
+	diag, _ := ctx.Value(diagnosticsContextKey{}).(*renderDiagnostics)
+	return diag
+}
+
+// networkReport returns a snapshot of the recorded network activity.
+func (d *renderDiagnostics) networkReport() networkReport {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	report := networkReport{Requests: make([]*networkEntry, 0, len(d.network)), Summary: networkSummary{Hosts: []string{}}}
+	for _, entry := range d.network {
+		copied := *entry
+		report.Requests = append(report.Requests, &copied)
+		report.Summary.Requests++
+		report.Summary.Bytes += entry.Bytes
+		report.Summary.DurationMS = max(report.Summary.DurationMS, entry.DurationMS)
+		if entry.Error != "" || entry.Status >= http.StatusBadRequest {
+			report.Summary.Failed++
+		}
+		if parsed, err := url.Parse(entry.URL); err == nil && parsed.Host != "" && !containsString(report.Summary.Hosts, parsed.Host) {
+			report.Summary.Hosts = append(report.Summary.Hosts, parsed.Host)
+		}
+	}
+	sort.Strings(report.Summary.Hosts)
+	return report
+}
+
+// networkTracer turns Network domain events into networkEntry records.
+type networkTracer struct {
+	diag     *renderDiagnostics
+	requests map[string]*networkEntry
+}
+
+func newNetworkTracer(diag *renderDiagnostics) *networkTracer {
+	return &networkTracer{diag: diag, requests: map[string]*networkEntry{}}
+}
+
+// handle processes a single CDP event. Unrelated events are ignored.
+func (t *networkTracer) handle(event cdpEvent) {
+	var params struct {
+		RequestID string  `json:"requestId"`
+		Timestamp float64 `json:"timestamp"`
+		Type      string  `json:"type"`
+		Request   struct {
+			URL    string `json:"url"`
+			Method string `json:"method"`
+		} `json:"request"`
+		Response struct {
+			Status            int    `json:"status"`
+			MimeType          string `json:"mimeType"`
+			FromDiskCache     bool   `json:"fromDiskCache"`
+			FromServiceWorker bool   `json:"fromServiceWorker"`
+		} `json:"response"`
+		EncodedDataLength float64 `json:"encodedDataLength"`
+		ErrorText         string  `json:"errorText"`
+	}
+
+	switch event.Method {
+	case "Network.requestWillBeSent", "Network.responseReceived", "Network.loadingFinished", "Network.loadingFailed":
+	default:
+		return
+	}
+	if err := json.Unmarshal(event.Params, &params); err != nil {
+		return
+	}
+
+	t.diag.mu.Lock()
+	defer t.diag.mu.Unlock()
+
+	entry := t.requests[params.RequestID]
+	if event.Method == "Network.requestWillBeSent" {
+		// Redirects reuse the request ID: each hop gets its own entry.
+		entry = &networkEntry{
+			URL:     params.Request.URL,
+			Method:  params.Request.Method,
+			Type:    params.Type,
+			started: params.Timestamp,
+		}
+		t.requests[params.RequestID] = entry
+		t.diag.network = append(t.diag.network, entry)
+		return
+	}
+	if entry == nil {
+		return
+	}
+
+	switch event.Method {
+	case "Network.responseReceived":
+		entry.Status = params.Response.Status
+		entry.MimeType = params.Response.MimeType
+		entry.FromCache = params.Response.FromDiskCache || params.Response.FromServiceWorker
+		if params.Type != "" {
+			entry.Type = params.Type
+		}
+	case "Network.loadingFinished":
+		entry.Bytes = int64(params.EncodedDataLength)
+		entry.DurationMS = elapsedMillis(entry.started, params.Timestamp)
+	case "Network.loadingFailed":
+		entry.Error = params.ErrorText
+		entry.DurationMS = elapsedMillis(entry.started, params.Timestamp)
+	}
+}
+
+// elapsedMillis converts two CDP monotonic timestamps (seconds) to milliseconds.
+func elapsedMillis(start, end float64) float64 {
+	if start == 0 || end < start {
+		return 0
+	}
+	return math.Round((end-start)*1e6) / 1e3
+}
+
+// writePDFWithDiagnostics answers with the PDF and the diagnostics in the
+// given trace format (multipart by default).
+func writePDFWithDiagnostics(w http.ResponseWriter, pdf []byte, diag *renderDiagnostics, format string) {
+	switch format {
+	case traceFormatJSON:
+		writeJSON(w, http.StatusOK, pdfEnvelope{PDF: pdf, Pages: countPDFPages(pdf), Network: diag.networkReport()})
+		return
+	case traceFormatHeader:
+		w.Header().Set(headerNetworkSummary, diag.networkReport().Summary.header())
+		writePDF(w, pdf)
+		return
+	}
+
+	report, err := json.MarshalIndent(diag.networkReport(), "", "  ")
+	if err != nil {
+		Errorf("diagnostics encode error: %v", err)
+		http.Error(w, "render failed", http.StatusInternalServerError)
+		return
+	}
+
+	mw := multipart.NewWriter(w)
+	w.Header().Set("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	parts := []struct {
+		contentType string
+		disposition string
+		data        []byte
+	}{
+		{"application/pdf", fmt.Sprintf("inline; filename=%q", pdfFilename), pdf},
+		{"application/json", fmt.Sprintf("attachment; filename=%q", networkTraceFilename), report},
+	}
+	for _, part := range parts {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", part.contentType)
+		header.Set("Content-Disposition", part.disposition)
+		pw, err := mw.CreatePart(header)
+		if err != nil {
+			Warnf("diagnostics write error: %v", err)
+			return
+		}
+		if _, err := pw.Write(part.data); err != nil {
+			Warnf("diagnostics write error: %v", err)
+			return
+		}
+	}
+	if err := mw.Close(); err != nil {
+		Warnf("diagnostics write error: %v", err)
+	}
+}
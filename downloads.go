@@ -0,0 +1,96 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// downloadSigner issues time-limited download URLs for stored results:
+// pre-signed S3 URLs for uploaded objects and HMAC-signed
+// /api/v1/downloads/{id} URLs for async job results.
+type downloadSigner struct {
+	secret []byte
+	expiry time.Duration
+	now    func() time.Time
+}
+
+// newDownloadSigner uses DOWNLOAD_URL_SECRET, or a random secret when it is
+// not set (URLs are then only valid on the replica that issued them, like
+// the jobs themselves).
+func newDownloadSigner(cfg config) (*downloadSigner, error) {
+	secret := []byte(cfg.DownloadURLSecret)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+	}
+	return &downloadSigner{secret: secret, expiry: cfg.DownloadURLExpiry, now: time.Now}, nil
+}
+
+// mac signs a job ID and expiry time.
+func (d *downloadSigner) mac(id string, expires int64) string {
+	mac := hmac.New(sha256.New, d.secret)
+	mac.Write([]byte(id + "\n" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// jobURL returns the signed download URL of a job result.
+func (d *downloadSigner) jobURL(id string) (string, time.Time) {
+	expiresAt := d.now().Add(d.expiry).Truncate(time.Second).UTC()
+	expires := expiresAt.Unix()
+	query := url.Values{
+		"expires":   {strconv.FormatInt(expires, 10)},
+		"signature": {d.mac(id, expires)},
+	}
+	return pathDownloads + "/" + id + "?" + query.Encode(), expiresAt
+}
+
+// verify checks the signature and expiry of a download URL.
+func (d *downloadSigner) verify(id string, query url.Values) bool {
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || d.now().Unix() > expires {
+		return false
+	}
+	return hmac.Equal([]byte(query.Get("signature")), []byte(d.mac(id, expires)))
+}
+
+// objectURL pre-signs the download of an uploaded object.
+func (d *downloadSigner) objectURL(client *s3Client, object *s3Object) {
+	downloadURL, expiresAt := client.presign(object.Key, min(d.expiry, s3MaxPresignExpiry))
+	object.DownloadURL, object.ExpiresAt = downloadURL, &expiresAt
+}
+
+// serveDownload serves GET /api/v1/downloads/{id}: the result of a job,
+// authorized by the URL signature instead of the caller's credentials.
+func (s *pdfService) serveDownload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	id := r.PathValue("id")
+	if !s.downloads.verify(id, r.URL.Query()) {
+		http.Error(w, "invalid or expired download link", http.StatusForbidden)
+		return
+	}
+	job, ok := s.jobs.lookup(id)
+	if !ok {
+		http.Error(w, "job not found", http.StatusNotFound)
+		return
+	}
+	snapshot := s.jobs.snapshot(job)
+	if snapshot.Status != jobDone || snapshot.Output != nil {
+		http.Error(w, "job not found", http.StatusNotFound)
+		return
+	}
+	s.writeJobResult(w, snapshot)
+}
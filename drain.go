@@ -0,0 +1,94 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// renderTracker keeps the renders in flight, so a shutdown can wait for them
+// and operators can list and cancel them (/admin/renders). Async jobs render
+// after their request has been answered, where http.Server.Shutdown does not
+// see them.
+type renderTracker struct {
+	mu      sync.Mutex
+	renders map[string]*activeRender
+	idle    chan struct{}
+}
+
+func newRenderTracker() *renderTracker {
+	return &renderTracker{renders: map[string]*activeRender{}}
+}
+
+// begin registers a render and returns the context it runs with, which
+// cancel interrupts.
+func (t *renderTracker) begin(ctx context.Context) (context.Context, *activeRender) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	render := &activeRender{ID: newRequestID(), RequestID: requestIDFromContext(ctx), Caller: jobOwner(ctx),
+		Started: time.Now(), cancel: cancel}
+	ctx = context.WithValue(ctx, activeRenderContextKey{}, render)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.renders) == 0 {
+		t.idle = make(chan struct{})
+	}
+	t.renders[render.ID] = render
+	return ctx, render
+}
+
+func (t *renderTracker) end(render *activeRender) {
+	render.cancel(nil)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.renders, render.ID)
+	if len(t.renders) == 0 {
+		close(t.idle)
+	}
+}
+
+// inFlight returns the number of renders in flight.
+func (t *renderTracker) inFlight() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.renders)
+}
+
+// wait blocks until no render is in flight or ctx is done.
+func (t *renderTracker) wait(ctx context.Context) error {
+	t.mu.Lock()
+	if len(t.renders) == 0 {
+		t.mu.Unlock()
+		return nil
+	}
+	idle := t.idle
+	t.mu.Unlock()
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%d renders still in flight: %w", t.inFlight(), ctx.Err())
+	}
+}
+
+// trackRenderer keeps the renders of next in tracker. A render cancelled by
+// an operator fails with errRenderCancelled.
+func trackRenderer(tracker *renderTracker, next pdfRenderer) pdfRenderer {
+	if tracker == nil {
+		return next
+	}
+	return func(ctx context.Context, wsURL, html string, wait time.Duration, options pdfOptions) ([]byte, time.Duration, error) {
+		ctx, render := tracker.begin(ctx)
+		defer tracker.end(render)
+		pdf, pdfTime, err := next(ctx, wsURL, html, wait, options)
+		if err != nil && errors.Is(context.Cause(ctx), errRenderCancelled) {
+			err = errRenderCancelled
+		}
+		return pdf, pdfTime, err
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Parts besides the HTML accepted in a multipart body.
+const maxEmailParts = 100
+
+// emailPart is a body part of a message, such as an inline image, that the
+// HTML refers to by Content-ID (a cid: URL).
+type emailPart struct {
+	ContentType string `json:"content_type"`
+	Data        []byte `json:"data"`
+}
+
+// emailPrintCSS adapts email layouts to paper: table-based layouts keep their
+// rows whole across page breaks, and bgcolor and background images, which
+// emails use for most of their styling, are printed.
+const emailPrintCSS = `<style>` +
+	`html,body{-webkit-print-color-adjust:exact;print-color-adjust:exact}` +
+	`tr,img{break-inside:avoid}` +
+	`</style>`
+
+// cidRefRe matches cid: URLs in attributes (src, background, href) and CSS
+// url() references.
+var cidRefRe = regexp.MustCompile(`(?i)((?:\bsrc|\bbackground|\bhref)\s*=\s*["']?|url\(\s*["']?)cid:([^"'\s)>]+)`)
+
+// isMultipartRequest reports whether the request body is a multipart body.
+func isMultipartRequest(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && strings.HasPrefix(mediaType, "multipart/")
+}
+
+// readMultipartBody splits a multipart body into the HTML and the other parts,
+// such as the ones an email message refers to by Content-ID (cid: URLs):
+//
+//   - multipart/related (RFC 2387): the root part (the start parameter, or
+//     the first part) is the HTML; the other parts are keyed by Content-ID.
+//   - multipart/form-data: the "html" field is the HTML; the other parts are
+//     keyed by their Content-ID header, or else by their field name.
+//
+// Base64 and quoted-printable part bodies are decoded.
+func readMultipartBody(contentType string, body []byte) (string, map[string]emailPart, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || params["boundary"] == "" {
+		return "", nil, fmt.Errorf("invalid multipart body")
+	}
+	formData := mediaType == "multipart/form-data"
+	if !formData && mediaType != "multipart/related" {
+		return "", nil, fmt.Errorf("unsupported multipart type %s", mediaType)
+	}
+	start := strings.Trim(params["start"], "<>")
+
+	var (
+		html    string
+		rootSet bool
+		parts   = map[string]emailPart{}
+	)
+	reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid multipart body")
+		}
+		data, err := readEmailPart(part)
+		if err != nil {
+			return "", nil, err
+		}
+		contentID := strings.Trim(part.Header.Get("Content-ID"), "<> ")
+
+		var root bool
+		switch {
+		case formData:
+			root = part.FormName() == "html"
+		case start != "":
+			root = contentID == start
+		default:
+			root = !rootSet
+		}
+		if root {
+			if rootSet {
+				return "", nil, fmt.Errorf("duplicate html part")
+			}
+			html, rootSet = string(data), true
+			continue
+		}
+
+		id := contentID
+		if id == "" && formData {
+			id = part.FormName()
+		}
+		if id == "" {
+			continue
+		}
+		if len(parts) >= maxEmailParts {
+			return "", nil, fmt.Errorf("too many parts (max %d)", maxEmailParts)
+		}
+		partType, _, err := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		if err != nil {
+			partType = http.DetectContentType(data)
+		}
+		parts[id] = emailPart{ContentType: partType, Data: data}
+	}
+	if !rootSet || html == "" {
+		return "", nil, fmt.Errorf("missing html part")
+	}
+	return html, parts, nil
+}
+
+// readEmailPart reads the body of part. NextPart already decodes
+// quoted-printable; base64, the usual encoding of attachments, is decoded
+// here.
+func readEmailPart(part *multipart.Part) ([]byte, error) {
+	var body io.Reader = part
+	if strings.EqualFold(strings.TrimSpace(part.Header.Get("Content-Transfer-Encoding")), "base64") {
+		// Mail wraps base64 at 76 columns; the decoder skips the line breaks.
+		body = base64.NewDecoder(base64.StdEncoding, part)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid multipart part: %w", err)
+	}
+	return data, nil
+}
+
+// processEmailHTML is the email pre-processing stage: it resolves cid: URLs
+// to data URIs from options.EmailParts (unknown ones are left alone, as a
+// mail client shows them broken) and adds emailPrintCSS.
+func processEmailHTML(_ context.Context, input string, options pdfOptions) (string, error) {
+	out := cidRefRe.ReplaceAllStringFunc(input, func(match string) string {
+		groups := cidRefRe.FindStringSubmatch(match)
+		id, err := url.PathUnescape(groups[2])
+		if err != nil {
+			return match
+		}
+		part, ok := options.EmailParts[id]
+		if !ok {
+			return match
+		}
+		return groups[1] + "data:" + part.ContentType + ";base64," + base64.StdEncoding.EncodeToString(part.Data)
+	})
+	return insertIntoHead(out, emailPrintCSS), nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// errMissingPDFData is returned by the decoders when Chrome sent no data.
+var errMissingPDFData = errors.New("missing pdf data")
+
+// emptyPDFError reports a print that produced no usable PDF: no data at all
+// (typically the page navigated away or crashed while printing), or data
+// that is not a PDF. It is returned to the client as JSON with 502, since
+// Chrome is the upstream that failed.
+type emptyPDFError struct {
+	Code     string `json:"error"`
+	Message  string `json:"message"`
+	Attempts int    `json:"attempts"`
+}
+
+func (e *emptyPDFError) Error() string {
+	return e.Message
+}
+
+// checkPDFOutput classifies the result of a print: a missing-data error or
+// output without the %PDF- header become an *emptyPDFError.
+func checkPDFOutput(pdf []byte, err error) error {
+	switch {
+	case errors.Is(err, errMissingPDFData):
+		return &emptyPDFError{Code: "empty_pdf", Message: "chrome returned an empty PDF", Attempts: 1}
+	case err != nil:
+		return err
+	case !bytes.HasPrefix(pdf, []byte("%PDF-")):
+		return &emptyPDFError{Code: "invalid_pdf", Message: fmt.Sprintf("chrome returned %d bytes that are not a PDF", len(pdf)), Attempts: 1}
+	}
+	return nil
+}
+
+// emptyPDFRetryRenderer renders again, up to retries times, when the print
+// produced no usable PDF. Other errors are returned at once.
+func emptyPDFRetryRenderer(retries int, next pdfRenderer) pdfRenderer {
+	if retries <= 0 {
+		return next
+	}
+	return func(ctx context.Context, wsURL, html string, wait time.Duration, options pdfOptions) ([]byte, time.Duration, error) {
+		var total time.Duration
+		for attempt := 1; ; attempt++ {
+			pdf, pdfTime, err := next(ctx, wsURL, html, wait, options)
+			total += pdfTime
+			var emptyErr *emptyPDFError
+			if !errors.As(err, &emptyErr) {
+				return pdf, total, err
+			}
+			emptyErr.Attempts = attempt
+			if attempt > retries || ctx.Err() != nil {
+				return nil, total, err
+			}
+			Warnf("%s, retrying (%d/%d)", emptyErr.Message, attempt, retries)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Accuracy reported with an emulated position when the request gives none,
+// in meters.
+const defaultGeolocationAccuracy = 100
+
+// Longest user_agent accepted.
+const maxUserAgentLength = 512
+
+// emulatedMediaTypes are the values of the emulate_media option.
+var emulatedMediaTypes = []string{"screen", "print"}
+
+// Values of the color_scheme and reduced_motion options, emulated as the
+// prefers-color-scheme and prefers-reduced-motion media features.
+var (
+	colorSchemes        = []string{"light", "dark"}
+	reducedMotionValues = []string{"reduce", "no-preference"}
+)
+
+// promptPermissions are the permissions a page can prompt for. Nobody is there
+// to answer, so each render settles them up front: denied, unless listed in
+// CHROME_GRANT_PERMISSIONS.
+var promptPermissions = []string{
+	"geolocation", "notifications", "clipboard-read", "clipboard-write",
+	"camera", "microphone", "midi", "display-capture", "idle-detection",
+}
+
+// geolocationOptions is the position reported to the page by the geolocation
+// option.
+type geolocationOptions struct {
+	Latitude  float64
+	Longitude float64
+	Accuracy  float64
+}
+
+// parseGeolocation parses "latitude,longitude[,accuracy]".
+func parseGeolocation(value string) (*geolocationOptions, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 2 && len(parts) != 3 {
+		return nil, fmt.Errorf("invalid geolocation")
+	}
+	numbers := make([]float64, len(parts))
+	for i, part := range parts {
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid geolocation")
+		}
+		numbers[i] = parsed
+	}
+	geo := &geolocationOptions{Latitude: numbers[0], Longitude: numbers[1], Accuracy: defaultGeolocationAccuracy}
+	if len(numbers) == 3 {
+		geo.Accuracy = numbers[2]
+	}
+	if geo.Latitude < -90 || geo.Latitude > 90 || geo.Longitude < -180 || geo.Longitude > 180 || geo.Accuracy < 0 {
+		return nil, fmt.Errorf("invalid geolocation")
+	}
+	return geo, nil
+}
+
+// settlePermissions grants the permissions in grant and denies the other
+// promptPermissions, for all origins of the render's browser context (the
+// default one when browserContextID is empty), so permission requests are
+// answered at once instead of waiting for a click.
+func settlePermissions(ctx context.Context, client *cdpClient, browserContextID string, grant []string) error {
+	for _, name := range promptPermissions {
+		setting := "denied"
+		if containsString(grant, name) {
+			setting = "granted"
+		}
+		params := map[string]any{
+			"permission": map[string]any{"name": name},
+			"setting":    setting,
+		}
+		if browserContextID != "" {
+			params["browserContextId"] = browserContextID
+		}
+		if err := client.Call(ctx, "", "Browser.setPermission", params, nil); err != nil {
+			return fmt.Errorf("set permission %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// emulateGeolocation makes the page see options.Geolocation as its position:
+// the geolocation permission is granted in the render's browser context (the
+// default one when browserContextID is empty) and the position is overridden
+// for the session. Browser.setPermission changes that one permission, so the
+// others settlePermissions set stay as they are. The returned function clears
+// the override, since a page websocket (CHROME_WS) is reused across renders.
+func emulateGeolocation(ctx context.Context, client *cdpClient, sessionID, browserContextID string, options pdfOptions) (func(), error) {
+	geo := options.Geolocation
+	if geo == nil {
+		return func() {}, nil
+	}
+	grant := map[string]any{
+		"permission": map[string]any{"name": "geolocation"},
+		"setting":    "granted",
+	}
+	if browserContextID != "" {
+		grant["browserContextId"] = browserContextID
+	}
+	if err := client.Call(ctx, "", "Browser.setPermission", grant, nil); err != nil {
+		return nil, err
+	}
+	if err := client.Call(ctx, sessionID, "Emulation.setGeolocationOverride", map[string]any{
+		"latitude":  geo.Latitude,
+		"longitude": geo.Longitude,
+		"accuracy":  geo.Accuracy,
+	}, nil); err != nil {
+		return nil, err
+	}
+	return func() {
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		if err := client.Call(cleanupCtx, sessionID, "Emulation.clearGeolocationOverride", nil, nil); err != nil {
+			Warnf("chrome clear geolocation error: %v", err)
+		}
+	}, nil
+}
+
+// emulateMedia sets the CSS media type the page is laid out for
+// (options.EmulateMedia), so a page styled for the screen can be printed as it
+// looks on screen instead of through its print stylesheet, and the
+// prefers-color-scheme and prefers-reduced-motion media features
+// (options.ColorScheme, options.ReducedMotion), so themed pages render their
+// dark variant. The returned function restores the defaults, since a page
+// websocket (CHROME_WS) is reused across renders.
+func emulateMedia(ctx context.Context, client *cdpClient, sessionID string, options pdfOptions) (func(), error) {
+	features := []map[string]string{}
+	if options.ColorScheme != "" {
+		features = append(features, map[string]string{"name": "prefers-color-scheme", "value": options.ColorScheme})
+	}
+	if options.ReducedMotion != "" {
+		features = append(features, map[string]string{"name": "prefers-reduced-motion", "value": options.ReducedMotion})
+	}
+	if options.EmulateMedia == "" && len(features) == 0 {
+		return func() {}, nil
+	}
+	params := map[string]any{"media": options.EmulateMedia, "features": features}
+	if err := client.Call(ctx, sessionID, "Emulation.setEmulatedMedia", params, nil); err != nil {
+		return nil, err
+	}
+	return func() {
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		reset := map[string]any{"media": "", "features": []map[string]string{}}
+		if err := client.Call(cleanupCtx, sessionID, "Emulation.setEmulatedMedia", reset, nil); err != nil {
+			Warnf("chrome clear emulated media error: %v", err)
+		}
+	}, nil
+}
+
+// overrideUserAgent makes the page requests and navigator.userAgent use
+// options.UserAgent, for origin servers that serve different markup to
+// headless Chrome. The returned function restores Chrome's own, since a page
+// websocket (CHROME_WS) is reused across renders.
+func overrideUserAgent(ctx context.Context, client *cdpClient, sessionID string, options pdfOptions) (func(), error) {
+	if options.UserAgent == "" {
+		return func() {}, nil
+	}
+	if err := client.Call(ctx, sessionID, "Emulation.setUserAgentOverride", map[string]any{"userAgent": options.UserAgent}, nil); err != nil {
+		return nil, err
+	}
+	return func() {
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		// An empty user agent removes the override.
+		if err := client.Call(cleanupCtx, sessionID, "Emulation.setUserAgentOverride", map[string]any{"userAgent": ""}, nil); err != nil {
+			Warnf("chrome clear user agent error: %v", err)
+		}
+	}, nil
+}
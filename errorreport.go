@@ -0,0 +1,325 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// Kinds of reported errors, sent as the "kind" tag.
+const (
+	errorKindRender = "render"
+	errorKindPanic  = "panic"
+	errorKindChrome = "chrome"
+)
+
+// errorReporter sends error events to a Sentry-compatible store endpoint
+// (ERROR_REPORT_DSN). Events are queued and posted in the background; when
+// the queue is full they are dropped, so a failing collector never slows
+// down the requests.
+type errorReporter struct {
+	storeURL    string
+	auth        string
+	environment string
+	release     string
+	serverName  string
+	client      *http.Client
+	queue       chan errorEvent
+}
+
+// errorReports is the reporter of the process, nil when error reporting is
+// disabled; set by configureErrorReporting.
+var errorReports *errorReporter
+
+// errorEvent is a Sentry event, with the subset of the event payload
+// pdfrest fills in.
+type errorEvent struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   string            `json:"timestamp"`
+	Level       string            `json:"level"`
+	Platform    string            `json:"platform"`
+	Logger      string            `json:"logger"`
+	ServerName  string            `json:"server_name,omitempty"`
+	Release     string            `json:"release,omitempty"`
+	Environment string            `json:"environment,omitempty"`
+	Message     string            `json:"message"`
+	Exception   *errorException   `json:"exception,omitempty"`
+	Request     *errorRequest     `json:"request,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Extra       map[string]string `json:"extra,omitempty"`
+}
+
+type errorException struct {
+	Values []errorExceptionValue `json:"values"`
+}
+
+type errorExceptionValue struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// errorRequest is the HTTP request an error happened in. Only headers that
+// cannot carry credentials are reported.
+type errorRequest struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// reportedRequestHeaders are the request headers sent with error events.
+var reportedRequestHeaders = []string{"User-Agent", "Content-Type", "Content-Length", "Prefer"}
+
+type errorRequestContextKey struct{}
+
+// parseErrorReportDSN returns the store URL and the X-Sentry-Auth key
+// fields of a DSN like https://<public key>[:<secret>]@host[/path]/<project>.
+func parseErrorReportDSN(dsn string) (storeURL, auth string, err error) {
+	parsed, err := url.Parse(dsn)
+	if err != nil {
+		return "", "", err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" || parsed.Host == "" {
+		return "", "", fmt.Errorf("expected an http(s) URL")
+	}
+	if parsed.User == nil || parsed.User.Username() == "" {
+		return "", "", fmt.Errorf("missing public key")
+	}
+	path := strings.TrimSuffix(parsed.Path, "/")
+	slash := strings.LastIndex(path, "/")
+	project := path[slash+1:]
+	if project == "" {
+		return "", "", fmt.Errorf("missing project ID")
+	}
+	auth = "sentry_key=" + parsed.User.Username()
+	if secret, ok := parsed.User.Password(); ok {
+		auth += ", sentry_secret=" + secret
+	}
+	storeURL = parsed.Scheme + "://" + parsed.Host + path[:slash] + "/api/" + project + "/store/"
+	return storeURL, auth, nil
+}
+
+// newErrorReporter returns nil when ERROR_REPORT_DSN is empty (reporting
+// disabled). The DSN itself is validated by loadConfig.
+func newErrorReporter(cfg config) (*errorReporter, error) {
+	if cfg.ErrorReportDSN == "" {
+		return nil, nil
+	}
+	storeURL, auth, err := parseErrorReportDSN(cfg.ErrorReportDSN)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ERROR_REPORT_DSN: %w", err)
+	}
+	serverName, _ := os.Hostname()
+	return &errorReporter{
+		storeURL:    storeURL,
+		auth:        auth,
+		environment: cfg.ErrorReportEnvironment,
+		release:     "pdfrest@" + currentBuildInfo().Version,
+		serverName:  serverName,
+		client:      &http.Client{Timeout: cfg.ErrorReportTimeout},
+		queue:       make(chan errorEvent, defaultErrorReportQueue),
+	}, nil
+}
+
+// configureErrorReporting installs the reporter of ERROR_REPORT_DSN and
+// starts its sender.
+func configureErrorReporting(cfg config) error {
+	reporter, err := newErrorReporter(cfg)
+	if err != nil {
+		return err
+	}
+	if reporter != nil {
+		go reporter.run()
+		Infof("error reporting: %s", reporter.storeURL)
+	}
+	errorReports = reporter
+	return nil
+}
+
+// run posts the queued events.
+func (e *errorReporter) run() {
+	for event := range e.queue {
+		if err := e.post(event); err != nil {
+			Warnf("error report %s: %v", event.EventID, err)
+		}
+	}
+}
+
+// post sends one event to the store endpoint.
+func (e *errorReporter) post(event errorEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, e.storeURL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", fmt.Sprintf("Sentry sentry_version=7, sentry_client=pdfrest/%s, %s", currentBuildInfo().Version, e.auth))
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector answered %s", resp.Status)
+	}
+	return nil
+}
+
+// report queues an event for err, with the request ID, key policy and HTTP
+// request of ctx. A nil reporter reports nothing.
+func (e *errorReporter) report(ctx context.Context, kind string, err error, extra map[string]string) {
+	if e == nil {
+		return
+	}
+	event := errorEvent{
+		EventID:     newEventID(),
+		Timestamp:   time.Now().UTC().Format(time.RFC3339Nano),
+		Level:       "error",
+		Platform:    "go",
+		Logger:      "pdfrest",
+		ServerName:  e.serverName,
+		Release:     e.release,
+		Environment: e.environment,
+		Message:     err.Error(),
+		Exception:   &errorException{Values: []errorExceptionValue{{Type: fmt.Sprintf("%T", err), Value: err.Error()}}},
+		Tags:        map[string]string{"kind": kind},
+		Extra:       extra,
+	}
+	if kind == errorKindPanic {
+		event.Level = "fatal"
+	}
+	if requestID := requestIDFromContext(ctx); requestID != "" {
+		event.Tags["request_id"] = requestID
+	}
+	if policy, ok := policyFromContext(ctx); ok {
+		event.Tags["key_id"] = policy.ID
+	}
+	if request, ok := ctx.Value(errorRequestContextKey{}).(*errorRequest); ok {
+		event.Request = request
+	}
+	select {
+	case e.queue <- event:
+	default:
+		Warnf("error report dropped: queue full (%s)", err)
+	}
+}
+
+// newEventID returns a random 32 hex digit event ID.
+func newEventID() string {
+	var buf [16]byte
+	_, _ = rand.Read(buf[:])
+	return hex.EncodeToString(buf[:])
+}
+
+// newErrorRequest returns the reported form of r.
+func newErrorRequest(r *http.Request) *errorRequest {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	request := &errorRequest{Method: r.Method, URL: scheme + "://" + r.Host + r.URL.Path}
+	for _, name := range reportedRequestHeaders {
+		if value := r.Header.Get(name); value != "" {
+			if request.Headers == nil {
+				request.Headers = map[string]string{}
+			}
+			request.Headers[name] = value
+		}
+	}
+	return request
+}
+
+// errorReportMiddleware attaches the request to the context of next for the
+// reported errors, and recovers its panics: they are logged and reported
+// with their stack, and answered with a 500 when nothing was written yet.
+func errorReportMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), errorRequestContextKey{}, newErrorRequest(r))
+		r = r.WithContext(ctx)
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+			stack := string(debug.Stack())
+			Errorf("panic serving %s %s: %v\n%s", r.Method, r.URL.Path, recovered, stack)
+			err, ok := recovered.(error)
+			if !ok {
+				err = fmt.Errorf("%v", recovered)
+			}
+			errorReports.report(ctx, errorKindPanic, err, map[string]string{"stack": stack})
+			if rw, ok := w.(*responseWriter); !ok || rw.bytes == 0 {
+				http.Error(w, "internal server error", http.StatusInternalServerError)
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isReportedRenderError reports whether a render failure is the service's
+// own: not a rejection, a limit or a problem of the document or options the
+// caller sent, whose answers already tell the caller what went wrong.
+func isReportedRenderError(err error) bool {
+	var printErr *printOptionsError
+	var backendErr *backendError
+	var assetsErr *brokenAssetsError
+	var jsErr *jsErrorsError
+	var quotaErr *quotaError
+	var limitErr *outputLimitError
+	var sandboxErr *sandboxError
+	var queueErr *queueError
+	var preErr *preProcessError
+	var postErr *postProcessError
+	switch {
+	case errors.Is(err, errRenderCancelled), errors.Is(err, context.Canceled):
+		return false
+	case errors.As(err, &backendErr):
+		return backendErr.status >= http.StatusInternalServerError
+	case errors.As(err, &postErr):
+		return postErr.status >= http.StatusInternalServerError
+	case errors.As(err, &printErr), errors.As(err, &assetsErr), errors.As(err, &jsErr), errors.As(err, &quotaErr),
+		errors.As(err, &limitErr), errors.As(err, &sandboxErr), errors.As(err, &queueErr), errors.As(err, &preErr):
+		return false
+	}
+	return true
+}
+
+// reportingRenderer reports the failures of next that are the service's own
+// (isReportedRenderError), unless the caller went away.
+func reportingRenderer(reporter *errorReporter, next pdfRenderer) pdfRenderer {
+	if reporter == nil {
+		return next
+	}
+	return func(ctx context.Context, wsURL, html string, wait time.Duration, options pdfOptions) ([]byte, time.Duration, error) {
+		pdf, pdfTime, err := next(ctx, wsURL, html, wait, options)
+		if err != nil && ctx.Err() == nil && isReportedRenderError(err) {
+			extra := map[string]string{"mode": "html"}
+			if options.URL != "" {
+				extra["mode"] = "url"
+			}
+			if options.Backend != "" {
+				extra["backend"] = options.Backend
+			}
+			reporter.report(ctx, errorKindRender, err, extra)
+		}
+		return pdf, pdfTime, err
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pdfETag is the strong ETag of a rendered document: the hash of its bytes.
+func pdfETag(pdf []byte) string {
+	sum := sha256.Sum256(pdf)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header lists etag. The
+// comparison is weak, as RFC 9110 requires for If-None-Match. "*" matches
+// nothing: the service keeps no current representation of a render.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// renderETag is the strong ETag of the render of html with options: the
+// hash of the input, the resolved options (key policy defaults included)
+// and the service version, which together decide the document. Like
+// renderKey it is known before rendering, so a client that already holds
+// the document is spared the render.
+func renderETag(html string, wait time.Duration, options pdfOptions) string {
+	// The queue position does not change the document.
+	options.Priority = ""
+	encoded, _ := json.Marshal(options)
+	sum := sha256.New()
+	for _, part := range []string{currentBuildInfo().Version, html, wait.String(), string(encoded)} {
+		sum.Write([]byte(part))
+		sum.Write([]byte{0})
+	}
+	return `"` + hex.EncodeToString(sum.Sum(nil)) + `"`
+}
+
+// urlRenderETag is the renderETag of a GET render. The page behind the URL
+// changes without the request changing, so the window of now stands in for
+// the input: the tag changes every window, and within one a cache
+// revalidating the document is answered without rendering.
+func urlRenderETag(wait, window time.Duration, options pdfOptions, now time.Time) string {
+	return renderETag(strconv.FormatInt(now.UnixNano()/int64(window), 10), wait, options)
+}
@@ -0,0 +1,178 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// configVars are the environment variables read by loadConfig. Each one has a
+// command-line flag of the same name in kebab case (ADDR is --addr), which
+// takes precedence over the variable. DEFAULT_<OPTION>,
+// POST_PROCESS_<STAGE>_CMD and RENDER_BACKEND_<NAME>_CMD have flags too
+// (--default-format, --post-process-pdfa-cmd, --render-backend-weasyprint-cmd).
+var configVars = []string{
+	"ADDR", "SOCKET_MODE",
+	"CHROME_ENDPOINT", "CHROME_ENDPOINT_MAX_SESSIONS", "CHROME_MAX_SESSIONS", "CHROME_WS",
+	"CHROME_TOKEN", "CHROME_AUTH_HEADER", "CHROME_PROXY", "CHROME_TLS_CA_FILE", "CHROME_TLS_CERT_FILE",
+	"CHROME_TLS_KEY_FILE", "CHROME_TLS_INSECURE_SKIP_VERIFY", "CDP_KEEPALIVE_INTERVAL", "CDP_KEEPALIVE_TIMEOUT",
+	"CDP_COMPRESSION", "CDP_MAX_FRAME_BYTES", "CDP_MAX_MESSAGE_BYTES", "CDP_SLOW_CALL",
+	"CHROME_PROBE_INTERVAL", "CHROME_WS_CACHE_TTL", "CHROME_ISOLATE_CONTEXTS", "CHROME_ORPHAN_TARGET_AGE",
+	"CHROME_GRANT_PERMISSIONS", "CHROME_MODE", "CHROME_PATH", "CHROME_ARGS", "CHROME_DEBUG_PORT",
+	"CHROME_USER_DATA_DIR", "CHROME_CGROUP", "CHROME_MEMORY_LIMIT", "CHROME_CPU_LIMIT",
+	"CHROME_MAX_PROCESSES", "CHROME_MAX_OPEN_FILES", "CHROME_USER_NAMESPACE", "CHROME_READ_ONLY_FS",
+	"CHROME_WRITABLE_PATHS", "CHROME_SECCOMP_FILTER", "CHROME_RECYCLE_RENDERS", "CHROME_RECYCLE_AFTER",
+	"REQUEST_TIMEOUT", "MAX_BODY_BYTES", "MAX_PDF_BYTES", "PDF_WAIT",
+	"MAX_CONCURRENT_RENDERS", "MAX_RENDER_QUEUE", "RENDER_QUEUE_TIMEOUT", "SHUTDOWN_DRAIN_TIMEOUT",
+	"TLS_CERT_FILE", "TLS_KEY_FILE", "TLS_CLIENT_CA_FILE",
+	"POLICIES_FILE", "POST_PROCESS", "PRE_PROCESS",
+	"ASSET_ALLOWED_HOSTS", "ASSET_MAX_BYTES", "ASSET_FETCH_TIMEOUT", "PDFA_ICC_PROFILE",
+	"ADMIN_TOKEN", "AUTH_PROVIDER", "AUTH_STATIC_KEYS", "AUTH_JWT_SECRET", "AUTH_JWT_PUBLIC_KEY_FILE",
+	"AUTH_JWT_ISSUER", "AUTH_JWT_AUDIENCE", "AUTH_HMAC_KEYS", "AUTH_HMAC_MAX_SKEW",
+	"AUTH_WEBHOOK_URL", "AUTH_WEBHOOK_TIMEOUT", "OPA_URL", "OPA_TIMEOUT", "OPA_FAIL_OPEN",
+	"RENDER_ARCHIVE_DIR", "RENDER_ARCHIVE_RETENTION", "AUDIT_LOG", "AUDIT_TIMEOUT",
+	"ERROR_REPORT_DSN", "ERROR_REPORT_ENVIRONMENT", "ERROR_REPORT_TIMEOUT", "GOLDEN_FILE", "DEDUP_RENDERS",
+	"JOB_RETENTION", "MAX_JOBS", "ARTIFACT_DIR", "ARTIFACT_TTL", "ARTIFACT_MAX_BYTES",
+	"S3_ENDPOINT", "S3_REGION", "S3_BUCKET", "S3_ACCESS_KEY_ID", "S3_SECRET_ACCESS_KEY",
+	"S3_SESSION_TOKEN", "S3_PATH_STYLE", "S3_KEY_TEMPLATE", "S3_CONTENT_TYPE", "S3_SSE",
+	"S3_SSE_KMS_KEY_ID", "S3_CACHE_CONTROL", "S3_TIMEOUT", "S3_ATTEMPTS", "S3_RETRY_BACKOFF",
+	"DOWNLOAD_URL_SECRET", "DOWNLOAD_URL_EXPIRY", "RENDER_LINK_EXPIRY", "RENDER_LINK_MAX_EXPIRY",
+	"WEBHOOK_ALLOWED_HOSTS", "WEBHOOK_SECRET", "WEBHOOK_TIMEOUT", "WEBHOOK_ATTEMPTS", "WEBHOOK_RETRY_BACKOFF",
+	"TEMPLATE_DIR", "TEMPLATE_RETENTION", "PDF_DECODE_MODE", "PDF_TRANSFER_MODE",
+	"URL_ALLOWED_HOSTS", "PDF_GET_CACHE_CONTROL", "PDF_GET_ETAG_WINDOW",
+	"BATCH_MAX_ITEMS", "BATCH_CONCURRENCY", "BATCH_TIMEOUT",
+	"BLOCK_REMOTE_REQUESTS", "REMOTE_ALLOWED_HOSTS", "SUBRESOURCE_ALLOW", "SUBRESOURCE_DENY",
+	"CORS_ALLOWED_ORIGINS", "CORS_ALLOWED_METHODS", "CORS_ALLOWED_HEADERS", "CORS_EXPOSED_HEADERS", "CORS_MAX_AGE",
+	"CORS_ALLOW_CREDENTIALS", "LOG_PAGE_CONSOLE", "LOG_LEVEL", "LOG_FORMAT", "ACCESS_LOG_FORMAT",
+	"ACCESS_LOG_SKIP_PROBES", "EMPTY_PDF_RETRIES", "RENDER_BACKEND", "RENDER_FALLBACK_BACKEND",
+}
+
+// boolConfigVars are the configVars whose flag may be given without a value
+// (--dedup-renders means --dedup-renders=true).
+var boolConfigVars = []string{
+	"CHROME_ISOLATE_CONTEXTS", "CHROME_USER_NAMESPACE", "CHROME_READ_ONLY_FS", "CHROME_TLS_INSECURE_SKIP_VERIFY",
+	"CDP_COMPRESSION", "OPA_FAIL_OPEN", "DEDUP_RENDERS", "S3_PATH_STYLE", "BLOCK_REMOTE_REQUESTS", "LOG_PAGE_CONSOLE",
+	"ACCESS_LOG_SKIP_PROBES", "CORS_ALLOW_CREDENTIALS",
+}
+
+// errVersion is returned by parseFlags for --version.
+var errVersion = errors.New("version requested")
+
+// envFlag is a flag that sets an environment variable.
+type envFlag struct {
+	name    string
+	value   *string
+	boolean bool
+}
+
+func (f *envFlag) String() string {
+	if f.value == nil {
+		return ""
+	}
+	return *f.value
+}
+
+func (f *envFlag) Set(value string) error {
+	f.value = &value
+	return nil
+}
+
+func (f *envFlag) IsBoolFlag() bool { return f.boolean }
+
+// flagName returns the flag of an environment variable.
+func flagName(envVar string) string {
+	return strings.ReplaceAll(strings.ToLower(envVar), "_", "-")
+}
+
+// parseFlags parses the command line and returns the environment variables
+// it sets. --help and --version return flag.ErrHelp and errVersion, after
+// writing the usage to output for the former.
+func parseFlags(args []string, output io.Writer) (map[string]string, error) {
+	fs := flag.NewFlagSet("pdfrest", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.Usage = func() {
+		fmt.Fprintf(output, "Usage: pdfrest [flags]\n\n"+
+			"Every flag sets the environment variable named in its description and\n"+
+			"takes precedence over it. Flags also exist for DEFAULT_<OPTION>,\n"+
+			"POST_PROCESS_<STAGE>_CMD and RENDER_BACKEND_<NAME>_CMD (--default-format,\n"+
+			"--post-process-pdfa-cmd, --render-backend-weasyprint-cmd).\n\n")
+		fs.PrintDefaults()
+	}
+	version := fs.Bool("version", false, "print the version and exit")
+
+	envVars := append([]string{}, configVars...)
+	for _, stage := range postProcessStages {
+		envVars = append(envVars, "POST_PROCESS_"+strings.ToUpper(stage)+"_CMD")
+	}
+	// DEFAULT_<OPTION> and RENDER_BACKEND_<NAME>_CMD flags are registered
+	// for the names on the command line, since they have no list of their
+	// own.
+	for _, arg := range args {
+		name, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		backendCmd := strings.HasPrefix(name, "render-backend-") && strings.HasSuffix(name, "-cmd")
+		if strings.HasPrefix(arg, "-") && (strings.HasPrefix(name, "default-") || backendCmd) {
+			envVars = append(envVars, strings.ToUpper(strings.ReplaceAll(name, "-", "_")))
+		}
+	}
+
+	var flags []*envFlag
+	for _, envVar := range envVars {
+		if fs.Lookup(flagName(envVar)) != nil {
+			continue
+		}
+		f := &envFlag{name: envVar, boolean: containsString(boolConfigVars, envVar)}
+		usage := "sets `" + envVar + "`"
+		if f.boolean {
+			usage = "sets " + envVar
+		}
+		fs.Var(f, flagName(envVar), usage)
+		flags = append(flags, f)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return nil, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	if *version {
+		return nil, errVersion
+	}
+	env := map[string]string{}
+	for _, f := range flags {
+		if f.value != nil {
+			env[f.name] = *f.value
+		}
+	}
+	return env, nil
+}
+
+// applyFlags sets the environment variables of the command-line flags, so
+// that loadConfig reads them instead of the inherited ones. It exits for
+// --help, --version and invalid flags.
+func applyFlags(args []string) {
+	env, err := parseFlags(args, os.Stderr)
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		os.Exit(0)
+	case errors.Is(err, errVersion):
+		info := currentBuildInfo()
+		fmt.Printf("pdfrest %s (commit %s, built %s)\n", info.Version, info.GitCommit, info.BuildDate)
+		os.Exit(0)
+	case err != nil:
+		os.Exit(2)
+	}
+	for name, value := range env {
+		if err := os.Setenv(name, value); err != nil {
+			Errorf("set %s: %v", name, err)
+			os.Exit(1)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// postOnlyParams are the options that store or send the document elsewhere,
+// or start a job. GET renders leave out their server defaults (DEFAULT_*).
+var postOnlyParams = []string{"output", "deliver", "webhook_url", "preview_pages"}
+
+// parseURLRender sets up a GET /api/v1/pdf render: the page to print is
+// the url query parameter, which must be allowed by URL_ALLOWED_HOSTS.
+// GET renders are safe requests, so the postOnlyParams the caller sets,
+// in the query or through a preset, are rejected.
+func parseURLRender(query url.Values, allowedHosts []string, options *pdfOptions) error {
+	target := query.Get("url")
+	if target == "" {
+		return errors.New("missing url")
+	}
+	parsed, err := url.Parse(target)
+	if err != nil || !newHostAllowlist(allowedHosts).allowed(parsed) {
+		return errors.New("url not allowed")
+	}
+	switch {
+	case options.Output != "":
+		return errors.New("output requires POST")
+	case len(options.Deliver) > 0:
+		return errors.New("deliver requires POST")
+	case options.WebhookURL != "":
+		return errors.New("webhook_url requires POST")
+	case options.PreviewPages != "":
+		return errors.New("preview_pages requires POST")
+	}
+	options.URL = parsed.String()
+	return nil
+}
+
+// setCacheHeaders sets the validator and the caching headers of a GET or
+// HEAD render.
+func setCacheHeaders(header http.Header, etag, cacheControl string) {
+	header.Set("ETag", etag)
+	// Key policies and their defaults depend on the caller.
+	header.Add("Vary", headerAPIKey)
+	header.Add("Vary", "Authorization")
+	header.Set("Cache-Control", cacheControl)
+}
+
+// writeCacheablePDF answers a GET or HEAD render with the document, cacheable by
+// browsers and shared caches as PDF_GET_CACHE_CONTROL allows, or with 304
+// when the client already holds it. Without a window ETag (urlRenderETag)
+// the document is tagged with the hash of its bytes.
+func writeCacheablePDF(w http.ResponseWriter, r *http.Request, pdf []byte, etag, cacheControl string) {
+	if etag == "" {
+		etag = pdfETag(pdf)
+	}
+	header := w.Header()
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		setCacheHeaders(header, etag, cacheControl)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	setPDFHeaders(header, countPDFPages(pdf))
+	// Instead of no-store.
+	setCacheHeaders(header, etag, cacheControl)
+	header.Set("Content-Length", strconv.Itoa(len(pdf)))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(pdf)
+}
@@ -0,0 +1,287 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// goldenFixture is a built-in document rendered by /admin/golden. The
+// fixtures only use local fonts and no network resources, and skip the
+// pre/post-processing stages and branding, so their output only depends on
+// Chrome and the fonts installed next to it.
+type goldenFixture struct {
+	name    string
+	html    string
+	options pdfOptions
+}
+
+var goldenFixtures = []goldenFixture{
+	{
+		name: "text",
+		html: `<!doctype html><html><head><style>
+body { margin: 0; font-size: 11pt; line-height: 1.4; }
+.serif { font-family: serif; } .sans { font-family: sans-serif; } .mono { font-family: monospace; }
+h1 { font-family: sans-serif; font-size: 24pt; }
+</style></head><body>
+<h1>Golden text fixture</h1>
+<p class="serif">The quick brown fox jumps over the lazy dog. AVAWAY Ta To fi fl ffi — “quoted” text, 0123456789.</p>
+<p class="sans"><b>Bold</b>, <i>italic</i>, <u>underlined</u> and <small>small</small> sans-serif text that wraps over several lines so line breaking and kerning are part of the output. Lorem ipsum dolor sit amet, consectetur adipiscing elit, sed do eiusmod tempor incididunt ut labore et dolore magna aliqua.</p>
+<pre class="mono">func main() {
+	fmt.Println("monospace")
+}</pre>
+<p class="serif" style="text-align: justify">Justified: Ut enim ad minim veniam, quis nostrud exercitation ullamco laboris nisi ut aliquip ex ea commodo consequat. Duis aute irure dolor in reprehenderit in voluptate velit esse cillum dolore eu fugiat nulla pariatur.</p>
+<p class="sans">Àccénts ñ ß ø œ € £ ¥ © ® ™ ½ ≤ ≥ ± × ÷</p>
+</body></html>`,
+	},
+	{
+		name: "table",
+		html: `<!doctype html><html><head><style>
+body { font-family: sans-serif; font-size: 10pt; }
+table { width: 100%; border-collapse: collapse; }
+th, td { border: 1px solid #444; padding: 4px 6px; }
+th { background: #ddd; } td.amount { text-align: right; font-variant-numeric: tabular-nums; }
+thead { display: table-header-group; } tr { break-inside: avoid; }
+</style></head><body>
+<h2>Golden table fixture</h2>
+<table><thead><tr><th>#</th><th>Description</th><th>Qty</th><th>Amount</th></tr></thead><tbody>
+` + goldenTableRows(80) + `</tbody></table>
+</body></html>`,
+		options: pdfOptions{PrintBackground: boolPtr(true)},
+	},
+	{
+		name: "layout",
+		html: `<!doctype html><html><head><style>
+body { margin: 0; font-family: sans-serif; }
+header { background: #1d4ed8; color: #fff; padding: 16px; border-radius: 8px; }
+.grid { display: grid; grid-template-columns: repeat(3, 1fr); gap: 12px; margin: 16px 0; }
+.card { border: 2px solid #1d4ed8; border-radius: 6px; padding: 8px; box-shadow: 2px 2px 0 #93c5fd; }
+.row { display: flex; justify-content: space-between; align-items: center; }
+.gradient { height: 40px; background: linear-gradient(90deg, #f00, #0f0, #00f); }
+.break { break-before: page; }
+</style></head><body>
+<header class="row"><span>Golden layout fixture</span><span>Page one</span></header>
+<div class="grid"><div class="card">Grid cell one</div><div class="card">Grid cell two with more text</div><div class="card">Three</div>
+<div class="card">Four</div><div class="card">Five</div><div class="card">Six</div></div>
+<div class="gradient"></div>
+<h2 class="break">Second page</h2>
+<p style="columns: 2; column-gap: 24px">Multi-column text. Sed ut perspiciatis unde omnis iste natus error sit voluptatem accusantium doloremque laudantium, totam rem aperiam, eaque ipsa quae ab illo inventore veritatis et quasi architecto beatae vitae dicta sunt explicabo.</p>
+</body></html>`,
+		options: pdfOptions{PrintBackground: boolPtr(true), Landscape: boolPtr(true)},
+	},
+	{
+		name: "svg",
+		html: `<!doctype html><html><body>
+<svg xmlns="http://www.w3.org/2000/svg" width="600" height="400" viewBox="0 0 600 400">
+<rect x="10" y="10" width="200" height="120" fill="#fde68a" stroke="#92400e" stroke-width="3"/>
+<circle cx="340" cy="80" r="60" fill="none" stroke="#065f46" stroke-width="6" stroke-dasharray="12 6"/>
+<path d="M20 300 C 120 180, 220 420, 320 300 S 520 180, 580 300" fill="none" stroke="#7c3aed" stroke-width="4"/>
+<polygon points="450,20 500,120 400,120" fill="#fecaca" opacity="0.7"/>
+<text x="20" y="380" font-family="serif" font-size="28">SVG text</text>
+</svg>
+</body></html>`,
+	},
+}
+
+func goldenTableRows(n int) string {
+	var rows strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&rows, "<tr><td>%d</td><td>Line item %d with a description</td><td>%d</td><td class=\"amount\">%d.%02d</td></tr>\n", i, i, i%7+1, i*37, i%100)
+	}
+	return rows.String()
+}
+
+// goldenEntry is the recorded output of one fixture.
+type goldenEntry struct {
+	pdfDigest
+	Pages int `json:"pages"`
+}
+
+// goldenSet is the content of GOLDEN_FILE.
+type goldenSet struct {
+	RecordedAt time.Time              `json:"recorded_at"`
+	Version    string                 `json:"version"`
+	Chrome     string                 `json:"chrome,omitempty"`
+	Fixtures   map[string]goldenEntry `json:"fixtures"`
+}
+
+// goldenResult is the outcome of one fixture in the /admin/golden report.
+// Status is "match", "drift", "new" (nothing recorded for the fixture yet)
+// or "error".
+type goldenResult struct {
+	Name     string       `json:"name"`
+	Status   string       `json:"status"`
+	Expected *goldenEntry `json:"expected,omitempty"`
+	Actual   *goldenEntry `json:"actual,omitempty"`
+	Error    string       `json:"error,omitempty"`
+}
+
+// goldenReport is the response of /admin/golden.
+type goldenReport struct {
+	Drift        bool           `json:"drift"`
+	Updated      bool           `json:"updated,omitempty"`
+	Chrome       string         `json:"chrome,omitempty"`
+	GoldenChrome string         `json:"golden_chrome,omitempty"`
+	RecordedAt   *time.Time     `json:"recorded_at,omitempty"`
+	Fixtures     []goldenResult `json:"fixtures"`
+}
+
+// goldenStore holds the recorded goldens, persisted to path when set.
+type goldenStore struct {
+	path string
+
+	mu  sync.Mutex
+	set *goldenSet
+}
+
+// newGoldenStore loads the goldens from path; a missing file means nothing
+// is recorded yet.
+func newGoldenStore(path string) (*goldenStore, error) {
+	store := &goldenStore{path: path}
+	if path == "" {
+		return store, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var set goldenSet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	store.set = &set
+	return store, nil
+}
+
+func (g *goldenStore) current() *goldenSet {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.set
+}
+
+// record replaces the goldens and writes them to the file, if any.
+func (g *goldenStore) record(set goldenSet) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.path != "" {
+		data, err := json.MarshalIndent(set, "", "  ")
+		if err != nil {
+			return err
+		}
+		tmp := filepath.Join(filepath.Dir(g.path), "."+filepath.Base(g.path)+".tmp")
+		if err := os.WriteFile(tmp, append(data, '\n'), 0o640); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, g.path); err != nil {
+			_ = os.Remove(tmp)
+			return err
+		}
+	}
+	g.set = &set
+	return nil
+}
+
+// goldenHandler serves POST /admin/golden: it renders the built-in fixtures
+// and compares their content hashes with the recorded goldens, reporting
+// drift. With ?update=true the outputs are recorded as the new goldens
+// instead (after a deliberate Chrome or font upgrade).
+func goldenHandler(cfg config, resolver wsResolver, renderer pdfRenderer, store *goldenStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		update, _ := strconv.ParseBool(r.URL.Query().Get("update"))
+
+		ctx, cancel := context.WithTimeout(r.Context(), cfg.RequestTimeout)
+		defer cancel()
+		wsURL, err := resolver.wsURL(ctx)
+		if err != nil {
+			Errorf("chrome ws error: %v", err)
+			http.Error(w, "chrome unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		statusCtx, statusCancel := context.WithTimeout(ctx, defaultChromeClientTimeout)
+		chrome := buildHealthReport(statusCtx, resolver, nil).Chrome.Browser
+		statusCancel()
+
+		golden := store.current()
+		report := goldenReport{Chrome: chrome}
+		if golden != nil {
+			report.GoldenChrome = golden.Chrome
+			report.RecordedAt = &golden.RecordedAt
+		}
+		recorded := goldenSet{
+			RecordedAt: time.Now().UTC(),
+			Version:    currentBuildInfo().Version,
+			Chrome:     chrome,
+			Fixtures:   map[string]goldenEntry{},
+		}
+		for _, fixture := range goldenFixtures {
+			result := goldenResult{Name: fixture.name}
+			options := fixture.options
+			options.PreProcess, options.PostProcess, options.NoBranding = []string{}, []string{}, true
+			pdf, _, err := renderer(ctx, wsURL, fixture.html, 0, options)
+			if err != nil {
+				result.Status, result.Error = "error", err.Error()
+				report.Drift = true
+				report.Fixtures = append(report.Fixtures, result)
+				continue
+			}
+			actual := goldenEntry{pdfDigest: digestPDF(pdf), Pages: countPDFPages(pdf)}
+			result.Actual = &actual
+			recorded.Fixtures[fixture.name] = actual
+			if expected, ok := golden.fixture(fixture.name); ok {
+				result.Expected = &expected
+				result.Status = "match"
+				if expected.ContentSHA256 != actual.ContentSHA256 {
+					result.Status = "drift"
+					report.Drift = true
+				}
+			} else {
+				result.Status = "new"
+			}
+			report.Fixtures = append(report.Fixtures, result)
+		}
+
+		if update {
+			if len(recorded.Fixtures) != len(goldenFixtures) {
+				writeJSON(w, http.StatusBadGateway, report)
+				return
+			}
+			if err := store.record(recorded); err != nil {
+				Errorf("golden record error: %v", err)
+				http.Error(w, "recording goldens failed", http.StatusInternalServerError)
+				return
+			}
+			report.Updated, report.GoldenChrome, report.RecordedAt = true, recorded.Chrome, &recorded.RecordedAt
+			Infof("golden: recorded %d fixtures (%s)", len(recorded.Fixtures), chrome)
+		} else if report.Drift {
+			Warnf("golden: render drift detected (chrome %s, goldens recorded with %s)", chrome, report.GoldenChrome)
+		}
+		writeJSON(w, http.StatusOK, report)
+	}
+}
+
+// fixture returns the golden of name; a nil set has none.
+func (s *goldenSet) fixture(name string) (goldenEntry, bool) {
+	if s == nil {
+		return goldenEntry{}, false
+	}
+	entry, ok := s.Fixtures[name]
+	return entry, ok
+}
@@ -9,16 +9,40 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
-func healthHandler(resolver wsResolver) http.HandlerFunc {
+// pageRangesRe matches a list of pages and page ranges, e.g. "1-3, 5".
+var pageRangesRe = regexp.MustCompile(`^\s*\d+(\s*-\s*\d+)?(\s*,\s*\d+(\s*-\s*\d+)?)*\s*$`)
+
+// livenessHandler reports that the process is up and serving HTTP. It has no
+// Chrome dependency, so orchestrators do not restart the pod while Chrome is
+// briefly unavailable.
+func livenessHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("ok"))
+	}
+}
+
+// healthHandler reports readiness: Chrome must be reachable. It backs both
+// /readyz and the legacy /healthz endpoint. With ?format=json (or an Accept
+// header asking for JSON) it returns a detailed report instead of "ok".
+func healthHandler(resolver wsResolver, limiter *renderLimiter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Health endpoints should be fast and side-effect free.
 		ctx, cancel := context.WithTimeout(r.Context(), defaultChromeClientTimeout)
 		defer cancel()
 
+		if wantsDetailedHealth(r) {
+			writeHealthReport(w, buildHealthReport(ctx, resolver, limiter))
+			return
+		}
+
 		if checker, ok := resolver.(interface {
 			checkChrome(ctx context.Context) error
 		}); ok {
@@ -36,82 +60,463 @@ func healthHandler(resolver wsResolver) http.HandlerFunc {
 	}
 }
 
+// pdfService serves the PDF endpoint. Optional dependencies may be nil.
+type pdfService struct {
+	cfg       config
+	resolver  wsResolver
+	renderer  pdfRenderer
+	templates *templateStore
+	archive   *renderArchive
+	authz     requestAuthorizer
+	jobs      *jobStore
+	s3        *s3Client
+	downloads *downloadSigner
+	policies  *policyStore
+	links     *renderLinkStore
+	webhooks  *webhookNotifier
+}
+
+// pdfHandler returns the PDF endpoint without the optional dependencies.
 func pdfHandler(cfg config, resolver wsResolver, renderer pdfRenderer) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		// Only POST is allowed.
-		if r.Method != http.MethodPost {
-			w.WriteHeader(http.StatusMethodNotAllowed)
-			return
+	return (&pdfService{cfg: cfg, resolver: resolver, renderer: renderer}).ServeHTTP
+}
+
+func (s *pdfService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Only POST is allowed, and GET (or HEAD) for url renders of PDFs.
+	urlRender := r.Method == http.MethodGet || r.Method == http.MethodHead
+	if r.Method != http.MethodPost && (!urlRender || r.URL.Path != pathPDF) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	r = withFreshDiscovery(r)
+
+	// Per-request timeout. This drives both Chrome discovery and PDF rendering.
+	ctx, cancel := context.WithTimeout(r.Context(), s.cfg.RequestTimeout)
+	defer cancel()
+
+	// Enforce maximum body size to protect memory.
+	r.Body = http.MaxBytesReader(w, r.Body, s.cfg.MaxBodyBytes)
+	defer func() {
+		if err := r.Body.Close(); err != nil {
+			Warnf("request body close error: %v", err)
 		}
+	}()
 
-		// Per-request timeout. This drives both Chrome discovery and PDF rendering.
-		ctx, cancel := context.WithTimeout(r.Context(), cfg.RequestTimeout)
-		defer cancel()
+	body, release, err := readRequestBody(r.Body)
+	if err != nil {
+		// Preserve original behavior: map specific read errors to an HTTP status.
+		http.Error(w, "invalid request body", mapBodyReadErrorToStatus(err))
+		return
+	}
+	defer release()
 
-		// Enforce maximum body size to protect memory.
-		r.Body = http.MaxBytesReader(w, r.Body, cfg.MaxBodyBytes)
-		defer func() {
-			if err := r.Body.Close(); err != nil {
-				Warnf("request body close error: %v", err)
-			}
-		}()
+	if len(body) == 0 && r.Method == http.MethodPost {
+		http.Error(w, "empty html", http.StatusBadRequest)
+		return
+	}
 
-		body, err := readRequestBody(r.Body)
-		if err != nil {
-			// Preserve original behavior: map specific read errors to an HTTP status.
-			http.Error(w, "invalid request body", mapBodyReadErrorToStatus(err))
+	// The server defaults of options a render cannot use are left out;
+	// only those the caller asks for are rejected.
+	defaults := s.cfg.DefaultOptions
+	switch {
+	case r.URL.Path == pathImage:
+		defaults = withoutOptions(defaults, pdfOnlyParams)
+	case urlRender:
+		defaults = withoutOptions(defaults, postOnlyParams)
+	}
+	values, err := s.optionValues(r.URL.Query(), defaults)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	options, err := parsePDFOptions(values)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	applyPolicyDefaults(r.Context(), s.cfg, &options)
+	if options.Priority, err = requestPriority(r, options.Priority, priorityNormal); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// GET prints the page at the url parameter, for links and tools that
+	// cannot POST. HEAD renders it too, for the headers.
+	if urlRender {
+		if err := parseURLRender(r.URL.Query(), s.cfg.URLAllowedHosts, &options); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	// POST /api/v1/image captures the page with Chrome instead of printing
+	// it; the options that shape the PDF do not apply.
+	if r.URL.Path == pathImage {
+		if options.Image, err = parseImageOptions(values); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if option := pdfOnlyOption(options); option != "" {
+			http.Error(w, option+" does not apply to images", http.StatusBadRequest)
 			return
 		}
+		if options.Backend == "" {
+			options.Backend = backendChrome
+		}
+		options.PostProcess = []string{}
+	}
+	if options.Output == outputS3 && s.s3 == nil {
+		http.Error(w, "s3 output is not configured", http.StatusBadRequest)
+		return
+	}
+	if err := s.checkDelivery(options); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-		if len(body) == 0 {
-			http.Error(w, "empty html", http.StatusBadRequest)
+	// Template mode: a JSON body carries a template (inline or stored) and
+	// the data to execute it with.
+	html := string(body)
+	var tmplReq *templateRequest
+	if options.URL == "" && isJSONRequest(r) {
+		rendered, pinned, err := renderTemplateBody(s.templates, body, options.Branding)
+		if err != nil {
+			writeTemplateError(w, err)
 			return
 		}
+		Debugf("rendered template %s: %d bytes", templateLabel(pinned), len(rendered))
+		html, tmplReq = rendered, &pinned
+	}
 
-		options, err := parsePDFOptions(r.URL.Query())
+	// A multipart body carries the HTML, a stylesheet in its css part and,
+	// in email mode, the parts the message's cid: URLs refer to.
+	if tmplReq == nil && options.URL == "" && isMultipartRequest(r) {
+		message, parts, err := readMultipartBody(r.Header.Get("Content-Type"), body)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		if part, ok := parts[cssPartName]; ok {
+			if options.CSS != "" {
+				http.Error(w, "css given both as a parameter and as a part", http.StatusBadRequest)
+				return
+			}
+			options.CSS = string(part.Data)
+			delete(parts, cssPartName)
+		}
+		html = message
+		if options.EmailMode {
+			options.EmailParts = parts
+		}
+	}
 
-		// Resolve Chrome websocket endpoint.
-		wsURL, err := resolver.wsURL(ctx)
-		if err != nil {
-			Errorf("chrome ws error: %v", err)
-			http.Error(w, "chrome unavailable", http.StatusServiceUnavailable)
+	input := authzInput{Mode: renderModeHTML}
+	if tmplReq != nil {
+		input.Mode, input.Template = renderModeTemplate, tmplReq.TemplateName
+	}
+	if err := s.authorize(r, input, options); err != nil {
+		writeAuthzError(w, err)
+		return
+	}
+
+	// Prefer: respond-async runs the render as a job; its network trace is
+	// part of the job status.
+	w.Header().Add("Vary", "Prefer")
+	if prefs := parsePrefer(r.Header); s.jobs != nil && options.Image == nil && r.Method == http.MethodPost {
+		if _, ok := prefs["respond-async"]; ok {
+			full := options
+			full.PreviewPages = ""
+			if s.startJob(w, r, html, tmplReq, full, prefs) {
+				return
+			}
+		}
+	}
+
+	// preview_pages answers with the first pages while the full document
+	// renders as a job. Without room for the job, the full document is
+	// rendered here.
+	if options.PreviewPages != "" {
+		full := options
+		full.PreviewPages = ""
+		if s.jobs != nil {
+			if job := s.launchJob(r, html, tmplReq, full); job != nil {
+				w.Header().Set(headerFullDocumentJob, pathJobs+"/"+job.ID)
+				// The preview itself is always returned in the response.
+				options.PageRanges, options.Preview, options.Output = options.PreviewPages, true, ""
+				options.Deliver, options.WebhookURL = nil, ""
+			}
+		}
+		options.PreviewPages = ""
+	}
+
+	// Conditional requests: the ETag of an HTML or template render is known
+	// before rendering. A POST whose If-None-Match lists it asks for a
+	// document the client already holds, so it fails with 412 without
+	// rendering. Renders that store or send the document elsewhere, or
+	// whose full document renders as a job, always run. GET renders are
+	// tagged by PDF_GET_ETAG_WINDOW, if set, and revalidate with 304.
+	var etag string
+	switch {
+	case urlRender:
+		if s.cfg.PDFGetETagWindow > 0 {
+			etag = urlRenderETag(s.cfg.PDFWait, s.cfg.PDFGetETagWindow, options, time.Now())
+		}
+	case options.Output == "" && len(options.Deliver) == 0 && w.Header().Get(headerFullDocumentJob) == "":
+		etag = renderETag(html, s.cfg.PDFWait, options)
+	}
+	if etag != "" && etagMatches(r.Header.Get("If-None-Match"), etag) {
+		if urlRender {
+			setCacheHeaders(w.Header(), etag, s.cfg.PDFGetCacheControl)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		http.Error(w, "precondition failed", http.StatusPreconditionFailed)
+		return
+	}
+
+	// Resolve Chrome websocket endpoint.
+	wsURL, err := s.resolver.wsURL(ctx)
+	if err != nil {
+		Errorf("chrome ws error: %v", err)
+		errorReports.report(ctx, errorKindChrome, err, nil)
+		http.Error(w, "chrome unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	// Optional diagnostics collected during the render.
+	var diag *renderDiagnostics
+	if options.TraceNetwork {
+		diag = &renderDiagnostics{}
+		ctx = withRenderDiagnostics(ctx, diag)
+	}
+
+	// Render PDF from HTML.
+	pdf, pdfTime, err := s.renderer(ctx, wsURL, html, s.cfg.PDFWait, options)
+	if rw, ok := w.(*responseWriter); ok && reachedChrome(err) {
+		rw.pdfTime = pdfTime
+		rw.pdfTimeSet = true
+	}
+	setRenderOutcome(w, r.Context(), err)
+	if err != nil {
+		if r.Context().Err() != nil {
+			// The client went away; its render was abandoned.
+			Infof("client disconnected, render abandoned: %v", err)
 			return
 		}
+		writeRenderError(w, err)
+		return
+	}
 
-		// Render PDF from HTML.
-		pdf, pdfTime, err := renderer(ctx, wsURL, string(body), cfg.PDFWait, options)
-		if rw, ok := w.(*responseWriter); ok {
-			rw.pdfTime = pdfTime
-			rw.pdfTimeSet = true
+	s.archiveRender(r, html, tmplReq, options, pdf)
+	if etag != "" && !urlRender {
+		w.Header().Set("ETag", etag)
+	}
+
+	if options.Image != nil {
+		writeImage(w, pdf, options.Image)
+		return
+	}
+	if options.Output == outputS3 {
+		object, err := s.s3.upload(ctx, pdf)
+		if err != nil {
+			Errorf("s3 upload error: %v", err)
+			http.Error(w, "upload failed", http.StatusBadGateway)
+			return
 		}
+		if s.downloads != nil {
+			s.downloads.objectURL(s.s3, object)
+		}
+		w.Header().Set(headerPDFPages, strconv.Itoa(object.Pages))
+		writeJSON(w, http.StatusOK, object)
+		return
+	}
+
+	// deliver fans the result out; the PDF is only in the response when
+	// "response" is one of the sinks, otherwise the delivery report is.
+	if len(options.Deliver) > 0 {
+		report, err := s.deliver(ctx, options, pdf)
 		if err != nil {
-			Errorf("render error: %v", err)
-			http.Error(w, "render failed", http.StatusInternalServerError)
+			Errorf("delivery error: %v", err)
+			http.Error(w, "upload failed", http.StatusBadGateway)
+			return
+		}
+		if !containsString(options.Deliver, sinkResponse) {
+			w.Header().Set(headerPDFPages, strconv.Itoa(report.Pages))
+			writeJSON(w, http.StatusOK, report)
 			return
 		}
+		if report.S3 != nil {
+			w.Header().Set(headerS3ObjectURL, report.S3.URL)
+		}
+	}
+
+	if diag != nil {
+		w.Header().Set(headerPDFPages, strconv.Itoa(countPDFPages(pdf)))
+		writePDFWithDiagnostics(w, pdf, diag, options.TraceFormat)
+		return
+	}
+	if urlRender {
+		writeCacheablePDF(w, r, pdf, etag, s.cfg.PDFGetCacheControl)
+		return
+	}
+	writePDF(w, pdf)
+}
 
-		// Response headers.
-		w.Header().Set("Content-Type", "application/pdf")
-		w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", pdfFilename))
+// writePDF answers with the rendered document. Its size is known, so the
+// response has a Content-Length instead of being chunked.
+func writePDF(w http.ResponseWriter, pdf []byte) {
+	setPDFHeaders(w.Header(), countPDFPages(pdf))
+	w.Header().Set("Content-Length", strconv.Itoa(len(pdf)))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(pdf)
+}
 
-		// Basic hardening headers (does not affect logic).
-		// These are safe defaults for an API returning binary content.
-		w.Header().Set("X-Content-Type-Options", "nosniff")
-		w.Header().Set("Cache-Control", "no-store")
+// setPDFHeaders sets the response headers of a PDF document.
+func setPDFHeaders(header http.Header, pages int) {
+	header.Set(headerPDFPages, strconv.Itoa(pages))
+	header.Set("Content-Type", "application/pdf")
+	header.Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", pdfFilename))
 
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write(pdf)
+	// Basic hardening headers (does not affect logic).
+	// These are safe defaults for an API returning binary content.
+	header.Set("X-Content-Type-Options", "nosniff")
+	header.Set("Cache-Control", "no-store")
+}
+
+// writeRenderError answers with a renderer error: limit and quota errors as
+// JSON, with Retry-After when the render can be retried later.
+func writeRenderError(w http.ResponseWriter, err error) {
+	var queueErr *queueError
+	if errors.As(err, &queueErr) {
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(queueErr.retryAfter)))
+	}
+	var limitErr *outputLimitError
+	if errors.As(err, &limitErr) {
+		Warnf("render rejected: %v", err)
+		writeJSON(w, limitErr.status, limitErr)
+		return
+	}
+	var emptyErr *emptyPDFError
+	if errors.As(err, &emptyErr) {
+		Errorf("render error: %v (%d attempts)", err, emptyErr.Attempts)
+		writeJSON(w, http.StatusBadGateway, emptyErr)
+		return
+	}
+	var assetsErr *brokenAssetsError
+	if errors.As(err, &assetsErr) {
+		Warnf("render rejected: %v", err)
+		writeJSON(w, http.StatusUnprocessableEntity, assetsErr)
+		return
+	}
+	var jsErr *jsErrorsError
+	if errors.As(err, &jsErr) {
+		Warnf("render rejected: %v", err)
+		writeJSON(w, http.StatusUnprocessableEntity, jsErr)
+		return
+	}
+	var quotaErr *quotaError
+	if errors.As(err, &quotaErr) {
+		Warnf("render rejected: %v", err)
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(quotaErr.retryAfter)))
+		writeJSON(w, http.StatusTooManyRequests, quotaErr)
+		return
+	}
+	status, msg := renderErrorStatus(err)
+	http.Error(w, msg, status)
+}
+
+// renderErrorStatus logs a renderer error and maps it to an HTTP status and
+// the message returned to the client.
+func renderErrorStatus(err error) (int, string) {
+	var queueErr *queueError
+	var preErr *preProcessError
+	var postErr *postProcessError
+	var limitErr *outputLimitError
+	var sandboxErr *sandboxError
+	var quotaErr *quotaError
+	var emptyErr *emptyPDFError
+	var assetsErr *brokenAssetsError
+	var jsErr *jsErrorsError
+	var printErr *printOptionsError
+	var backendErr *backendError
+	switch {
+	case errors.Is(err, errRenderCancelled):
+		Warnf("render error: %v", err)
+		return http.StatusServiceUnavailable, err.Error()
+	case errors.As(err, &printErr):
+		return http.StatusBadRequest, printErr.Error()
+	case errors.As(err, &backendErr):
+		Warnf("render rejected: %v", err)
+		return backendErr.status, backendErr.Error()
+	case errors.As(err, &assetsErr):
+		Warnf("render rejected: %v", err)
+		return http.StatusUnprocessableEntity, assetsErr.Message
+	case errors.As(err, &jsErr):
+		Warnf("render rejected: %v", err)
+		return http.StatusUnprocessableEntity, jsErr.Message
+	case errors.As(err, &emptyErr):
+		Errorf("render error: %v (%d attempts)", err, emptyErr.Attempts)
+		return http.StatusBadGateway, emptyErr.Message
+	case errors.As(err, &quotaErr):
+		Warnf("render rejected: %v", err)
+		return http.StatusTooManyRequests, quotaErr.Message
+	case errors.As(err, &limitErr):
+		Warnf("render rejected: %v", err)
+		return limitErr.status, limitErr.Message
+	case errors.As(err, &sandboxErr):
+		Warnf("render stopped by the sandbox: %v", err)
+		return http.StatusUnprocessableEntity, fmt.Sprintf("render exceeded the %s limit", sandboxErr.Resource)
+	case errors.As(err, &queueErr):
+		Warnf("render rejected: %v", err)
+		return http.StatusServiceUnavailable, "server busy"
+	case errors.As(err, &preErr):
+		Warnf("pre-process error: %v", err)
+		return preErr.status, preErr.err.Error()
+	case errors.As(err, &postErr) && postErr.status == http.StatusBadRequest:
+		return http.StatusBadRequest, postErr.err.Error()
+	case errors.As(err, &postErr):
+		Errorf("post-process error: %v", err)
+		return http.StatusInternalServerError, "post-processing failed"
+	default:
+		Errorf("render error: %v", err)
+		return http.StatusInternalServerError, "render failed"
+	}
+}
+
+// reachedChrome reports whether a render attempt got as far as Chrome, i.e.
+// it was not rejected by the limiter or the pre/post-processing validation.
+func reachedChrome(err error) bool {
+	var queueErr *queueError
+	var preErr *preProcessError
+	var postErr *postProcessError
+	var quotaErr *quotaError
+	if errors.As(err, &quotaErr) && quotaErr.Requested == 0 {
+		return false
+	}
+	return !errors.As(err, &queueErr) && !errors.As(err, &preErr) &&
+		!(errors.As(err, &postErr) && postErr.status == http.StatusBadRequest)
+}
+
+// retryAfterSeconds converts a delay into a Retry-After header value (whole
+// seconds, at least 1).
+func retryAfterSeconds(d time.Duration) int {
+	seconds := int((d + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		return 1
 	}
+	return seconds
 }
 
-// readRequestBody reads the body fully. The MaxBytesReader is already applied at the handler level.
-func readRequestBody(r io.Reader) ([]byte, error) {
-	// Keep the original semantics: ReadAll then validate len.
-	return io.ReadAll(r)
+// readRequestBody reads the body fully into a pooled buffer. The body is only
+// valid until release is called. The MaxBytesReader is already applied at the
+// handler level.
+func readRequestBody(r io.Reader) ([]byte, func(), error) {
+	// Keep the original semantics: read everything, then validate len.
+	buf := getBuffer()
+	if _, err := buf.ReadFrom(r); err != nil {
+		putBuffer(buf)
+		return nil, nil, err
+	}
+	return buf.Bytes(), func() { putBuffer(buf) }, nil
 }
 
 // mapBodyReadErrorToStatus keeps the current status mapping logic intact,
@@ -140,6 +545,21 @@ func parsePDFOptions(values map[string][]string) (pdfOptions, error) {
 		options.Landscape = &parsed
 	}
 
+	if value := getQueryValue(values, "orientation"); value != "" {
+		var landscape bool
+		switch strings.ToLower(value) {
+		case "portrait":
+		case "landscape":
+			landscape = true
+		default:
+			return options, fmt.Errorf("invalid orientation")
+		}
+		if options.Landscape != nil && *options.Landscape != landscape {
+			return options, fmt.Errorf("orientation and landscape disagree")
+		}
+		options.Landscape = &landscape
+	}
+
 	if value := getQueryValue(values, "scale"); value != "" {
 		parsed, err := strconv.ParseFloat(value, 64)
 		if err != nil {
@@ -164,8 +584,20 @@ func parsePDFOptions(values map[string][]string) (pdfOptions, error) {
 		options.PaperHeight = &parsed
 	}
 
+	if value := getQueryValue(values, "format"); value != "" {
+		size, ok := paperFormats[strings.ToLower(value)]
+		if !ok {
+			return options, fmt.Errorf("invalid format")
+		}
+		if options.PaperWidth != nil || options.PaperHeight != nil {
+			return options, fmt.Errorf("format and paper_width/paper_height are mutually exclusive")
+		}
+		width, height := size[0], size[1]
+		options.PaperWidth, options.PaperHeight = &width, &height
+	}
+
 	if value := getQueryValue(values, "margin_top"); value != "" {
-		parsed, err := strconv.ParseFloat(value, 64)
+		parsed, err := parseLength(value)
 		if err != nil {
 			return options, fmt.Errorf("invalid margin_top")
 		}
@@ -173,7 +605,7 @@ func parsePDFOptions(values map[string][]string) (pdfOptions, error) {
 	}
 
 	if value := getQueryValue(values, "margin_bottom"); value != "" {
-		parsed, err := strconv.ParseFloat(value, 64)
+		parsed, err := parseLength(value)
 		if err != nil {
 			return options, fmt.Errorf("invalid margin_bottom")
 		}
@@ -181,7 +613,7 @@ func parsePDFOptions(values map[string][]string) (pdfOptions, error) {
 	}
 
 	if value := getQueryValue(values, "margin_left"); value != "" {
-		parsed, err := strconv.ParseFloat(value, 64)
+		parsed, err := parseLength(value)
 		if err != nil {
 			return options, fmt.Errorf("invalid margin_left")
 		}
@@ -189,7 +621,7 @@ func parsePDFOptions(values map[string][]string) (pdfOptions, error) {
 	}
 
 	if value := getQueryValue(values, "margin_right"); value != "" {
-		parsed, err := strconv.ParseFloat(value, 64)
+		parsed, err := parseLength(value)
 		if err != nil {
 			return options, fmt.Errorf("invalid margin_right")
 		}
@@ -205,43 +637,417 @@ func parsePDFOptions(values map[string][]string) (pdfOptions, error) {
 	}
 
 	options.PageRanges = getQueryValue(values, "page_ranges")
+	if options.PageRanges != "" {
+		if err := checkPageRanges(options.PageRanges); err != nil {
+			return options, err
+		}
+	}
+
+	if list, ok := values["post_process"]; ok && len(list) > 0 {
+		options.PostProcess = parseStageList(list[0])
+		for _, stage := range options.PostProcess {
+			if !containsString(postProcessStages, stage) {
+				return options, fmt.Errorf("invalid post_process")
+			}
+		}
+	}
+
+	if list, ok := values["pre_process"]; ok && len(list) > 0 {
+		options.PreProcess = parseStageList(list[0])
+		for _, stage := range options.PreProcess {
+			if !containsString(preProcessStages, stage) {
+				return options, fmt.Errorf("invalid pre_process")
+			}
+		}
+	}
+
+	if value := getQueryValue(values, "inline_assets"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return options, fmt.Errorf("invalid inline_assets")
+		}
+		options.InlineAssets = parsed
+	}
+
+	options.CSS = getQueryValue(values, "css")
+
+	if value := getQueryValue(values, "email_mode"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return options, fmt.Errorf("invalid email_mode")
+		}
+		options.EmailMode = parsed
+	}
+
+	if value := getQueryValue(values, "trace_network"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return options, fmt.Errorf("invalid trace_network")
+		}
+		options.TraceNetwork = parsed
+	}
+
+	if value := getQueryValue(values, "trace_format"); value != "" {
+		switch value {
+		case traceFormatMultipart, traceFormatJSON, traceFormatHeader:
+		default:
+			return options, fmt.Errorf("invalid trace_format")
+		}
+		// Asking for a format asks for the trace.
+		options.TraceNetwork, options.TraceFormat = true, value
+	}
+
+	if value := getQueryValue(values, "preview_pages"); value != "" {
+		if !pageRangesRe.MatchString(value) || options.PageRanges != "" || options.TraceNetwork {
+			return options, fmt.Errorf("invalid preview_pages")
+		}
+		options.PreviewPages = value
+	}
+
+	if value := getQueryValue(values, "branding"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return options, fmt.Errorf("invalid branding")
+		}
+		options.NoBranding = !parsed
+	}
+
+	watermark, err := parseWatermarkOptions(values)
+	if err != nil {
+		return options, err
+	}
+	options.Watermark = watermark
+
+	if value := getQueryValue(values, "max_pages"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			return options, fmt.Errorf("invalid max_pages")
+		}
+		options.MaxPages = parsed
+	}
+
+	if value := getQueryValue(values, "freeze_time"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return options, fmt.Errorf("invalid freeze_time")
+		}
+		options.FreezeTime = &parsed
+	}
+
+	if value := getQueryValue(values, "random_seed"); value != "" {
+		parsed, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return options, fmt.Errorf("invalid random_seed")
+		}
+		seed := uint32(parsed)
+		options.RandomSeed = &seed
+	}
+
+	if value := getQueryValue(values, "lazy_load"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return options, fmt.Errorf("invalid lazy_load")
+		}
+		options.LazyLoad = parsed
+	}
+
+	if value := getQueryValue(values, "running_elements"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return options, fmt.Errorf("invalid running_elements")
+		}
+		options.RunningElements = parsed
+	}
+
+	if value := getQueryValue(values, "block_remote"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return options, fmt.Errorf("invalid block_remote")
+		}
+		options.BlockRemote = parsed
+	}
+
+	if value := getQueryValue(values, "fail_on_js_error"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return options, fmt.Errorf("invalid fail_on_js_error")
+		}
+		options.FailOnJSError = parsed
+	}
+
+	if value := getQueryValue(values, "strict_assets"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return options, fmt.Errorf("invalid strict_assets")
+		}
+		options.StrictAssets = parsed
+	}
+
+	if value := getQueryValue(values, "geolocation"); value != "" {
+		geo, err := parseGeolocation(value)
+		if err != nil {
+			return options, err
+		}
+		options.Geolocation = geo
+	}
+
+	if value := getQueryValue(values, "emulate_media"); value != "" {
+		value = strings.ToLower(value)
+		if !containsString(emulatedMediaTypes, value) {
+			return options, fmt.Errorf("invalid emulate_media")
+		}
+		options.EmulateMedia = value
+	}
+
+	if value := getQueryValue(values, "color_scheme"); value != "" {
+		value = strings.ToLower(value)
+		if !containsString(colorSchemes, value) {
+			return options, fmt.Errorf("invalid color_scheme")
+		}
+		options.ColorScheme = value
+	}
+
+	if value := getQueryValue(values, "reduced_motion"); value != "" {
+		value = strings.ToLower(value)
+		if !containsString(reducedMotionValues, value) {
+			return options, fmt.Errorf("invalid reduced_motion")
+		}
+		options.ReducedMotion = value
+	}
+
+	if value := getQueryValue(values, "user_agent"); value != "" {
+		if len(value) > maxUserAgentLength || strings.ContainsAny(value, "\r\n") {
+			return options, fmt.Errorf("invalid user_agent")
+		}
+		options.UserAgent = value
+	}
+
+	if value := getQueryValue(values, "backend"); value != "" {
+		value = strings.ToLower(value)
+		if strings.Trim(value, "abcdefghijklmnopqrstuvwxyz0123456789_") != "" {
+			return options, fmt.Errorf("invalid backend")
+		}
+		options.Backend = value
+	}
+
+	if value := getQueryValue(values, "priority"); value != "" {
+		priority, err := parsePriority(value)
+		if err != nil {
+			return options, err
+		}
+		options.Priority = priority
+	}
+
+	if value := getQueryValue(values, "pdfa"); value != "" {
+		value = strings.ToLower(value)
+		if !containsString(pdfaLevels, value) {
+			return options, fmt.Errorf("invalid pdfa")
+		}
+		options.PDFA = value
+	}
+
+	if value := getQueryValue(values, "base_url"); value != "" {
+		parsed, err := url.Parse(value)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			return options, fmt.Errorf("invalid base_url")
+		}
+		options.BaseURL = value
+	}
+
+	if value := getQueryValue(values, "output"); value != "" {
+		if value != outputS3 {
+			return options, fmt.Errorf("invalid output")
+		}
+		options.Output = value
+	}
 
+	if value := getQueryValue(values, "deliver"); value != "" {
+		sinks, err := parseDeliver(value)
+		if err != nil {
+			return options, err
+		}
+		if options.Output != "" {
+			return options, fmt.Errorf("deliver and output are mutually exclusive")
+		}
+		options.Deliver = sinks
+	}
+
+	if value := getQueryValue(values, "webhook_url"); value != "" {
+		parsed, err := url.Parse(value)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return options, fmt.Errorf("invalid webhook_url")
+		}
+		options.WebhookURL = value
+	}
+	if containsString(options.Deliver, sinkWebhook) != (options.WebhookURL != "") {
+		return options, fmt.Errorf("webhook_url requires deliver=webhook and vice versa")
+	}
+
+	options.Metadata = pdfMetadata{
+		Title:    getQueryValue(values, "meta_title"),
+		Author:   getQueryValue(values, "meta_author"),
+		Subject:  getQueryValue(values, "meta_subject"),
+		Keywords: getQueryValue(values, "meta_keywords"),
+		Creator:  getQueryValue(values, "meta_creator"),
+	}
+
+	if err := checkPageLayout(options); err != nil {
+		return options, err
+	}
 	return options, nil
 }
 
-func parseLength(value string) (float64, error) {
-	const (
-		mmPerInch = 25.4
-		pxPerInch = 96.0
-	)
+// Bounds of the page layout options: Chrome rejects scales outside
+// [minScale, maxScale], and PDF pages are at most maxPaperInches (14400pt) on
+// each side.
+const (
+	minScale       = 0.1
+	maxScale       = 2.0
+	maxPaperInches = 200.0
+)
+
+// Paper size Chrome prints on without paper_width/paper_height (Letter), and
+// its margins without margin_* (about 1cm).
+const (
+	defaultPaperWidth  = 8.5
+	defaultPaperHeight = 11.0
+	defaultMargin      = 0.4
+)
+
+// checkPageLayout rejects scales, paper sizes and margins Chrome would fail
+// on (or print nothing with), naming the constraint in the error.
+func checkPageLayout(options pdfOptions) error {
+	if options.Scale != nil && (*options.Scale < minScale || *options.Scale > maxScale) {
+		return fmt.Errorf("invalid scale: must be between %g and %g", minScale, maxScale)
+	}
+	width, height := defaultPaperWidth, defaultPaperHeight
+	for _, size := range []struct {
+		name  string
+		value *float64
+		into  *float64
+	}{
+		{"paper_width", options.PaperWidth, &width},
+		{"paper_height", options.PaperHeight, &height},
+	} {
+		if size.value == nil {
+			continue
+		}
+		if *size.value <= 0 || *size.value > maxPaperInches {
+			return fmt.Errorf("invalid %s: must be more than 0 and at most %gin", size.name, maxPaperInches)
+		}
+		*size.into = *size.value
+	}
+	margins := map[string]float64{}
+	for name, value := range map[string]*float64{
+		"margin_top": options.MarginTop, "margin_bottom": options.MarginBottom,
+		"margin_left": options.MarginLeft, "margin_right": options.MarginRight,
+	} {
+		if value == nil {
+			continue
+		}
+		if *value < 0 {
+			return fmt.Errorf("invalid %s: must not be negative", name)
+		}
+		margins[name] = *value
+	}
+	if options.Landscape != nil && *options.Landscape {
+		width, height = height, width
+	}
+	if margins["margin_top"]+margins["margin_bottom"] >= height {
+		return fmt.Errorf("invalid margins: margin_top and margin_bottom leave no room on a %.4gin high page", height)
+	}
+	if margins["margin_left"]+margins["margin_right"] >= width {
+		return fmt.Errorf("invalid margins: margin_left and margin_right leave no room on a %.4gin wide page", width)
+	}
+	return nil
+}
+
+// checkPageRanges checks page_ranges against Chrome's grammar: comma-separated
+// pages ("5") and ranges ("1-3", open-ended "7-" or "-2"), counted from 1.
+func checkPageRanges(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		from, to, isRange := strings.Cut(strings.TrimSpace(item), "-")
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+		first, err := parsePageNumber(from, isRange)
+		if err != nil {
+			return err
+		}
+		if !isRange {
+			continue
+		}
+		last, err := parsePageNumber(to, true)
+		if err != nil {
+			return err
+		}
+		if from == "" && to == "" {
+			return fmt.Errorf("invalid page_ranges: %q has no bounds", strings.TrimSpace(item))
+		}
+		if first > 0 && last > 0 && first > last {
+			return fmt.Errorf("invalid page_ranges: range %s-%s ends before it starts", from, to)
+		}
+	}
+	return nil
+}
+
+// parsePageNumber parses one bound of a page range; an empty bound (0) is
+// allowed for open-ended ranges.
+func parsePageNumber(value string, optional bool) (int, error) {
+	if value == "" && optional {
+		return 0, nil
+	}
+	page, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid page_ranges: expected pages and ranges like 1-3,5")
+	}
+	if page < 1 {
+		return 0, fmt.Errorf("invalid page_ranges: pages start at 1")
+	}
+	return page, nil
+}
+
+// lengthUnits are the units accepted by parseLength, in units per inch.
+var lengthUnits = []struct {
+	suffix  string
+	perInch float64
+}{
+	{"mm", 25.4},
+	{"cm", 2.54},
+	{"in", 1},
+	{"px", 96},
+	{"pt", 72},
+}
 
+// parseLength parses a length in inches, or with a unit suffix (mm, cm, in,
+// px, pt), and returns it in inches.
+func parseLength(value string) (float64, error) {
 	trimmed := strings.TrimSpace(value)
 	if trimmed == "" {
 		return 0, fmt.Errorf("empty length")
 	}
 
 	lower := strings.ToLower(trimmed)
-	switch {
-	case strings.HasSuffix(lower, "mm"):
-		number := strings.TrimSpace(trimmed[:len(trimmed)-2])
-		parsed, err := strconv.ParseFloat(number, 64)
-		if err != nil {
-			return 0, err
-		}
-		return parsed / mmPerInch, nil
-	case strings.HasSuffix(lower, "px"):
-		number := strings.TrimSpace(trimmed[:len(trimmed)-2])
-		parsed, err := strconv.ParseFloat(number, 64)
-		if err != nil {
-			return 0, err
+	for _, unit := range lengthUnits {
+		if strings.HasSuffix(lower, unit.suffix) {
+			number := strings.TrimSpace(trimmed[:len(trimmed)-len(unit.suffix)])
+			parsed, err := strconv.ParseFloat(number, 64)
+			if err != nil {
+				return 0, err
+			}
+			return parsed / unit.perInch, nil
 		}
-		return parsed / pxPerInch, nil
-	case strings.HasSuffix(lower, "in"):
-		number := strings.TrimSpace(trimmed[:len(trimmed)-2])
-		return strconv.ParseFloat(strings.TrimSpace(number), 64)
-	default:
-		return strconv.ParseFloat(trimmed, 64)
 	}
+	return strconv.ParseFloat(trimmed, 64)
+}
+
+// paperFormats are the paper sizes of the format option, width and height in
+// inches (portrait).
+var paperFormats = map[string][2]float64{
+	"a3":      {297 / 25.4, 420 / 25.4},
+	"a4":      {210 / 25.4, 297 / 25.4},
+	"a5":      {148 / 25.4, 210 / 25.4},
+	"letter":  {8.5, 11},
+	"legal":   {8.5, 14},
+	"tabloid": {11, 17},
 }
 
 func getQueryValue(values map[string][]string, key string) string {
@@ -0,0 +1,132 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const (
+	// chromeLauncherArg makes the pdfrest binary act as the hardened Chrome
+	// launcher: it isolates itself, then execs Chrome in its place.
+	chromeLauncherArg = "__chrome-launcher"
+	// chromeHardeningEnv passes the launcher its chromeHardening as JSON.
+	chromeHardeningEnv = "PDFREST_CHROME_HARDENING"
+)
+
+// chromeHardening are the isolation options of managed Chrome. Setting up a
+// mount namespace or a seccomp filter has to happen between fork and exec,
+// which os/exec cannot do, so pdfrest re-executes itself as a launcher that
+// applies them and then execs Chrome (same pid, so the cgroup and rlimits of
+// the sandbox still apply).
+type chromeHardening struct {
+	// UserNamespace runs Chrome in a new user namespace, as root mapped to
+	// the service user.
+	UserNamespace bool `json:"user_namespace,omitempty"`
+	// ReadOnlyFS remounts the filesystem read-only in a private mount
+	// namespace, except for Writable (and /proc, /sys, /dev).
+	ReadOnlyFS bool     `json:"read_only_fs,omitempty"`
+	Writable   []string `json:"writable,omitempty"`
+	// SeccompFilter is a compiled seccomp BPF program (as written by
+	// libseccomp's seccomp_export_bpf) loaded before exec.
+	SeccompFilter string `json:"seccomp_filter,omitempty"`
+
+	// Probe makes the launcher exit after isolating itself.
+	Probe bool `json:"probe,omitempty"`
+}
+
+func (h chromeHardening) enabled() bool {
+	return h.UserNamespace || h.ReadOnlyFS || h.SeccompFilter != ""
+}
+
+// launcherCommand returns the command that runs path with args through the
+// hardened launcher.
+func (h chromeHardening) launcherCommand(path string, args []string) (*exec.Cmd, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("chrome launcher: %w", err)
+	}
+	encoded, err := json.Marshal(h)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(self, append([]string{chromeLauncherArg, path}, args...)...)
+	cmd.Env = append(os.Environ(), chromeHardeningEnv+"="+string(encoded))
+	if err := isolateCommand(cmd, h); err != nil {
+		return nil, err
+	}
+	return cmd, nil
+}
+
+// probeChromeHardening runs the launcher up to the point where it would exec
+// Chrome, so an environment that cannot provide the sandbox is reported at
+// startup with the failing step instead of as a crash loop.
+func probeChromeHardening(h chromeHardening) error {
+	h.Probe = true
+	cmd, err := h.launcherCommand("true", nil)
+	if err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = nil, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = errors.New(msg)
+		}
+		return fmt.Errorf("%w%s", err, hardeningHint(h, err))
+	}
+	return nil
+}
+
+// hardeningHint suggests the usual fix for a failed probe.
+func hardeningHint(h chromeHardening, err error) string {
+	msg := err.Error()
+	switch {
+	case h.UserNamespace && (strings.Contains(msg, "operation not permitted") || strings.Contains(msg, "no space left on device")) && !strings.Contains(msg, "seccomp"):
+		return " (user namespaces are unavailable: check the kernel.unprivileged_userns_clone and user.max_user_namespaces sysctls, and that the container runtime's seccomp/AppArmor profile allows unshare/clone with CLONE_NEWUSER)"
+	case h.ReadOnlyFS && !h.UserNamespace && strings.Contains(msg, "operation not permitted"):
+		return " (CHROME_READ_ONLY_FS needs CHROME_USER_NAMESPACE=true or CAP_SYS_ADMIN)"
+	case strings.Contains(msg, "seccomp") && strings.Contains(msg, "invalid argument"):
+		return " (the kernel rejected the filter: check that CHROME_SECCOMP_FILTER is a compiled BPF program for this architecture)"
+	case strings.Contains(msg, "signal: bad system call"):
+		return " (the seccomp filter blocks a syscall the launcher needs to exit; allow exit_group)"
+	}
+	return ""
+}
+
+// runChromeLauncher is the entry point of the launcher process (see
+// chromeLauncherArg). It only returns on failure.
+func runChromeLauncher(args []string) int {
+	var h chromeHardening
+	if err := json.Unmarshal([]byte(os.Getenv(chromeHardeningEnv)), &h); err != nil || len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "chrome launcher: missing configuration")
+		return 2
+	}
+	path, err := exec.LookPath(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "chrome launcher: %v\n", err)
+		return 127
+	}
+	// Chrome should not see the launcher's configuration.
+	if err := os.Unsetenv(chromeHardeningEnv); err != nil {
+		fmt.Fprintf(os.Stderr, "chrome launcher: %v\n", err)
+		return 1
+	}
+	if err := enterChromeHardening(h); err != nil {
+		fmt.Fprintf(os.Stderr, "chrome sandbox: %v\n", err)
+		return 1
+	}
+	if h.Probe {
+		return 0
+	}
+	err = execChrome(path, args, os.Environ())
+	fmt.Fprintf(os.Stderr, "chrome launcher: exec %s: %v\n", path, err)
+	return 127
+}
@@ -0,0 +1,237 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+//go:build linux
+
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// prctl options and seccomp mode used to load the filter.
+const (
+	prSetNoNewPrivs   = 38
+	prSetSeccomp      = 22
+	seccompModeFilter = 2
+
+	// Max instructions of a classic BPF program (BPF_MAXINSNS).
+	bpfMaxInstructions = 4096
+)
+
+// Mount options that must be preserved when remounting (the kernel refuses
+// to drop them inside a user namespace).
+var mountOptionFlags = map[string]uintptr{
+	"nosuid":      syscall.MS_NOSUID,
+	"nodev":       syscall.MS_NODEV,
+	"noexec":      syscall.MS_NOEXEC,
+	"noatime":     syscall.MS_NOATIME,
+	"nodiratime":  syscall.MS_NODIRATIME,
+	"relatime":    syscall.MS_RELATIME,
+	"strictatime": syscall.MS_STRICTATIME,
+}
+
+// isolateCommand starts the launcher in new user and/or mount namespaces.
+func isolateCommand(cmd *exec.Cmd, h chromeHardening) error {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	if h.UserNamespace {
+		cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWUSER
+		// Root inside the namespace keeps the capabilities needed for the
+		// mount setup across the exec of the launcher.
+		cmd.SysProcAttr.UidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}}
+		cmd.SysProcAttr.GidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}}
+		cmd.SysProcAttr.GidMappingsEnableSetgroups = false
+	}
+	if h.ReadOnlyFS {
+		cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWNS
+	}
+	return nil
+}
+
+// enterChromeHardening applies the in-process part of the hardening: the
+// read-only filesystem and the seccomp filter (loaded last, on the thread
+// that execs Chrome).
+func enterChromeHardening(h chromeHardening) error {
+	runtime.LockOSThread()
+	if h.ReadOnlyFS {
+		if err := remountReadOnly(h.Writable); err != nil {
+			return fmt.Errorf("read-only filesystem: %w", err)
+		}
+	}
+	if h.SeccompFilter != "" {
+		if err := loadSeccompFilter(h.SeccompFilter); err != nil {
+			return fmt.Errorf("seccomp: %w", err)
+		}
+	}
+	return nil
+}
+
+func execChrome(path string, args, env []string) error {
+	return syscall.Exec(path, args, env)
+}
+
+// remountReadOnly makes every mount of the (private) mount namespace
+// read-only, except for the writable paths, which are bind-mounted onto
+// themselves first, and the kernel filesystems under /proc, /sys and /dev.
+func remountReadOnly(writable []string) error {
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("make mounts private: %w", err)
+	}
+	keep := []string{"/proc", "/sys", "/dev"}
+	for _, path := range writable {
+		path = filepath.Clean(path)
+		if err := os.MkdirAll(path, 0o700); err != nil {
+			return err
+		}
+		if err := syscall.Mount(path, path, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+			return fmt.Errorf("bind %s: %w", path, err)
+		}
+		keep = append(keep, path)
+	}
+
+	mounts, err := readMountInfo()
+	if err != nil {
+		return err
+	}
+	for _, m := range mounts {
+		if m.readOnly || underAny(m.point, keep) {
+			continue
+		}
+		if err := syscall.Mount("", m.point, "", syscall.MS_REMOUNT|syscall.MS_BIND|syscall.MS_RDONLY|m.flags, ""); err != nil {
+			if errors.Is(err, syscall.ENOENT) {
+				// Shadowed by a later mount.
+				continue
+			}
+			return fmt.Errorf("remount %s: %w", m.point, err)
+		}
+	}
+	return nil
+}
+
+// mountEntry is one line of /proc/self/mountinfo.
+type mountEntry struct {
+	point    string
+	flags    uintptr
+	readOnly bool
+}
+
+func readMountInfo() ([]mountEntry, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	var mounts []mountEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 {
+			continue
+		}
+		entry := mountEntry{point: unescapeMountPath(fields[4])}
+		for _, option := range strings.Split(fields[5], ",") {
+			if option == "ro" {
+				entry.readOnly = true
+			}
+			entry.flags |= mountOptionFlags[option]
+		}
+		mounts = append(mounts, entry)
+	}
+	return mounts, scanner.Err()
+}
+
+// unescapeMountPath decodes the octal escapes (\040 etc.) of mountinfo.
+func unescapeMountPath(path string) string {
+	if !strings.Contains(path, `\`) {
+		return path
+	}
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] == '\\' && i+3 < len(path) {
+			if value, err := strconv.ParseUint(path[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(value))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(path[i])
+	}
+	return b.String()
+}
+
+func underAny(path string, roots []string) bool {
+	for _, root := range roots {
+		if path == root || strings.HasPrefix(path, strings.TrimSuffix(root, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// sockFilter and sockFprog mirror struct sock_filter / sock_fprog.
+type sockFilter struct {
+	Code uint16
+	JT   uint8
+	JF   uint8
+	K    uint32
+}
+
+type sockFprog struct {
+	Len    uint16
+	Filter *sockFilter
+}
+
+// parseSeccompFilter decodes a compiled BPF program: 8-byte sock_filter
+// instructions in native byte order.
+func parseSeccompFilter(data []byte) ([]sockFilter, error) {
+	if len(data) == 0 || len(data)%8 != 0 || len(data)/8 > bpfMaxInstructions {
+		return nil, fmt.Errorf("invalid BPF program: %d bytes", len(data))
+	}
+	filter := make([]sockFilter, len(data)/8)
+	for i := range filter {
+		ins := data[i*8:]
+		filter[i] = sockFilter{
+			Code: binary.NativeEndian.Uint16(ins[0:2]),
+			JT:   ins[2],
+			JF:   ins[3],
+			K:    binary.NativeEndian.Uint32(ins[4:8]),
+		}
+	}
+	return filter, nil
+}
+
+// loadSeccompFilter installs the filter on the calling thread, after
+// PR_SET_NO_NEW_PRIVS (required without CAP_SYS_ADMIN). It is inherited by
+// the exec'd Chrome and all of its children.
+func loadSeccompFilter(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	filter, err := parseSeccompFilter(data)
+	if err != nil {
+		return err
+	}
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0); errno != 0 {
+		return fmt.Errorf("no_new_privs: %w", errno)
+	}
+	prog := sockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetSeccomp, seccompModeFilter, uintptr(unsafe.Pointer(&prog))); errno != 0 {
+		return fmt.Errorf("load filter: %w", errno)
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"os/exec"
+)
+
+var errHardeningUnsupported = errors.New("chrome sandbox hardening is only supported on linux")
+
+func isolateCommand(_ *exec.Cmd, _ chromeHardening) error {
+	return errHardeningUnsupported
+}
+
+func enterChromeHardening(_ chromeHardening) error {
+	return errHardeningUnsupported
+}
+
+func execChrome(_ string, _, _ []string) error {
+	return errHardeningUnsupported
+}
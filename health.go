@@ -0,0 +1,124 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// startTime is used to report process uptime.
+var startTime = time.Now()
+
+// chromeStatus describes the state of the Chrome connection.
+type chromeStatus struct {
+	Reachable          bool     `json:"reachable"`
+	WebSocketReachable bool     `json:"websocket_reachable"`
+	Browser            string   `json:"browser,omitempty"`
+	ProtocolVersion    string   `json:"protocol_version,omitempty"`
+	CachedWSAgeSeconds *float64 `json:"cached_ws_age_seconds,omitempty"`
+	Error              string   `json:"error,omitempty"`
+
+	// Endpoints lists each endpoint when CHROME_ENDPOINT has several.
+	Endpoints []chromeEndpointStatus `json:"endpoints,omitempty"`
+}
+
+// renderStatus describes the state of the render limiter.
+type renderStatus struct {
+	InFlight      int `json:"in_flight"`
+	Queued        int `json:"queued"`
+	MaxConcurrent int `json:"max_concurrent"`
+	MaxQueue      int `json:"max_queue"`
+}
+
+// healthReport is the JSON body of the detailed health check and /status.
+type healthReport struct {
+	Status        string       `json:"status"`
+	Version       string       `json:"version,omitempty"`
+	StartedAt     time.Time    `json:"started_at"`
+	UptimeSeconds float64      `json:"uptime_seconds"`
+	Chrome        chromeStatus `json:"chrome"`
+	Renders       renderStatus `json:"renders"`
+	CDP           cdpStatus    `json:"cdp"`
+}
+
+// wantsDetailedHealth reports whether the client asked for the JSON report,
+// either with ?format=json or an Accept header preferring application/json.
+func wantsDetailedHealth(r *http.Request) bool {
+	if r.URL.Query().Get("format") == "json" {
+		return true
+	}
+	return strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+// buildHealthReport collects Chrome and limiter diagnostics.
+func buildHealthReport(ctx context.Context, resolver wsResolver, limiter *renderLimiter) healthReport {
+	report := healthReport{
+		Status:        "ok",
+		StartedAt:     startTime.UTC(),
+		UptimeSeconds: time.Since(startTime).Seconds(),
+	}
+
+	if reporter, ok := resolver.(interface {
+		status(ctx context.Context) chromeStatus
+	}); ok {
+		report.Chrome = reporter.status(ctx)
+	} else if _, err := resolver.wsURL(ctx); err != nil {
+		report.Chrome.Error = err.Error()
+	} else {
+		report.Chrome.Reachable = true
+	}
+	if !report.Chrome.Reachable {
+		report.Status = "unavailable"
+	}
+
+	report.Renders.InFlight, report.Renders.Queued = limiter.stats()
+	if limiter != nil {
+		report.Renders.MaxConcurrent = limiter.maxActive
+		report.Renders.MaxQueue = limiter.maxQueue
+	}
+	report.CDP = currentCDPStatus()
+	return report
+}
+
+// statusHandler serves the operational status report for dashboards. Unlike
+// the probes it always answers 200 (if it can answer at all): the state of
+// each component is carried in the body, not in the status code.
+func statusHandler(resolver wsResolver, limiter *renderLimiter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), defaultChromeClientTimeout)
+		defer cancel()
+
+		report := buildHealthReport(ctx, resolver, limiter)
+		report.Version = currentBuildInfo().Version
+		writeJSONReport(w, http.StatusOK, report)
+	}
+}
+
+// writeHealthReport writes report as JSON, using 503 when Chrome is unavailable.
+func writeHealthReport(w http.ResponseWriter, report healthReport) {
+	status := http.StatusOK
+	if report.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSONReport(w, status, report)
+}
+
+// writeJSONReport writes report as an uncacheable JSON response.
+func writeJSONReport(w http.ResponseWriter, status int, report healthReport) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		Warnf("health report encode error: %v", err)
+	}
+}
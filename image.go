@@ -0,0 +1,240 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Image formats of POST /api/v1/image (the type parameter).
+var imageTypes = []string{"png", "jpeg"}
+
+// Viewport of an image render without width/height, and the bounds of the
+// image parameters.
+const (
+	defaultImageWidth   = 1280
+	defaultImageHeight  = 800
+	maxImageDimension   = 10000
+	defaultImageQuality = 80
+	minDeviceScale      = 0.1
+	maxDeviceScale      = 4.0
+)
+
+// imageOptions are the parameters of an image render: the viewport the page
+// is laid out in (Width x Height CSS pixels at DeviceScale), and what is
+// captured: the viewport, the whole page (FullPage) or the Clip rectangle.
+type imageOptions struct {
+	Type        string
+	Quality     int
+	Width       int
+	Height      int
+	DeviceScale float64
+	FullPage    bool
+	Clip        *imageClip
+}
+
+// imageClip is a rectangle of the page, in CSS pixels.
+type imageClip struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// contentType returns the media type of the image.
+func (o *imageOptions) contentType() string {
+	return "image/" + o.Type
+}
+
+// parseImageOptions parses the image parameters of values.
+func parseImageOptions(values map[string][]string) (*imageOptions, error) {
+	image := &imageOptions{Type: "png", Width: defaultImageWidth, Height: defaultImageHeight, DeviceScale: 1}
+
+	if value := getQueryValue(values, "type"); value != "" {
+		value = strings.ToLower(value)
+		if value == "jpg" {
+			value = "jpeg"
+		}
+		if !containsString(imageTypes, value) {
+			return nil, fmt.Errorf("invalid type")
+		}
+		image.Type = value
+	}
+	if value := getQueryValue(values, "quality"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > 100 || image.Type != "jpeg" {
+			return nil, fmt.Errorf("invalid quality: must be between 1 and 100, with type=jpeg")
+		}
+		image.Quality = parsed
+	} else if image.Type == "jpeg" {
+		image.Quality = defaultImageQuality
+	}
+	for _, dimension := range []struct {
+		name string
+		into *int
+	}{
+		{"width", &image.Width},
+		{"height", &image.Height},
+	} {
+		if value := getQueryValue(values, dimension.name); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 1 || parsed > maxImageDimension {
+				return nil, fmt.Errorf("invalid %s: must be between 1 and %d", dimension.name, maxImageDimension)
+			}
+			*dimension.into = parsed
+		}
+	}
+	if value := getQueryValue(values, "device_scale_factor"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed < minDeviceScale || parsed > maxDeviceScale {
+			return nil, fmt.Errorf("invalid device_scale_factor: must be between %g and %g", minDeviceScale, maxDeviceScale)
+		}
+		image.DeviceScale = parsed
+	}
+	if value := getQueryValue(values, "full_page"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid full_page")
+		}
+		image.FullPage = parsed
+	}
+	if value := getQueryValue(values, "clip"); value != "" {
+		parts := strings.Split(value, ",")
+		if len(parts) != 4 {
+			return nil, fmt.Errorf("invalid clip: expected x,y,width,height")
+		}
+		numbers := make([]float64, 4)
+		for i, part := range parts {
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+			if err != nil || parsed < 0 || parsed > maxImageDimension {
+				return nil, fmt.Errorf("invalid clip: expected x,y,width,height")
+			}
+			numbers[i] = parsed
+		}
+		if numbers[2] == 0 || numbers[3] == 0 {
+			return nil, fmt.Errorf("invalid clip: width and height must be more than 0")
+		}
+		if image.FullPage {
+			return nil, fmt.Errorf("clip and full_page are mutually exclusive")
+		}
+		image.Clip = &imageClip{X: numbers[0], Y: numbers[1], Width: numbers[2], Height: numbers[3]}
+	}
+	return image, nil
+}
+
+// pdfOnlyParams are the query parameters of the options pdfOnlyOption
+// rejects.
+var pdfOnlyParams = []string{"page_ranges", "max_pages", "pdfa", "watermark_", "running_elements", "trace_network",
+	"output", "deliver", "preview_pages"}
+
+// pdfOnlyOption returns the first option of options that changes the PDF
+// document, or "". Page layout options are ignored by image renders, since
+// server defaults may set them.
+func pdfOnlyOption(options pdfOptions) string {
+	checks := []struct {
+		name string
+		set  bool
+	}{
+		{"page_ranges", options.PageRanges != ""},
+		{"max_pages", options.MaxPages > 0},
+		{"pdfa", options.PDFA != ""},
+		{"watermark", options.Watermark != nil},
+		{"running_elements", options.RunningElements},
+		{"trace_network", options.TraceNetwork},
+		{"output", options.Output != ""},
+		{"deliver", len(options.Deliver) > 0},
+		{"preview_pages", options.PreviewPages != ""},
+	}
+	for _, check := range checks {
+		if check.set {
+			return check.name
+		}
+	}
+	return ""
+}
+
+// setViewport lays the page out in the viewport of options.Image. The
+// returned function restores Chrome's, since a page websocket (CHROME_WS) is
+// reused across renders.
+func setViewport(ctx context.Context, client *cdpClient, sessionID string, options pdfOptions) (func(), error) {
+	image := options.Image
+	if image == nil {
+		return func() {}, nil
+	}
+	if err := client.Call(ctx, sessionID, "Emulation.setDeviceMetricsOverride", map[string]any{
+		"width":             image.Width,
+		"height":            image.Height,
+		"deviceScaleFactor": image.DeviceScale,
+		"mobile":            false,
+	}, nil); err != nil {
+		return nil, err
+	}
+	return func() {
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		if err := client.Call(cleanupCtx, sessionID, "Emulation.clearDeviceMetricsOverride", nil, nil); err != nil {
+			Warnf("chrome clear viewport error: %v", err)
+		}
+	}, nil
+}
+
+// captureImage takes the screenshot of options.Image: the viewport, the whole
+// page (its content size, beyond the viewport) or the clip rectangle.
+func captureImage(ctx context.Context, client *cdpClient, sessionID string, image *imageOptions, maxBytes int64) ([]byte, time.Duration, error) {
+	params := map[string]any{"format": image.Type, "fromSurface": true}
+	if image.Type == "jpeg" {
+		params["quality"] = image.Quality
+	}
+	clip := image.Clip
+	if image.FullPage {
+		var metrics struct {
+			CSSContentSize struct {
+				Width  float64 `json:"width"`
+				Height float64 `json:"height"`
+			} `json:"cssContentSize"`
+		}
+		if err := client.Call(ctx, sessionID, "Page.getLayoutMetrics", nil, &metrics); err != nil {
+			return nil, 0, err
+		}
+		clip = &imageClip{Width: metrics.CSSContentSize.Width, Height: metrics.CSSContentSize.Height}
+	}
+	if clip != nil {
+		params["clip"] = map[string]any{"x": clip.X, "y": clip.Y, "width": clip.Width, "height": clip.Height, "scale": 1}
+		params["captureBeyondViewport"] = true
+	}
+
+	start := time.Now()
+	var result struct {
+		Data string `json:"data"`
+	}
+	if err := client.Call(ctx, sessionID, "Page.captureScreenshot", params, &result); err != nil {
+		return nil, time.Since(start), err
+	}
+	elapsed := time.Since(start)
+	data, err := base64.StdEncoding.DecodeString(result.Data)
+	if err != nil || len(data) == 0 {
+		return nil, elapsed, fmt.Errorf("chrome returned no image")
+	}
+	if err := checkPDFLimits(data, maxBytes, 0); err != nil {
+		return nil, elapsed, err
+	}
+	return data, elapsed, nil
+}
+
+// writeImage answers with the rendered image.
+func writeImage(w http.ResponseWriter, data []byte, image *imageOptions) {
+	w.Header().Set("Content-Type", image.contentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", "document."+image.Type))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+}
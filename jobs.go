@@ -0,0 +1,407 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// headerFullDocumentJob points a preview_pages response at the job that
+// renders the full document.
+const headerFullDocumentJob = "X-Full-Document-Job"
+
+// Job states.
+const (
+	jobPending = "pending"
+	jobDone    = "done"
+	jobFailed  = "failed"
+	// jobExpired is a done job whose artifact was evicted.
+	jobExpired = "expired"
+)
+
+// renderJob is an asynchronous render started with Prefer: respond-async.
+type renderJob struct {
+	ID          string     `json:"id"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	Pages       int        `json:"pages,omitempty"`
+	Bytes       int        `json:"bytes,omitempty"`
+	Result      string     `json:"result,omitempty"`
+	Output      *s3Object  `json:"output,omitempty"`
+	// DownloadURL is a signed link to the result that needs no credentials,
+	// valid until ExpiresAt.
+	DownloadURL string     `json:"download_url,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	// ErrorStatus is the status the render would have been answered with
+	// synchronously.
+	ErrorStatus int `json:"error_status,omitempty"`
+	// Network is the network trace of a trace_network render.
+	Network *networkReport `json:"network,omitempty"`
+
+	owner string
+	// The PDF is kept in memory, or on disk at artifact with ARTIFACT_DIR.
+	pdf      []byte
+	artifact string
+	done     chan struct{}
+}
+
+// jobStore keeps async jobs in memory for the retention window, and their
+// results too unless an artifact store is set. At most maxJobs are kept; when
+// the store is full, new requests are rendered synchronously.
+type jobStore struct {
+	retention time.Duration
+	maxJobs   int
+	artifacts *artifactStore
+
+	mu   sync.Mutex
+	jobs map[string]*renderJob
+}
+
+func newJobStore(retention time.Duration, maxJobs int, artifacts *artifactStore) *jobStore {
+	return &jobStore{retention: retention, maxJobs: maxJobs, artifacts: artifacts, jobs: map[string]*renderJob{}}
+}
+
+// create registers a pending job for owner, or returns nil when the store is
+// full.
+func (s *jobStore) create(owner string) *renderJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked(time.Now())
+	if s.maxJobs > 0 && len(s.jobs) >= s.maxJobs {
+		return nil
+	}
+	job := &renderJob{
+		ID:        newRequestID(),
+		Status:    jobPending,
+		CreatedAt: time.Now().UTC(),
+		owner:     owner,
+		done:      make(chan struct{}),
+	}
+	s.jobs[job.ID] = job
+	return job
+}
+
+// get returns the job id of owner. Jobs of other owners are not found.
+func (s *jobStore) get(id, owner string) (*renderJob, bool) {
+	job, ok := s.lookup(id)
+	if !ok || job.owner != owner {
+		return nil, false
+	}
+	return job, true
+}
+
+// lookup returns the job id of any owner.
+func (s *jobStore) lookup(id string) (*renderJob, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	return job, ok
+}
+
+// snapshot returns a copy of job that is safe to encode.
+func (s *jobStore) snapshot(job *renderJob) renderJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *job
+}
+
+// attachNetwork records the network trace of job.
+func (s *jobStore) attachNetwork(job *renderJob, report networkReport) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job.Network = &report
+}
+
+// complete records the PDF of job, or where it was uploaded (the PDF is then
+// not kept).
+func (s *jobStore) complete(job *renderJob, pdf []byte, object *s3Object) {
+	var artifact string
+	if object == nil && s.artifacts != nil {
+		var err error
+		if artifact, err = s.artifacts.save(job.ID, pdf); err != nil {
+			Warnf("job %s: artifact error, keeping the result in memory: %v", job.ID, err)
+		}
+	}
+
+	s.mu.Lock()
+	now := time.Now().UTC()
+	job.Status, job.CompletedAt = jobDone, &now
+	job.Pages, job.Bytes = countPDFPages(pdf), len(pdf)
+	switch {
+	case object != nil:
+		job.Output = object
+	case artifact != "":
+		job.artifact = artifact
+	default:
+		job.pdf = pdf
+	}
+	job.Result = pathJobs + "/" + job.ID + "/result"
+	s.mu.Unlock()
+	close(job.done)
+}
+
+// fail records the error job failed with.
+func (s *jobStore) fail(job *renderJob, status int, msg string) {
+	s.mu.Lock()
+	now := time.Now().UTC()
+	job.Status, job.CompletedAt = jobFailed, &now
+	job.ErrorStatus, job.Error = status, msg
+	s.mu.Unlock()
+	close(job.done)
+}
+
+// pruneLocked drops finished jobs older than the retention window. The caller
+// holds s.mu.
+func (s *jobStore) pruneLocked(now time.Time) {
+	for id, job := range s.jobs {
+		if job.CompletedAt != nil && now.Sub(*job.CompletedAt) > s.retention {
+			if job.artifact != "" {
+				s.artifacts.remove(job.artifact)
+			}
+			delete(s.jobs, id)
+		}
+	}
+}
+
+// runPruner drops expired jobs periodically until ctx is done.
+func (s *jobStore) runPruner(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.mu.Lock()
+			s.pruneLocked(now)
+			s.mu.Unlock()
+		}
+	}
+}
+
+// jobOwner identifies the caller that may read a job: the authenticated
+// principal, else the key policy ID. Anonymous jobs are only protected by
+// their unguessable ID.
+func jobOwner(ctx context.Context) string {
+	if p, ok := principalFromContext(ctx); ok {
+		return p.Provider + ":" + p.ID
+	}
+	if policy, ok := policyFromContext(ctx); ok {
+		return "key:" + policy.ID
+	}
+	return ""
+}
+
+// parsePrefer parses the Prefer request headers (RFC 7240) into preference
+// names (lower case) and values.
+func parsePrefer(header http.Header) map[string]string {
+	prefs := map[string]string{}
+	for _, line := range header.Values("Prefer") {
+		for _, item := range strings.Split(line, ",") {
+			// Parameters after ';' are not used by any supported preference.
+			item, _, _ = strings.Cut(item, ";")
+			name, value, _ := strings.Cut(strings.TrimSpace(item), "=")
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+			if _, ok := prefs[name]; !ok {
+				prefs[name] = strings.Trim(strings.TrimSpace(value), `"`)
+			}
+		}
+	}
+	return prefs
+}
+
+// startJob runs the render in the background and answers 202 Accepted with
+// the job resource in Location. With a "wait" preference, a render that
+// finishes within that many seconds is answered directly. It returns false,
+// without writing anything, when the job store is full.
+func (s *pdfService) startJob(w http.ResponseWriter, r *http.Request, html string, tmplReq *templateRequest, options pdfOptions, prefs map[string]string) bool {
+	job := s.launchJob(r, html, tmplReq, options)
+	if job == nil {
+		return false
+	}
+
+	if wait, err := strconv.Atoi(prefs["wait"]); err == nil && wait > 0 {
+		timer := time.NewTimer(min(time.Duration(wait)*time.Second, s.cfg.RequestTimeout))
+		defer timer.Stop()
+		select {
+		case <-job.done:
+			s.writeJobResult(w, s.jobView(job))
+			return true
+		case <-timer.C:
+		case <-r.Context().Done():
+		}
+	}
+
+	location := pathJobs + "/" + job.ID
+	w.Header().Set("Location", location)
+	w.Header().Set("Preference-Applied", "respond-async")
+	w.Header().Set("Retry-After", "1")
+	writeJSON(w, http.StatusAccepted, s.jobs.snapshot(job))
+	return true
+}
+
+// launchJob starts rendering html in the background, or returns nil when the
+// job store is full.
+func (s *pdfService) launchJob(r *http.Request, html string, tmplReq *templateRequest, options pdfOptions) *renderJob {
+	job := s.jobs.create(jobOwner(r.Context()))
+	if job == nil {
+		Warnf("job store full, rendering synchronously")
+		return nil
+	}
+
+	// The render outlives the request; keep its values (request ID, policy)
+	// for logging, quotas and the archive.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), s.cfg.RequestTimeout)
+	var diag *renderDiagnostics
+	if options.TraceNetwork {
+		diag = &renderDiagnostics{}
+		ctx = withRenderDiagnostics(ctx, diag)
+	}
+	go func() {
+		defer cancel()
+		wsURL, err := s.resolver.wsURL(ctx)
+		if err != nil {
+			Errorf("job %s: chrome ws error: %v", job.ID, err)
+			s.jobs.fail(job, http.StatusServiceUnavailable, "chrome unavailable")
+			return
+		}
+		pdf, _, err := s.renderer(ctx, wsURL, html, s.cfg.PDFWait, options)
+		if diag != nil {
+			// Failed renders keep their trace too: it often tells why.
+			s.jobs.attachNetwork(job, diag.networkReport())
+		}
+		if err != nil {
+			status, msg := renderErrorStatus(err)
+			s.jobs.fail(job, status, msg)
+			return
+		}
+		s.archiveRender(r, html, tmplReq, options, pdf)
+		var object *s3Object
+		if options.Output == outputS3 {
+			if object, err = s.s3.upload(ctx, pdf); err != nil {
+				Errorf("job %s: s3 upload error: %v", job.ID, err)
+				s.jobs.fail(job, http.StatusBadGateway, "upload failed")
+				return
+			}
+		}
+		if len(options.Deliver) > 0 {
+			report, err := s.deliver(ctx, options, pdf)
+			if err != nil {
+				Errorf("job %s: delivery error: %v", job.ID, err)
+				s.jobs.fail(job, http.StatusBadGateway, "upload failed")
+				return
+			}
+			// With "response" among the sinks the job keeps the PDF itself.
+			if !containsString(options.Deliver, sinkResponse) {
+				object = report.S3
+			}
+		}
+		s.jobs.complete(job, pdf, object)
+		Infof("job %s: done, %d bytes", job.ID, len(pdf))
+	}()
+	return job
+}
+
+// serveJob serves GET /api/v1/jobs/{id}: the job status, with the result
+// link once it is done.
+func (s *pdfService) serveJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	job, ok := s.jobs.get(r.PathValue("id"), jobOwner(r.Context()))
+	if !ok {
+		http.Error(w, "job not found", http.StatusNotFound)
+		return
+	}
+	snapshot := s.jobView(job)
+	if snapshot.Status == jobPending {
+		w.Header().Set("Retry-After", "1")
+	}
+	writeJSON(w, http.StatusOK, snapshot)
+}
+
+// jobView returns a snapshot of job with fresh download URLs for its result.
+func (s *pdfService) jobView(job *renderJob) renderJob {
+	snapshot := s.jobs.snapshot(job)
+	if snapshot.Status == jobDone && snapshot.artifact != "" && !s.jobs.artifacts.exists(snapshot.artifact) {
+		snapshot.Status, snapshot.Result = jobExpired, ""
+	}
+	if snapshot.Status != jobDone || s.downloads == nil {
+		return snapshot
+	}
+	if snapshot.Output != nil {
+		object := *snapshot.Output
+		s.downloads.objectURL(s.s3, &object)
+		snapshot.Output = &object
+	} else {
+		downloadURL, expiresAt := s.downloads.jobURL(snapshot.ID)
+		snapshot.DownloadURL, snapshot.ExpiresAt = downloadURL, &expiresAt
+	}
+	return snapshot
+}
+
+// serveJobResult serves GET /api/v1/jobs/{id}/result: the PDF of a finished
+// job (the uploaded object with output=s3), or the error the render failed
+// with.
+func (s *pdfService) serveJobResult(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	job, ok := s.jobs.get(r.PathValue("id"), jobOwner(r.Context()))
+	if !ok {
+		http.Error(w, "job not found", http.StatusNotFound)
+		return
+	}
+	snapshot := s.jobView(job)
+	if snapshot.Status == jobPending {
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "job not finished", http.StatusConflict)
+		return
+	}
+	s.writeJobResult(w, snapshot)
+}
+
+func (s *pdfService) writeJobResult(w http.ResponseWriter, job renderJob) {
+	if job.Status == jobFailed {
+		http.Error(w, job.Error, job.ErrorStatus)
+		return
+	}
+	if job.Output != nil {
+		w.Header().Set(headerPDFPages, strconv.Itoa(job.Output.Pages))
+		writeJSON(w, http.StatusOK, job.Output)
+		return
+	}
+	if job.artifact != "" {
+		f, size, err := s.jobs.artifacts.open(job.artifact)
+		if errors.Is(err, os.ErrNotExist) {
+			http.Error(w, "job result expired", http.StatusGone)
+			return
+		}
+		if err != nil {
+			Errorf("job %s: artifact error: %v", job.ID, err)
+			http.Error(w, "job result unavailable", http.StatusInternalServerError)
+			return
+		}
+		setPDFHeaders(w.Header(), job.Pages)
+		w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+		w.WriteHeader(http.StatusOK)
+		copyArtifact(w, f)
+		return
+	}
+	writePDF(w, job.pdf)
+}
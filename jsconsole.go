@@ -0,0 +1,179 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Console messages and exceptions logged per render; the rest are counted,
+// so a page logging in a loop cannot flood the service log.
+const maxConsoleLogLines = 50
+
+// Longest console message logged, in bytes.
+const maxConsoleMessageBytes = 1024
+
+// jsError is an uncaught exception thrown by the page.
+type jsError struct {
+	Message string `json:"message"`
+	URL     string `json:"url,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+}
+
+// jsErrorsError fails a fail_on_js_error render whose page threw. It is
+// returned to the client as JSON with 422: an uncaught exception is the usual
+// reason for a blank PDF.
+type jsErrorsError struct {
+	Code    string    `json:"error"`
+	Message string    `json:"message"`
+	Errors  []jsError `json:"errors"`
+}
+
+func (e *jsErrorsError) Error() string {
+	return e.Message
+}
+
+// consoleWatcher logs the console messages and uncaught exceptions of a page
+// with the request ID, and keeps the exceptions.
+type consoleWatcher struct {
+	requestID string
+
+	mu      sync.Mutex
+	logged  int
+	dropped int
+	errors  []jsError
+}
+
+// watchConsole enables the Runtime domain on the session and watches the
+// console of the page.
+func watchConsole(ctx context.Context, client *cdpClient, sessionID string) (*consoleWatcher, error) {
+	watcher := &consoleWatcher{requestID: requestIDFromContext(ctx)}
+	client.addEventHandler(func(event cdpEvent) {
+		if event.SessionID == sessionID {
+			watcher.handle(event)
+		}
+	})
+	if err := client.Call(ctx, sessionID, "Runtime.enable", nil, nil); err != nil {
+		return nil, err
+	}
+	return watcher, nil
+}
+
+// consoleArg is the part of a CDP RemoteObject used to print it.
+type consoleArg struct {
+	Type        string          `json:"type"`
+	Value       json.RawMessage `json:"value"`
+	Description string          `json:"description"`
+}
+
+func (a consoleArg) String() string {
+	var text string
+	if a.Type == "string" && json.Unmarshal(a.Value, &text) == nil {
+		return text
+	}
+	if a.Description != "" {
+		return a.Description
+	}
+	if len(a.Value) > 0 {
+		return string(a.Value)
+	}
+	return a.Type
+}
+
+// handle processes a single CDP event. Unrelated events are ignored.
+func (w *consoleWatcher) handle(event cdpEvent) {
+	switch event.Method {
+	case "Runtime.consoleAPICalled":
+		var params struct {
+			Type string       `json:"type"`
+			Args []consoleArg `json:"args"`
+		}
+		if err := json.Unmarshal(event.Params, &params); err != nil {
+			return
+		}
+		parts := make([]string, 0, len(params.Args))
+		for _, arg := range params.Args {
+			parts = append(parts, arg.String())
+		}
+		w.log(params.Type == "error" || params.Type == "assert", "console.%s: %s", params.Type, strings.Join(parts, " "))
+
+	case "Runtime.exceptionThrown":
+		var params struct {
+			ExceptionDetails struct {
+				Text         string `json:"text"`
+				URL          string `json:"url"`
+				LineNumber   int    `json:"lineNumber"`
+				ColumnNumber int    `json:"columnNumber"`
+				Exception    *struct {
+					Description string `json:"description"`
+				} `json:"exception"`
+			} `json:"exceptionDetails"`
+		}
+		if err := json.Unmarshal(event.Params, &params); err != nil {
+			return
+		}
+		details := params.ExceptionDetails
+		message := details.Text
+		if details.Exception != nil && details.Exception.Description != "" {
+			// The first line; the rest is the stack.
+			message, _, _ = strings.Cut(details.Exception.Description, "\n")
+		}
+		// CDP positions are 0-based.
+		thrown := jsError{Message: message, URL: details.URL, Line: details.LineNumber + 1, Column: details.ColumnNumber + 1}
+		w.mu.Lock()
+		w.errors = append(w.errors, thrown)
+		w.mu.Unlock()
+		w.log(true, "uncaught %s (%s:%d:%d)", thrown.Message, thrown.URL, thrown.Line, thrown.Column)
+	}
+}
+
+// log writes one line about the page, up to maxConsoleLogLines per render.
+func (w *consoleWatcher) log(isError bool, format string, args ...any) {
+	w.mu.Lock()
+	if w.logged >= maxConsoleLogLines {
+		w.dropped++
+		w.mu.Unlock()
+		return
+	}
+	w.logged++
+	w.mu.Unlock()
+
+	line := fmt.Sprintf(format, args...)
+	if len(line) > maxConsoleMessageBytes {
+		line = line[:maxConsoleMessageBytes] + "..."
+	}
+	if isError {
+		Warnf("page %s: %s", w.requestID, line)
+	} else {
+		Debugf("page %s: %s", w.requestID, line)
+	}
+}
+
+// check returns a *jsErrorsError when the page threw.
+func (w *consoleWatcher) check() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.errors) == 0 {
+		return nil
+	}
+	return &jsErrorsError{
+		Code:    "js_error",
+		Message: fmt.Sprintf("page threw %d uncaught exceptions", len(w.errors)),
+		Errors:  append([]jsError(nil), w.errors...),
+	}
+}
+
+// done logs how many lines were left out.
+func (w *consoleWatcher) done() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.dropped > 0 {
+		Debugf("page %s: %d more console lines not logged", w.requestID, w.dropped)
+	}
+}
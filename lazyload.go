@@ -0,0 +1,76 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Time the lazy_load scroll-through may take, including the wait for the
+// images it triggered, and the pause between two scroll steps.
+const (
+	lazyLoadBudget = 10 * time.Second
+	lazyLoadStep   = 100 * time.Millisecond
+)
+
+// lazyLoadScript makes lazy content load before printing: loading="lazy"
+// images become eager, and the page is scrolled to the bottom one viewport at
+// a time, pausing lazyLoadStep at each step for IntersectionObserver callbacks
+// and scroll handlers to run, and back to the top. It then waits for the
+// images still loading. The whole script stops after lazyLoadBudget.
+const lazyLoadScript = `(async () => {
+  const step = %d, deadline = Date.now() + %d;
+  const sleep = (ms) => new Promise((resolve) => setTimeout(resolve, ms));
+  document.querySelectorAll('img[loading="lazy"], iframe[loading="lazy"]').forEach((el) => { el.loading = "eager"; });
+  const root = document.scrollingElement || document.documentElement;
+  let scrolls = 0;
+  for (let y = 0; y < root.scrollHeight && Date.now() < deadline; y += Math.max(window.innerHeight, 100)) {
+    window.scrollTo(0, y);
+    scrolls++;
+    await sleep(step);
+  }
+  window.scrollTo(0, root.scrollHeight);
+  await sleep(step);
+  window.scrollTo(0, 0);
+  const pending = Array.from(document.images).filter((img) => !img.complete);
+  await Promise.race([
+    Promise.all(pending.map((img) => new Promise((resolve) => {
+      img.addEventListener("load", resolve, { once: true });
+      img.addEventListener("error", resolve, { once: true });
+    }))),
+    sleep(Math.max(0, deadline - Date.now())),
+  ]);
+  return { scrolls, pending: Array.from(document.images).filter((img) => !img.complete).length };
+})()`
+
+// scrollThrough runs lazyLoadScript in the page.
+func scrollThrough(ctx context.Context, client *cdpClient, sessionID string) error {
+	var result struct {
+		Result struct {
+			Value struct {
+				Scrolls int `json:"scrolls"`
+				Pending int `json:"pending"`
+			} `json:"value"`
+		} `json:"result"`
+		ExceptionDetails *struct {
+			Text string `json:"text"`
+		} `json:"exceptionDetails"`
+	}
+	if err := client.Call(ctx, sessionID, "Runtime.evaluate", map[string]any{
+		"expression":    fmt.Sprintf(lazyLoadScript, lazyLoadStep.Milliseconds(), lazyLoadBudget.Milliseconds()),
+		"awaitPromise":  true,
+		"returnByValue": true,
+	}, &result); err != nil {
+		return err
+	}
+	if result.ExceptionDetails != nil {
+		return errors.New("lazy load: " + result.ExceptionDetails.Text)
+	}
+	value := result.Result.Value
+	Debugf("lazy load: %d scroll steps, %d images still loading", value.Scrolls, value.Pending)
+	return nil
+}
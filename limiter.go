@@ -0,0 +1,276 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Render priorities, from the priority parameter or the X-Priority header.
+// Batch items default to low, every other render to normal.
+const (
+	priorityHigh   = "high"
+	priorityNormal = "normal"
+	priorityLow    = "low"
+
+	headerPriority = "X-Priority"
+)
+
+// renderPriorities ranks the priorities, most urgent first.
+var renderPriorities = []string{priorityHigh, priorityNormal, priorityLow}
+
+// priorityRank returns the rank of priority in renderPriorities; "" is normal.
+func priorityRank(priority string) int {
+	for i, name := range renderPriorities {
+		if name == priority {
+			return i
+		}
+	}
+	return 1
+}
+
+// parsePriority validates a priority value.
+func parsePriority(value string) (string, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if !containsString(renderPriorities, value) {
+		return "", fmt.Errorf("invalid priority: must be high, normal or low")
+	}
+	return value, nil
+}
+
+// requestPriority returns the priority of a render: the priority option when
+// set, else the X-Priority header, else fallback.
+func requestPriority(r *http.Request, option, fallback string) (string, error) {
+	if option != "" {
+		return option, nil
+	}
+	if value := r.Header.Get(headerPriority); value != "" {
+		return parsePriority(value)
+	}
+	return fallback, nil
+}
+
+// renderLimiter bounds the number of renders running against Chrome at the same
+// time. Requests that cannot get a slot immediately wait in a bounded queue for
+// at most queueWait, where a free slot goes to the oldest waiter of the highest
+// priority. When the queue is full, a request sheds the newest waiter of a
+// lower priority, or is rejected right away.
+//
+// A nil limiter never blocks nor counts. With maxActive <= 0 renders are
+// unlimited but still counted, so in-flight numbers can be reported.
+type renderLimiter struct {
+	maxActive int
+	maxQueue  int
+	queueWait time.Duration
+
+	mu      sync.Mutex
+	active  int
+	waiters []*limiterWaiter
+	// avgRender is a moving average of how long renders hold their slot,
+	// from which retryAfter estimates when the queue will have room.
+	avgRender time.Duration
+}
+
+// Bounds of the Retry-After delay suggested to shed clients.
+const (
+	minRetryAfter = time.Second
+	maxRetryAfter = 5 * time.Minute
+)
+
+// limiterWaiter is a queued request. ready is closed when it leaves the
+// queue: granted tells whether it got a slot or was shed.
+type limiterWaiter struct {
+	rank    int
+	ready   chan struct{}
+	granted bool
+}
+
+// queueError is returned when a render is shed by the limiter. It carries the
+// suggested Retry-After delay for the client.
+type queueError struct {
+	reason     string
+	retryAfter time.Duration
+}
+
+func (e *queueError) Error() string {
+	return fmt.Sprintf("render queue: %s", e.reason)
+}
+
+func newRenderLimiter(maxActive, maxQueue int, queueWait time.Duration) *renderLimiter {
+	return &renderLimiter{
+		maxActive: maxActive,
+		maxQueue:  maxQueue,
+		queueWait: queueWait,
+	}
+}
+
+// acquire obtains a render slot at normal priority, see acquirePriority.
+func (l *renderLimiter) acquire(ctx context.Context) (func(), error) {
+	return l.acquirePriority(ctx, priorityNormal)
+}
+
+// acquirePriority obtains a render slot, waiting in the queue if necessary. On
+// success it returns a release function that must be called exactly once when
+// the render is done (additional calls are ignored).
+func (l *renderLimiter) acquirePriority(ctx context.Context, priority string) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	l.mu.Lock()
+	if l.maxActive <= 0 || (l.active < l.maxActive && len(l.waiters) == 0) {
+		l.active++
+		l.mu.Unlock()
+		return l.releaseFunc(), nil
+	}
+	waiter := &limiterWaiter{rank: priorityRank(priority), ready: make(chan struct{})}
+	if len(l.waiters) >= l.maxQueue && !l.shedWaiter(waiter.rank) {
+		l.mu.Unlock()
+		return nil, &queueError{reason: "queue full", retryAfter: l.retryAfter()}
+	}
+	l.waiters = append(l.waiters, waiter)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(l.queueWait)
+	defer timer.Stop()
+
+	var err error
+	select {
+	case <-waiter.ready:
+		if waiter.granted {
+			return l.releaseFunc(), nil
+		}
+		return nil, &queueError{reason: "queue full", retryAfter: l.retryAfter()}
+	case <-timer.C:
+		err = &queueError{reason: "wait timeout", retryAfter: l.retryAfter()}
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	l.mu.Lock()
+	if !l.removeWaiter(waiter) && waiter.granted {
+		// The slot was handed over while we were giving up: pass it on.
+		l.mu.Unlock()
+		l.release()
+		return nil, err
+	}
+	l.mu.Unlock()
+	return nil, err
+}
+
+// stats returns the number of running renders and queued requests.
+func (l *renderLimiter) stats() (int, int) {
+	if l == nil {
+		return 0, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.active, len(l.waiters)
+}
+
+// releaseFunc returns an idempotent release function for one acquired slot.
+func (l *renderLimiter) releaseFunc() func() {
+	var once sync.Once
+	start := time.Now()
+	return func() {
+		once.Do(func() {
+			l.observe(time.Since(start))
+			l.release()
+		})
+	}
+}
+
+// observe adds the time a render held its slot to the moving average.
+func (l *renderLimiter) observe(held time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.avgRender == 0 {
+		l.avgRender = held
+		return
+	}
+	l.avgRender += (held - l.avgRender) / 8
+}
+
+// release frees a slot, handing it directly to the oldest waiter of the
+// highest priority if any.
+func (l *renderLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.waiters) > 0 {
+		next := l.waiters[0]
+		for _, waiter := range l.waiters[1:] {
+			if waiter.rank < next.rank {
+				next = waiter
+			}
+		}
+		l.removeWaiter(next)
+		next.granted = true
+		close(next.ready)
+		return
+	}
+	l.active--
+}
+
+// shedWaiter makes room in a full queue for a request of rank by rejecting the
+// newest waiter of a lower priority. It must be called with l.mu held and
+// reports false if there is none.
+func (l *renderLimiter) shedWaiter(rank int) bool {
+	var victim *limiterWaiter
+	for _, waiter := range l.waiters {
+		if waiter.rank > rank && (victim == nil || waiter.rank >= victim.rank) {
+			victim = waiter
+		}
+	}
+	if victim == nil {
+		return false
+	}
+	l.removeWaiter(victim)
+	close(victim.ready)
+	return true
+}
+
+// removeWaiter drops waiter from the queue. It must be called with l.mu held
+// and reports false if the waiter was already dequeued by release or
+// shedWaiter.
+func (l *renderLimiter) removeWaiter(waiter *limiterWaiter) bool {
+	for i, queued := range l.waiters {
+		if queued == waiter {
+			l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// retryAfter suggests how long a rejected client should wait before retrying:
+// the time the renders queued ahead of it take on the available slots, from
+// the average render time, or the queue wait until renders have been timed.
+func (l *renderLimiter) retryAfter() time.Duration {
+	l.mu.Lock()
+	estimate := l.queueWait
+	if l.avgRender > 0 && l.maxActive > 0 {
+		estimate = l.avgRender * time.Duration(len(l.waiters)+1) / time.Duration(l.maxActive)
+	}
+	l.mu.Unlock()
+	return min(max(estimate, minRetryAfter), maxRetryAfter)
+}
+
+// limitRenderer wraps a renderer so that every render holds a limiter slot,
+// queued at options.Priority.
+func limitRenderer(limiter *renderLimiter, next pdfRenderer) pdfRenderer {
+	return func(ctx context.Context, wsURL, html string, wait time.Duration, options pdfOptions) ([]byte, time.Duration, error) {
+		release, err := limiter.acquirePriority(ctx, options.Priority)
+		if err != nil {
+			return nil, 0, err
+		}
+		defer release()
+		return next(ctx, wsURL, html, wait, options)
+	}
+}
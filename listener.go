@@ -0,0 +1,85 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// unixAddrPrefix makes ADDR a Unix domain socket path
+// (unix:///var/run/pdfrest.sock) instead of a TCP address.
+const unixAddrPrefix = "unix://"
+
+// Permissions of the Unix domain socket when SOCKET_MODE is not set: the
+// owner and its group, such as a local reverse proxy, may connect.
+const defaultSocketMode os.FileMode = 0o660
+
+// socketPath returns the socket path of a unix:// ADDR, or "" for a TCP one.
+func socketPath(addr string) string {
+	path, ok := strings.CutPrefix(addr, unixAddrPrefix)
+	if !ok {
+		return ""
+	}
+	return path
+}
+
+// socketListener removes its socket file when closed. The file was renamed
+// into place, so the listener's own unlink would miss it.
+type socketListener struct {
+	net.Listener
+	path string
+}
+
+func (l socketListener) Close() error {
+	err := l.Listener.Close()
+	if removeErr := os.Remove(l.path); removeErr != nil && !os.IsNotExist(removeErr) && err == nil {
+		err = removeErr
+	}
+	return err
+}
+
+// listen opens the listener of ADDR: a TCP address, or a Unix domain socket
+// with the permissions in mode. The socket is created in a private (0700)
+// directory next to the path, given its permissions and only then renamed
+// into place, so no other user can connect before mode applies. A socket
+// file left behind by a previous run is replaced; any other file at the path
+// is an error. The socket file is removed when the listener is closed.
+func listen(addr string, mode os.FileMode) (net.Listener, error) {
+	if !strings.HasPrefix(addr, unixAddrPrefix) {
+		return net.Listen("tcp", addr)
+	}
+	path := socketPath(addr)
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode().Type() != os.ModeSocket {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("remove stale socket: %w", err)
+		}
+	}
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".pdfrest-")
+	if err != nil {
+		return nil, fmt.Errorf("create socket directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	private := filepath.Join(dir, "sock")
+	ln, err := net.Listen("unix", private)
+	if err != nil {
+		return nil, err
+	}
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	if err := os.Chmod(private, mode); err != nil {
+		_ = ln.Close()
+		return nil, fmt.Errorf("set socket permissions: %w", err)
+	}
+	if err := os.Rename(private, path); err != nil {
+		_ = ln.Close()
+		return nil, fmt.Errorf("move socket into place: %w", err)
+	}
+	return socketListener{Listener: ln, path: path}, nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// Saturation headers added to every response, for gateways that balance
+// replicas by load. Shed requests (429 and 503) also get X-Queue-Depth, for
+// clients backing off.
+const (
+	headerRenderUtilization = "X-Render-Utilization"
+	headerRenderQueueDepth  = "X-Render-Queue-Depth"
+	headerQueueDepth        = "X-Queue-Depth"
+)
+
+// loadReport is the body of /loadz. Utilization is the share of render
+// slots in use and QueueUtilization the share of the queue, in percent; both
+// are 0 when the corresponding limit is disabled.
+type loadReport struct {
+	InFlight         int  `json:"in_flight"`
+	Queued           int  `json:"queued"`
+	MaxConcurrent    int  `json:"max_concurrent"`
+	MaxQueue         int  `json:"max_queue"`
+	Utilization      int  `json:"utilization"`
+	QueueUtilization int  `json:"queue_utilization"`
+	Saturated        bool `json:"saturated"`
+}
+
+// load reports the current saturation of the limiter.
+func (l *renderLimiter) load() loadReport {
+	var report loadReport
+	report.InFlight, report.Queued = l.stats()
+	if l == nil {
+		return report
+	}
+	report.MaxConcurrent, report.MaxQueue = l.maxActive, l.maxQueue
+	if l.maxActive > 0 {
+		report.Utilization = report.InFlight * 100 / l.maxActive
+		report.Saturated = report.InFlight >= l.maxActive
+	}
+	if l.maxQueue > 0 {
+		report.QueueUtilization = report.Queued * 100 / l.maxQueue
+	}
+	return report
+}
+
+// loadzHandler serves /loadz: the limiter saturation, cheap enough to poll
+// often. It does not contact Chrome.
+func loadzHandler(limiter *renderLimiter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, http.StatusOK, limiter.load())
+	}
+}
+
+// loadHeadersMiddleware adds the saturation headers to every response, as
+// of the moment the response is written.
+func loadHeadersMiddleware(limiter *renderLimiter, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(&loadHeaderWriter{ResponseWriter: w, limiter: limiter}, r)
+	})
+}
+
+// loadHeaderWriter sets the saturation headers before the status is sent,
+// and tells shed clients when to retry: Retry-After, unless the handler set
+// a more specific one, and X-Queue-Depth.
+type loadHeaderWriter struct {
+	http.ResponseWriter
+	limiter     *renderLimiter
+	wroteHeader bool
+}
+
+func (lw *loadHeaderWriter) WriteHeader(status int) {
+	if !lw.wroteHeader {
+		lw.wroteHeader = true
+		load := lw.limiter.load()
+		lw.Header().Set(headerRenderUtilization, strconv.Itoa(load.Utilization))
+		lw.Header().Set(headerRenderQueueDepth, strconv.Itoa(load.Queued))
+		if status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable {
+			lw.Header().Set(headerQueueDepth, strconv.Itoa(load.Queued))
+			if lw.Header().Get("Retry-After") == "" && lw.limiter != nil {
+				lw.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(lw.limiter.retryAfter())))
+			}
+		}
+	}
+	lw.ResponseWriter.WriteHeader(status)
+}
+
+func (lw *loadHeaderWriter) Write(p []byte) (int, error) {
+	if !lw.wroteHeader {
+		lw.WriteHeader(http.StatusOK)
+	}
+	return lw.ResponseWriter.Write(p)
+}
+
+// Unwrap exposes the underlying ResponseWriter to http.ResponseController.
+func (lw *loadHeaderWriter) Unwrap() http.ResponseWriter {
+	return lw.ResponseWriter
+}
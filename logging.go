@@ -5,12 +5,12 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
 	"encoding/hex"
-	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"strings"
@@ -18,120 +18,183 @@ import (
 	"time"
 )
 
-type jsonLogWriter struct {
-	out io.Writer
-	mu  sync.Mutex
-	buf bytes.Buffer
-}
-
-// Write writes the given byte slice p to the jsonLogWriter.
-// It locks the writer to ensure thread safety, then attempts to
-// write the data to an internal buffer. It processes the buffer
-// line by line, encoding each line as a JSON object with a timestamp,
-// log level, and message. If a line is successfully processed, it
-// is written to the output. The function returns the number of bytes
-// written and any error encountered during the process.
-func (w *jsonLogWriter) Write(p []byte) (int, error) {
-	w.mu.Lock()
-	defer w.mu.Unlock()
-
-	if _, err := w.buf.Write(p); err != nil {
-		return len(p), err
-	}
+// recentLogs keeps the last log lines for support bundles.
+var recentLogs = newLogRing(defaultRecentLogLines)
 
-	for {
-		data := w.buf.Bytes()
-		idx := bytes.IndexByte(data, '\n')
-		if idx == -1 {
-			break
-		}
+// logRing is a fixed-size buffer of the most recent encoded log lines.
+type logRing struct {
+	mu    sync.Mutex
+	lines [][]byte
+	next  int
+	full  bool
+}
 
-		line := string(data[:idx])
-		w.buf.Next(idx + 1)
-		if line == "" {
-			continue
-		}
+func newLogRing(size int) *logRing {
+	return &logRing{lines: make([][]byte, size)}
+}
 
-		level, msg := parseLogLine(line)
-		payload := map[string]string{
-			"time":  time.Now().Format(time.RFC3339Nano),
-			"level": level,
-			"msg":   msg,
-		}
-		encoded, err := json.Marshal(payload)
-		if err != nil {
-			return len(p), err
-		}
-		if _, err := w.out.Write(append(encoded, '\n')); err != nil {
-			return len(p), err
-		}
+func (r *logRing) add(line []byte) {
+	if r == nil || len(r.lines) == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lines[r.next] = line
+	r.next = (r.next + 1) % len(r.lines)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// snapshot returns the buffered lines, oldest first.
+func (r *logRing) snapshot() [][]byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.full {
+		return append([][]byte{}, r.lines[:r.next]...)
 	}
+	return append(append([][]byte{}, r.lines[r.next:]...), r.lines[:r.next]...)
+}
+
+// Output formats of LOG_FORMAT.
+const (
+	logFormatJSON = "json"
+	logFormatText = "text"
+)
+
+// logLevel is LOG_LEVEL: records below it are dropped.
+var logLevel = new(slog.LevelVar)
 
-	return len(p), nil
+// logRingWriter writes the encoded log records to out, keeping a copy of the
+// recent ones. Handlers write each record in a single call.
+type logRingWriter struct {
+	out    io.Writer
+	recent *logRing
+}
+
+func (w logRingWriter) Write(p []byte) (int, error) {
+	w.recent.add(bytes.Clone(p))
+	return w.out.Write(p)
 }
 
 func init() {
-	log.SetFlags(0)
-	// Set the log output to our custom JSON log writer
-	log.SetOutput(&jsonLogWriter{out: os.Stderr})
+	slog.SetDefault(newLogger(logFormatJSON, logRingWriter{out: os.Stderr, recent: recentLogs}))
+}
+
+// configureLogging applies LOG_LEVEL and LOG_FORMAT. The standard log
+// package (e.g. net/http server errors) goes through the same handler.
+func configureLogging(cfg config) {
+	level, _ := parseLogLevel(cfg.LogLevel)
+	logLevel.Set(level)
+	slog.SetDefault(newLogger(cfg.LogFormat, logRingWriter{out: os.Stderr, recent: recentLogs}))
 }
 
-func parseLogLine(line string) (string, string) {
-	level := "info"
-	msg := line
-	if !strings.HasPrefix(line, "level=") {
-		return level, msg
+func newLogger(format string, out io.Writer) *slog.Logger {
+	options := &slog.HandlerOptions{Level: logLevel, ReplaceAttr: replaceLogAttr}
+	if format == logFormatText {
+		return slog.New(slog.NewTextHandler(out, options))
 	}
-	splitAt := strings.IndexByte(line, ' ')
-	if splitAt == -1 {
-		return strings.TrimPrefix(line, "level="), ""
+	return slog.New(slog.NewJSONHandler(out, options))
+}
+
+// replaceLogAttr keeps the level names of earlier releases ("warning"
+// rather than "WARN"), which log pipelines may match on.
+func replaceLogAttr(groups []string, attr slog.Attr) slog.Attr {
+	if len(groups) == 0 && attr.Key == slog.LevelKey {
+		if level, ok := attr.Value.Any().(slog.Level); ok {
+			attr.Value = slog.StringValue(logLevelName(level))
+		}
+	}
+	return attr
+}
+
+func logLevelName(level slog.Level) string {
+	switch {
+	case level < slog.LevelInfo:
+		return "debug"
+	case level < slog.LevelWarn:
+		return "info"
+	case level < slog.LevelError:
+		return "warning"
+	default:
+		return "error"
 	}
-	level = strings.TrimPrefix(line[:splitAt], "level=")
-	msg = strings.TrimSpace(line[splitAt+1:])
-	return level, msg
 }
 
-func logf(level, format string, args ...any) {
-	msg := fmt.Sprintf(format, args...)
-	log.Printf("level=%s %s", level, msg)
+// parseLogLevel parses a LOG_LEVEL value.
+func parseLogLevel(value string) (slog.Level, bool) {
+	switch strings.ToLower(value) {
+	case "debug":
+		return slog.LevelDebug, true
+	case "info":
+		return slog.LevelInfo, true
+	case "warn", "warning":
+		return slog.LevelWarn, true
+	case "error":
+		return slog.LevelError, true
+	}
+	return slog.LevelInfo, false
+}
+
+// logf formats and logs a message at level, unless the level is filtered
+// out.
+func logf(level slog.Level, format string, args ...any) {
+	ctx := context.Background()
+	if !slog.Default().Enabled(ctx, level) {
+		return
+	}
+	slog.Default().Log(ctx, level, fmt.Sprintf(format, args...))
 }
 
 func Infof(format string, args ...any) {
-	logf("info", format, args...)
+	logf(slog.LevelInfo, format, args...)
 }
 
 func Warnf(format string, args ...any) {
-	logf("warning", format, args...)
+	logf(slog.LevelWarn, format, args...)
 }
 
 func Errorf(format string, args ...any) {
-	logf("error", format, args...)
+	logf(slog.LevelError, format, args...)
 }
 
 func Debugf(format string, args ...any) {
-	logf("debug", format, args...)
+	logf(slog.LevelDebug, format, args...)
 }
 
-// loggingMiddleware logs method/path/status/duration.
-// It wraps the ResponseWriter to capture the status code.
-func loggingMiddleware(next http.Handler) http.Handler {
+// headerRequestID carries the request identifier back to the client.
+const headerRequestID = "X-Request-ID"
+
+type requestIDContextKey struct{}
+
+// requestIDFromContext returns the identifier assigned by loggingMiddleware.
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey{}).(string)
+	return id
+}
+
+// loggingMiddleware writes the access log (see accessLog).
+// It wraps the ResponseWriter to capture the status code, and assigns every
+// request an identifier (X-Request-ID response header, request context).
+func loggingMiddleware(cfg config, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 
+		requestID := newRequestID()
+		w.Header().Set(headerRequestID, requestID)
+		r = r.WithContext(context.WithValue(r.Context(), requestIDContextKey{}, requestID))
+		body := &countingBody{ReadCloser: r.Body}
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = body
+		}
+
 		rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
 		next.ServeHTTP(rw, r)
 
-		if r.URL.Path == pathPDF && r.Method == http.MethodPost {
-			requestID := newRequestID()
-			pdfTime := "-"
-			if rw.pdfTimeSet {
-				pdfTime = rw.pdfTime.String()
-			}
-			Infof("%s %s %d %s request_id=%s PDF_TIME=%s", r.Method, r.URL.Path, rw.status, time.Since(start), requestID, pdfTime)
+		if cfg.AccessLogSkipProbes && isProbePath(r.URL.Path) && rw.status < http.StatusBadRequest {
 			return
 		}
-
-		Infof("%s %s %d %s", r.Method, r.URL.Path, rw.status, time.Since(start))
+		accessLog(cfg.AccessLogFormat, r, rw, requestID, body.n, time.Since(start))
 	})
 }
 
@@ -150,15 +213,21 @@ func newRequestID() string {
 // responseWriter wraps an http.ResponseWriter to capture additional response
 // metadata for logging/metrics.
 //
-// It records the final HTTP status code written for the response, and optionally
-// tracks the time spent in PDF processing. The pdfTimeSet flag indicates whether
+// It records the final HTTP status code written for the response and the size
+// of its body, and optionally tracks the time spent in PDF processing. The pdfTimeSet flag indicates whether
 // pdfTime has been explicitly recorded, allowing callers to distinguish a real
 // measured duration from the zero value.
 type responseWriter struct {
 	http.ResponseWriter
 	status     int
+	bytes      int64
 	pdfTime    time.Duration
 	pdfTimeSet bool
+
+	// keyID is the API key policy of the caller, and outcome the result of
+	// a render (renderOutcome*), both set by the handlers for the access log.
+	keyID   string
+	outcome string
 }
 
 // WriteHeader records the HTTP status code and forwards it to the underlying
@@ -170,3 +239,16 @@ func (rw *responseWriter) WriteHeader(status int) {
 	rw.status = status
 	rw.ResponseWriter.WriteHeader(status)
 }
+
+// Write counts the body bytes and forwards them to the underlying
+// ResponseWriter.
+func (rw *responseWriter) Write(p []byte) (int, error) {
+	n, err := rw.ResponseWriter.Write(p)
+	rw.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap exposes the underlying ResponseWriter to http.ResponseController.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
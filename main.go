@@ -4,20 +4,15 @@
 package main
 
 import (
+	"context"
 	"net/http"
 	"os"
-	"strings"
 	"time"
 )
 
 func printVersion() {
-	data, err := os.ReadFile("VERSION")
-	if err != nil {
-		Warnf("unable to read VERSION file: %v", err)
-		return
-	}
-	version := strings.TrimSpace(string(data))
-	Infof("software version: %s", version)
+	info := currentBuildInfo()
+	Infof("software version: %s (commit %s, built %s)", info.Version, info.GitCommit, info.BuildDate)
 }
 
 func printBanner() {
@@ -34,31 +29,276 @@ func printBanner() {
 }
 
 func main() {
+	// Hardened Chrome launcher (managed mode): isolate, then exec Chrome.
+	if len(os.Args) > 1 && os.Args[1] == chromeLauncherArg {
+		os.Exit(runChromeLauncher(os.Args[2:]))
+	}
+
+	// One-shot rendering: "pdfrest render" or the pdfrest-cli link.
+	if isCLIInvocation(os.Args[0]) {
+		os.Exit(runCLI(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+	}
+	if len(os.Args) > 1 && os.Args[1] == cliCommand {
+		os.Exit(runCLI(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+	}
+
+	// Command-line flags override the environment variables they mirror.
+	applyFlags(os.Args[1:])
+
 	// Print ASCII banner.
 	printBanner()
 	printVersion()
 
-	cfg := loadConfig()
+	cfg, err := loadConfig()
+	if err != nil {
+		Errorf("invalid configuration:\n%v", err)
+		os.Exit(1)
+	}
+	configureLogging(cfg)
+
+	// Credentials for remote Chrome endpoints, used by discovery and the
+	// websocket handshake alike.
+	if err := configureChromeConn(cfg); err != nil {
+		Errorf("chrome connection error: %v", err)
+		os.Exit(1)
+	}
+
+	// Error reporting (ERROR_REPORT_DSN): render failures, panics and Chrome
+	// connection errors.
+	if err := configureErrorReporting(cfg); err != nil {
+		Errorf("error reporting configuration error: %v", err)
+		os.Exit(1)
+	}
+
+	// Resolver: discovers Chrome websocket URL unless explicitly provided,
+	// failing over between the CHROME_ENDPOINT entries.
+	resolver := newChromePool(cfg)
+	go resolver.runProber(context.Background(), cfg.ChromeProbeInterval)
+	if sweeper := newTargetSweeper(resolver, cfg.ChromeOrphanTargetAge); sweeper != nil {
+		go sweeper.run(context.Background(), defaultChromeSweepInterval)
+	}
+
+	// Managed mode: launch and supervise Chrome inside the resource sandbox.
+	var sandbox *chromeSandbox
+	var managed *managedChrome
+	if cfg.ChromeMode == chromeModeManaged {
+		var err error
+		sandbox, err = newChromeSandbox(cfg.chromeLimits(), cfg.ChromeCgroup)
+		if err != nil {
+			Errorf("chrome sandbox error: %v", err)
+			os.Exit(1)
+		}
+		if hardening := cfg.chromeHardening(); hardening.enabled() {
+			if err := probeChromeHardening(hardening); err != nil {
+				Errorf("chrome sandbox unavailable: %v", err)
+				os.Exit(1)
+			}
+			Infof("chrome hardening: user namespace=%t read-only fs=%t seccomp filter=%q",
+				hardening.UserNamespace, hardening.ReadOnlyFS, hardening.SeccompFilter)
+		}
+		managed = newManagedChrome(cfg, sandbox)
+		managed.onStart = resolver.invalidate
+		ctx, cancel := context.WithTimeout(context.Background(), defaultManagedChromeStartTimeout)
+		err = managed.start(ctx)
+		cancel()
+		if err != nil {
+			Errorf("chrome start error: %v", err)
+			os.Exit(1)
+		}
+	}
+
+	// Limiter: bounds concurrent Chrome renders and queues the excess.
+	limiter := newRenderLimiter(cfg.MaxConcurrentRenders, cfg.MaxRenderQueue, cfg.RenderQueueTimeout)
+
+	// Key policies: per-caller defaults selected by X-API-Key.
+	policies, err := loadPolicies(cfg.PoliciesFile)
+	if err != nil {
+		Errorf("policies error: %v", err)
+		os.Exit(1)
+	}
+
+	// Authentication: AUTH_PROVIDER selects how API callers are authenticated.
+	auth, err := newAuthProvider(cfg)
+	if err != nil {
+		Errorf("auth configuration error: %v", err)
+		os.Exit(1)
+	}
+
+	// Pre-processing (HTML) and post-processing (PDF) pipelines around the renderer.
+	preProcess := newPreProcessPipeline(cfg)
+	postProcess := newPostProcessPipeline(cfg)
+	var recycler *chromeRecycler
+	if managed != nil {
+		// Recycling: restart the managed browser after N renders or M minutes.
+		recycler = newChromeRecycler(cfg.ChromeRecycleRenders, cfg.ChromeRecycleAfter, managed.recycle)
+	}
+	// Subresource policy: the URLs rendered pages may fetch (SUBRESOURCE_ALLOW,
+	// SUBRESOURCE_DENY).
+	subresources, err := newSubresourcePolicy(cfg)
+	if err != nil {
+		Errorf("subresource policy error: %v", err)
+		os.Exit(1)
+	}
+	pageRenderer := newChromeRenderer(cfg)
+	pageRenderer.subresources = subresources
+	// Session caps: each endpoint runs at most CHROME_MAX_SESSIONS renders.
+	chrome := recycleRenderer(recycler, resolver, sessionRenderer(resolver, sandboxRenderer(sandbox, pageRenderer.render)))
+	// Prints that produce no PDF (EMPTY_PDF_RETRIES) are retried in the same slot.
+	chrome = emptyPDFRetryRenderer(cfg.EmptyPDFRetries, chrome)
+	// Backends: Chrome, or an external command (RENDER_BACKEND_<NAME>_CMD)
+	// selected by RENDER_BACKEND or the backend option.
+	chrome = newBackendRenderer(cfg, chrome)
+	renderer := postProcessRenderer(postProcess, preProcessRenderer(preProcess, limitRenderer(limiter, chrome)))
+	// Output limits apply to the post-processed document as well.
+	renderer = outputLimitRenderer(cfg.MaxPDFBytes, renderer)
+
+	// Identical concurrent renders run once; each caller is still charged
+	// its page quota.
+	var dedup *renderDedup
+	if cfg.DedupRenders {
+		dedup = newRenderDedup()
+	}
+	renderer = dedupRenderer(dedup, renderer)
+
+	// Page quotas of the key policies, charged with the final page count.
+	renderer = quotaRenderer(newPageQuotas(), renderer)
+
+	// Audit trail: who rendered what and when (AUDIT_LOG), one record per
+	// caller, failures included.
+	audit, err := newAuditLog(cfg)
+	if err != nil {
+		Errorf("audit log error: %v", err)
+		os.Exit(1)
+	}
+	renderer = auditRenderer(audit, renderer)
+	renderer = reportingRenderer(errorReports, renderer)
+
+	// In-flight renders, including those of async jobs, are drained on
+	// shutdown.
+	renders := newRenderTracker()
+	renderer = trackRenderer(renders, renderer)
 
-	// Resolver: discovers Chrome websocket URL unless explicitly provided.
-	resolver := newChromeResolver(cfg)
+	// Stored templates: in memory, persisted under TEMPLATE_DIR when set.
+	templates, err := newTemplateStore(cfg.TemplateDir, cfg.TemplateRetention)
+	if err != nil {
+		Errorf("templates error: %v", err)
+		os.Exit(1)
+	}
+
+	// Render archive: inputs and output hashes of every render, for replay.
+	archive, err := newRenderArchive(cfg.ArchiveDir, cfg.ArchiveRetention)
+	if err != nil {
+		Errorf("archive error: %v", err)
+		os.Exit(1)
+	}
+	go archive.runPruner(context.Background(), defaultArchivePruneInterval)
+
+	// Golden renders of the built-in fixtures, to detect output drift.
+	goldens, err := newGoldenStore(cfg.GoldenFile)
+	if err != nil {
+		Errorf("golden file error: %v", err)
+		os.Exit(1)
+	}
 
 	// Router.
+	service := &pdfService{cfg: cfg, resolver: resolver, renderer: renderer, templates: templates, archive: archive,
+		policies: policies, links: newRenderLinkStore()}
+	if len(cfg.BackendCommands) > 0 {
+		// Command backends render without Chrome, so its absence is left
+		// for backendRenderer to judge.
+		service.resolver = fallbackResolver{resolver}
+		Infof("render backends: default %s, fallback %q, commands %d", cfg.RenderBackend, cfg.RenderFallbackBackend, len(cfg.BackendCommands))
+	}
+	// Artifacts: async job results on disk, evicted by TTL and disk usage.
+	artifacts, err := newArtifactStore(cfg.ArtifactDir, cfg.ArtifactTTL, cfg.ArtifactMaxBytes)
+	if err != nil {
+		Errorf("artifact store error: %v", err)
+		os.Exit(1)
+	}
+	go artifacts.runCollector(context.Background(), defaultArtifactGCInterval)
+	service.jobs = newJobStore(cfg.JobRetention, cfg.MaxJobs, artifacts)
+	if service.s3, err = newS3Client(cfg); err != nil {
+		Errorf("s3 configuration error: %v", err)
+		os.Exit(1)
+	}
+	if service.s3 != nil {
+		Infof("s3 output: bucket %s at %s", cfg.S3Bucket, service.s3.endpoint.Host)
+	}
+	if service.downloads, err = newDownloadSigner(cfg); err != nil {
+		Errorf("download signer error: %v", err)
+		os.Exit(1)
+	}
+	if service.webhooks = newWebhookNotifier(cfg); service.webhooks != nil {
+		Infof("render webhooks: allowed hosts %v", cfg.WebhookAllowedHosts)
+	}
+	go service.jobs.runPruner(context.Background(), defaultJobPruneInterval)
+	if opa := newOPAAuthorizer(cfg); opa != nil {
+		Infof("authorization: OPA at %s (fail open: %t)", cfg.OPAURL, cfg.OPAFailOpen)
+		service.authz = opa
+	}
 	mux := http.NewServeMux()
-	mux.HandleFunc(pathPDF, pdfHandler(cfg, resolver, renderPDF))
-	mux.HandleFunc(pathHealthz, healthHandler(resolver))
+	mux.Handle(pathPDF, service)
+	mux.Handle(pathImage, service)
+	mux.HandleFunc(pathPDFBatch, service.serveBatch)
+	mux.HandleFunc(pathJobs+"/{id}", service.serveJob)
+	mux.HandleFunc(pathJobs+"/{id}/result", service.serveJobResult)
+	mux.HandleFunc(pathDownloads+"/{id}", service.serveDownload)
+	mux.HandleFunc(pathRenderLinks, service.serveRenderLinks)
+	mux.HandleFunc(pathRenderLinks+"/{token}", service.serveRenderLink)
+	mux.HandleFunc(pathHealthz, healthHandler(resolver, limiter))
+	mux.HandleFunc(pathLivez, livenessHandler())
+	mux.HandleFunc(pathReadyz, healthHandler(resolver, limiter))
+	mux.HandleFunc(pathVersion, versionHandler(resolver))
+	mux.HandleFunc(pathStatus, statusHandler(resolver, limiter))
+	mux.HandleFunc(pathLoadz, loadzHandler(limiter))
+	mux.HandleFunc(pathTemplates, templatesHandler(templates))
+	mux.HandleFunc(pathTemplates+"/{name}", templateHandler(templates, cfg.MaxBodyBytes))
+	mux.HandleFunc(pathTemplates+"/{name}/restore", templateRestoreHandler(templates))
+	mux.HandleFunc(pathTemplates+"/{name}/fixtures", templateFixturesHandler(templates))
+	mux.HandleFunc(pathTemplates+"/{name}/fixtures/{fixture}", templateFixtureHandler(templates, cfg.MaxBodyBytes))
+	mux.HandleFunc(pathTemplates+"/{name}/test", service.serveTemplateTest)
+	mux.Handle(pathAdminExport, adminMiddleware(cfg.AdminToken, exportHandler(templates, policies)))
+	mux.Handle(pathAdminImport, adminMiddleware(cfg.AdminToken, importHandler(templates, policies)))
+	mux.Handle(pathAdminReplay, adminMiddleware(cfg.AdminToken, http.HandlerFunc(service.serveReplay)))
+	mux.Handle(pathAdminSupport, adminMiddleware(cfg.AdminToken, supportBundleHandler(cfg, resolver, limiter, renderer)))
+	mux.Handle(pathAdminGolden, adminMiddleware(cfg.AdminToken, goldenHandler(cfg, resolver, renderer, goldens)))
+	mux.Handle(pathAdminFlush, adminMiddleware(cfg.AdminToken, flushHandler(resolver, dedup)))
+	mux.Handle(pathAdminRenders, adminMiddleware(cfg.AdminToken, activeRendersHandler(renders)))
+	mux.Handle(pathAdminRenders+"/{id}", adminMiddleware(cfg.AdminToken, cancelRenderHandler(renders)))
+
+	// Middleware: CORS preflights (CORS_ALLOWED_ORIGINS) are answered before
+	// authentication, which browsers do not send them with.
+	handler := loggingMiddleware(cfg, corsMiddleware(newCORSPolicy(cfg), errorReportMiddleware(authMiddleware(auth, policyMiddleware(policies, mux)))))
 
 	// Server with sane defaults. Note: WriteTimeout is set to (request timeout + small buffer),
 	// so handlers can use the full configured RequestTimeout.
 	srv := &http.Server{
 		Addr:              cfg.Addr,
-		Handler:           loggingMiddleware(mux),
+		Handler:           loadHeadersMiddleware(limiter, handler),
 		ReadHeaderTimeout: defaultReadHeaderTimeout,
 		ReadTimeout:       defaultReadTimeout,
 		WriteTimeout:      cfg.RequestTimeout + 5*time.Second,
 		IdleTimeout:       defaultIdleTimeout,
 	}
 
+	// Optional TLS (and mTLS) termination.
+	tlsConfig, err := loadServerTLSConfig(cfg)
+	if err != nil {
+		Errorf("tls configuration error: %v", err)
+		os.Exit(1)
+	}
+	srv.TLSConfig = tlsConfig
+
+	// Listener: TCP, or a Unix domain socket with ADDR=unix:///path.
+	ln, err := listen(cfg.Addr, cfg.SocketMode)
+	if err != nil {
+		Errorf("listen error: %v", err)
+		os.Exit(1)
+	}
+
 	// Start server.
-	runServer(srv, cfg.Addr)
+	runServer(srv, ln, renders, cfg.ShutdownDrainTimeout)
+	if managed != nil {
+		managed.stop()
+	}
 }
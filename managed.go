@@ -0,0 +1,264 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Chrome modes: connect to an existing instance, or launch and supervise one.
+// chromeModeLaunch is an alias of chromeModeManaged.
+const (
+	chromeModeRemote  = "remote"
+	chromeModeManaged = "managed"
+	chromeModeLaunch  = "launch"
+)
+
+// managedChromeFlags are passed to Chrome in managed mode, before CHROME_ARGS.
+var managedChromeFlags = []string{
+	"--headless",
+	"--disable-gpu",
+	"--no-sandbox",
+	"--disable-dev-shm-usage",
+	"--disable-background-networking",
+	"--disable-extensions",
+	"--disable-default-apps",
+	"--no-first-run",
+	"--disable-sync",
+	"--remote-debugging-address=127.0.0.1",
+}
+
+// managedChrome launches Chrome (CHROME_MODE=managed), restarts it when it
+// exits and runs it inside the configured sandbox.
+type managedChrome struct {
+	path      string
+	args      []string
+	port      int
+	dataDir   string
+	sandbox   *chromeSandbox
+	hardening chromeHardening
+
+	// onStart runs after every (re)start, e.g. to drop cached websocket URLs.
+	onStart func()
+
+	mu      sync.Mutex
+	cmd     *exec.Cmd
+	stopped bool
+	exited  chan struct{}
+	waitErr error
+
+	// recycled is open while recycle restarts Chrome itself.
+	recycled chan struct{}
+}
+
+func newManagedChrome(cfg config, sandbox *chromeSandbox) *managedChrome {
+	return &managedChrome{
+		path:      cfg.ChromePath,
+		args:      cfg.ChromeArgs,
+		port:      cfg.ChromeDebugPort,
+		dataDir:   cfg.ChromeUserDataDir,
+		sandbox:   sandbox,
+		hardening: cfg.chromeHardening(),
+	}
+}
+
+// command builds the Chrome command line, through the hardened launcher when
+// hardening is enabled.
+func (m *managedChrome) command() (*exec.Cmd, error) {
+	args := append([]string{}, managedChromeFlags...)
+	args = append(args, "--remote-debugging-port="+strconv.Itoa(m.port), "--user-data-dir="+m.dataDir)
+	args = append(args, m.args...)
+	cmd := exec.Command(m.path, args...)
+	if m.hardening.enabled() {
+		var err error
+		if cmd, err = m.hardening.launcherCommand(m.path, args); err != nil {
+			return nil, err
+		}
+	}
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	return cmd, nil
+}
+
+// start launches Chrome, waits until its DevTools endpoint answers and keeps
+// supervising it in the background.
+func (m *managedChrome) start(ctx context.Context) error {
+	if err := m.launch(); err != nil {
+		return err
+	}
+	if err := m.waitReady(ctx); err != nil {
+		m.stop()
+		return err
+	}
+	go m.supervise()
+	return nil
+}
+
+func (m *managedChrome) launch() error {
+	cmd, err := m.command()
+	if err != nil {
+		return err
+	}
+	if m.sandbox != nil {
+		if err := m.sandbox.prepare(cmd); err != nil {
+			return err
+		}
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("start chrome: %w", err)
+	}
+	if m.sandbox != nil {
+		if err := m.sandbox.apply(cmd.Process.Pid); err != nil {
+			_ = cmd.Process.Kill()
+			_ = cmd.Wait()
+			return err
+		}
+	}
+	Infof("chrome started: pid %d, debugging port %d", cmd.Process.Pid, m.port)
+
+	exited := make(chan struct{})
+	m.mu.Lock()
+	m.cmd, m.exited = cmd, exited
+	m.mu.Unlock()
+	go func() {
+		err := cmd.Wait()
+		m.mu.Lock()
+		m.waitErr = err
+		m.mu.Unlock()
+		close(exited)
+	}()
+	if m.onStart != nil {
+		m.onStart()
+	}
+	return nil
+}
+
+// waitReady polls /json/version until Chrome answers or ctx expires.
+func (m *managedChrome) waitReady(ctx context.Context) error {
+	endpoint := fmt.Sprintf("http://127.0.0.1:%d/json/version", m.port)
+	client := &http.Client{Timeout: time.Second}
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+		if err != nil {
+			return err
+		}
+		if resp, err := client.Do(req); err == nil {
+			_ = resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("chrome did not become ready: %w", ctx.Err())
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// supervise waits for Chrome to exit and restarts it until stop is called.
+func (m *managedChrome) supervise() {
+	for {
+		before := m.sandbox.events()
+		m.mu.Lock()
+		exited := m.exited
+		m.mu.Unlock()
+		<-exited
+
+		m.mu.Lock()
+		stopped, err, recycled := m.stopped, m.waitErr, m.recycled
+		m.mu.Unlock()
+		if stopped {
+			return
+		}
+		if recycled != nil {
+			<-recycled
+			continue
+		}
+		if violation := m.sandbox.violation(before); violation != nil {
+			Errorf("chrome exited: %v", violation)
+			errorReports.report(context.Background(), errorKindChrome, violation, nil)
+		} else {
+			Errorf("chrome exited unexpectedly: %v", err)
+			errorReports.report(context.Background(), errorKindChrome, fmt.Errorf("chrome exited unexpectedly: %v", err), nil)
+		}
+
+		for {
+			time.Sleep(defaultManagedChromeRestartDelay)
+			m.mu.Lock()
+			stopped = m.stopped
+			m.mu.Unlock()
+			if stopped {
+				return
+			}
+			if err := m.launch(); err != nil {
+				Errorf("chrome restart failed: %v", err)
+				continue
+			}
+			break
+		}
+	}
+}
+
+// recycle restarts Chrome on purpose: it stops the current process and
+// launches a fresh one, waiting until it answers. The supervisor takes over
+// again if the launch fails.
+func (m *managedChrome) recycle(ctx context.Context) error {
+	m.mu.Lock()
+	if m.stopped || m.cmd == nil {
+		m.mu.Unlock()
+		return nil
+	}
+	done := make(chan struct{})
+	cmd, exited := m.cmd, m.exited
+	m.recycled = done
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		m.recycled = nil
+		m.mu.Unlock()
+		close(done)
+	}()
+
+	_ = cmd.Process.Signal(os.Interrupt)
+	select {
+	case <-exited:
+	case <-time.After(defaultShutdownTimeout):
+		_ = cmd.Process.Kill()
+		<-exited
+	}
+	m.mu.Lock()
+	stopped := m.stopped
+	m.mu.Unlock()
+	if stopped {
+		return nil
+	}
+	if err := m.launch(); err != nil {
+		return err
+	}
+	return m.waitReady(ctx)
+}
+
+// stop terminates Chrome and the supervision loop.
+func (m *managedChrome) stop() {
+	m.mu.Lock()
+	m.stopped = true
+	cmd, exited := m.cmd, m.exited
+	m.mu.Unlock()
+	if cmd == nil || cmd.Process == nil {
+		return
+	}
+	_ = cmd.Process.Signal(os.Interrupt)
+	select {
+	case <-exited:
+	case <-time.After(defaultShutdownTimeout):
+		_ = cmd.Process.Kill()
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Headers of the page requests that callers cannot override.
+var reservedNavigationHeaders = []string{"host", "content-length", "transfer-encoding", "connection"}
+
+// basicAuth is the username and password of a URL render, answered to the
+// HTTP basic auth challenges of the page's own origin.
+type basicAuth struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// validateNavigationHeaders checks the extra headers of a URL render.
+func validateNavigationHeaders(headers map[string]string) error {
+	for name, value := range headers {
+		if name == "" || strings.ContainsAny(name, " \t\r\n:") {
+			return fmt.Errorf("invalid header name %q", name)
+		}
+		if containsString(reservedNavigationHeaders, strings.ToLower(name)) {
+			return fmt.Errorf("header %s cannot be set", name)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("invalid value for header %s", name)
+		}
+	}
+	return nil
+}
+
+// navigationHeaders returns the headers of a paused request with the extra
+// headers of the render set, overriding the page's own of the same name.
+// Fetch.continueRequest replaces all the headers of the request, so they are
+// given whole, sorted by name.
+func navigationHeaders(request, extra map[string]string) []map[string]string {
+	entries := make([]map[string]string, 0, len(request)+len(extra))
+	for name, value := range request {
+		overridden := false
+		for extraName := range extra {
+			if strings.EqualFold(name, extraName) {
+				overridden = true
+				break
+			}
+		}
+		if !overridden {
+			entries = append(entries, map[string]string{"name": name, "value": value})
+		}
+	}
+	for name, value := range extra {
+		entries = append(entries, map[string]string{"name": name, "value": value})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i]["name"] < entries[j]["name"] })
+	return entries
+}
+
+// urlOrigin returns the origin of a URL as Chrome reports it in auth
+// challenges (scheme://host[:port]).
+func urlOrigin(raw string) string {
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Host == "" {
+		return ""
+	}
+	return parsed.Scheme + "://" + parsed.Host
+}
+
+// answerAuth answers a Fetch.authRequired event: the credentials are given
+// once per request and only to server challenges from authOrigin; other
+// challenges, and a second one after wrong credentials, are canceled, so the
+// page renders the server's 401 response instead of waiting.
+func (f *requestFilter) answerAuth(ctx context.Context, client *cdpClient, event cdpEvent) {
+	var params struct {
+		RequestID     string `json:"requestId"`
+		AuthChallenge struct {
+			Source string `json:"source"`
+			Origin string `json:"origin"`
+		} `json:"authChallenge"`
+	}
+	if err := json.Unmarshal(event.Params, &params); err != nil || params.RequestID == "" {
+		return
+	}
+	response := map[string]any{"response": "CancelAuth"}
+	challenge := params.AuthChallenge
+	switch {
+	case f.auth == nil || challenge.Source != "Server" || challenge.Origin != f.authOrigin:
+		Debugf("canceled auth challenge from %s", challenge.Origin)
+	case f.authAttempted[params.RequestID]:
+		Warnf("credentials rejected by %s", challenge.Origin)
+	default:
+		f.authAttempted[params.RequestID] = true
+		response = map[string]any{"response": "ProvideCredentials", "username": f.auth.Username, "password": f.auth.Password}
+	}
+	answer := map[string]any{"requestId": params.RequestID, "authChallengeResponse": response}
+	if err := client.send(ctx, event.SessionID, "Fetch.continueWithAuth", answer); err != nil {
+		Warnf("chrome Fetch.continueWithAuth error: %v", err)
+	}
+}
@@ -0,0 +1,313 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// defaultSubresourceDeny keeps rendered pages away from the link-local range
+// and the cloud metadata services unless SUBRESOURCE_DENY says otherwise.
+var defaultSubresourceDeny = []string{
+	"169.254.0.0/16", "fe80::/10", "fd00:ec2::254/128", "100.100.100.200/32",
+	"metadata.google.internal", "metadata.goog",
+}
+
+// Longest DNS lookup for a CIDR rule; a host that does not resolve in time is
+// blocked.
+const subresourceResolveTimeout = 2 * time.Second
+
+// urlRule matches request URLs. It is a scheme ("file:"), an IP or CIDR
+// ("10.0.0.0/8", matched against the resolved addresses of host names), or
+// a host pattern as in ASSET_ALLOWED_HOSTS with an optional scheme
+// ("https://*.example.com").
+type urlRule struct {
+	scheme string
+	prefix netip.Prefix
+	host   string
+}
+
+func parseURLRule(value string) (urlRule, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	var rule urlRule
+	if scheme, rest, ok := strings.Cut(value, ":"); ok && (rest == "" || strings.HasPrefix(rest, "//")) {
+		rule.scheme, value = scheme, strings.TrimPrefix(rest, "//")
+		if value == "" {
+			return rule, nil
+		}
+	}
+	if prefix, err := netip.ParsePrefix(value); err == nil {
+		rule.prefix = prefix.Masked()
+		return rule, nil
+	}
+	if addr, err := netip.ParseAddr(strings.Trim(value, "[]")); err == nil {
+		rule.prefix = netip.PrefixFrom(addr, addr.BitLen())
+		return rule, nil
+	}
+	if value == "" || strings.ContainsAny(value, "/?#@ ") {
+		return rule, fmt.Errorf("invalid url rule %q", value)
+	}
+	rule.host = value
+	return rule, nil
+}
+
+// matches reports whether u matches the rule; addrs are the addresses of its
+// host (needed by CIDR rules only).
+func (r urlRule) matches(u *url.URL, addrs []netip.Addr) bool {
+	if r.scheme != "" && r.scheme != u.Scheme {
+		return false
+	}
+	switch {
+	case r.prefix.IsValid():
+		for _, addr := range addrs {
+			if r.prefix.Contains(addr.Unmap()) {
+				return true
+			}
+		}
+		return false
+	case r.host != "":
+		if strings.HasPrefix(r.host, "*.") {
+			return strings.HasSuffix(strings.ToLower(u.Hostname()), r.host[1:])
+		}
+		return r.host == strings.ToLower(u.Host) || r.host == strings.ToLower(u.Hostname())
+	}
+	return true
+}
+
+// subresourcePolicy decides which URLs rendered pages may fetch: nothing
+// matching a deny rule, and only what matches an allow rule when there are
+// any (SUBRESOURCE_DENY, SUBRESOURCE_ALLOW).
+type subresourcePolicy struct {
+	allow  []urlRule
+	deny   []urlRule
+	lookup func(ctx context.Context, host string) ([]netip.Addr, error)
+}
+
+// newSubresourcePolicy returns nil when there are no rules (SUBRESOURCE_DENY
+// set to "none" and no SUBRESOURCE_ALLOW).
+func newSubresourcePolicy(cfg config) (*subresourcePolicy, error) {
+	deny := cfg.SubresourceDeny
+	if deny == nil {
+		deny = defaultSubresourceDeny
+	} else if len(deny) == 1 && deny[0] == "none" {
+		deny = nil
+	}
+	policy := &subresourcePolicy{lookup: func(ctx context.Context, host string) ([]netip.Addr, error) {
+		return net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	}}
+	for _, value := range deny {
+		rule, err := parseURLRule(value)
+		if err != nil {
+			return nil, fmt.Errorf("SUBRESOURCE_DENY: %w", err)
+		}
+		policy.deny = append(policy.deny, rule)
+	}
+	for _, value := range cfg.SubresourceAllow {
+		rule, err := parseURLRule(value)
+		if err != nil {
+			return nil, fmt.Errorf("SUBRESOURCE_ALLOW: %w", err)
+		}
+		policy.allow = append(policy.allow, rule)
+	}
+	if len(policy.deny) == 0 && len(policy.allow) == 0 {
+		return nil, nil
+	}
+	return policy, nil
+}
+
+// needsAddrs reports whether a rule matches on addresses.
+func (p *subresourcePolicy) needsAddrs() bool {
+	for _, rule := range append(p.deny[:len(p.deny):len(p.deny)], p.allow...) {
+		if rule.prefix.IsValid() {
+			return true
+		}
+	}
+	return false
+}
+
+// allows applies the rules to u, whose host resolves to addrs.
+func (p *subresourcePolicy) allows(u *url.URL, addrs []netip.Addr) bool {
+	for _, rule := range p.deny {
+		if rule.matches(u, addrs) {
+			return false
+		}
+	}
+	if len(p.allow) == 0 {
+		return true
+	}
+	for _, rule := range p.allow {
+		if rule.matches(u, addrs) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestFilter fails the network requests of a rendered page that the
+// subresource policy or block_remote do not allow, so untrusted HTML cannot
+// make Chrome fetch internal URLs. Requests are paused with the Fetch domain
+// and answered from the client's event handler; data: URLs never reach the
+// network and are not affected.
+type requestFilter struct {
+	policy  *subresourcePolicy
+	remote  *hostAllowlist
+	blocked atomic.Int64
+
+	// addrs caches the resolved hosts; only the event handler uses it.
+	addrs map[string][]netip.Addr
+
+	// auth answers the basic auth challenges of authOrigin (see answerAuth);
+	// authAttempted, also only used by the event handler, holds the requests
+	// it was given to.
+	auth          *basicAuth
+	authOrigin    string
+	authAttempted map[string]bool
+
+	// headers are added to the requests to headersOrigin, the origin of the
+	// page, and to no other host.
+	headers       map[string]string
+	headersOrigin string
+}
+
+// blockRemote reports whether the render must block remote requests:
+// BLOCK_REMOTE_REQUESTS makes it the default, which a request cannot lift.
+func (c *chromeRenderer) blockRemote(options pdfOptions) bool {
+	return c.blockRemoteDefault || options.BlockRemote
+}
+
+// requestFilter returns the filter of a render, or nil when it may fetch
+// anything. With block_remote, only REMOTE_ALLOWED_HOSTS and, for URL
+// sources, the host of the page itself are reachable.
+func (c *chromeRenderer) requestFilter(options pdfOptions) *requestFilter {
+	if c.subresources == nil && !c.blockRemote(options) && options.Auth == nil && len(options.Headers) == 0 {
+		return nil
+	}
+	filter := &requestFilter{policy: c.subresources, addrs: make(map[string][]netip.Addr)}
+	if options.Auth != nil {
+		filter.auth, filter.authOrigin = options.Auth, urlOrigin(options.URL)
+		filter.authAttempted = map[string]bool{}
+	}
+	if len(options.Headers) > 0 {
+		filter.headers, filter.headersOrigin = options.Headers, urlOrigin(options.URL)
+	}
+	if c.blockRemote(options) {
+		hosts := c.remoteAllowedHosts
+		if options.URL != "" {
+			if parsed, err := url.Parse(options.URL); err == nil && parsed.Host != "" {
+				hosts = append(append([]string(nil), hosts...), parsed.Host)
+			}
+		}
+		remote := newHostAllowlist(hosts)
+		filter.remote = &remote
+	}
+	return filter
+}
+
+// allowed decides whether the page may fetch target.
+func (f *requestFilter) allowed(ctx context.Context, target *url.URL) bool {
+	if f.remote != nil && !f.remote.allowed(target) {
+		return false
+	}
+	if f.policy == nil {
+		return true
+	}
+	var addrs []netip.Addr
+	if f.policy.needsAddrs() {
+		var ok bool
+		if addrs, ok = f.resolve(ctx, target.Hostname()); !ok {
+			return false
+		}
+	}
+	return f.policy.allows(target, addrs)
+}
+
+// resolve returns the addresses of host, an IP literal or a name.
+func (f *requestFilter) resolve(ctx context.Context, host string) ([]netip.Addr, bool) {
+	if host == "" {
+		return nil, true
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return []netip.Addr{addr}, true
+	}
+	if addrs, ok := f.addrs[host]; ok {
+		return addrs, addrs != nil
+	}
+	lookupCtx, cancel := context.WithTimeout(ctx, subresourceResolveTimeout)
+	defer cancel()
+	addrs, err := f.policy.lookup(lookupCtx, host)
+	if err != nil || len(addrs) == 0 {
+		Debugf("subresource %s: resolve: %v", host, err)
+		addrs = nil
+	}
+	f.addrs[host] = addrs
+	return addrs, addrs != nil
+}
+
+// install intercepts the requests of the session. The returned function
+// stops intercepting, since a page websocket (CHROME_WS) is reused across
+// renders.
+func (f *requestFilter) install(ctx context.Context, client *cdpClient, sessionID string) (func(), error) {
+	client.addEventHandler(func(event cdpEvent) {
+		if event.SessionID != sessionID {
+			return
+		}
+		switch event.Method {
+		case "Fetch.requestPaused":
+			f.handle(ctx, client, event)
+		case "Fetch.authRequired":
+			f.answerAuth(ctx, client, event)
+		}
+	})
+	if err := client.Call(ctx, sessionID, "Fetch.enable", map[string]any{
+		"patterns": []map[string]any{{"urlPattern": "*"}},
+		// Without it Chrome answers challenges itself, with no credentials.
+		"handleAuthRequests": f.auth != nil,
+	}, nil); err != nil {
+		return nil, err
+	}
+	return func() {
+		if blocked := f.blocked.Load(); blocked > 0 {
+			Infof("blocked %d subresource requests", blocked)
+		}
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		if err := client.Call(cleanupCtx, sessionID, "Fetch.disable", nil, nil); err != nil {
+			Warnf("chrome fetch disable error: %v", err)
+		}
+	}, nil
+}
+
+// handle continues or fails one paused request. Requests to the origin of
+// the page continue with the extra headers of the render.
+func (f *requestFilter) handle(ctx context.Context, client *cdpClient, event cdpEvent) {
+	var params struct {
+		RequestID string `json:"requestId"`
+		Request   struct {
+			URL     string            `json:"url"`
+			Headers map[string]string `json:"headers"`
+		} `json:"request"`
+	}
+	if err := json.Unmarshal(event.Params, &params); err != nil || params.RequestID == "" {
+		return
+	}
+	method, answer := "Fetch.continueRequest", map[string]any{"requestId": params.RequestID}
+	if target, err := url.Parse(params.Request.URL); err != nil || !f.allowed(ctx, target) {
+		method, answer["errorReason"] = "Fetch.failRequest", "BlockedByClient"
+		f.blocked.Add(1)
+		Debugf("blocked subresource request %s", params.Request.URL)
+	} else if f.headers != nil && urlOrigin(params.Request.URL) == f.headersOrigin {
+		answer["headers"] = navigationHeaders(params.Request.Headers, f.headers)
+	}
+	if err := client.send(ctx, event.SessionID, method, answer); err != nil {
+		Warnf("chrome %s error: %v", method, err)
+	}
+}
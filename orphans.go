@@ -0,0 +1,120 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// targetSweeper closes the tabs renders leaked: a process that dies between
+// Target.createTarget and the deferred Target.closeTarget leaves its tab open
+// until Chrome restarts. Only pages this service opened (see targetURL) that
+// no client is attached to are considered, and only once they have been seen
+// for maxAge. Chrome does not report when a target was created, so the age
+// counts from the first sweep that saw it.
+type targetSweeper struct {
+	pool   *chromePool
+	maxAge time.Duration
+	now    func() time.Time
+
+	// seen is only used by the sweeping goroutine.
+	seen map[string]time.Time
+}
+
+// cdpTargetInfo is the subset of Target.TargetInfo the sweeper needs.
+type cdpTargetInfo struct {
+	TargetID string `json:"targetId"`
+	Type     string `json:"type"`
+	URL      string `json:"url"`
+	Attached bool   `json:"attached"`
+}
+
+// newTargetSweeper returns nil when maxAge is not positive (sweeping disabled).
+func newTargetSweeper(pool *chromePool, maxAge time.Duration) *targetSweeper {
+	if maxAge <= 0 {
+		return nil
+	}
+	return &targetSweeper{pool: pool, maxAge: maxAge, now: time.Now, seen: make(map[string]time.Time)}
+}
+
+// sweep closes the orphaned tabs of every endpoint.
+func (s *targetSweeper) sweep(ctx context.Context) {
+	seen := make(map[string]time.Time)
+	for _, member := range s.pool.members {
+		wsURL, err := member.resolver.wsURL(ctx)
+		if err != nil || isPageWebSocket(wsURL) {
+			// Unreachable (the prober reports it), or a single page that
+			// renders never open tabs in.
+			continue
+		}
+		client, err := newCDPClient(ctx, wsURL)
+		if err != nil {
+			Warnf("chrome %s: target sweep: %v", member.label, err)
+			continue
+		}
+		closed, err := s.sweepClient(ctx, client, member.label, seen)
+		_ = client.Close()
+		if err != nil {
+			Warnf("chrome %s: target sweep: %v", member.label, err)
+		}
+		if closed > 0 {
+			Warnf("chrome %s: closed %d orphaned targets", member.label, closed)
+		}
+	}
+	s.seen = seen
+}
+
+// sweepClient closes the orphaned tabs of one browser, recording the ones
+// still too young in seen under endpoint.
+func (s *targetSweeper) sweepClient(ctx context.Context, client *cdpClient, endpoint string, seen map[string]time.Time) (int, error) {
+	var targets struct {
+		TargetInfos []cdpTargetInfo `json:"targetInfos"`
+	}
+	if err := client.Call(ctx, "", "Target.getTargets", nil, &targets); err != nil {
+		return 0, err
+	}
+	now := s.now()
+	closed := 0
+	for _, target := range targets.TargetInfos {
+		if target.Type != "page" || target.Attached || !strings.HasPrefix(target.URL, "about:blank#"+targetFragmentPrefix) {
+			continue
+		}
+		key := endpoint + "\n" + target.TargetID
+		firstSeen, ok := s.seen[key]
+		if !ok {
+			firstSeen = now
+		}
+		if now.Sub(firstSeen) < s.maxAge {
+			seen[key] = firstSeen
+			continue
+		}
+		if err := client.Call(ctx, "", "Target.closeTarget", map[string]any{"targetId": target.TargetID}, nil); err != nil {
+			return closed, err
+		}
+		Debugf("chrome %s: closed orphaned target %s (%s)", endpoint, target.TargetID, target.URL)
+		closed++
+	}
+	return closed, nil
+}
+
+// run sweeps every interval until ctx is done.
+func (s *targetSweeper) run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sweepCtx, cancel := context.WithTimeout(ctx, defaultChromeClientTimeout)
+			s.sweep(sweepCtx)
+			cancel()
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+var pdfPageTypeRe = regexp.MustCompile(`/Type\s*/Page\b`)
+
+// outputLimitError reports a rendered PDF over MAX_PDF_BYTES (413) or the
+// request's max_pages (422). It is returned to the client as JSON.
+type outputLimitError struct {
+	status  int
+	Code    string `json:"error"`
+	Message string `json:"message"`
+	Limit   int64  `json:"limit"`
+	Actual  int64  `json:"actual"`
+}
+
+func (e *outputLimitError) Error() string {
+	return e.Message
+}
+
+func pdfTooLargeError(limit, actual int64) *outputLimitError {
+	return &outputLimitError{
+		status:  http.StatusRequestEntityTooLarge,
+		Code:    "pdf_too_large",
+		Message: fmt.Sprintf("generated PDF is %d bytes, limit is %d", actual, limit),
+		Limit:   limit,
+		Actual:  actual,
+	}
+}
+
+// checkPDFLimits enforces maxBytes and maxPages (0 disables a limit).
+func checkPDFLimits(pdf []byte, maxBytes int64, maxPages int) error {
+	if maxBytes > 0 && int64(len(pdf)) > maxBytes {
+		return pdfTooLargeError(maxBytes, int64(len(pdf)))
+	}
+	if maxPages > 0 {
+		if pages := countPDFPages(pdf); pages > maxPages {
+			return &outputLimitError{
+				status:  http.StatusUnprocessableEntity,
+				Code:    "too_many_pages",
+				Message: fmt.Sprintf("generated PDF has %d pages, limit is %d", pages, maxPages),
+				Limit:   int64(maxPages),
+				Actual:  int64(pages),
+			}
+		}
+	}
+	return nil
+}
+
+// outputLimitRenderer enforces MAX_PDF_BYTES and max_pages on the final
+// document of next, so post-processing stages cannot grow it past the
+// limits the renderers already checked. Images have their own limit.
+func outputLimitRenderer(maxBytes int64, next pdfRenderer) pdfRenderer {
+	return func(ctx context.Context, wsURL, html string, wait time.Duration, options pdfOptions) ([]byte, time.Duration, error) {
+		pdf, pdfTime, err := next(ctx, wsURL, html, wait, options)
+		if err != nil || options.Image != nil {
+			return pdf, pdfTime, err
+		}
+		if err := checkPDFLimits(pdf, maxBytes, options.MaxPages); err != nil {
+			return nil, pdfTime, err
+		}
+		return pdf, pdfTime, nil
+	}
+}
+
+// headerPDFPages carries the page count of a rendered PDF.
+const headerPDFPages = "X-PDF-Pages"
+
+// countPDFPages walks the page tree, falling back to counting page objects
+// when the document cannot be read that way.
+func countPDFPages(pdf []byte) int {
+	if reader, err := newPDFReader(pdf); err == nil {
+		if pages, err := reader.pages(); err == nil {
+			return len(pages)
+		}
+	}
+	return len(pdfPageTypeRe.FindAll(pdf, -1))
+}
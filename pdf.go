@@ -5,14 +5,40 @@ package main
 
 import (
 	"context"
-	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 )
 
-// renderPDF uses a remote Chrome instance via DevTools websocket and prints the given HTML to PDF.
+// chromeRenderer prints HTML to PDF with a remote Chrome instance. Its fields
+// hold the service-wide rendering settings; per-request settings come from
+// pdfOptions.
+type chromeRenderer struct {
+	transferMode string
+	decodeMode   string
+	maxPDFBytes  int64
+	isolate      bool
+	permissions  []string
+
+	blockRemoteDefault bool
+	remoteAllowedHosts []string
+	// subresources restricts what pages may fetch; nil allows everything.
+	subresources *subresourcePolicy
+	logConsole   bool
+}
+
+func newChromeRenderer(cfg config) *chromeRenderer {
+	return &chromeRenderer{transferMode: cfg.PDFTransferMode, decodeMode: cfg.PDFDecodeMode, maxPDFBytes: cfg.MaxPDFBytes,
+		isolate: cfg.ChromeIsolateContexts, permissions: cfg.ChromeGrantPermissions,
+		blockRemoteDefault: cfg.BlockRemoteRequests, remoteAllowedHosts: cfg.RemoteAllowedHosts,
+		logConsole: cfg.LogPageConsole}
+}
+
+// render uses a remote Chrome instance via DevTools websocket and prints the given HTML to PDF.
 // Logic is unchanged: navigate to about:blank -> set document content -> wait for body -> optional sleep -> PrintToPDF.
-func renderPDF(ctx context.Context, wsURL, html string, wait time.Duration, options pdfOptions) ([]byte, time.Duration, error) {
+func (c *chromeRenderer) render(ctx context.Context, wsURL, html string, wait time.Duration, options pdfOptions) ([]byte, time.Duration, error) {
 	client, err := newCDPClient(ctx, wsURL)
 	if err != nil {
 		return nil, 0, err
@@ -25,54 +51,151 @@ func renderPDF(ctx context.Context, wsURL, html string, wait time.Duration, opti
 
 	sessionID := ""
 	targetID := ""
+	browserContextID := ""
 	if !isPageWebSocket(wsURL) {
-		sessionID, targetID, err = openTargetSession(ctx, client)
+		// Each render gets its own browser context, so no state leaks between
+		// requests sharing the browser. It is disposed after the target.
+		if c.isolate {
+			if browserContextID, err = createBrowserContext(ctx, client); err != nil {
+				return nil, 0, err
+			}
+			defer func() {
+				cleanupCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+				defer cancel()
+				client, done := cleanupClient(cleanupCtx, client, wsURL)
+				defer done()
+				if err := disposeBrowserContext(cleanupCtx, client, browserContextID); err != nil {
+					Warnf("chrome dispose browser context error: %v", err)
+				}
+			}()
+		}
+		sessionID, targetID, err = openTargetSession(ctx, client, browserContextID)
 		if err != nil {
 			return nil, 0, err
 		}
+		setRenderTarget(ctx, targetID)
 		defer func() {
 			cleanupCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 			defer cancel()
+			client, done := cleanupClient(cleanupCtx, client, wsURL)
+			defer done()
 			if err := closeTarget(cleanupCtx, client, targetID); err != nil {
 				Warnf("chrome close target error: %v", err)
 			}
 		}()
 	}
 
-	if err := client.Call(ctx, sessionID, "Page.navigate", map[string]any{
-		"url": "about:blank",
-	}, nil); err != nil {
+	if err := settlePermissions(ctx, client, browserContextID, c.permissions); err != nil {
 		return nil, 0, err
 	}
-
-	var frameTree struct {
-		FrameTree struct {
-			Frame struct {
-				ID string `json:"id"`
-			} `json:"frame"`
-		} `json:"frameTree"`
+	clearGeolocation, err := emulateGeolocation(ctx, client, sessionID, browserContextID, options)
+	if err != nil {
+		return nil, 0, err
 	}
-	if err := client.Call(ctx, sessionID, "Page.getFrameTree", nil, &frameTree); err != nil {
+	defer clearGeolocation()
+	clearMedia, err := emulateMedia(ctx, client, sessionID, options)
+	if err != nil {
 		return nil, 0, err
 	}
-	if frameTree.FrameTree.Frame.ID == "" {
-		return nil, 0, errors.New("missing frame id")
+	defer clearMedia()
+	clearUserAgent, err := overrideUserAgent(ctx, client, sessionID, options)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer clearUserAgent()
+	clearViewport, err := setViewport(ctx, client, sessionID, options)
+	if err != nil {
+		return nil, 0, err
 	}
+	defer clearViewport()
 
-	if err := client.Call(ctx, sessionID, "Page.setDocumentContent", map[string]any{
-		"frameId": frameTree.FrameTree.Frame.ID,
-		"html":    html,
-	}, nil); err != nil {
+	if options.TraceNetwork {
+		if err := enableNetworkTrace(ctx, client, sessionID); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	var assets *assetChecker
+	if options.StrictAssets {
+		if assets, err = watchAssets(ctx, client, sessionID); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	var console *consoleWatcher
+	if c.logConsole || options.FailOnJSError {
+		if console, err = watchConsole(ctx, client, sessionID); err != nil {
+			return nil, 0, err
+		}
+		defer console.done()
+	}
+
+	filter := c.requestFilter(options)
+	if filter != nil {
+		stopFiltering, err := filter.install(ctx, client, sessionID)
+		if err != nil {
+			return nil, 0, err
+		}
+		defer stopFiltering()
+	}
+
+	if err := loadDocument(ctx, client, sessionID, html, options); err != nil {
 		return nil, 0, err
 	}
 
 	if err := waitForBody(ctx, client, sessionID); err != nil {
 		return nil, 0, err
 	}
-	if err := sleepWithContext(ctx, wait); err != nil {
+	if filter != nil {
+		// Paused requests are only answered while the client reads.
+		err = pumpEvents(ctx, client, wait)
+	} else {
+		err = sleepWithContext(ctx, wait)
+	}
+	if err != nil {
 		return nil, 0, err
 	}
 
+	if options.LazyLoad {
+		if err := scrollThrough(ctx, client, sessionID); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	if options.CSS != "" {
+		if err := injectStyleSheet(ctx, client, sessionID, options.CSS); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	var running runningElements
+	if options.RunningElements {
+		if running, err = extractRunningElements(ctx, client, sessionID); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	if assets != nil || options.FailOnJSError {
+		// A round trip dispatches the events Chrome has sent so far.
+		if err := client.Call(ctx, "", "Browser.getVersion", nil, nil); err != nil {
+			return nil, 0, err
+		}
+	}
+	if options.FailOnJSError {
+		if err := console.check(); err != nil {
+			return nil, 0, err
+		}
+	}
+	if assets != nil {
+		if err := assets.check(); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	if options.Image != nil {
+		return captureImage(ctx, client, sessionID, options.Image, c.maxPDFBytes)
+	}
+
 	params := printToPDFParams{
 		PrintBackground: boolPtr(true),
 	}
@@ -106,20 +229,80 @@ func renderPDF(ctx context.Context, wsURL, html string, wait time.Duration, opti
 	if options.PageRanges != "" {
 		params.PageRanges = options.PageRanges
 	}
-
-	var result struct {
-		Data string `json:"data"`
+	if options.Branding.hasHeaderFooter() {
+		params.DisplayHeaderFooter = boolPtr(true)
+		params.HeaderTemplate = options.Branding.headerTemplate()
+		params.FooterTemplate = options.Branding.footerTemplate()
+		// Leave room for the header/footer unless margins were set explicitly.
+		if params.MarginTop == nil {
+			params.MarginTop = float64Ptr(brandingMarginInches)
+		}
+		if params.MarginBottom == nil {
+			params.MarginBottom = float64Ptr(brandingMarginInches)
+		}
 	}
-	startPDF := time.Now()
-	if err := client.Call(ctx, sessionID, "Page.printToPDF", params, &result); err != nil {
-		return nil, time.Since(startPDF), err
+	// Running elements replace the branding header or footer.
+	if running.Header != "" || running.Footer != "" {
+		if !options.Branding.hasHeaderFooter() {
+			params.DisplayHeaderFooter = boolPtr(true)
+			params.HeaderTemplate, params.FooterTemplate = runningTemplate(""), runningTemplate("")
+		}
+		if running.Header != "" {
+			params.HeaderTemplate = runningTemplate(running.Header)
+			if params.MarginTop == nil {
+				params.MarginTop = float64Ptr(brandingMarginInches)
+			}
+		}
+		if running.Footer != "" {
+			params.FooterTemplate = runningTemplate(running.Footer)
+			if params.MarginBottom == nil {
+				params.MarginBottom = float64Ptr(brandingMarginInches)
+			}
+		}
 	}
-	pdfTime := time.Since(startPDF)
-	if result.Data == "" {
-		return nil, pdfTime, errors.New("missing pdf data")
+
+	startPDF := time.Now()
+	var pdf []byte
+	var pdfTime time.Duration
+	if c.transferMode == transferModeStream {
+		params.TransferMode = "ReturnAsStream"
+		var result struct {
+			Stream string `json:"stream"`
+		}
+		if err := client.Call(ctx, sessionID, "Page.printToPDF", params, &result); err != nil {
+			return nil, time.Since(startPDF), printError(err)
+		}
+		if result.Stream == "" {
+			return nil, time.Since(startPDF), checkPDFOutput(nil, errMissingPDFData)
+		}
+		// Chrome generates the stream while it is read, so the transfer
+		// is part of the PDF time.
+		pdf, err = readPDFStream(ctx, client, sessionID, result.Stream, c.maxPDFBytes)
+		pdfTime = time.Since(startPDF)
+		if err := checkPDFOutput(pdf, err); err != nil {
+			return nil, pdfTime, err
+		}
+	} else {
+		var result struct {
+			Data json.RawMessage `json:"data"`
+		}
+		if err := client.Call(ctx, sessionID, "Page.printToPDF", params, &result); err != nil {
+			return nil, time.Since(startPDF), printError(err)
+		}
+		pdfTime = time.Since(startPDF)
+		if len(result.Data) == 0 {
+			return nil, pdfTime, checkPDFOutput(nil, errMissingPDFData)
+		}
+		// Reject oversized output from the base64 length, before decoding it.
+		if estimated := int64(len(result.Data)-2) / 4 * 3; c.maxPDFBytes > 0 && estimated-2 > c.maxPDFBytes {
+			return nil, pdfTime, pdfTooLargeError(c.maxPDFBytes, estimated)
+		}
+		pdf, err = decodePDFData(result.Data, c.decodeMode)
+		if err := checkPDFOutput(pdf, err); err != nil {
+			return nil, pdfTime, err
+		}
 	}
-	pdf, err := base64.StdEncoding.DecodeString(result.Data)
-	if err != nil {
+	if err := checkPDFLimits(pdf, c.maxPDFBytes, options.MaxPages); err != nil {
 		return nil, pdfTime, err
 	}
 
@@ -137,12 +320,63 @@ type printToPDFParams struct {
 	MarginRight     *float64 `json:"marginRight,omitempty"`
 	PrintBackground *bool    `json:"printBackground,omitempty"`
 	PageRanges      string   `json:"pageRanges,omitempty"`
+
+	DisplayHeaderFooter *bool  `json:"displayHeaderFooter,omitempty"`
+	HeaderTemplate      string `json:"headerTemplate,omitempty"`
+	FooterTemplate      string `json:"footerTemplate,omitempty"`
+
+	TransferMode string `json:"transferMode,omitempty"`
 }
 
 func boolPtr(value bool) *bool {
 	return &value
 }
 
+func float64Ptr(value float64) *float64 {
+	return &value
+}
+
+// Interval of the calls pumpEvents makes to read pending events.
+const eventPumpInterval = 50 * time.Millisecond
+
+// pumpEvents waits like sleepWithContext, but keeps the client reading so its
+// event handlers run during the wait: cdpClient only reads inside Call.
+func pumpEvents(ctx context.Context, client *cdpClient, wait time.Duration) error {
+	deadline := time.Now().Add(wait)
+	for {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil
+		}
+		if err := sleepWithContext(ctx, min(remaining, eventPumpInterval)); err != nil {
+			return err
+		}
+		if err := client.Call(ctx, "", "Browser.getVersion", nil, nil); err != nil {
+			return err
+		}
+	}
+}
+
+// cleanupClient returns the connection to close the target and browser
+// context of a render with: client, or a new one to wsURL when the render was
+// abandoned in the middle of a call (errCDPInterrupted), so Chrome stops
+// working on it at once. done closes the new connection.
+func cleanupClient(ctx context.Context, client *cdpClient, wsURL string) (*cdpClient, func()) {
+	if !client.isInterrupted() {
+		return client, func() {}
+	}
+	fresh, err := newCDPClient(ctx, wsURL)
+	if err != nil {
+		Warnf("chrome cleanup connection error: %v", err)
+		return client, func() {}
+	}
+	return fresh, func() {
+		if err := fresh.Close(); err != nil {
+			Warnf("chrome websocket close error: %v", err)
+		}
+	}
+}
+
 func sleepWithContext(ctx context.Context, wait time.Duration) error {
 	if wait <= 0 {
 		return nil
@@ -156,3 +390,90 @@ func sleepWithContext(ctx context.Context, wait time.Duration) error {
 		return nil
 	}
 }
+
+// enableNetworkTrace enables the Network domain on the session and records
+// its events into the diagnostics collector attached to ctx.
+func enableNetworkTrace(ctx context.Context, client *cdpClient, sessionID string) error {
+	diag := diagnosticsFromContext(ctx)
+	if diag == nil {
+		diag = &renderDiagnostics{}
+	}
+	tracer := newNetworkTracer(diag)
+	client.addEventHandler(func(event cdpEvent) {
+		if event.SessionID == sessionID {
+			tracer.handle(event)
+		}
+	})
+	return client.Call(ctx, sessionID, "Network.enable", nil, nil)
+}
+
+// loadDocument loads the page content: options.URL when set, otherwise html
+// via about:blank (see targetURL) + Page.setDocumentContent. The freeze_time
+// and random_seed overrides are in place before the document's scripts run.
+func loadDocument(ctx context.Context, client *cdpClient, sessionID, html string, options pdfOptions) error {
+	remove, err := installDeterministicScript(ctx, client, sessionID, options)
+	if err != nil {
+		return err
+	}
+	defer remove()
+
+	if options.URL != "" {
+		var nav struct {
+			ErrorText string `json:"errorText"`
+		}
+		if err := client.Call(ctx, sessionID, "Page.navigate", map[string]any{
+			"url": options.URL,
+		}, &nav); err != nil {
+			return err
+		}
+		if nav.ErrorText != "" {
+			return fmt.Errorf("navigate: %s", nav.ErrorText)
+		}
+		return nil
+	}
+
+	if err := client.Call(ctx, sessionID, "Page.navigate", map[string]any{
+		"url": targetURL(ctx),
+	}, nil); err != nil {
+		return err
+	}
+
+	var frameTree struct {
+		FrameTree struct {
+			Frame struct {
+				ID string `json:"id"`
+			} `json:"frame"`
+		} `json:"frameTree"`
+	}
+	if err := client.Call(ctx, sessionID, "Page.getFrameTree", nil, &frameTree); err != nil {
+		return err
+	}
+	if frameTree.FrameTree.Frame.ID == "" {
+		return errors.New("missing frame id")
+	}
+
+	return client.Call(ctx, sessionID, "Page.setDocumentContent", map[string]any{
+		"frameId": frameTree.FrameTree.Frame.ID,
+		"html":    html,
+	}, nil)
+}
+
+// printOptionsError is a print Chrome refused for its options, such as a
+// page_ranges past the end of the document. It is answered with 400.
+type printOptionsError struct {
+	msg string
+}
+
+func (e *printOptionsError) Error() string {
+	return "invalid print options: " + e.msg
+}
+
+// printError classifies a Page.printToPDF failure: rejected parameters and
+// page ranges become a *printOptionsError.
+func printError(err error) error {
+	var cdpErr *cdpError
+	if errors.As(err, &cdpErr) && (cdpErr.Code == cdpInvalidParams || strings.Contains(strings.ToLower(cdpErr.Message), "page range")) {
+		return &printOptionsError{msg: cdpErr.Message}
+	}
+	return err
+}
@@ -0,0 +1,346 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// PDF/A conformance levels accepted by the pdfa option. The built-in
+// converter handles the "b" levels of parts 2 and 3; the others need an
+// external converter (POST_PROCESS_PDFA_CMD).
+var (
+	pdfaLevels       = []string{"1b", "2b", "2u", "3b", "3u"}
+	pdfaNativeLevels = []string{"2b", "3b"}
+)
+
+const pdfaOutputCondition = "sRGB IEC61966-2.1"
+
+// pdfaConverter implements the built-in pdfa post-processing stage. Chrome
+// already embeds (subsets of) every font it uses; the converter adds what
+// PDF/A requires on top of that: an XMP metadata stream with the PDF/A
+// identification mirroring the document information dictionary, an sRGB
+// output intent and a document ID.
+type pdfaConverter struct {
+	icc []byte
+}
+
+// newPDFAConverter uses the ICC profile at PDFA_ICC_PROFILE, or a built-in
+// sRGB profile.
+func newPDFAConverter(cfg config) *pdfaConverter {
+	if cfg.PDFAICCProfile != "" {
+		icc, err := os.ReadFile(cfg.PDFAICCProfile)
+		if err == nil {
+			return &pdfaConverter{icc: icc}
+		}
+		Warnf("pdfa: read ICC profile: %v; using the built-in sRGB profile", err)
+	}
+	return &pdfaConverter{icc: srgbICCProfile()}
+}
+
+func (c *pdfaConverter) process(_ context.Context, pdf []byte, options pdfOptions) ([]byte, error) {
+	if options.PDFA == "" {
+		return pdf, nil
+	}
+	if !containsString(pdfaNativeLevels, options.PDFA) {
+		return nil, &postProcessError{
+			status: http.StatusBadRequest,
+			err:    fmt.Errorf("pdfa %s requires an external converter (POST_PROCESS_PDFA_CMD)", options.PDFA),
+		}
+	}
+
+	reader, err := newPDFReader(pdf)
+	if err != nil {
+		return nil, err
+	}
+	catalog, err := reader.resolve([]byte(reader.trailer.Root))
+	if err != nil {
+		return nil, err
+	}
+	root, err := pdfDictEntries(catalog)
+	if err != nil {
+		return nil, fmt.Errorf("pdf: catalog: %w", err)
+	}
+	var info []pdfDictEntry
+	if reader.trailer.Info != "" {
+		value, err := reader.resolve([]byte(reader.trailer.Info))
+		if err != nil {
+			return nil, err
+		}
+		if info, err = pdfDictEntries(value); err != nil {
+			return nil, fmt.Errorf("pdf: info: %w", err)
+		}
+	}
+
+	update, err := newPDFUpdate(pdf)
+	if err != nil {
+		return nil, err
+	}
+	if update.trailer.ID == "" {
+		sum := sha256.Sum256(pdf)
+		id := hex.EncodeToString(sum[:16])
+		update.trailer.ID = fmt.Sprintf("[<%s> <%s>]", id, id)
+	}
+
+	xmp := pdfaXMP(options.PDFA, info)
+	metadata := update.addObject(pdfStreamObjectDict("/Type /Metadata /Subtype /XML", xmp))
+	profile := update.addObject(pdfStreamObjectDict("/N 3", c.icc))
+	intent := fmt.Sprintf("[<< /Type /OutputIntent /S /GTS_PDFA1 /OutputConditionIdentifier %s /Info %s /DestOutputProfile %d 0 R >>]",
+		pdfTextString(pdfaOutputCondition), pdfTextString(pdfaOutputCondition), profile)
+
+	match := pdfRefRe.FindStringSubmatch(reader.trailer.Root)
+	if match == nil {
+		return nil, errors.New("pdf: invalid /Root")
+	}
+	num, _ := strconv.Atoi(match[1])
+	root = pdfDictSet(root, "Metadata", []byte(fmt.Sprintf("%d 0 R", metadata)))
+	root = pdfDictSet(root, "OutputIntents", []byte(intent))
+	update.setObject(num, formatPDFDict(root))
+	return update.bytes(), nil
+}
+
+// pdfaXMP returns the XMP packet identifying the document as PDF/A level,
+// with the information dictionary entries mirrored as PDF/A requires.
+func pdfaXMP(level string, info []pdfDictEntry) []byte {
+	text := func(key string) string {
+		value := pdfDictGet(info, key)
+		if value == nil {
+			return ""
+		}
+		return xmlEscape(pdfDecodeTextString(value))
+	}
+	date := func(key string) string {
+		return pdfDateToXMP(pdfDecodeTextString(pdfDictGet(info, key)))
+	}
+
+	var b bytes.Buffer
+	b.WriteString("<?xpacket begin=\"\ufeff\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	b.WriteString(`<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">` + "\n")
+	b.WriteString(`<rdf:Description rdf:about="" xmlns:pdfaid="http://www.aiim.org/pdfa/ns/id/">`)
+	fmt.Fprintf(&b, "<pdfaid:part>%s</pdfaid:part><pdfaid:conformance>%s</pdfaid:conformance>", level[:1], strings.ToUpper(level[1:]))
+	b.WriteString("</rdf:Description>\n")
+
+	b.WriteString(`<rdf:Description rdf:about="" xmlns:dc="http://purl.org/dc/elements/1.1/">`)
+	b.WriteString("<dc:format>application/pdf</dc:format>")
+	if v := text("Title"); v != "" {
+		fmt.Fprintf(&b, `<dc:title><rdf:Alt><rdf:li xml:lang="x-default">%s</rdf:li></rdf:Alt></dc:title>`, v)
+	}
+	if v := text("Author"); v != "" {
+		fmt.Fprintf(&b, "<dc:creator><rdf:Seq><rdf:li>%s</rdf:li></rdf:Seq></dc:creator>", v)
+	}
+	if v := text("Subject"); v != "" {
+		fmt.Fprintf(&b, `<dc:description><rdf:Alt><rdf:li xml:lang="x-default">%s</rdf:li></rdf:Alt></dc:description>`, v)
+	}
+	b.WriteString("</rdf:Description>\n")
+
+	b.WriteString(`<rdf:Description rdf:about="" xmlns:pdf="http://ns.adobe.com/pdf/1.3/" xmlns:xmp="http://ns.adobe.com/xap/1.0/">`)
+	if v := text("Keywords"); v != "" {
+		fmt.Fprintf(&b, "<pdf:Keywords>%s</pdf:Keywords>", v)
+	}
+	if v := text("Producer"); v != "" {
+		fmt.Fprintf(&b, "<pdf:Producer>%s</pdf:Producer>", v)
+	}
+	if v := text("Creator"); v != "" {
+		fmt.Fprintf(&b, "<xmp:CreatorTool>%s</xmp:CreatorTool>", v)
+	}
+	if v := date("CreationDate"); v != "" {
+		fmt.Fprintf(&b, "<xmp:CreateDate>%s</xmp:CreateDate>", v)
+	}
+	if v := date("ModDate"); v != "" {
+		fmt.Fprintf(&b, "<xmp:ModifyDate>%s</xmp:ModifyDate>", v)
+	}
+	b.WriteString("</rdf:Description>\n")
+
+	b.WriteString("</rdf:RDF></x:xmpmeta>\n<?xpacket end=\"w\"?>")
+	return b.Bytes()
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// pdfDecodeTextString decodes a literal or hex PDF string: UTF-16BE with a
+// byte order mark, PDFDocEncoding (treated as Latin-1) otherwise.
+func pdfDecodeTextString(value []byte) string {
+	value = bytes.TrimSpace(value)
+	var raw []byte
+	switch {
+	case len(value) >= 2 && value[0] == '(':
+		raw = pdfUnescapeLiteral(value[1 : len(value)-1])
+	case len(value) >= 2 && value[0] == '<':
+		digits := bytes.Map(func(r rune) rune {
+			if strings.ContainsRune("0123456789abcdefABCDEF", r) {
+				return r
+			}
+			return -1
+		}, value[1:len(value)-1])
+		if len(digits)%2 == 1 {
+			digits = append(digits, '0')
+		}
+		raw = make([]byte, len(digits)/2)
+		_, _ = hex.Decode(raw, digits)
+	default:
+		return ""
+	}
+
+	if len(raw) >= 2 && raw[0] == 0xfe && raw[1] == 0xff {
+		units := make([]uint16, 0, len(raw)/2)
+		for i := 2; i+1 < len(raw); i += 2 {
+			units = append(units, uint16(raw[i])<<8|uint16(raw[i+1]))
+		}
+		return string(utf16.Decode(units))
+	}
+	runes := make([]rune, len(raw))
+	for i, c := range raw {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+// pdfUnescapeLiteral resolves the escape sequences of a literal string body.
+func pdfUnescapeLiteral(s []byte) []byte {
+	out := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			out = append(out, s[i])
+			continue
+		}
+		i++
+		switch c := s[i]; c {
+		case 'n':
+			out = append(out, '\n')
+		case 'r':
+			out = append(out, '\r')
+		case 't':
+			out = append(out, '\t')
+		case 'b':
+			out = append(out, '\b')
+		case 'f':
+			out = append(out, '\f')
+		case '\r':
+			if i+1 < len(s) && s[i+1] == '\n' {
+				i++
+			}
+		case '\n':
+		default:
+			if c < '0' || c > '7' {
+				out = append(out, c)
+				continue
+			}
+			v := 0
+			for n := 0; n < 3 && i < len(s) && s[i] >= '0' && s[i] <= '7'; n++ {
+				v = v*8 + int(s[i]-'0')
+				i++
+			}
+			i--
+			out = append(out, byte(v))
+		}
+	}
+	return out
+}
+
+var pdfDateRe = regexp.MustCompile(`^D:(\d{4})(\d{2})?(\d{2})?(\d{2})?(\d{2})?(\d{2})?(Z|[+-]\d{2}'?\d{2}'?)?`)
+
+// pdfDateToXMP converts a PDF date (D:YYYYMMDDHHmmSSOHH'mm') to the XMP
+// (ISO 8601) form, or "" if it cannot be parsed.
+func pdfDateToXMP(date string) string {
+	m := pdfDateRe.FindStringSubmatch(date)
+	if m == nil {
+		return ""
+	}
+	def := func(v, fallback string) string {
+		if v == "" {
+			return fallback
+		}
+		return v
+	}
+	out := fmt.Sprintf("%s-%s-%sT%s:%s:%s", m[1], def(m[2], "01"), def(m[3], "01"), def(m[4], "00"), def(m[5], "00"), def(m[6], "00"))
+	switch tz := strings.ReplaceAll(m[7], "'", ""); {
+	case tz == "" || tz == "Z":
+		return out + "Z"
+	default:
+		return out + tz[:3] + ":" + tz[3:]
+	}
+}
+
+// srgbICCProfile builds a minimal ICC v2 display profile with the sRGB
+// primaries (D50-adapted) and a 2.2 gamma, used as the PDF/A output intent
+// when no PDFA_ICC_PROFILE is configured.
+func srgbICCProfile() []byte {
+	s15 := func(v float64) uint32 { return uint32(int32(math.Round(v * 65536))) }
+	xyz := func(x, y, z float64) []byte {
+		b := []byte("XYZ \x00\x00\x00\x00")
+		b = binary.BigEndian.AppendUint32(b, s15(x))
+		b = binary.BigEndian.AppendUint32(b, s15(y))
+		return binary.BigEndian.AppendUint32(b, s15(z))
+	}
+	desc := func(s string) []byte {
+		b := []byte("desc\x00\x00\x00\x00")
+		b = binary.BigEndian.AppendUint32(b, uint32(len(s)+1))
+		b = append(b, s...)
+		b = append(b, 0)
+		b = append(b, make([]byte, 4+4+2+1+67)...) // empty Unicode and ScriptCode descriptions
+		return b
+	}
+	curve := []byte("curv\x00\x00\x00\x00\x00\x00\x00\x01\x02\x33") // gamma 2.2 (u8Fixed8)
+
+	tags := []struct {
+		sig  string
+		data []byte
+	}{
+		{"desc", desc(pdfaOutputCondition)},
+		{"cprt", append([]byte("text\x00\x00\x00\x00No copyright, use freely"), 0)},
+		{"wtpt", xyz(0.9642, 1.0, 0.8249)},
+		{"rXYZ", xyz(0.4361, 0.2225, 0.0139)},
+		{"gXYZ", xyz(0.3851, 0.7169, 0.0971)},
+		{"bXYZ", xyz(0.1431, 0.0606, 0.7141)},
+		{"rTRC", curve},
+		{"gTRC", curve},
+		{"bTRC", curve},
+	}
+
+	table := make([]byte, 0, 4+12*len(tags))
+	table = binary.BigEndian.AppendUint32(table, uint32(len(tags)))
+	var data []byte
+	offset := 128 + 4 + 12*len(tags)
+	for _, tag := range tags {
+		table = append(table, tag.sig...)
+		table = binary.BigEndian.AppendUint32(table, uint32(offset+len(data)))
+		table = binary.BigEndian.AppendUint32(table, uint32(len(tag.data)))
+		data = append(data, tag.data...)
+		for len(data)%4 != 0 {
+			data = append(data, 0)
+		}
+	}
+
+	header := make([]byte, 128)
+	binary.BigEndian.PutUint32(header[0:], uint32(128+len(table)+len(data)))
+	binary.BigEndian.PutUint32(header[8:], 0x02100000) // version 2.1
+	copy(header[12:], "mntrRGB XYZ ")
+	for i, v := range []uint16{2026, 1, 1, 0, 0, 0} {
+		binary.BigEndian.PutUint16(header[24+2*i:], v)
+	}
+	copy(header[36:], "acsp")
+	binary.BigEndian.PutUint32(header[68:], s15(0.9642))
+	binary.BigEndian.PutUint32(header[72:], s15(1.0))
+	binary.BigEndian.PutUint32(header[76:], s15(0.8249))
+
+	return append(append(header, table...), data...)
+}
@@ -0,0 +1,138 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Decode modes for the base64 PDF payload returned by Page.printToPDF.
+const (
+	// decodeModeStream decodes the raw JSON string as received, without
+	// materializing the payload as a Go string first. The name is kept for
+	// PDF_DECODE_MODE; the payload is decoded in one call.
+	decodeModeStream = "stream"
+	// decodeModeString unmarshals the payload into a string and decodes it in
+	// one go (the original behavior).
+	decodeModeString = "string"
+)
+
+// decodePDFData decodes the "data" field of a Page.printToPDF result. raw
+// is the whole base64 payload as received; only PDF_TRANSFER_MODE=stream
+// avoids holding it.
+func decodePDFData(raw json.RawMessage, mode string) ([]byte, error) {
+	if mode == decodeModeString {
+		return decodePDFString(raw)
+	}
+
+	if len(raw) < 2 || raw[0] != '"' || raw[len(raw)-1] != '"' {
+		return nil, errors.New("pdf data is not a json string")
+	}
+	encoded := raw[1 : len(raw)-1]
+	if bytes.IndexByte(encoded, '\\') >= 0 {
+		// Escaped characters never appear in base64, but fall back rather
+		// than decode garbage if Chrome ever escapes "/".
+		return decodePDFString(raw)
+	}
+	if len(encoded) == 0 {
+		return nil, errMissingPDFData
+	}
+
+	// The document is returned to the renderers wrapping this one, so it
+	// cannot come from a pool.
+	out := make([]byte, base64.StdEncoding.DecodedLen(len(encoded)))
+	n, err := base64.StdEncoding.Decode(out, encoded)
+	if err != nil {
+		return nil, fmt.Errorf("decode pdf data: %w", err)
+	}
+	return out[:n], nil
+}
+
+// decodePDFString is the non-streaming decode path.
+func decodePDFString(raw json.RawMessage) ([]byte, error) {
+	var data string
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, err
+	}
+	if data == "" {
+		return nil, errMissingPDFData
+	}
+	return base64.StdEncoding.DecodeString(data)
+}
+
+// Transfer modes of Page.printToPDF.
+const (
+	// transferModeStream asks Chrome for a stream handle and reads the PDF
+	// in chunks with IO.read, so the whole base64 payload never sits in one
+	// websocket message.
+	transferModeStream = "stream"
+	// transferModeBase64 returns the whole PDF base64-encoded in the
+	// printToPDF result, decoded according to PDF_DECODE_MODE.
+	transferModeBase64 = "base64"
+)
+
+// cdpCaller is the part of cdpClient used to read streams.
+type cdpCaller interface {
+	Call(ctx context.Context, sessionID, method string, params any, result any) error
+}
+
+// readPDFStream reads a Page.printToPDF stream handle chunk by chunk and
+// closes it. The chunks are collected into the whole document: the
+// renderers wrapping this one need it, so it is not streamed to the
+// response. Reading stops as soon as the PDF grows past maxBytes (0 = no
+// limit), without transferring the rest.
+func readPDFStream(ctx context.Context, client cdpCaller, sessionID, handle string, maxBytes int64) ([]byte, error) {
+	defer func() {
+		closeCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		if err := client.Call(closeCtx, sessionID, "IO.close", map[string]string{"handle": handle}, nil); err != nil {
+			Warnf("chrome stream close error: %v", err)
+		}
+	}()
+
+	params := map[string]any{"handle": handle, "size": defaultPDFStreamChunkBytes}
+	encoded := getBuffer()
+	defer putBuffer(encoded)
+	var out []byte
+	for {
+		var chunk struct {
+			Base64Encoded bool   `json:"base64Encoded"`
+			Data          string `json:"data"`
+			EOF           bool   `json:"eof"`
+		}
+		if err := client.Call(ctx, sessionID, "IO.read", params, &chunk); err != nil {
+			return nil, err
+		}
+		if chunk.Base64Encoded {
+			encoded.Reset()
+			encoded.WriteString(chunk.Data)
+			start, size := len(out), base64.StdEncoding.DecodedLen(encoded.Len())
+			out = slices.Grow(out, size)[:start+size]
+			n, err := base64.StdEncoding.Decode(out[start:], encoded.Bytes())
+			if err != nil {
+				return nil, fmt.Errorf("decode pdf stream: %w", err)
+			}
+			out = out[:start+n]
+		} else {
+			out = append(out, chunk.Data...)
+		}
+		if maxBytes > 0 && int64(len(out)) > maxBytes {
+			return nil, pdfTooLargeError(maxBytes, int64(len(out)))
+		}
+		if chunk.EOF {
+			break
+		}
+	}
+	if len(out) == 0 {
+		return nil, errMissingPDFData
+	}
+	return out, nil
+}
@@ -0,0 +1,631 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"unicode/utf16"
+)
+
+// This file contains the tiny subset of PDF handling the post-processors need:
+// reading the trailer of a finished document and appending an incremental
+// update (new or replaced objects plus a new xref section). It deliberately
+// does not try to be a general PDF parser.
+
+var (
+	pdfTrailerSizeRe = regexp.MustCompile(`/Size\s+(\d+)`)
+	pdfTrailerRootRe = regexp.MustCompile(`/Root\s+(\d+\s+\d+\s+R)`)
+	pdfTrailerInfoRe = regexp.MustCompile(`/Info\s+(\d+\s+\d+\s+R)`)
+	pdfTrailerIDRe   = regexp.MustCompile(`/ID\s*(\[[^\]]*\])`)
+)
+
+// pdfTrailer holds the trailer entries needed to append an incremental update.
+type pdfTrailer struct {
+	Size      int
+	Root      string
+	Info      string
+	ID        string
+	StartXref int64
+}
+
+// parsePDFTrailer locates the last cross-reference section of pdf and extracts
+// its trailer dictionary. Both classic xref tables and xref streams are
+// accepted; only the dictionary entries are read.
+func parsePDFTrailer(pdf []byte) (pdfTrailer, error) {
+	trailer := pdfTrailer{}
+
+	idx := bytes.LastIndex(pdf, []byte("startxref"))
+	if idx == -1 {
+		return trailer, errors.New("pdf: startxref not found")
+	}
+	fields := bytes.Fields(pdf[idx+len("startxref"):])
+	if len(fields) == 0 {
+		return trailer, errors.New("pdf: missing startxref offset")
+	}
+	offset, err := strconv.ParseInt(string(fields[0]), 10, 64)
+	if err != nil || offset < 0 || offset >= int64(len(pdf)) {
+		return trailer, errors.New("pdf: invalid startxref offset")
+	}
+	trailer.StartXref = offset
+
+	section := pdf[offset:idx]
+	if bytes.HasPrefix(section, []byte("xref")) {
+		at := bytes.Index(section, []byte("trailer"))
+		if at == -1 {
+			return trailer, errors.New("pdf: trailer not found")
+		}
+		section = section[at:]
+	}
+	dict := pdfFirstDict(section)
+	if dict == nil {
+		return trailer, errors.New("pdf: trailer dictionary not found")
+	}
+
+	match := pdfTrailerSizeRe.FindSubmatch(dict)
+	if match == nil {
+		return trailer, errors.New("pdf: trailer /Size missing")
+	}
+	trailer.Size, _ = strconv.Atoi(string(match[1]))
+	match = pdfTrailerRootRe.FindSubmatch(dict)
+	if match == nil {
+		return trailer, errors.New("pdf: trailer /Root missing")
+	}
+	trailer.Root = string(match[1])
+	if match = pdfTrailerInfoRe.FindSubmatch(dict); match != nil {
+		trailer.Info = string(match[1])
+	}
+	if match = pdfTrailerIDRe.FindSubmatch(dict); match != nil {
+		trailer.ID = string(match[1])
+	}
+
+	return trailer, nil
+}
+
+// pdfFirstDict returns the first balanced "<< ... >>" dictionary in data.
+func pdfFirstDict(data []byte) []byte {
+	start := bytes.Index(data, []byte("<<"))
+	if start == -1 {
+		return nil
+	}
+	depth := 0
+	for i := start; i < len(data)-1; i++ {
+		switch {
+		case data[i] == '<' && data[i+1] == '<':
+			depth++
+			i++
+		case data[i] == '>' && data[i+1] == '>':
+			depth--
+			i++
+			if depth == 0 {
+				return data[start : i+1]
+			}
+		}
+	}
+	return nil
+}
+
+// pdfUpdate accumulates objects for an incremental update of a document.
+type pdfUpdate struct {
+	base    []byte
+	trailer pdfTrailer
+	objects map[int][]byte
+	next    int
+}
+
+func newPDFUpdate(pdf []byte) (*pdfUpdate, error) {
+	trailer, err := parsePDFTrailer(pdf)
+	if err != nil {
+		return nil, err
+	}
+	return &pdfUpdate{
+		base:    pdf,
+		trailer: trailer,
+		objects: map[int][]byte{},
+		next:    trailer.Size,
+	}, nil
+}
+
+// addObject appends a new object and returns its object number.
+func (u *pdfUpdate) addObject(body []byte) int {
+	num := u.next
+	u.next++
+	u.objects[num] = body
+	return num
+}
+
+// setObject replaces the body of an existing object (generation 0).
+func (u *pdfUpdate) setObject(num int, body []byte) {
+	u.objects[num] = body
+}
+
+// setInfo points the trailer /Info entry to the given object number.
+func (u *pdfUpdate) setInfo(num int) {
+	u.trailer.Info = fmt.Sprintf("%d 0 R", num)
+}
+
+// bytes serializes the original document followed by the update.
+func (u *pdfUpdate) bytes() []byte {
+	var out bytes.Buffer
+	out.Grow(len(u.base) + 1024)
+	out.Write(u.base)
+	if len(u.base) > 0 && u.base[len(u.base)-1] != '\n' {
+		out.WriteByte('\n')
+	}
+
+	nums := make([]int, 0, len(u.objects))
+	for num := range u.objects {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+
+	offsets := make(map[int]int, len(nums))
+	for _, num := range nums {
+		offsets[num] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n", num)
+		out.Write(u.objects[num])
+		out.WriteString("\nendobj\n")
+	}
+
+	xrefOffset := out.Len()
+	out.WriteString("xref\n")
+	for _, num := range nums {
+		fmt.Fprintf(&out, "%d 1\n%010d 00000 n \n", num, offsets[num])
+	}
+
+	size := u.trailer.Size
+	if u.next > size {
+		size = u.next
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root %s", size, u.trailer.Root)
+	if u.trailer.Info != "" {
+		fmt.Fprintf(&out, " /Info %s", u.trailer.Info)
+	}
+	if u.trailer.ID != "" {
+		fmt.Fprintf(&out, " /ID %s", u.trailer.ID)
+	}
+	fmt.Fprintf(&out, " /Prev %d >>\nstartxref\n%d\n%%%%EOF\n", u.trailer.StartXref, xrefOffset)
+
+	return out.Bytes()
+}
+
+// pdfTextString encodes s as a PDF text string: a literal string for ASCII,
+// UTF-16BE with BOM (hex) otherwise.
+func pdfTextString(s string) string {
+	ascii := true
+	for _, r := range s {
+		if r > 0x7e || (r < 0x20 && r != '\t' && r != '\n' && r != '\r') {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		var b bytes.Buffer
+		b.WriteByte('(')
+		for i := 0; i < len(s); i++ {
+			switch s[i] {
+			case '\\', '(', ')':
+				b.WriteByte('\\')
+			}
+			b.WriteByte(s[i])
+		}
+		b.WriteByte(')')
+		return b.String()
+	}
+
+	var b bytes.Buffer
+	b.WriteString("<FEFF")
+	for _, unit := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", unit)
+	}
+	b.WriteByte('>')
+	return b.String()
+}
+
+var (
+	pdfTrailerPrevRe = regexp.MustCompile(`/Prev\s+(\d+)`)
+	pdfRefRe         = regexp.MustCompile(`^(\d+)\s+(\d+)\s+R$`)
+	pdfRefsRe        = regexp.MustCompile(`(\d+)\s+\d+\s+R`)
+)
+
+// pdfReader looks up objects of a document through its classic xref tables.
+// Documents using xref streams (PDF 1.5 object streams) are not supported;
+// Chrome does not produce them.
+type pdfReader struct {
+	data    []byte
+	trailer pdfTrailer
+	offsets map[int]int64
+}
+
+func newPDFReader(pdf []byte) (*pdfReader, error) {
+	trailer, err := parsePDFTrailer(pdf)
+	if err != nil {
+		return nil, err
+	}
+	r := &pdfReader{data: pdf, trailer: trailer, offsets: map[int]int64{}}
+
+	// Walk the sections from the newest one; the first entry seen for an
+	// object wins.
+	seen := map[int64]bool{}
+	for offset := trailer.StartXref; offset >= 0 && !seen[offset]; {
+		seen[offset] = true
+		if offset >= int64(len(pdf)) || !bytes.HasPrefix(pdf[offset:], []byte("xref")) {
+			return nil, errors.New("pdf: xref streams are not supported")
+		}
+		offset, err = r.readXrefTable(pdf[offset:])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// readXrefTable records the entries of one xref table and returns the offset
+// of the previous section, or -1.
+func (r *pdfReader) readXrefTable(section []byte) (int64, error) {
+	at := bytes.Index(section, []byte("trailer"))
+	if at == -1 {
+		return -1, errors.New("pdf: trailer not found")
+	}
+	fields := bytes.Fields(section[len("xref"):at])
+	for len(fields) >= 2 {
+		start, err1 := strconv.Atoi(string(fields[0]))
+		count, err2 := strconv.Atoi(string(fields[1]))
+		if err1 != nil || err2 != nil || count < 0 || len(fields) < 2+3*count {
+			return -1, errors.New("pdf: malformed xref table")
+		}
+		for i := 0; i < count; i++ {
+			entry := fields[2+3*i:]
+			if _, ok := r.offsets[start+i]; ok {
+				continue
+			}
+			offset, err := strconv.ParseInt(string(entry[0]), 10, 64)
+			if err != nil || string(entry[2]) != "n" {
+				offset = -1
+			}
+			r.offsets[start+i] = offset
+		}
+		fields = fields[2+3*count:]
+	}
+
+	dict := pdfFirstDict(section[at:])
+	if match := pdfTrailerPrevRe.FindSubmatch(dict); match != nil {
+		prev, err := strconv.ParseInt(string(match[1]), 10, 64)
+		if err == nil {
+			return prev, nil
+		}
+	}
+	return -1, nil
+}
+
+// object returns the value of object num (a dictionary, array, ...), without
+// any stream data.
+func (r *pdfReader) object(num int) ([]byte, error) {
+	offset, ok := r.offsets[num]
+	if !ok || offset < 0 || offset >= int64(len(r.data)) {
+		return nil, fmt.Errorf("pdf: object %d not found", num)
+	}
+	data := r.data[offset:]
+	at := bytes.Index(data, []byte("obj"))
+	if at == -1 {
+		return nil, fmt.Errorf("pdf: object %d: bad offset", num)
+	}
+	if header := bytes.Fields(data[:at]); len(header) != 2 || string(header[0]) != strconv.Itoa(num) {
+		return nil, fmt.Errorf("pdf: object %d: bad offset", num)
+	}
+	start, end, err := pdfReadValue(data, at+len("obj"))
+	if err != nil {
+		return nil, fmt.Errorf("pdf: object %d: %w", num, err)
+	}
+	return data[start:end], nil
+}
+
+// resolve returns the object value is a reference to, or value itself.
+func (r *pdfReader) resolve(value []byte) ([]byte, error) {
+	match := pdfRefRe.FindSubmatch(bytes.TrimSpace(value))
+	if match == nil {
+		return value, nil
+	}
+	num, _ := strconv.Atoi(string(match[1]))
+	return r.object(num)
+}
+
+// pdfPage is a leaf of the page tree, with its inheritable attributes
+// resolved.
+type pdfPage struct {
+	Num       int
+	Dict      []pdfDictEntry
+	MediaBox  [4]float64
+	Resources []byte
+}
+
+// pages returns the pages of the document in order.
+func (r *pdfReader) pages() ([]pdfPage, error) {
+	catalog, err := r.resolve([]byte(r.trailer.Root))
+	if err != nil {
+		return nil, err
+	}
+	entries, err := pdfDictEntries(catalog)
+	if err != nil {
+		return nil, fmt.Errorf("pdf: catalog: %w", err)
+	}
+	match := pdfRefRe.FindSubmatch(pdfDictGet(entries, "Pages"))
+	if match == nil {
+		return nil, errors.New("pdf: catalog /Pages missing")
+	}
+	root, _ := strconv.Atoi(string(match[1]))
+
+	var pages []pdfPage
+	visited := map[int]bool{}
+	var walk func(num int, resources, mediaBox []byte) error
+	walk = func(num int, resources, mediaBox []byte) error {
+		if visited[num] {
+			return fmt.Errorf("pdf: page tree loop at object %d", num)
+		}
+		visited[num] = true
+		value, err := r.object(num)
+		if err != nil {
+			return err
+		}
+		node, err := pdfDictEntries(value)
+		if err != nil {
+			return fmt.Errorf("pdf: object %d: %w", num, err)
+		}
+		if v := pdfDictGet(node, "Resources"); v != nil {
+			resources = v
+		}
+		if v := pdfDictGet(node, "MediaBox"); v != nil {
+			mediaBox = v
+		}
+
+		if string(pdfDictGet(node, "Type")) != "/Pages" {
+			page := pdfPage{Num: num, Dict: node}
+			if page.Resources, err = r.resolve(resources); err != nil {
+				return err
+			}
+			if page.MediaBox, err = r.parseRect(mediaBox); err != nil {
+				return fmt.Errorf("pdf: page %d: %w", num, err)
+			}
+			pages = append(pages, page)
+			return nil
+		}
+
+		kids, err := r.resolve(pdfDictGet(node, "Kids"))
+		if err != nil {
+			return err
+		}
+		for _, kid := range pdfRefsRe.FindAllSubmatch(kids, -1) {
+			child, _ := strconv.Atoi(string(kid[1]))
+			if err := walk(child, resources, mediaBox); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(root, nil, nil); err != nil {
+		return nil, err
+	}
+	return pages, nil
+}
+
+// parseRect parses a rectangle such as a /MediaBox.
+func (r *pdfReader) parseRect(value []byte) ([4]float64, error) {
+	var rect [4]float64
+	value, err := r.resolve(value)
+	if err != nil {
+		return rect, err
+	}
+	fields := bytes.Fields(bytes.Trim(bytes.TrimSpace(value), "[]"))
+	if len(fields) != 4 {
+		return rect, errors.New("invalid rectangle")
+	}
+	for i, field := range fields {
+		if rect[i], err = strconv.ParseFloat(string(field), 64); err != nil {
+			return rect, errors.New("invalid rectangle")
+		}
+	}
+	return rect, nil
+}
+
+// pdfDictEntry is a key/value pair of a dictionary; Value is the raw value.
+type pdfDictEntry struct {
+	Key   string
+	Value []byte
+}
+
+// pdfDictEntries splits a "<< ... >>" dictionary into its entries.
+func pdfDictEntries(dict []byte) ([]pdfDictEntry, error) {
+	i := pdfSkipSpace(dict, 0)
+	if !bytes.HasPrefix(dict[i:], []byte("<<")) {
+		return nil, errors.New("not a dictionary")
+	}
+	var entries []pdfDictEntry
+	for i += 2; ; {
+		i = pdfSkipSpace(dict, i)
+		if i >= len(dict) {
+			return nil, errors.New("unterminated dictionary")
+		}
+		if bytes.HasPrefix(dict[i:], []byte(">>")) {
+			return entries, nil
+		}
+		keyStart, keyEnd, err := pdfReadValue(dict, i)
+		if err != nil {
+			return nil, err
+		}
+		if dict[keyStart] != '/' {
+			return nil, errors.New("dictionary key is not a name")
+		}
+		start, end, err := pdfReadValue(dict, keyEnd)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, pdfDictEntry{Key: string(dict[keyStart+1 : keyEnd]), Value: dict[start:end]})
+		i = end
+	}
+}
+
+// pdfDictGet returns the raw value of key, or nil.
+func pdfDictGet(entries []pdfDictEntry, key string) []byte {
+	for _, entry := range entries {
+		if entry.Key == key {
+			return entry.Value
+		}
+	}
+	return nil
+}
+
+// pdfDictSet replaces or appends key.
+func pdfDictSet(entries []pdfDictEntry, key string, value []byte) []pdfDictEntry {
+	for i, entry := range entries {
+		if entry.Key == key {
+			entries[i].Value = value
+			return entries
+		}
+	}
+	return append(entries, pdfDictEntry{Key: key, Value: value})
+}
+
+// formatPDFDict serializes dictionary entries.
+func formatPDFDict(entries []pdfDictEntry) []byte {
+	var b bytes.Buffer
+	b.WriteString("<<")
+	for _, entry := range entries {
+		b.WriteString(" /")
+		b.WriteString(entry.Key)
+		b.WriteByte(' ')
+		b.Write(entry.Value)
+	}
+	b.WriteString(" >>")
+	return b.Bytes()
+}
+
+func pdfIsSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func pdfIsDelimiter(c byte) bool {
+	return pdfIsSpace(c) || bytes.IndexByte([]byte("()<>[]{}/%"), c) != -1
+}
+
+// pdfSkipSpace skips whitespace and comments starting at i.
+func pdfSkipSpace(data []byte, i int) int {
+	for i < len(data) {
+		switch {
+		case pdfIsSpace(data[i]):
+			i++
+		case data[i] == '%':
+			for i < len(data) && data[i] != '\n' && data[i] != '\r' {
+				i++
+			}
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// pdfReadValue reads one value (dictionary, array, string, name, number,
+// reference or keyword) starting at i and returns its bounds.
+func pdfReadValue(data []byte, i int) (int, int, error) {
+	i = pdfSkipSpace(data, i)
+	if i >= len(data) {
+		return 0, 0, errors.New("unexpected end of data")
+	}
+	start := i
+	switch {
+	case bytes.HasPrefix(data[i:], []byte("<<")):
+		for i += 2; ; {
+			i = pdfSkipSpace(data, i)
+			if i >= len(data) {
+				return 0, 0, errors.New("unterminated dictionary")
+			}
+			if bytes.HasPrefix(data[i:], []byte(">>")) {
+				return start, i + 2, nil
+			}
+			_, end, err := pdfReadValue(data, i)
+			if err != nil {
+				return 0, 0, err
+			}
+			i = end
+		}
+	case data[i] == '[':
+		for i++; ; {
+			i = pdfSkipSpace(data, i)
+			if i >= len(data) {
+				return 0, 0, errors.New("unterminated array")
+			}
+			if data[i] == ']' {
+				return start, i + 1, nil
+			}
+			_, end, err := pdfReadValue(data, i)
+			if err != nil {
+				return 0, 0, err
+			}
+			i = end
+		}
+	case data[i] == '(':
+		depth := 0
+		for ; i < len(data); i++ {
+			switch data[i] {
+			case '\\':
+				i++
+			case '(':
+				depth++
+			case ')':
+				depth--
+				if depth == 0 {
+					return start, i + 1, nil
+				}
+			}
+		}
+		return 0, 0, errors.New("unterminated string")
+	case data[i] == '<':
+		end := bytes.IndexByte(data[i:], '>')
+		if end == -1 {
+			return 0, 0, errors.New("unterminated hex string")
+		}
+		return start, i + end + 1, nil
+	case data[i] == '/':
+		for i++; i < len(data) && !pdfIsDelimiter(data[i]); i++ {
+		}
+		return start, i, nil
+	case bytes.IndexByte([]byte(")>]}"), data[i]) != -1:
+		return 0, 0, fmt.Errorf("unexpected %q", data[i])
+	}
+
+	for ; i < len(data) && !pdfIsDelimiter(data[i]); i++ {
+	}
+	end := i
+	// "<num> <gen> R" is a single (reference) value.
+	if isPDFInteger(data[start:end]) {
+		genStart := pdfSkipSpace(data, end)
+		genEnd := genStart
+		for genEnd < len(data) && !pdfIsDelimiter(data[genEnd]) {
+			genEnd++
+		}
+		if genEnd > genStart && isPDFInteger(data[genStart:genEnd]) {
+			r := pdfSkipSpace(data, genEnd)
+			if r < len(data) && data[r] == 'R' && (r+1 == len(data) || pdfIsDelimiter(data[r+1])) {
+				return start, r + 1, nil
+			}
+		}
+	}
+	return start, end, nil
+}
+
+func isPDFInteger(token []byte) bool {
+	if len(token) == 0 {
+		return false
+	}
+	for _, c := range token {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
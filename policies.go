@@ -0,0 +1,274 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// headerAPIKey carries the caller key used to select a key policy.
+const headerAPIKey = "X-API-Key"
+
+// keyPolicy holds per-caller defaults, selected by the X-API-Key header.
+// Request options always take precedence over the policy.
+type keyPolicy struct {
+	ID          string   `json:"id"`
+	Tenant      string   `json:"tenant,omitempty"`
+	PostProcess []string `json:"post_process,omitempty"`
+
+	// Quota limits the pages rendered per hour/day.
+	Quota *pageQuota `json:"quota,omitempty"`
+
+	// TenantPolicy is the resolved policy of Tenant, filled in at load time.
+	TenantPolicy tenantPolicy `json:"-"`
+}
+
+// tenantPolicy holds defaults shared by every key of a tenant.
+type tenantPolicy struct {
+	PreProcess []string `json:"pre_process,omitempty"`
+	InjectCSS  string   `json:"inject_css,omitempty"`
+	InjectJS   string   `json:"inject_js,omitempty"`
+	BaseURL    string   `json:"base_url,omitempty"`
+
+	Branding *tenantBranding `json:"branding,omitempty"`
+}
+
+// policyFile is the on-disk format of POLICIES_FILE.
+//
+//	{
+//	  "keys": {"<api key>": {"id": "billing", "tenant": "acme", "post_process": ["metadata"]}},
+//	  "tenants": {"acme": {"pre_process": ["sanitize", "inject"], "inject_css": "body{font-size:11pt}"}},
+//	  "presets": {"label-4x6": {"paper_width": "4in", "paper_height": "6in", "margin_top": "0"}}
+//	}
+type policyFile struct {
+	Keys    map[string]keyPolicy    `json:"keys"`
+	Tenants map[string]tenantPolicy `json:"tenants"`
+	Presets map[string]printPreset  `json:"presets,omitempty"`
+}
+
+// policyStore resolves key policies. A nil store has no policies. The
+// policies can be replaced at runtime (see replace).
+type policyStore struct {
+	path string
+
+	mu   sync.RWMutex
+	keys map[string]keyPolicy
+	file policyFile
+}
+
+type policyContextKey struct{}
+
+// loadPolicies reads POLICIES_FILE. An empty path yields an empty store.
+func loadPolicies(path string) (*policyStore, error) {
+	store := &policyStore{path: path, keys: map[string]keyPolicy{}}
+	if path == "" {
+		return store, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read policies: %w", err)
+	}
+	var file policyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse policies: %w", err)
+	}
+	keys, err := resolvePolicies(file)
+	if err != nil {
+		return nil, err
+	}
+	store.keys, store.file = keys, file
+	return store, nil
+}
+
+// resolvePolicies validates a policy file and returns the key policies with
+// their tenant policies resolved.
+func resolvePolicies(file policyFile) (map[string]keyPolicy, error) {
+	keys := map[string]keyPolicy{}
+	if err := validatePresets(file.Presets); err != nil {
+		return nil, err
+	}
+	for name, tenant := range file.Tenants {
+		for _, stage := range tenant.PreProcess {
+			if !containsString(preProcessStages, stage) {
+				return nil, fmt.Errorf("tenant %s: unknown pre-process stage %q", name, stage)
+			}
+		}
+		if tenant.Branding != nil {
+			if err := tenant.Branding.resolveLogo(); err != nil {
+				return nil, fmt.Errorf("tenant %s: %w", name, err)
+			}
+		}
+	}
+	for key, policy := range file.Keys {
+		if policy.ID == "" {
+			return nil, fmt.Errorf("policy for key %q has no id", maskKey(key))
+		}
+		for _, stage := range policy.PostProcess {
+			if !containsString(postProcessStages, stage) {
+				return nil, fmt.Errorf("policy %s: unknown post-process stage %q", policy.ID, stage)
+			}
+		}
+		if policy.Quota != nil && (policy.Quota.PagesPerHour < 0 || policy.Quota.PagesPerDay < 0) {
+			return nil, fmt.Errorf("policy %s: negative page quota", policy.ID)
+		}
+		if policy.Tenant != "" {
+			tenant, ok := file.Tenants[policy.Tenant]
+			if !ok {
+				return nil, fmt.Errorf("policy %s: unknown tenant %q", policy.ID, policy.Tenant)
+			}
+			policy.TenantPolicy = tenant
+		}
+		keys[key] = policy
+	}
+	return keys, nil
+}
+
+// snapshot returns the current policy file.
+func (s *policyStore) snapshot() policyFile {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.file
+}
+
+// replace validates file and swaps it in, writing it to POLICIES_FILE when
+// one is configured so the change survives a restart.
+func (s *policyStore) replace(file policyFile) error {
+	keys, err := resolvePolicies(file)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.path != "" {
+		data, err := json.MarshalIndent(file, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(s.path, data, 0o600); err != nil {
+			return fmt.Errorf("write policies: %w", err)
+		}
+	}
+	s.keys, s.file = keys, file
+	return nil
+}
+
+// lookup returns the policy for the key presented by r, if any.
+func (s *policyStore) lookup(r *http.Request) (keyPolicy, bool) {
+	if s == nil {
+		return keyPolicy{}, false
+	}
+	key := r.Header.Get(headerAPIKey)
+	if key == "" {
+		return keyPolicy{}, false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	policy, ok := s.keys[key]
+	return policy, ok
+}
+
+// byID returns the policy with the given ID, if any key still has it.
+func (s *policyStore) byID(id string) (keyPolicy, bool) {
+	if s == nil {
+		return keyPolicy{}, false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, policy := range s.keys {
+		if policy.ID == id {
+			return policy, true
+		}
+	}
+	return keyPolicy{}, false
+}
+
+// policyMiddleware attaches the caller's key policy (if any) to the request context.
+func policyMiddleware(store *policyStore, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if policy, ok := store.lookup(r); ok {
+			r = r.WithContext(context.WithValue(r.Context(), policyContextKey{}, policy))
+			if rw, ok := w.(*responseWriter); ok {
+				rw.keyID = policy.ID
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// policyFromContext returns the key policy attached by policyMiddleware.
+func policyFromContext(ctx context.Context) (keyPolicy, bool) {
+	policy, ok := ctx.Value(policyContextKey{}).(keyPolicy)
+	return policy, ok
+}
+
+// maskKey hides all but the last four characters of a key for logging.
+func maskKey(key string) string {
+	if len(key) <= 4 {
+		return "****"
+	}
+	return "****" + key[len(key)-4:]
+}
+
+// applyPolicyDefaults fills the options the request did not set from the
+// caller's key/tenant policy and then from the server configuration.
+func applyPolicyDefaults(ctx context.Context, cfg config, options *pdfOptions) {
+	policy, _ := policyFromContext(ctx)
+	tenant := policy.TenantPolicy
+
+	if options.PostProcess == nil {
+		options.PostProcess = cfg.PostProcess
+		if policy.PostProcess != nil {
+			options.PostProcess = policy.PostProcess
+		}
+	}
+	if options.PreProcess == nil {
+		options.PreProcess = cfg.PreProcess
+		if tenant.PreProcess != nil {
+			options.PreProcess = tenant.PreProcess
+		}
+	}
+	if options.EmailMode {
+		// Mail clients run no scripts and lay messages out with screen styles.
+		for _, stage := range []string{stageEmail, stageSanitize} {
+			if !containsString(options.PreProcess, stage) {
+				options.PreProcess = append(append([]string{}, options.PreProcess...), stage)
+			}
+		}
+		if options.EmulateMedia == "" {
+			options.EmulateMedia = "screen"
+		}
+	}
+	if options.InlineAssets && !containsString(options.PreProcess, stageInlineAssets) {
+		options.PreProcess = append(append([]string{}, options.PreProcess...), stageInlineAssets)
+	}
+	if options.Watermark != nil && !containsString(options.PostProcess, stageWatermark) {
+		options.PostProcess = append(append([]string{}, options.PostProcess...), stageWatermark)
+	}
+	if options.PDFA != "" && !containsString(options.PostProcess, stagePDFA) {
+		options.PostProcess = append(append([]string{}, options.PostProcess...), stagePDFA)
+	}
+	if options.Branding == nil && !options.NoBranding {
+		options.Branding = tenant.Branding
+	}
+	if options.Branding != nil && !containsString(options.PreProcess, stageBrand) {
+		options.PreProcess = append(append([]string{}, options.PreProcess...), stageBrand)
+	}
+	if options.BaseURL == "" {
+		options.BaseURL = tenant.BaseURL
+	}
+	if options.InjectCSS == "" {
+		options.InjectCSS = tenant.InjectCSS
+	}
+	if options.InjectJS == "" {
+		options.InjectJS = tenant.InjectJS
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+// runningElementsScript lifts the running elements out of the document. Chrome
+// does not support position: running() and element() in @page margin boxes,
+// so elements marked data-running="header" or "footer" stand in for them: the
+// first of each becomes Chrome's print header or footer, and all of them are
+// removed from the flow. Inside, data-counter="page", "pages", "title" and
+// "date" elements are filled in by Chrome on every page.
+const runningElementsScript = `(() => {
+  const counters = { page: "pageNumber", pages: "totalPages", title: "title", date: "date" };
+  const pick = (name) => {
+    const found = document.querySelectorAll('[data-running="' + name + '"]');
+    if (found.length === 0) { return ""; }
+    const clone = found[0].cloneNode(true);
+    found.forEach((el) => el.remove());
+    clone.removeAttribute("data-running");
+    clone.querySelectorAll("[data-counter]").forEach((el) => {
+      const counter = counters[el.getAttribute("data-counter")];
+      if (counter) { el.classList.add(counter); }
+    });
+    return clone.outerHTML;
+  };
+  return { header: pick("header"), footer: pick("footer") };
+})()`
+
+// runningElements are the print header and footer lifted from the document.
+type runningElements struct {
+	Header string `json:"header"`
+	Footer string `json:"footer"`
+}
+
+// runningTemplateStyle wraps a running element in Chrome's header/footer
+// area, which does not inherit the page styles and defaults to a tiny font.
+const runningTemplateStyle = `<div style="width:100%;padding:0 0.4in;font-size:10pt">`
+
+// runningTemplate returns the Chrome header/footer template of a running
+// element, or an empty span so Chrome does not print its default date and
+// title.
+func runningTemplate(element string) string {
+	if element == "" {
+		return "<span></span>"
+	}
+	return runningTemplateStyle + element + "</div>"
+}
+
+// extractRunningElements runs runningElementsScript in the page.
+func extractRunningElements(ctx context.Context, client *cdpClient, sessionID string) (runningElements, error) {
+	var result struct {
+		Result struct {
+			Value runningElements `json:"value"`
+		} `json:"result"`
+		ExceptionDetails *struct {
+			Text string `json:"text"`
+		} `json:"exceptionDetails"`
+	}
+	if err := client.Call(ctx, sessionID, "Runtime.evaluate", map[string]any{
+		"expression":    runningElementsScript,
+		"returnByValue": true,
+	}, &result); err != nil {
+		return runningElements{}, err
+	}
+	if result.ExceptionDetails != nil {
+		return runningElements{}, errors.New("running elements: " + result.ExceptionDetails.Text)
+	}
+	return result.Result.Value, nil
+}
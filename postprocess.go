@@ -0,0 +1,255 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Post-processing stages, in the order they are applied. A request (or key
+// policy) only selects which stages run; the order is always this one, so
+// e.g. signing never happens before encryption.
+const (
+	stageWatermark = "watermark"
+	stageMetadata  = "metadata"
+	stagePDFA      = "pdfa"
+	stageEncrypt   = "encrypt"
+	stageOptimize  = "optimize"
+	stageSign      = "sign"
+)
+
+var postProcessStages = []string{stageWatermark, stageMetadata, stagePDFA, stageEncrypt, stageOptimize, stageSign}
+
+// postProcessor transforms a rendered PDF.
+type postProcessor interface {
+	process(ctx context.Context, pdf []byte, options pdfOptions) ([]byte, error)
+}
+
+// postProcessorFunc adapts a function to the postProcessor interface.
+type postProcessorFunc func(ctx context.Context, pdf []byte, options pdfOptions) ([]byte, error)
+
+func (f postProcessorFunc) process(ctx context.Context, pdf []byte, options pdfOptions) ([]byte, error) {
+	return f(ctx, pdf, options)
+}
+
+// postProcessError reports a post-processing failure. Status is the HTTP status
+// the handler should answer with.
+type postProcessError struct {
+	status int
+	err    error
+}
+
+func (e *postProcessError) Error() string {
+	return fmt.Sprintf("post-process: %v", e.err)
+}
+
+func (e *postProcessError) Unwrap() error {
+	return e.err
+}
+
+// postProcessPipeline holds the processors available for each stage.
+type postProcessPipeline struct {
+	processors map[string]postProcessor
+}
+
+// newPostProcessPipeline registers the built-in processors plus any external
+// command configured via POST_PROCESS_<STAGE>_CMD.
+func newPostProcessPipeline(cfg config) *postProcessPipeline {
+	p := &postProcessPipeline{processors: map[string]postProcessor{}}
+	p.register(stageWatermark, newWatermarker(cfg))
+	p.register(stageMetadata, postProcessorFunc(applyMetadata))
+	p.register(stagePDFA, newPDFAConverter(cfg))
+	for stage, command := range cfg.PostProcessCommands {
+		p.register(stage, commandPostProcessor{command: command})
+	}
+	return p
+}
+
+func (p *postProcessPipeline) register(stage string, processor postProcessor) {
+	p.processors[stage] = processor
+}
+
+// plan validates the requested stages and returns them in pipeline order.
+func (p *postProcessPipeline) plan(stages []string) ([]string, error) {
+	return planStages(postProcessStages, stages, func(stage string) bool {
+		return p != nil && p.processors[stage] != nil
+	})
+}
+
+// run applies the given (already planned) stages to pdf.
+func (p *postProcessPipeline) run(ctx context.Context, stages []string, pdf []byte, options pdfOptions) ([]byte, error) {
+	for _, stage := range stages {
+		start := time.Now()
+		out, err := p.processors[stage].process(ctx, pdf, options)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", stage, err)
+		}
+		Debugf("post-process %s: %d -> %d bytes in %s", stage, len(pdf), len(out), time.Since(start))
+		pdf = out
+	}
+	return pdf, nil
+}
+
+// postProcessRenderer wraps a renderer and applies options.PostProcess to its
+// output. The chain is validated before rendering so that a bad request does
+// not cost a Chrome render.
+func postProcessRenderer(pipeline *postProcessPipeline, next pdfRenderer) pdfRenderer {
+	return func(ctx context.Context, wsURL, html string, wait time.Duration, options pdfOptions) ([]byte, time.Duration, error) {
+		stages, err := pipeline.plan(options.PostProcess)
+		if err != nil {
+			return nil, 0, &postProcessError{status: http.StatusBadRequest, err: err}
+		}
+		if containsString(stages, stagePDFA) && containsString(stages, stageEncrypt) {
+			return nil, 0, &postProcessError{status: http.StatusBadRequest, err: errors.New("PDF/A documents cannot be encrypted")}
+		}
+
+		pdf, pdfTime, err := next(ctx, wsURL, html, wait, options)
+		if err != nil || len(stages) == 0 {
+			return pdf, pdfTime, err
+		}
+
+		pdf, err = pipeline.run(ctx, stages, pdf, options)
+		if err != nil {
+			// A stage may reject the request itself (e.g. a bad watermark image).
+			var stageErr *postProcessError
+			if errors.As(err, &stageErr) {
+				return nil, pdfTime, stageErr
+			}
+			return nil, pdfTime, &postProcessError{status: http.StatusInternalServerError, err: err}
+		}
+		return pdf, pdfTime, nil
+	}
+}
+
+// parseStageList parses a comma-separated list of stage names. The special
+// value "none" yields an empty, non-nil list (explicitly no post-processing).
+func parseStageList(value string) []string {
+	stages := []string{}
+	if strings.TrimSpace(value) == "none" {
+		return stages
+	}
+	for _, part := range strings.Split(value, ",") {
+		if stage := strings.TrimSpace(part); stage != "" {
+			stages = append(stages, stage)
+		}
+	}
+	return stages
+}
+
+// applyMetadata sets the document information dictionary from options.Metadata.
+func applyMetadata(_ context.Context, pdf []byte, options pdfOptions) ([]byte, error) {
+	meta := options.Metadata
+	entries := []struct {
+		key   string
+		value string
+	}{
+		{"Title", meta.Title},
+		{"Author", meta.Author},
+		{"Subject", meta.Subject},
+		{"Keywords", meta.Keywords},
+		{"Creator", meta.Creator},
+	}
+
+	update, err := newPDFUpdate(pdf)
+	if err != nil {
+		return nil, err
+	}
+
+	var dict bytes.Buffer
+	dict.WriteString("<<")
+	for _, entry := range entries {
+		if entry.value != "" {
+			fmt.Fprintf(&dict, " /%s %s", entry.key, pdfTextString(entry.value))
+		}
+	}
+	fmt.Fprintf(&dict, " /Producer %s", pdfTextString("pdfrest"))
+	fmt.Fprintf(&dict, " /ModDate %s >>", pdfTextString(time.Now().UTC().Format("D:20060102150405Z")))
+
+	update.setInfo(update.addObject(dict.Bytes()))
+	return update.bytes(), nil
+}
+
+// commandPostProcessor runs an external command (e.g. qpdf or ghostscript).
+// If the command line contains {in}/{out} placeholders, the PDF is exchanged
+// through temporary files; otherwise it is piped through stdin/stdout.
+// {pdfa_part} and {pdfa_conformance} are replaced with the requested PDF/A
+// level (e.g. "2" and "b").
+type commandPostProcessor struct {
+	command string
+}
+
+func (c commandPostProcessor) process(ctx context.Context, pdf []byte, options pdfOptions) ([]byte, error) {
+	placeholders := map[string]string{}
+	if options.PDFA != "" {
+		placeholders["{pdfa_part}"] = options.PDFA[:1]
+		placeholders["{pdfa_conformance}"] = options.PDFA[1:]
+	}
+	return runCommand(ctx, c.command, pdf, "in.pdf", placeholders)
+}
+
+// runCommand runs an external command line on input and returns its output
+// PDF. With {in}/{out} placeholders the data goes through temporary files (the
+// input one named inName), otherwise through stdin/stdout. The other
+// placeholders are replaced in every argument.
+func runCommand(ctx context.Context, command string, input []byte, inName string, placeholders map[string]string) ([]byte, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, errors.New("empty command")
+	}
+	for i, arg := range args {
+		for placeholder, value := range placeholders {
+			arg = strings.ReplaceAll(arg, placeholder, value)
+		}
+		args[i] = arg
+	}
+
+	if !strings.Contains(command, "{in}") && !strings.Contains(command, "{out}") {
+		var stdout, stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+		cmd.Stdin = bytes.NewReader(input)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return nil, fmt.Errorf("%s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+		}
+		return stdout.Bytes(), nil
+	}
+
+	dir, err := os.MkdirTemp("", "pdfrest-cmd-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			Warnf("command temp cleanup error: %v", err)
+		}
+	}()
+
+	in := filepath.Join(dir, inName)
+	out := filepath.Join(dir, "out.pdf")
+	if err := os.WriteFile(in, input, 0o600); err != nil {
+		return nil, err
+	}
+	for i, arg := range args {
+		arg = strings.ReplaceAll(arg, "{in}", in)
+		args[i] = strings.ReplaceAll(arg, "{out}", out)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return os.ReadFile(out)
+}
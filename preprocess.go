@@ -0,0 +1,216 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Pre-processing stages, in the order they are applied to the input HTML
+// before it is handed to Chrome.
+const (
+	stageEmail        = "email"
+	stageSanitize     = "sanitize"
+	stageInlineAssets = "inline_assets"
+	stageInject       = "inject"
+	stageBrand        = "brand"
+	stageBaseTag      = "base_tag"
+)
+
+var preProcessStages = []string{stageEmail, stageSanitize, stageInlineAssets, stageInject, stageBrand, stageBaseTag}
+
+// preProcessor transforms the input HTML.
+type preProcessor interface {
+	process(ctx context.Context, html string, options pdfOptions) (string, error)
+}
+
+// preProcessorFunc adapts a function to the preProcessor interface.
+type preProcessorFunc func(ctx context.Context, html string, options pdfOptions) (string, error)
+
+func (f preProcessorFunc) process(ctx context.Context, html string, options pdfOptions) (string, error) {
+	return f(ctx, html, options)
+}
+
+// preProcessError reports a pre-processing failure. Status is the HTTP status
+// the handler should answer with.
+type preProcessError struct {
+	status int
+	err    error
+}
+
+func (e *preProcessError) Error() string {
+	return fmt.Sprintf("pre-process: %v", e.err)
+}
+
+func (e *preProcessError) Unwrap() error {
+	return e.err
+}
+
+// preProcessPipeline holds the processors available for each stage.
+type preProcessPipeline struct {
+	processors map[string]preProcessor
+}
+
+// newPreProcessPipeline registers the built-in HTML processors.
+func newPreProcessPipeline(cfg config) *preProcessPipeline {
+	p := &preProcessPipeline{processors: map[string]preProcessor{}}
+	p.register(stageEmail, preProcessorFunc(processEmailHTML))
+	p.register(stageSanitize, preProcessorFunc(sanitizeHTML))
+	p.register(stageInlineAssets, newAssetInliner(cfg))
+	p.register(stageInject, preProcessorFunc(injectAssets))
+	p.register(stageBrand, preProcessorFunc(applyBranding))
+	p.register(stageBaseTag, preProcessorFunc(addBaseTag))
+	return p
+}
+
+func (p *preProcessPipeline) register(stage string, processor preProcessor) {
+	p.processors[stage] = processor
+}
+
+// plan validates the requested stages and returns them in pipeline order.
+func (p *preProcessPipeline) plan(stages []string) ([]string, error) {
+	return planStages(preProcessStages, stages, func(stage string) bool {
+		return p != nil && p.processors[stage] != nil
+	})
+}
+
+// run applies the given (already planned) stages to html.
+func (p *preProcessPipeline) run(ctx context.Context, stages []string, html string, options pdfOptions) (string, error) {
+	for _, stage := range stages {
+		start := time.Now()
+		out, err := p.processors[stage].process(ctx, html, options)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", stage, err)
+		}
+		Debugf("pre-process %s: %d -> %d bytes in %s", stage, len(html), len(out), time.Since(start))
+		html = out
+	}
+	return html, nil
+}
+
+// preProcessRenderer wraps a renderer and applies options.PreProcess to the
+// input HTML before rendering.
+func preProcessRenderer(pipeline *preProcessPipeline, next pdfRenderer) pdfRenderer {
+	return func(ctx context.Context, wsURL, html string, wait time.Duration, options pdfOptions) ([]byte, time.Duration, error) {
+		// URL sources are loaded by Chrome itself; there is no HTML to process.
+		if options.URL != "" {
+			return next(ctx, wsURL, html, wait, options)
+		}
+		stages, err := pipeline.plan(options.PreProcess)
+		if err != nil {
+			return nil, 0, &preProcessError{status: http.StatusBadRequest, err: err}
+		}
+		html, err = pipeline.run(ctx, stages, html, options)
+		if err != nil {
+			return nil, 0, &preProcessError{status: http.StatusUnprocessableEntity, err: err}
+		}
+		return next(ctx, wsURL, html, wait, options)
+	}
+}
+
+// planStages validates requested against the known stage order and returns the
+// requested stages (deduplicated) in that order.
+func planStages(order, requested []string, available func(string) bool) ([]string, error) {
+	wanted := map[string]bool{}
+	for _, stage := range requested {
+		if !containsString(order, stage) {
+			return nil, fmt.Errorf("unknown stage %q", stage)
+		}
+		if !available(stage) {
+			return nil, fmt.Errorf("stage %q is not configured", stage)
+		}
+		wanted[stage] = true
+	}
+
+	ordered := make([]string, 0, len(wanted))
+	for _, stage := range order {
+		if wanted[stage] {
+			ordered = append(ordered, stage)
+		}
+	}
+	return ordered, nil
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	sanitizeElementRes = []*regexp.Regexp{
+		regexp.MustCompile(`(?is)<script\b[^>]*>.*?</script\s*>`),
+		regexp.MustCompile(`(?is)<iframe\b[^>]*>.*?</iframe\s*>`),
+		regexp.MustCompile(`(?is)<object\b[^>]*>.*?</object\s*>`),
+		regexp.MustCompile(`(?is)<(script|iframe|object|embed|frame|frameset)\b[^>]*>`),
+		regexp.MustCompile(`(?is)<meta\b[^>]*http-equiv\s*=\s*["']?refresh[^>]*>`),
+	}
+	sanitizeEventAttrRe = regexp.MustCompile(`(?i)\s+on[a-z]+\s*=\s*("[^"]*"|'[^']*'|[^\s>]+)`)
+	sanitizeJSURLRe     = regexp.MustCompile(`(?i)\b(href|src|action)\s*=\s*("\s*javascript:[^"]*"|'\s*javascript:[^']*')`)
+	headOpenRe          = regexp.MustCompile(`(?i)<head\b[^>]*>`)
+	htmlOpenRe          = regexp.MustCompile(`(?i)<html\b[^>]*>`)
+	baseTagRe           = regexp.MustCompile(`(?i)<base\b`)
+)
+
+// sanitizeHTML removes scripts, frames/plugins, inline event handlers,
+// javascript: URLs and meta refreshes. It is a best-effort, regex-based filter
+// meant as a safety net, not a replacement for sanitizing in the caller.
+func sanitizeHTML(_ context.Context, input string, _ pdfOptions) (string, error) {
+	out := input
+	for _, re := range sanitizeElementRes {
+		out = re.ReplaceAllString(out, "")
+	}
+	out = sanitizeEventAttrRe.ReplaceAllString(out, "")
+	out = sanitizeJSURLRe.ReplaceAllString(out, `$1="#"`)
+	return out, nil
+}
+
+// injectAssets adds options.InjectCSS and options.InjectJS to the document head.
+func injectAssets(_ context.Context, input string, options pdfOptions) (string, error) {
+	var snippet strings.Builder
+	if options.InjectCSS != "" {
+		snippet.WriteString("<style>")
+		snippet.WriteString(options.InjectCSS)
+		snippet.WriteString("</style>")
+	}
+	if options.InjectJS != "" {
+		snippet.WriteString("<script>")
+		snippet.WriteString(options.InjectJS)
+		snippet.WriteString("</script>")
+	}
+	if snippet.Len() == 0 {
+		return input, nil
+	}
+	return insertIntoHead(input, snippet.String()), nil
+}
+
+// addBaseTag adds <base href> for options.BaseURL so relative URLs resolve,
+// unless the document already declares one.
+func addBaseTag(_ context.Context, input string, options pdfOptions) (string, error) {
+	if options.BaseURL == "" || baseTagRe.MatchString(input) {
+		return input, nil
+	}
+	return insertIntoHead(input, fmt.Sprintf(`<base href="%s">`, html.EscapeString(options.BaseURL))), nil
+}
+
+// insertIntoHead inserts snippet right after the opening <head> tag, falling
+// back to after <html> or to the start of the document.
+func insertIntoHead(input, snippet string) string {
+	if loc := headOpenRe.FindStringIndex(input); loc != nil {
+		return input[:loc[1]] + snippet + input[loc[1]:]
+	}
+	if loc := htmlOpenRe.FindStringIndex(input); loc != nil {
+		return input[:loc[1]] + "<head>" + snippet + "</head>" + input[loc[1]:]
+	}
+	return snippet + input
+}
@@ -0,0 +1,219 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// presetParam selects a print preset.
+const presetParam = "preset"
+
+// printPreset is a named set of render options (query parameters), kept in
+// POLICIES_FILE, that a request selects with preset=<name> instead of
+// repeating them: paper size, margins, running elements, stages and so on.
+type printPreset map[string]string
+
+// validatePresets checks that every preset parses as render options.
+func validatePresets(presets map[string]printPreset) error {
+	for name, preset := range presets {
+		if name == "" {
+			return fmt.Errorf("preset with an empty name")
+		}
+		if _, ok := preset[presetParam]; ok {
+			return fmt.Errorf("preset %s: presets cannot select other presets", name)
+		}
+		if _, err := parsePDFOptions(preset.values()); err != nil {
+			return fmt.Errorf("preset %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (p printPreset) values() url.Values {
+	values := url.Values{}
+	for key, value := range p {
+		values.Set(key, value)
+	}
+	return values
+}
+
+// withPreset returns values with the options of the preset they select
+// filled in; options given in values take precedence over the preset.
+// Values without a preset are returned as they are.
+func (s *policyStore) withPreset(values url.Values) (url.Values, error) {
+	name := values.Get(presetParam)
+	if name == "" {
+		return values, nil
+	}
+	var (
+		preset printPreset
+		ok     bool
+	)
+	if s != nil {
+		s.mu.RLock()
+		preset, ok = s.file.Presets[name]
+		s.mu.RUnlock()
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown preset %q", name)
+	}
+
+	merged := mergeOptions(preset.values(), values)
+	delete(merged, presetParam)
+	return merged, nil
+}
+
+// exclusiveOptions are groups of options that select the same thing in
+// different ways. When a layer of options (the request over a preset, or
+// either over the server defaults) sets one of a group, the layer below
+// contributes none of it, so a default format does not clash with a
+// requested paper_width.
+var exclusiveOptions = [][]string{
+	{"format", "paper_width", "paper_height"},
+	{"landscape", "orientation"},
+	{"output", "deliver"},
+	{"watermark_text", "watermark_image"},
+}
+
+// mergeOptions returns the options of over on top of those of under.
+func mergeOptions(under, over url.Values) url.Values {
+	merged := url.Values{}
+	for key, list := range under {
+		if _, ok := over[key]; ok || overridesGroup(key, over) {
+			continue
+		}
+		merged[key] = list
+	}
+	for key, list := range over {
+		merged[key] = list
+	}
+	return merged
+}
+
+// overridesGroup reports whether over sets an option exclusive with key.
+func overridesGroup(key string, over url.Values) bool {
+	for _, group := range exclusiveOptions {
+		if !containsString(group, key) {
+			continue
+		}
+		for _, other := range group {
+			if _, ok := over[other]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// defaultOptionsPrefix starts the environment variables that set a server
+// default for a render option: DEFAULT_PRINT_BACKGROUND=false is the default
+// of print_background. DEFAULT_MARGINS sets the four margins.
+const defaultOptionsPrefix = "DEFAULT_"
+
+// Options that cannot have a DEFAULT_ variable: the stages have their own
+// (PRE_PROCESS, POST_PROCESS) that tenant and key policies take precedence
+// over, and a default preset would outrank the options it sets.
+var nonDefaultOptions = []string{"pre_process", "post_process", presetParam}
+
+// renderOptionNames are the query parameters parsePDFOptions and
+// parseImageOptions read; a DEFAULT_ variable for any other name is a typo
+// that would otherwise be ignored.
+var renderOptionNames = []string{
+	"backend", "base_url", "block_remote", "branding", "color_scheme", "css", "deliver", "email_mode", "emulate_media",
+	"fail_on_js_error", "format", "freeze_time", "geolocation", "inline_assets", "landscape", "lazy_load",
+	"margin_bottom", "margin_left", "margin_right", "margin_top", "max_pages",
+	"meta_author", "meta_creator", "meta_keywords", "meta_subject", "meta_title",
+	"orientation", "output", "page_ranges", "paper_height", "paper_width", "pdfa", "preview_pages",
+	"print_background", "priority", "random_seed", "reduced_motion", "running_elements", "scale", "strict_assets",
+	"trace_format", "trace_network", "user_agent", "webhook_url",
+	"watermark_color", "watermark_image", "watermark_opacity", "watermark_pages", "watermark_position",
+	"watermark_rotation", "watermark_size", "watermark_text",
+	"clip", "device_scale_factor", "full_page", "height", "quality", "type", "width",
+}
+
+// loadDefaultOptions reads the DEFAULT_* variables of environ (KEY=value
+// pairs).
+func loadDefaultOptions(environ []string) (url.Values, error) {
+	var (
+		defaults = url.Values{}
+		margins  string
+		errs     []error
+	)
+	for _, entry := range environ {
+		key, value, _ := strings.Cut(entry, "=")
+		if !strings.HasPrefix(key, defaultOptionsPrefix) || value == "" {
+			continue
+		}
+		name := strings.ToLower(strings.TrimPrefix(key, defaultOptionsPrefix))
+		switch {
+		case name == "margins":
+			margins = value
+			continue
+		case containsString(nonDefaultOptions, name):
+			errs = append(errs, fmt.Errorf("%s cannot be set", key))
+			continue
+		case !containsString(renderOptionNames, name):
+			errs = append(errs, fmt.Errorf("%s: unknown option %s", key, name))
+			continue
+		}
+		if _, err := parsePDFOptions(url.Values{name: {value}}); err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s %q: %w", key, value, err))
+			continue
+		}
+		defaults.Set(name, value)
+	}
+	if margins != "" {
+		for _, side := range []string{"margin_top", "margin_bottom", "margin_left", "margin_right"} {
+			if _, ok := defaults[side]; !ok {
+				defaults.Set(side, margins)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	if _, err := parsePDFOptions(defaults); err != nil {
+		return nil, fmt.Errorf("invalid DEFAULT_* options: %w", err)
+	}
+	// The image options depend on each other (quality on type), so they
+	// are checked together.
+	if _, err := parseImageOptions(defaults); err != nil {
+		return nil, fmt.Errorf("invalid DEFAULT_* options: %w", err)
+	}
+	return defaults, nil
+}
+
+// optionValues resolves the options of a request: its own, then those of the
+// preset it selects, then defaults: the server defaults (DEFAULT_*), or those
+// of them that apply to the request (see withoutOptions).
+func (s *pdfService) optionValues(values, defaults url.Values) (url.Values, error) {
+	values, err := s.policies.withPreset(values)
+	if err != nil {
+		return nil, err
+	}
+	return mergeOptions(defaults, values), nil
+}
+
+// withoutOptions returns values without the options in names; a name
+// ending in "_", such as "watermark_", removes every option it starts.
+func withoutOptions(values url.Values, names []string) url.Values {
+	kept := url.Values{}
+	for key, list := range values {
+		drop := false
+		for _, name := range names {
+			if key == name || strings.HasSuffix(name, "_") && strings.HasPrefix(key, name) {
+				drop = true
+				break
+			}
+		}
+		if !drop {
+			kept[key] = list
+		}
+	}
+	return kept
+}
@@ -0,0 +1,159 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// pageQuota limits the pages a key may render per UTC hour and day (0 = no
+// limit). It is set per key in POLICIES_FILE:
+//
+//	"keys": {"<api key>": {"id": "billing", "quota": {"pages_per_hour": 1000, "pages_per_day": 10000}}}
+type pageQuota struct {
+	PagesPerHour int `json:"pages_per_hour,omitempty"`
+	PagesPerDay  int `json:"pages_per_day,omitempty"`
+}
+
+// quotaWindow is one window of a pageQuota.
+type quotaWindow struct {
+	name   string
+	length time.Duration
+	limit  int
+}
+
+func (q pageQuota) windows() []quotaWindow {
+	var windows []quotaWindow
+	if q.PagesPerHour > 0 {
+		windows = append(windows, quotaWindow{name: "hour", length: time.Hour, limit: q.PagesPerHour})
+	}
+	if q.PagesPerDay > 0 {
+		windows = append(windows, quotaWindow{name: "day", length: 24 * time.Hour, limit: q.PagesPerDay})
+	}
+	return windows
+}
+
+// quotaError is returned when a render does not fit in a key's page quota.
+// It is answered as JSON with 429 and Retry-After set to the end of the
+// exhausted window.
+type quotaError struct {
+	retryAfter time.Duration
+
+	Code      string `json:"error"`
+	Message   string `json:"message"`
+	Window    string `json:"window"`
+	Limit     int    `json:"limit"`
+	Used      int    `json:"used"`
+	Requested int    `json:"requested,omitempty"`
+}
+
+func (e *quotaError) Error() string {
+	return e.Message
+}
+
+// quotaCounter counts the pages of one key in the current window.
+type quotaCounter struct {
+	start time.Time
+	pages int
+}
+
+// pageQuotas tracks page usage per key ID, in memory: every replica enforces
+// its own quota.
+type pageQuotas struct {
+	now func() time.Time
+
+	mu    sync.Mutex
+	usage map[string]map[string]*quotaCounter
+}
+
+func newPageQuotas() *pageQuotas {
+	return &pageQuotas{now: time.Now, usage: map[string]map[string]*quotaCounter{}}
+}
+
+// counter returns the counter of id in window, reset when a new window began.
+// The caller holds q.mu.
+func (q *pageQuotas) counter(id string, window quotaWindow, now time.Time) *quotaCounter {
+	byWindow, ok := q.usage[id]
+	if !ok {
+		byWindow = map[string]*quotaCounter{}
+		q.usage[id] = byWindow
+	}
+	counter, ok := byWindow[window.name]
+	if !ok {
+		counter = &quotaCounter{}
+		byWindow[window.name] = counter
+	}
+	if start := now.Truncate(window.length); !counter.start.Equal(start) {
+		counter.start, counter.pages = start, 0
+	}
+	return counter
+}
+
+// reserve charges pages to id, or returns a *quotaError without charging
+// anything when a window cannot take them. With pages == 0 it only checks
+// that no window is exhausted.
+func (q *pageQuotas) reserve(id string, quota pageQuota, pages int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.now()
+	windows := quota.windows()
+	counters := make([]*quotaCounter, len(windows))
+	for i, window := range windows {
+		counter := q.counter(id, window, now)
+		counters[i] = counter
+		if counter.pages >= window.limit || counter.pages+pages > window.limit {
+			err := &quotaError{
+				retryAfter: counter.start.Add(window.length).Sub(now),
+				Code:       "quota_exceeded",
+				Window:     window.name,
+				Limit:      window.limit,
+				Used:       counter.pages,
+				Requested:  pages,
+			}
+			if pages > 0 {
+				err.Message = fmt.Sprintf("page quota exceeded: %d pages requested, %d of %d per %s used", pages, counter.pages, window.limit, window.name)
+			} else {
+				err.Message = fmt.Sprintf("page quota exhausted: %d of %d pages per %s used", counter.pages, window.limit, window.name)
+			}
+			return err
+		}
+	}
+	for _, counter := range counters {
+		counter.pages += pages
+	}
+	return nil
+}
+
+// quotaRenderer enforces the page quota of the caller's key policy: a key
+// with an exhausted window is rejected before rendering, and a render is
+// charged its page count afterwards, failing when it does not fit in what is
+// left. Previews are only checked: their pages are charged with the full
+// document. An image counts as one page.
+func quotaRenderer(quotas *pageQuotas, next pdfRenderer) pdfRenderer {
+	return func(ctx context.Context, wsURL, html string, wait time.Duration, options pdfOptions) ([]byte, time.Duration, error) {
+		policy, ok := policyFromContext(ctx)
+		if !ok || policy.Quota == nil {
+			return next(ctx, wsURL, html, wait, options)
+		}
+		if err := quotas.reserve(policy.ID, *policy.Quota, 0); err != nil {
+			return nil, 0, err
+		}
+		pdf, pdfTime, err := next(ctx, wsURL, html, wait, options)
+		if err != nil || options.Preview {
+			return pdf, pdfTime, err
+		}
+		pages := 1
+		if options.Image == nil {
+			pages = countPDFPages(pdf)
+		}
+		if err := quotas.reserve(policy.ID, *policy.Quota, pages); err != nil {
+			return nil, pdfTime, err
+		}
+		return pdf, pdfTime, nil
+	}
+}
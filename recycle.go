@@ -0,0 +1,136 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// chromeRecycler restarts the managed browser after maxRenders renders or
+// maxAge, whichever comes first, so a long-lived Chrome does not keep
+// growing. Once a recycle is due, new renders wait until the in-flight ones
+// have finished and the browser is back.
+type chromeRecycler struct {
+	maxRenders int
+	maxAge     time.Duration
+	restart    func(ctx context.Context) error
+	now        func() time.Time
+
+	mu        sync.Mutex
+	inFlight  int
+	renders   int
+	startedAt time.Time
+	draining  bool
+	recycling bool
+	resumed   chan struct{}
+}
+
+// newChromeRecycler returns nil when neither limit is set.
+func newChromeRecycler(maxRenders int, maxAge time.Duration, restart func(ctx context.Context) error) *chromeRecycler {
+	if maxRenders <= 0 && maxAge <= 0 {
+		return nil
+	}
+	return &chromeRecycler{maxRenders: maxRenders, maxAge: maxAge, restart: restart, now: time.Now, startedAt: time.Now()}
+}
+
+// dueLocked reports whether the browser has reached one of its limits.
+func (r *chromeRecycler) dueLocked() bool {
+	return (r.maxRenders > 0 && r.renders >= r.maxRenders) ||
+		(r.maxAge > 0 && r.now().Sub(r.startedAt) >= r.maxAge)
+}
+
+// drainLocked stops admitting renders and recycles once none is in flight.
+func (r *chromeRecycler) drainLocked() {
+	if !r.draining {
+		r.draining = true
+		r.resumed = make(chan struct{})
+	}
+	if r.inFlight == 0 && !r.recycling {
+		r.recycling = true
+		go r.recycle()
+	}
+}
+
+// begin admits a render, waiting while the browser is recycled. It reports
+// whether the render waited (its websocket URL is then stale).
+func (r *chromeRecycler) begin(ctx context.Context) (bool, error) {
+	waited := false
+	r.mu.Lock()
+	for {
+		if !r.draining && r.dueLocked() {
+			r.drainLocked()
+		}
+		if !r.draining {
+			break
+		}
+		resumed := r.resumed
+		r.mu.Unlock()
+		select {
+		case <-resumed:
+		case <-ctx.Done():
+			return waited, ctx.Err()
+		}
+		waited = true
+		r.mu.Lock()
+	}
+	r.inFlight++
+	r.renders++
+	r.mu.Unlock()
+	return waited, nil
+}
+
+// end releases a render admitted by begin.
+func (r *chromeRecycler) end() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.inFlight--
+	if r.draining || r.dueLocked() {
+		r.drainLocked()
+	}
+}
+
+// recycle restarts the browser and lets the waiting renders through.
+func (r *chromeRecycler) recycle() {
+	r.mu.Lock()
+	renders, age := r.renders, r.now().Sub(r.startedAt)
+	r.mu.Unlock()
+	Infof("chrome recycle: %d renders in %s", renders, age.Round(time.Second))
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultManagedChromeStartTimeout)
+	err := r.restart(ctx)
+	cancel()
+	if err != nil {
+		// The supervisor keeps restarting Chrome; renders fail until it is back.
+		Errorf("chrome recycle: %v", err)
+	}
+
+	r.mu.Lock()
+	r.renders, r.startedAt = 0, r.now()
+	r.draining, r.recycling = false, false
+	close(r.resumed)
+	r.mu.Unlock()
+}
+
+// recycleRenderer counts renders against the recycler. Renders that waited
+// for a recycle resolve the websocket URL of the new browser.
+func recycleRenderer(recycler *chromeRecycler, resolver wsResolver, next pdfRenderer) pdfRenderer {
+	if recycler == nil {
+		return next
+	}
+	return func(ctx context.Context, wsURL, html string, wait time.Duration, options pdfOptions) ([]byte, time.Duration, error) {
+		waited, err := recycler.begin(ctx)
+		if err != nil {
+			return nil, 0, err
+		}
+		defer recycler.end()
+		if waited {
+			if wsURL, err = resolver.wsURL(ctx); err != nil {
+				return nil, 0, err
+			}
+		}
+		return next(ctx, wsURL, html, wait, options)
+	}
+}
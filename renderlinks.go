@@ -0,0 +1,312 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Longest render link token; the data travels in the URL.
+const maxRenderLinkBytes = 4096
+
+// renderLinkFilenameRe restricts the attachment name of a render link.
+var renderLinkFilenameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._ -]{0,99}$`)
+
+// renderLinkRequest is the body of POST /api/v1/render-links: a stored
+// template, its data and the render options (same names and values as the
+// /api/v1/pdf query parameters).
+type renderLinkRequest struct {
+	templateRequest
+	Options   map[string]string `json:"options,omitempty"`
+	Filename  string            `json:"filename,omitempty"`
+	ExpiresIn string            `json:"expires_in,omitempty"`
+}
+
+// renderLink is the response of POST /api/v1/render-links.
+type renderLink struct {
+	URL             string    `json:"url"`
+	ExpiresAt       time.Time `json:"expires_at"`
+	TemplateName    string    `json:"template_name"`
+	TemplateVersion int       `json:"template_version"`
+}
+
+// renderLinkClaims is the signed payload of a render link token. The
+// template version is pinned when the link is issued.
+type renderLinkClaims struct {
+	ID              string            `json:"id"`
+	Expires         int64             `json:"exp"`
+	KeyID           string            `json:"key,omitempty"`
+	TemplateName    string            `json:"tpl"`
+	TemplateVersion int               `json:"ver"`
+	Data            json.RawMessage   `json:"data,omitempty"`
+	Options         map[string]string `json:"opt,omitempty"`
+	Filename        string            `json:"fn,omitempty"`
+}
+
+// renderLinkStore remembers the links already redeemed until they expire, so
+// each link renders once (per replica).
+type renderLinkStore struct {
+	mu   sync.Mutex
+	used map[string]int64
+}
+
+func newRenderLinkStore() *renderLinkStore {
+	return &renderLinkStore{used: make(map[string]int64)}
+}
+
+// claim marks the link id as used; false if it already was.
+func (s *renderLinkStore) claim(id string, expires int64, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for usedID, usedExpires := range s.used {
+		if now.Unix() > usedExpires {
+			delete(s.used, usedID)
+		}
+	}
+	if _, ok := s.used[id]; ok {
+		return false
+	}
+	s.used[id] = expires
+	return true
+}
+
+// release makes a claimed link usable again after a failed render.
+func (s *renderLinkStore) release(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.used, id)
+}
+
+// linkMAC signs the payload of a render link token.
+func (d *downloadSigner) linkMAC(payload string) []byte {
+	mac := hmac.New(sha256.New, d.secret)
+	mac.Write([]byte("render-link\n" + payload))
+	return mac.Sum(nil)
+}
+
+// signLink encodes and signs claims into a token.
+func (d *downloadSigner) signLink(claims renderLinkClaims) (string, error) {
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(d.linkMAC(payload)), nil
+}
+
+// verifyLink checks the signature and expiry of a token.
+func (d *downloadSigner) verifyLink(token string) (renderLinkClaims, bool) {
+	var claims renderLinkClaims
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return claims, false
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, d.linkMAC(payload)) {
+		return claims, false
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil || json.Unmarshal(data, &claims) != nil {
+		return claims, false
+	}
+	return claims, d.now().Unix() <= claims.Expires
+}
+
+// linkOptions parses the options of a render link. Options that change where
+// or how the result is delivered are not allowed.
+func linkOptions(options map[string]string, defaults url.Values) (pdfOptions, error) {
+	values := url.Values{}
+	for key, value := range options {
+		values.Set(key, value)
+	}
+	parsed, err := parsePDFOptions(mergeOptions(defaults, values))
+	if err != nil {
+		return parsed, err
+	}
+	if parsed.Output != "" || len(parsed.Deliver) > 0 || parsed.PreviewPages != "" || parsed.TraceNetwork {
+		return parsed, fmt.Errorf("output, deliver, preview_pages and trace_network are not allowed in render links")
+	}
+	return parsed, nil
+}
+
+// serveRenderLinks serves POST /api/v1/render-links: it issues a signed,
+// one-time URL that renders a stored template without credentials.
+func (s *pdfService) serveRenderLinks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, s.cfg.MaxBodyBytes)
+	body, release, err := readRequestBody(r.Body)
+	if err != nil {
+		http.Error(w, "invalid request body", mapBodyReadErrorToStatus(err))
+		return
+	}
+	var req renderLinkRequest
+	err = json.Unmarshal(body, &req)
+	release()
+	if err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+	if req.TemplateName == "" || req.Template != "" {
+		http.Error(w, "render links require a stored template_name", http.StatusBadRequest)
+		return
+	}
+	if req.Filename != "" && !renderLinkFilenameRe.MatchString(req.Filename) {
+		http.Error(w, "invalid filename", http.StatusBadRequest)
+		return
+	}
+	expiry := s.cfg.RenderLinkExpiry
+	if req.ExpiresIn != "" {
+		expiry, err = time.ParseDuration(req.ExpiresIn)
+		if err != nil || expiry <= 0 {
+			http.Error(w, "invalid expires_in", http.StatusBadRequest)
+			return
+		}
+	}
+	if expiry > s.cfg.RenderLinkMaxExpiry {
+		http.Error(w, fmt.Sprintf("expires_in exceeds %s", s.cfg.RenderLinkMaxExpiry), http.StatusBadRequest)
+		return
+	}
+
+	options, err := linkOptions(req.Options, s.cfg.DefaultOptions)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	applyPolicyDefaults(r.Context(), s.cfg, &options)
+
+	// Execute the template once to pin its version and catch bad data now
+	// rather than in the end user's browser.
+	_, tmpl, err := renderTemplate(s.templates, req.templateRequest, options.Branding)
+	if err != nil {
+		writeTemplateError(w, err)
+		return
+	}
+	if err := s.authorize(r, authzInput{Mode: renderModeTemplate, Template: tmpl.Name}, options); err != nil {
+		writeAuthzError(w, err)
+		return
+	}
+
+	expiresAt := s.downloads.now().Add(expiry).Truncate(time.Second).UTC()
+	claims := renderLinkClaims{
+		ID:              newRequestID(),
+		Expires:         expiresAt.Unix(),
+		TemplateName:    tmpl.Name,
+		TemplateVersion: tmpl.Version,
+		Data:            req.Data,
+		Options:         req.Options,
+		Filename:        req.Filename,
+	}
+	if policy, ok := policyFromContext(r.Context()); ok {
+		claims.KeyID = policy.ID
+	}
+	token, err := s.downloads.signLink(claims)
+	if err != nil {
+		Errorf("render link error: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if len(token) > maxRenderLinkBytes {
+		http.Error(w, "render link data too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	writeJSON(w, http.StatusCreated, renderLink{
+		URL:             pathRenderLinks + "/" + token,
+		ExpiresAt:       expiresAt,
+		TemplateName:    tmpl.Name,
+		TemplateVersion: tmpl.Version,
+	})
+}
+
+// serveRenderLink serves GET /api/v1/render-links/{token}: it renders the
+// request embedded in the token as an attachment, with the policy of the key
+// that issued it. The signature replaces the caller's credentials; a failed
+// render does not use up the link.
+func (s *pdfService) serveRenderLink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	claims, ok := s.downloads.verifyLink(r.PathValue("token"))
+	if !ok {
+		http.Error(w, "invalid or expired render link", http.StatusForbidden)
+		return
+	}
+	ctx := r.Context()
+	if claims.KeyID != "" {
+		policy, ok := s.policies.byID(claims.KeyID)
+		if !ok {
+			http.Error(w, "render link revoked", http.StatusForbidden)
+			return
+		}
+		ctx = context.WithValue(ctx, policyContextKey{}, policy)
+		r = r.WithContext(ctx)
+	}
+	if !s.links.claim(claims.ID, claims.Expires, s.downloads.now()) {
+		http.Error(w, "render link already used", http.StatusGone)
+		return
+	}
+	rendered := false
+	defer func() {
+		if !rendered {
+			s.links.release(claims.ID)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.RequestTimeout)
+	defer cancel()
+
+	options, err := linkOptions(claims.Options, s.cfg.DefaultOptions)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	applyPolicyDefaults(ctx, s.cfg, &options)
+
+	tmplReq := templateRequest{TemplateName: claims.TemplateName, TemplateVersion: claims.TemplateVersion, Data: claims.Data}
+	html, _, err := renderTemplate(s.templates, tmplReq, options.Branding)
+	if err != nil {
+		writeTemplateError(w, err)
+		return
+	}
+
+	wsURL, err := s.resolver.wsURL(ctx)
+	if err != nil {
+		Errorf("chrome ws error: %v", err)
+		http.Error(w, "chrome unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	pdf, _, err := s.renderer(ctx, wsURL, html, s.cfg.PDFWait, options)
+	if err != nil {
+		writeRenderError(w, err)
+		return
+	}
+	rendered = true
+	s.archiveRender(r, html, &tmplReq, options, pdf)
+
+	filename := claims.Filename
+	if filename == "" {
+		filename = pdfFilename
+	}
+	setPDFHeaders(w.Header(), countPDFPages(pdf))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Header().Set("Content-Length", strconv.Itoa(len(pdf)))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(pdf)
+}
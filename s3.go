@@ -0,0 +1,338 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Longest validity of a Signature V4 pre-signed URL.
+	s3MaxPresignExpiry = 7 * 24 * time.Hour
+
+	// outputS3 uploads the PDF to S3_BUCKET instead of returning it.
+	outputS3 = "s3"
+
+	s3Service       = "s3"
+	s3SignAlgorithm = "AWS4-HMAC-SHA256"
+	s3AmzDateFormat = "20060102T150405Z"
+
+	defaultS3KeyTemplate = "pdfrest/{date}/{request_id}.pdf"
+)
+
+// s3Client uploads objects to an S3-compatible bucket with Signature V4.
+type s3Client struct {
+	endpoint     *url.URL
+	region       string
+	bucket       string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	pathStyle    bool
+
+	keyTemplate  string
+	contentType  string
+	sse          string
+	sseKMSKeyID  string
+	cacheControl string
+
+	retry  retryPolicy
+	client *http.Client
+	now    func() time.Time
+}
+
+// s3Object is the response of a render with output=s3.
+type s3Object struct {
+	Bucket string `json:"bucket"`
+	Key    string `json:"key"`
+	URL    string `json:"url"`
+	ETag   string `json:"etag,omitempty"`
+	Bytes  int    `json:"bytes"`
+	Pages  int    `json:"pages"`
+	SHA256 string `json:"sha256"`
+
+	// DownloadURL is a pre-signed GET URL of the object, valid until
+	// ExpiresAt.
+	DownloadURL string     `json:"download_url,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+// newS3Client returns nil when S3_BUCKET is not set (S3 output disabled).
+func newS3Client(cfg config) (*s3Client, error) {
+	if cfg.S3Bucket == "" {
+		return nil, nil
+	}
+	endpoint := cfg.S3Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + cfg.S3Region + ".amazonaws.com"
+	}
+	parsed, err := url.Parse(endpoint)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid S3_ENDPOINT %q", endpoint)
+	}
+	if cfg.S3AccessKeyID == "" || cfg.S3SecretAccessKey == "" {
+		return nil, fmt.Errorf("S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY are required with S3_BUCKET")
+	}
+	if cfg.S3SSE != "" && cfg.S3SSE != "AES256" && cfg.S3SSE != "aws:kms" && cfg.S3SSE != "aws:kms:dsse" {
+		return nil, fmt.Errorf("invalid S3_SSE %q", cfg.S3SSE)
+	}
+	return &s3Client{
+		endpoint:     parsed,
+		region:       cfg.S3Region,
+		bucket:       cfg.S3Bucket,
+		accessKey:    cfg.S3AccessKeyID,
+		secretKey:    cfg.S3SecretAccessKey,
+		sessionToken: cfg.S3SessionToken,
+		pathStyle:    cfg.S3PathStyle,
+		keyTemplate:  cfg.S3KeyTemplate,
+		contentType:  cfg.S3ContentType,
+		sse:          cfg.S3SSE,
+		sseKMSKeyID:  cfg.S3SSEKMSKeyID,
+		cacheControl: cfg.S3CacheControl,
+		retry:        retryPolicy{attempts: cfg.S3Attempts, timeout: cfg.S3Timeout, backoff: cfg.S3RetryBackoff},
+		client:       &http.Client{},
+		now:          time.Now,
+	}, nil
+}
+
+// objectKey expands the key template. Placeholders: {request_id}, {key_id},
+// {tenant}, {date} (2006-01-02), {time} (150405), {sha256}.
+func (c *s3Client) objectKey(ctx context.Context, pdf []byte) string {
+	now := c.now().UTC()
+	sum := sha256.Sum256(pdf)
+	keyID, tenant := "anonymous", "none"
+	if policy, ok := policyFromContext(ctx); ok {
+		keyID = policy.ID
+		if policy.Tenant != "" {
+			tenant = policy.Tenant
+		}
+	}
+	requestID := requestIDFromContext(ctx)
+	if requestID == "" {
+		requestID = newRequestID()
+	}
+	return strings.NewReplacer(
+		"{request_id}", requestID,
+		"{key_id}", keyID,
+		"{tenant}", tenant,
+		"{date}", now.Format("2006-01-02"),
+		"{time}", now.Format("150405"),
+		"{sha256}", hex.EncodeToString(sum[:]),
+	).Replace(c.keyTemplate)
+}
+
+// objectURL returns the URL of key, path-style or virtual-hosted.
+func (c *s3Client) objectURL(key string) *url.URL {
+	u := *c.endpoint
+	if c.pathStyle {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + c.bucket + "/" + key
+	} else {
+		u.Host = c.bucket + "." + u.Host
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + key
+	}
+	u.RawPath = s3EscapePath(u.Path)
+	return &u
+}
+
+// upload stores pdf under the expanded key template, with the S3_TIMEOUT,
+// S3_ATTEMPTS and S3_RETRY_BACKOFF retry policy. The upload is not aborted
+// when the client goes away.
+func (c *s3Client) upload(ctx context.Context, pdf []byte) (*s3Object, error) {
+	key := c.objectKey(ctx, pdf)
+	var object *s3Object
+	err := c.retry.do(ctx, "s3 upload "+key, func(ctx context.Context) error {
+		var err error
+		object, err = c.put(ctx, key, pdf)
+		return err
+	})
+	return object, err
+}
+
+// put makes one attempt at uploading pdf as key.
+func (c *s3Client) put(ctx context.Context, key string, pdf []byte) (*s3Object, error) {
+	target := c.objectURL(key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target.String(), bytes.NewReader(pdf))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", c.contentType)
+	if c.cacheControl != "" {
+		req.Header.Set("Cache-Control", c.cacheControl)
+	}
+	if c.sse != "" {
+		req.Header.Set("X-Amz-Server-Side-Encryption", c.sse)
+		if c.sseKMSKeyID != "" {
+			req.Header.Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", c.sseKMSKeyID)
+		}
+	}
+	sum := sha256.Sum256(pdf)
+	payloadHash := hex.EncodeToString(sum[:])
+	c.sign(req, payloadHash)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, &sinkStatusError{status: resp.StatusCode, msg: fmt.Sprintf("put %s: %s: %s", key, resp.Status, strings.TrimSpace(string(body)))}
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return &s3Object{
+		Bucket: c.bucket,
+		Key:    key,
+		URL:    target.String(),
+		ETag:   strings.Trim(resp.Header.Get("ETag"), `"`),
+		Bytes:  len(pdf),
+		Pages:  countPDFPages(pdf),
+		SHA256: payloadHash,
+	}, nil
+}
+
+// sign adds the Signature V4 Authorization header to req, signing the host
+// and every header already set on it.
+func (c *s3Client) sign(req *http.Request, payloadHash string) {
+	now := c.now().UTC()
+	amzDate := now.Format(s3AmzDateFormat)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if c.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.Join(values, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		s3CanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	signature := c.signature(now, canonicalRequest)
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3SignAlgorithm, c.accessKey, c.scope(now), signedHeaders, signature))
+}
+
+// presign returns a GET URL for key that is valid for expiry (at most 7
+// days), signed in the query string.
+func (c *s3Client) presign(key string, expiry time.Duration) (string, time.Time) {
+	now := c.now().UTC()
+	target := c.objectURL(key)
+	query := url.Values{
+		"X-Amz-Algorithm":     {s3SignAlgorithm},
+		"X-Amz-Credential":    {c.accessKey + "/" + c.scope(now)},
+		"X-Amz-Date":          {now.Format(s3AmzDateFormat)},
+		"X-Amz-Expires":       {strconv.Itoa(int(expiry / time.Second))},
+		"X-Amz-SignedHeaders": {"host"},
+	}
+	if c.sessionToken != "" {
+		query.Set("X-Amz-Security-Token", c.sessionToken)
+	}
+	canonicalQuery := s3CanonicalQuery(query)
+	canonicalRequest := strings.Join([]string{
+		http.MethodGet,
+		target.EscapedPath(),
+		canonicalQuery,
+		"host:" + target.Host + "\n",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+	target.RawQuery = canonicalQuery + "&X-Amz-Signature=" + c.signature(now, canonicalRequest)
+	return target.String(), now.Add(expiry)
+}
+
+// scope is the credential scope of requests signed at now.
+func (c *s3Client) scope(now time.Time) string {
+	return now.Format("20060102") + "/" + c.region + "/" + s3Service + "/aws4_request"
+}
+
+// signature signs a canonical request made at now.
+func (c *s3Client) signature(now time.Time, canonicalRequest string) string {
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := s3SignAlgorithm + "\n" + now.Format(s3AmzDateFormat) + "\n" + c.scope(now) + "\n" + hex.EncodeToString(requestHash[:])
+	return hex.EncodeToString(hmacSHA256(c.signingKey(now), stringToSign))
+}
+
+// signingKey derives the Signature V4 key for the day of now.
+func (c *s3Client) signingKey(now time.Time) []byte {
+	key := hmacSHA256([]byte("AWS4"+c.secretKey), now.Format("20060102"))
+	key = hmacSHA256(key, c.region)
+	key = hmacSHA256(key, s3Service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3EscapePath URI-encodes every path segment as Signature V4 expects
+// (RFC 3986 unreserved characters are kept).
+func s3EscapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = s3Escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+func s3Escape(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		ch := value[i]
+		if ch >= 'A' && ch <= 'Z' || ch >= 'a' && ch <= 'z' || ch >= '0' && ch <= '9' || ch == '-' || ch == '_' || ch == '.' || ch == '~' {
+			b.WriteByte(ch)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", ch)
+	}
+	return b.String()
+}
+
+// s3CanonicalQuery sorts and encodes the query parameters.
+func s3CanonicalQuery(values url.Values) string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, key := range keys {
+		vals := append([]string{}, values[key]...)
+		sort.Strings(vals)
+		for _, value := range vals {
+			parts = append(parts, s3Escape(key)+"="+s3Escape(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
@@ -0,0 +1,190 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cgroupCPUPeriod is the cpu.max period (microseconds) used for CHROME_CPU_LIMIT.
+const cgroupCPUPeriod = 100000
+
+// sandboxLimits are the resource limits applied to managed Chrome. Zero
+// values leave a resource unlimited.
+type sandboxLimits struct {
+	MemoryBytes  int64
+	CPUs         float64
+	MaxProcesses int
+	MaxOpenFiles uint64
+}
+
+func (l sandboxLimits) cgroupLimited() bool {
+	return l.MemoryBytes > 0 || l.CPUs > 0 || l.MaxProcesses > 0
+}
+
+// chromeSandbox confines managed Chrome: memory, CPU and process limits through
+// a cgroup v2 directory that every browser process is started in, the open
+// files limit through RLIMIT_NOFILE (inherited by Chrome's child processes).
+// A nil sandbox applies nothing and never reports violations.
+type chromeSandbox struct {
+	limits    sandboxLimits
+	cgroupDir string
+	cgroup    *os.File
+}
+
+// newChromeSandbox creates the cgroup (when a cgroup limit is set) and writes
+// the limits into it. It returns nil when no limit is configured.
+func newChromeSandbox(limits sandboxLimits, cgroupDir string) (*chromeSandbox, error) {
+	if !limits.cgroupLimited() && limits.MaxOpenFiles == 0 {
+		return nil, nil
+	}
+	s := &chromeSandbox{limits: limits}
+	if !limits.cgroupLimited() {
+		return s, nil
+	}
+	if err := os.MkdirAll(cgroupDir, 0o755); err != nil {
+		return nil, fmt.Errorf("create chrome cgroup: %w", err)
+	}
+	values := map[string]string{}
+	if limits.MemoryBytes > 0 {
+		values["memory.max"] = strconv.FormatInt(limits.MemoryBytes, 10)
+		values["memory.swap.max"] = "0"
+	}
+	if limits.CPUs > 0 {
+		values["cpu.max"] = fmt.Sprintf("%d %d", int64(limits.CPUs*cgroupCPUPeriod), cgroupCPUPeriod)
+	}
+	if limits.MaxProcesses > 0 {
+		values["pids.max"] = strconv.Itoa(limits.MaxProcesses)
+	}
+	for name, value := range values {
+		if err := os.WriteFile(filepath.Join(cgroupDir, name), []byte(value), 0o644); err != nil {
+			if name == "memory.swap.max" {
+				// Not every kernel accounts swap; memory.max still applies.
+				Debugf("chrome cgroup: cannot set %s: %v", name, err)
+				continue
+			}
+			return nil, fmt.Errorf("chrome cgroup: set %s (is the controller enabled in the parent's cgroup.subtree_control?): %w", name, err)
+		}
+	}
+	cgroup, err := os.Open(cgroupDir)
+	if err != nil {
+		return nil, fmt.Errorf("open chrome cgroup: %w", err)
+	}
+	s.cgroupDir, s.cgroup = cgroupDir, cgroup
+	return s, nil
+}
+
+// prepare makes cmd start inside the cgroup.
+func (s *chromeSandbox) prepare(cmd *exec.Cmd) error {
+	if s == nil || s.cgroup == nil {
+		return nil
+	}
+	return startInCgroup(cmd, s.cgroup)
+}
+
+// apply sets the rlimits of the started browser process.
+func (s *chromeSandbox) apply(pid int) error {
+	if s == nil || s.limits.MaxOpenFiles == 0 {
+		return nil
+	}
+	if err := setOpenFilesLimit(pid, s.limits.MaxOpenFiles); err != nil {
+		return fmt.Errorf("chrome rlimit: %w", err)
+	}
+	return nil
+}
+
+// sandboxEvents are the cgroup counters of limit hits.
+type sandboxEvents struct {
+	OOMKills uint64
+	PIDsMax  uint64
+}
+
+// events reads the current cgroup counters.
+func (s *chromeSandbox) events() sandboxEvents {
+	var events sandboxEvents
+	if s == nil || s.cgroupDir == "" {
+		return events
+	}
+	events.OOMKills = readCgroupCounter(filepath.Join(s.cgroupDir, "memory.events"), "oom_kill")
+	events.PIDsMax = readCgroupCounter(filepath.Join(s.cgroupDir, "pids.events"), "max")
+	return events
+}
+
+// violation reports the limit Chrome hit since before, or nil.
+func (s *chromeSandbox) violation(before sandboxEvents) *sandboxError {
+	if s == nil {
+		return nil
+	}
+	now := s.events()
+	switch {
+	case now.OOMKills > before.OOMKills:
+		return &sandboxError{Resource: "memory", Limit: strconv.FormatInt(s.limits.MemoryBytes, 10) + " bytes"}
+	case now.PIDsMax > before.PIDsMax:
+		return &sandboxError{Resource: "processes", Limit: strconv.Itoa(s.limits.MaxProcesses)}
+	}
+	return nil
+}
+
+// readCgroupCounter returns the value of key in a flat-keyed cgroup file.
+func readCgroupCounter(path, key string) uint64 {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == key {
+			value, _ := strconv.ParseUint(fields[1], 10, 64)
+			return value
+		}
+	}
+	return 0
+}
+
+// sandboxError is returned when a render fails because Chrome hit one of the
+// sandbox limits.
+type sandboxError struct {
+	Resource string
+	Limit    string
+	err      error
+}
+
+func (e *sandboxError) Error() string {
+	msg := fmt.Sprintf("chrome exceeded the %s limit (%s)", e.Resource, e.Limit)
+	if e.err != nil {
+		msg += ": " + e.err.Error()
+	}
+	return msg
+}
+
+func (e *sandboxError) Unwrap() error { return e.err }
+
+// sandboxRenderer turns render failures caused by a sandbox limit into a
+// *sandboxError. Other errors pass through unchanged.
+func sandboxRenderer(sandbox *chromeSandbox, next pdfRenderer) pdfRenderer {
+	if sandbox == nil || sandbox.cgroupDir == "" {
+		return next
+	}
+	return func(ctx context.Context, wsURL, html string, wait time.Duration, options pdfOptions) ([]byte, time.Duration, error) {
+		before := sandbox.events()
+		pdf, pdfTime, err := next(ctx, wsURL, html, wait, options)
+		if err != nil {
+			if violation := sandbox.violation(before); violation != nil {
+				violation.err = err
+				return nil, pdfTime, violation
+			}
+		}
+		return pdf, pdfTime, err
+	}
+}
@@ -0,0 +1,35 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"unsafe"
+)
+
+// startInCgroup makes cmd start directly inside the cgroup open as dir, so
+// no browser process ever runs outside of it.
+func startInCgroup(cmd *exec.Cmd, dir *os.File) error {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(dir.Fd())
+	return nil
+}
+
+// setOpenFilesLimit sets RLIMIT_NOFILE of the process pid.
+func setOpenFilesLimit(pid int, limit uint64) error {
+	rlimit := syscall.Rlimit{Cur: limit, Max: limit}
+	_, _, errno := syscall.RawSyscall6(syscall.SYS_PRLIMIT64, uintptr(pid), uintptr(syscall.RLIMIT_NOFILE), uintptr(unsafe.Pointer(&rlimit)), 0, 0, 0)
+	if errno != 0 {
+		return fmt.Errorf("prlimit(%d, RLIMIT_NOFILE): %w", pid, errno)
+	}
+	return nil
+}
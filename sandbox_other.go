@@ -0,0 +1,22 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+var errSandboxUnsupported = errors.New("chrome resource limits are only supported on linux")
+
+func startInCgroup(_ *exec.Cmd, _ *os.File) error {
+	return errSandboxUnsupported
+}
+
+func setOpenFilesLimit(_ int, _ uint64) error {
+	return errSandboxUnsupported
+}
@@ -6,24 +6,39 @@ package main
 import (
 	"context"
 	"errors"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 )
 
-// runServer starts the provided HTTP server and blocks until it receives either:
+// runServer serves the provided HTTP server on ln and blocks until it receives
+// either:
 //   - an OS interrupt/termination signal (SIGINT, SIGTERM), or
-//   - a non-graceful server error from ListenAndServe.
+//   - a non-graceful server error from Serve.
 //
-// It logs the listening address, then attempts a graceful shutdown using
-// srv.Shutdown with a timeout defined by defaultShutdownTimeout.
-func runServer(srv *http.Server, addr string) {
+// If srv.TLSConfig is set (with certificates loaded), the server is started with
+// ServeTLS instead.
+//
+// It logs the listening address, then shuts down gracefully: srv.Shutdown
+// stops accepting connections and waits for the active requests to finish and
+// flush their responses, then the renders still in flight in renders (those
+// of async jobs) are waited for, all within drainTimeout.
+func runServer(srv *http.Server, ln net.Listener, renders *renderTracker, drainTimeout time.Duration) {
 	serverErr := make(chan error, 1)
 
 	go func() {
-		Infof("listening on %s", addr)
-		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		var err error
+		if srv.TLSConfig != nil {
+			Infof("listening on %s (tls)", ln.Addr())
+			err = srv.ServeTLS(ln, "", "")
+		} else {
+			Infof("listening on %s", ln.Addr())
+			err = srv.Serve(ln)
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			serverErr <- err
 		}
 	}()
@@ -41,9 +56,15 @@ func runServer(srv *http.Server, addr string) {
 	}
 
 	// Graceful shutdown.
-	ctx, cancel := context.WithTimeout(context.Background(), defaultShutdownTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
 	defer cancel()
+	if n := renders.inFlight(); n > 0 {
+		Infof("draining %d renders in flight (up to %s)", n, drainTimeout)
+	}
 	if err := srv.Shutdown(ctx); err != nil {
 		Errorf("shutdown error: %v", err)
 	}
+	if err := renders.wait(ctx); err != nil {
+		Errorf("shutdown error: %v", err)
+	}
 }
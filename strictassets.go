@@ -0,0 +1,123 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// strictAssetTypes are the resource types whose failure fails a strict_assets
+// render: the ones that change how the document looks.
+var strictAssetTypes = map[string]bool{"Stylesheet": true, "Image": true, "Font": true}
+
+// brokenAsset is a stylesheet, image or font the page could not load.
+type brokenAsset struct {
+	URL    string `json:"url"`
+	Type   string `json:"type"`
+	Status int    `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// brokenAssetsError fails a strict_assets render whose page could not load
+// some of its assets. It is returned to the client as JSON with 422, so a
+// document missing its logo is not delivered silently.
+type brokenAssetsError struct {
+	Code    string        `json:"error"`
+	Message string        `json:"message"`
+	Assets  []brokenAsset `json:"assets"`
+}
+
+func (e *brokenAssetsError) Error() string {
+	return e.Message
+}
+
+// assetChecker records the failed asset requests of a page from its Network
+// events.
+type assetChecker struct {
+	mu       sync.Mutex
+	requests map[string]brokenAsset
+	broken   []brokenAsset
+}
+
+// watchAssets enables the Network domain on the session and checks the asset
+// requests of the page.
+func watchAssets(ctx context.Context, client *cdpClient, sessionID string) (*assetChecker, error) {
+	checker := &assetChecker{requests: map[string]brokenAsset{}}
+	client.addEventHandler(func(event cdpEvent) {
+		if event.SessionID == sessionID {
+			checker.handle(event)
+		}
+	})
+	if err := client.Call(ctx, sessionID, "Network.enable", nil, nil); err != nil {
+		return nil, err
+	}
+	return checker, nil
+}
+
+// handle processes a single CDP event. Unrelated events are ignored.
+func (c *assetChecker) handle(event cdpEvent) {
+	var params struct {
+		RequestID string `json:"requestId"`
+		Type      string `json:"type"`
+		Request   struct {
+			URL string `json:"url"`
+		} `json:"request"`
+		Response struct {
+			Status int `json:"status"`
+		} `json:"response"`
+		ErrorText string `json:"errorText"`
+		Canceled  bool   `json:"canceled"`
+	}
+	switch event.Method {
+	case "Network.requestWillBeSent", "Network.responseReceived", "Network.loadingFailed":
+	default:
+		return
+	}
+	if err := json.Unmarshal(event.Params, &params); err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if event.Method == "Network.requestWillBeSent" {
+		if strictAssetTypes[params.Type] {
+			c.requests[params.RequestID] = brokenAsset{URL: params.Request.URL, Type: params.Type}
+		}
+		return
+	}
+	asset, ok := c.requests[params.RequestID]
+	if !ok {
+		return
+	}
+	switch {
+	case event.Method == "Network.responseReceived" && params.Response.Status >= http.StatusBadRequest:
+		asset.Status = params.Response.Status
+	case event.Method == "Network.loadingFailed" && !params.Canceled:
+		// Requests the page dropped itself (canceled) are not broken.
+		asset.Error = params.ErrorText
+	default:
+		return
+	}
+	delete(c.requests, params.RequestID)
+	c.broken = append(c.broken, asset)
+}
+
+// check returns a *brokenAssetsError when an asset failed.
+func (c *assetChecker) check() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.broken) == 0 {
+		return nil
+	}
+	return &brokenAssetsError{
+		Code:    "broken_assets",
+		Message: fmt.Sprintf("%d assets failed to load", len(c.broken)),
+		Assets:  append([]brokenAsset(nil), c.broken...),
+	}
+}
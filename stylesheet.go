@@ -0,0 +1,47 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// cssPartName is the multipart body part that carries the css option.
+const cssPartName = "css"
+
+// injectStyleSheetScript appends a stylesheet (%s, a JSON string) at the end
+// of the document, after the page's own styles, so its rules win over rules
+// of the same specificity.
+const injectStyleSheetScript = `(() => {
+  const style = document.createElement("style");
+  style.setAttribute("data-pdfrest", "css");
+  style.textContent = %s;
+  (document.head || document.documentElement).appendChild(style);
+})()`
+
+// injectStyleSheet adds the css option to the loaded page, so callers can
+// apply print tweaks to documents, including url renders, they cannot edit.
+func injectStyleSheet(ctx context.Context, client *cdpClient, sessionID, css string) error {
+	encoded, err := json.Marshal(css)
+	if err != nil {
+		return err
+	}
+	var result struct {
+		ExceptionDetails *struct {
+			Text string `json:"text"`
+		} `json:"exceptionDetails"`
+	}
+	if err := client.Call(ctx, sessionID, "Runtime.evaluate", map[string]any{
+		"expression": fmt.Sprintf(injectStyleSheetScript, encoded),
+	}, &result); err != nil {
+		return err
+	}
+	if result.ExceptionDetails != nil {
+		return errors.New("inject css: " + result.ExceptionDetails.Text)
+	}
+	return nil
+}
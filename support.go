@@ -0,0 +1,187 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+// supportTestHTML is rendered by the support bundle to check the whole chain.
+const supportTestHTML = "<!doctype html><html><body><h1>pdfrest support bundle</h1><p>Test render.</p></body></html>"
+
+// supportManifest describes a support bundle.
+type supportManifest struct {
+	GeneratedAt time.Time `json:"generated_at"`
+	RequestID   string    `json:"request_id,omitempty"`
+	Build       buildInfo `json:"build"`
+	Files       []string  `json:"files"`
+}
+
+// runtimeSnapshot holds process metrics at the time of the bundle.
+type runtimeSnapshot struct {
+	Goroutines     int     `json:"goroutines"`
+	GOMAXPROCS     int     `json:"gomaxprocs"`
+	HeapAllocBytes uint64  `json:"heap_alloc_bytes"`
+	HeapSysBytes   uint64  `json:"heap_sys_bytes"`
+	NumGC          uint32  `json:"num_gc"`
+	UptimeSeconds  float64 `json:"uptime_seconds"`
+}
+
+// supportRender is the outcome of the bundle's test render.
+type supportRender struct {
+	OK         bool    `json:"ok"`
+	DurationMS float64 `json:"duration_ms"`
+	PDFTimeMS  float64 `json:"pdf_time_ms"`
+	Bytes      int     `json:"bytes,omitempty"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// supportBundleHandler serves GET /admin/support-bundle: a tar.gz with the
+// redacted configuration, the status report and runtime metrics, build and
+// Chrome version information, the recent logs and the result of a test
+// render (skipped with ?render=false), to attach to bug reports.
+func supportBundleHandler(cfg config, resolver wsResolver, limiter *renderLimiter, renderer pdfRenderer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), cfg.RequestTimeout)
+		defer cancel()
+
+		statusCtx, statusCancel := context.WithTimeout(ctx, defaultChromeClientTimeout)
+		report := buildHealthReport(statusCtx, resolver, limiter)
+		statusCancel()
+		report.Version = currentBuildInfo().Version
+
+		var memStats runtime.MemStats
+		runtime.ReadMemStats(&memStats)
+
+		files := []struct {
+			name string
+			data any
+		}{
+			{"config.json", cfg.redacted()},
+			{"status.json", report},
+			{"runtime.json", runtimeSnapshot{
+				Goroutines:     runtime.NumGoroutine(),
+				GOMAXPROCS:     runtime.GOMAXPROCS(0),
+				HeapAllocBytes: memStats.HeapAlloc,
+				HeapSysBytes:   memStats.HeapSys,
+				NumGC:          memStats.NumGC,
+				UptimeSeconds:  time.Since(startTime).Seconds(),
+			}},
+			{"version.json", versionResponseBody{buildInfo: currentBuildInfo(), Chrome: report.Chrome}},
+		}
+
+		var renderedPDF []byte
+		if render, err := strconv.ParseBool(r.URL.Query().Get("render")); err != nil || render {
+			var result supportRender
+			result, renderedPDF = supportTestRender(ctx, cfg, resolver, renderer)
+			files = append(files, struct {
+				name string
+				data any
+			}{"test-render.json", result})
+		}
+
+		manifest := supportManifest{
+			GeneratedAt: time.Now().UTC(),
+			RequestID:   requestIDFromContext(r.Context()),
+			Build:       currentBuildInfo(),
+		}
+		for _, file := range files {
+			manifest.Files = append(manifest.Files, file.name)
+		}
+		manifest.Files = append(manifest.Files, "logs.jsonl")
+		if renderedPDF != nil {
+			manifest.Files = append(manifest.Files, "test-render.pdf")
+		}
+
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		archive := tar.NewWriter(gz)
+		add := func(name string, data []byte) error {
+			header := &tar.Header{Name: name, Mode: 0o640, Size: int64(len(data)), ModTime: manifest.GeneratedAt}
+			if err := archive.WriteHeader(header); err != nil {
+				return err
+			}
+			_, err := archive.Write(data)
+			return err
+		}
+		addJSON := func(name string, v any) error {
+			data, err := json.MarshalIndent(v, "", "  ")
+			if err != nil {
+				return err
+			}
+			return add(name, append(data, '\n'))
+		}
+
+		err := addJSON("manifest.json", manifest)
+		for _, file := range files {
+			if err != nil {
+				break
+			}
+			err = addJSON(file.name, file.data)
+		}
+		if err == nil {
+			err = add("logs.jsonl", bytes.Join(recentLogs.snapshot(), nil))
+		}
+		if err == nil && renderedPDF != nil {
+			err = add("test-render.pdf", renderedPDF)
+		}
+		if err == nil {
+			err = archive.Close()
+		}
+		if err == nil {
+			err = gz.Close()
+		}
+		if err != nil {
+			Errorf("support bundle error: %v", err)
+			http.Error(w, "support bundle failed", http.StatusInternalServerError)
+			return
+		}
+
+		Infof("admin support bundle: %d bytes", buf.Len())
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="pdfrest-support-%s.tar.gz"`, manifest.GeneratedAt.Format("20060102-150405")))
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(buf.Bytes())
+	}
+}
+
+// supportTestRender renders supportTestHTML through the full renderer chain
+// with the server defaults.
+func supportTestRender(ctx context.Context, cfg config, resolver wsResolver, renderer pdfRenderer) (supportRender, []byte) {
+	start := time.Now()
+	result := supportRender{}
+
+	wsURL, err := resolver.wsURL(ctx)
+	if err != nil {
+		result.Error = fmt.Sprintf("chrome unavailable: %v", err)
+		result.DurationMS = float64(time.Since(start).Microseconds()) / 1000
+		return result, nil
+	}
+	options := pdfOptions{}
+	applyPolicyDefaults(ctx, cfg, &options)
+	pdf, pdfTime, err := renderer(ctx, wsURL, supportTestHTML, cfg.PDFWait, options)
+	result.DurationMS = float64(time.Since(start).Microseconds()) / 1000
+	result.PDFTimeMS = float64(pdfTime.Microseconds()) / 1000
+	if err != nil {
+		result.Error = err.Error()
+		return result, nil
+	}
+	result.OK, result.Bytes = true, len(pdf)
+	return result, pdf
+}
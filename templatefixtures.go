@@ -0,0 +1,407 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Fixtures are stored under <TEMPLATE_DIR>/<name>/fixtures.
+const templateFixtureDir = "fixtures"
+
+// Most fixtures a template can have; every contract test renders them all.
+const maxTemplateFixtures = 50
+
+// templateFixture is a sample data set of a stored template, rendered by the
+// contract test of the template (POST /api/v1/templates/{name}/test). Query
+// holds render options as /api/v1/pdf query parameters; Pages is the page
+// count the render is expected to have (0 when none was recorded).
+type templateFixture struct {
+	Name      string            `json:"name"`
+	Data      json.RawMessage   `json:"data,omitempty"`
+	Query     map[string]string `json:"query,omitempty"`
+	Pages     int               `json:"pages,omitempty"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// fixtureResult is the outcome of one fixture in a contract test. Status is
+// "pass", "pages_changed", "new" (no expected page count yet) or "error".
+type fixtureResult struct {
+	Name          string `json:"name"`
+	Status        string `json:"status"`
+	Pages         int    `json:"pages,omitempty"`
+	ExpectedPages int    `json:"expected_pages,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// fixtureReport is the response of a contract test.
+type fixtureReport struct {
+	Template string          `json:"template"`
+	Version  int             `json:"version"`
+	Passed   bool            `json:"passed"`
+	Updated  bool            `json:"updated,omitempty"`
+	Fixtures []fixtureResult `json:"fixtures"`
+}
+
+// loadFixtures reads the fixtures persisted under s.dir.
+func (s *templateStore) loadFixtures() error {
+	files, err := filepath.Glob(filepath.Join(s.dir, "*", templateFixtureDir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("read fixture: %w", err)
+		}
+		var fixture templateFixture
+		if err := json.Unmarshal(data, &fixture); err != nil {
+			return fmt.Errorf("parse fixture %s: %w", file, err)
+		}
+		name := filepath.Base(filepath.Dir(filepath.Dir(file)))
+		if s.fixtures[name] == nil {
+			s.fixtures[name] = map[string]templateFixture{}
+		}
+		s.fixtures[name][fixture.Name] = fixture
+	}
+	return nil
+}
+
+// putFixture stores fixture for the template name, replacing the fixture of
+// the same name.
+func (s *templateStore) putFixture(name string, fixture templateFixture) (templateFixture, error) {
+	if !templateNameRe.MatchString(fixture.Name) {
+		return templateFixture{}, &templateError{status: http.StatusBadRequest, msg: "invalid fixture name"}
+	}
+	if _, err := fixtureOptions(fixture, nil); err != nil {
+		return templateFixture{}, &templateError{status: http.StatusBadRequest, msg: err.Error()}
+	}
+	if fixture.Pages < 0 {
+		return templateFixture{}, &templateError{status: http.StatusBadRequest, msg: "invalid pages"}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.live(name) {
+		return templateFixture{}, &templateError{status: http.StatusNotFound, msg: "template not found"}
+	}
+	fixtures := s.fixtures[name]
+	if _, ok := fixtures[fixture.Name]; !ok && len(fixtures) >= maxTemplateFixtures {
+		return templateFixture{}, &templateError{status: http.StatusConflict, msg: fmt.Sprintf("template already has %d fixtures", maxTemplateFixtures)}
+	}
+	fixture.UpdatedAt = time.Now().UTC()
+	if err := s.persistFixture(name, fixture); err != nil {
+		return templateFixture{}, err
+	}
+	if fixtures == nil {
+		fixtures = map[string]templateFixture{}
+		s.fixtures[name] = fixtures
+	}
+	fixtures[fixture.Name] = fixture
+	return fixture, nil
+}
+
+// persistFixture writes a fixture to disk, if the store has a directory.
+// Callers hold s.mu.
+func (s *templateStore) persistFixture(name string, fixture templateFixture) error {
+	if s.dir == "" {
+		return nil
+	}
+	dir := filepath.Join(s.dir, name, templateFixtureDir)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return err
+	}
+	data, err := json.Marshal(fixture)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, fixture.Name+".json"), data, 0o640)
+}
+
+// fixtureList returns the fixtures of the live template name, by name.
+func (s *templateStore) fixtureList(name string) ([]templateFixture, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if !s.live(name) {
+		return nil, false
+	}
+	list := make([]templateFixture, 0, len(s.fixtures[name]))
+	for _, fixture := range s.fixtures[name] {
+		list = append(list, fixture)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, true
+}
+
+// fixture returns one fixture of the live template name.
+func (s *templateStore) fixture(name, fixtureName string) (templateFixture, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if !s.live(name) {
+		return templateFixture{}, false
+	}
+	fixture, ok := s.fixtures[name][fixtureName]
+	return fixture, ok
+}
+
+// deleteFixture removes one fixture of the live template name.
+func (s *templateStore) deleteFixture(name, fixtureName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.fixtures[name][fixtureName]; !ok || !s.live(name) {
+		return &templateError{status: http.StatusNotFound, msg: "fixture not found"}
+	}
+	if s.dir != "" {
+		if err := os.Remove(filepath.Join(s.dir, name, templateFixtureDir, fixtureName+".json")); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	delete(s.fixtures[name], fixtureName)
+	return nil
+}
+
+// recordFixturePages stores the page counts of a contract test as the
+// expected ones. Fixtures changed or deleted meanwhile are left alone.
+func (s *templateStore) recordFixturePages(name string, tested []templateFixture, pages map[string]int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, fixture := range tested {
+		current, ok := s.fixtures[name][fixture.Name]
+		count, rendered := pages[fixture.Name]
+		if !ok || !rendered || !current.UpdatedAt.Equal(fixture.UpdatedAt) {
+			continue
+		}
+		current.Pages = count
+		if err := s.persistFixture(name, current); err != nil {
+			return err
+		}
+		s.fixtures[name][fixture.Name] = current
+	}
+	return nil
+}
+
+// live reports whether name has versions and is not soft-deleted. Callers
+// hold s.mu.
+func (s *templateStore) live(name string) bool {
+	_, deleted := s.deleted[name]
+	return len(s.templates[name]) > 0 && !deleted
+}
+
+// fixtureOptions parses the render options of a fixture. A fixture only
+// describes the document, so options that send it elsewhere are rejected.
+func fixtureOptions(fixture templateFixture, defaults url.Values) (pdfOptions, error) {
+	values := url.Values{}
+	for key, value := range fixture.Query {
+		values.Set(key, value)
+	}
+	options, err := parsePDFOptions(mergeOptions(defaults, values))
+	if err != nil {
+		return options, fmt.Errorf("fixture %s: %w", fixture.Name, err)
+	}
+	if options.Output != "" || len(options.Deliver) > 0 || options.PreviewPages != "" || options.TraceNetwork {
+		return options, fmt.Errorf("fixture %s: output, deliver, preview_pages and trace_network are not supported", fixture.Name)
+	}
+	return options, nil
+}
+
+// testFixture renders one fixture with the given template version and its
+// resolved options, within REQUEST_TIMEOUT.
+func (s *pdfService) testFixture(ctx context.Context, wsURL string, tmpl storedTemplate, fixture templateFixture, options pdfOptions) fixtureResult {
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.RequestTimeout)
+	defer cancel()
+	result := fixtureResult{Name: fixture.Name, ExpectedPages: fixture.Pages}
+	html, _, err := renderTemplate(s.templates, templateRequest{TemplateName: tmpl.Name, TemplateVersion: tmpl.Version, Data: fixture.Data}, options.Branding)
+	if err != nil {
+		result.Status, result.Error = "error", err.Error()
+		return result
+	}
+	pdf, _, err := s.renderer(ctx, wsURL, html, s.cfg.PDFWait, options)
+	if err != nil {
+		result.Status, result.Error = "error", err.Error()
+		return result
+	}
+	result.Pages = countPDFPages(pdf)
+	switch {
+	case fixture.Pages == 0:
+		result.Status = "new"
+	case fixture.Pages != result.Pages:
+		result.Status = "pages_changed"
+	default:
+		result.Status = "pass"
+	}
+	return result
+}
+
+// templateFixturesHandler serves GET /api/v1/templates/{name}/fixtures.
+func templateFixturesHandler(store *templateStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		list, ok := store.fixtureList(r.PathValue("name"))
+		if !ok {
+			http.Error(w, "template not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"fixtures": list})
+	}
+}
+
+// templateFixtureHandler serves /api/v1/templates/{name}/fixtures/{fixture}:
+//   - PUT stores the JSON body ({"data": ..., "query": {...}, "pages": N}),
+//   - GET returns the fixture,
+//   - DELETE removes it.
+func templateFixtureHandler(store *templateStore, maxBodyBytes int64) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name, fixtureName := r.PathValue("name"), r.PathValue("fixture")
+
+		switch r.Method {
+		case http.MethodPut:
+			r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+			var fixture templateFixture
+			decoder := json.NewDecoder(r.Body)
+			decoder.DisallowUnknownFields()
+			if err := decoder.Decode(&fixture); err != nil {
+				var maxErr *http.MaxBytesError
+				if errors.As(err, &maxErr) {
+					http.Error(w, "invalid request body", mapBodyReadErrorToStatus(err))
+					return
+				}
+				http.Error(w, "invalid json body", http.StatusBadRequest)
+				return
+			}
+			fixture.Name = fixtureName
+			stored, err := store.putFixture(name, fixture)
+			if err != nil {
+				writeTemplateError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, stored)
+
+		case http.MethodGet:
+			fixture, ok := store.fixture(name, fixtureName)
+			if !ok {
+				http.Error(w, "fixture not found", http.StatusNotFound)
+				return
+			}
+			writeJSON(w, http.StatusOK, fixture)
+
+		case http.MethodDelete:
+			if err := store.deleteFixture(name, fixtureName); err != nil {
+				writeTemplateError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// serveTemplateTest serves POST /api/v1/templates/{name}/test: it renders
+// every fixture of the template with the latest version (or ?version=N, e.g.
+// one about to be published) and reports failures and page count changes.
+// With ?update=true the page counts are recorded as the expected ones.
+// Fixtures render as template renders of the caller: with its key policy
+// defaults, and authorized before any of them renders.
+func (s *pdfService) serveTemplateTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	name := r.PathValue("name")
+	version := 0
+	if value := r.URL.Query().Get("version"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			http.Error(w, "invalid version", http.StatusBadRequest)
+			return
+		}
+		version = parsed
+	}
+	update, _ := strconv.ParseBool(r.URL.Query().Get("update"))
+
+	tmpl, ok := s.templates.get(name, version)
+	if !ok {
+		http.Error(w, "template not found", http.StatusNotFound)
+		return
+	}
+	fixtures, _ := s.templates.fixtureList(name)
+	if len(fixtures) == 0 {
+		http.Error(w, "template has no fixtures", http.StatusConflict)
+		return
+	}
+
+	options := make([]pdfOptions, len(fixtures))
+	optionErrs := make([]error, len(fixtures))
+	for i, fixture := range fixtures {
+		if options[i], optionErrs[i] = fixtureOptions(fixture, s.cfg.DefaultOptions); optionErrs[i] != nil {
+			continue
+		}
+		applyPolicyDefaults(r.Context(), s.cfg, &options[i])
+		if err := s.authorize(r, authzInput{Mode: renderModeTemplate, Template: tmpl.Name}, options[i]); err != nil {
+			writeAuthzError(w, err)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.cfg.RequestTimeout)
+	wsURL, err := s.resolver.wsURL(ctx)
+	cancel()
+	if err != nil {
+		Errorf("chrome ws error: %v", err)
+		http.Error(w, "chrome unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	report := fixtureReport{Template: tmpl.Name, Version: tmpl.Version, Passed: true}
+	pages := map[string]int{}
+	for i, fixture := range fixtures {
+		result := fixtureResult{Name: fixture.Name, ExpectedPages: fixture.Pages, Status: "error"}
+		if optionErrs[i] != nil {
+			result.Error = optionErrs[i].Error()
+		} else {
+			result = s.testFixture(r.Context(), wsURL, tmpl, fixture, options[i])
+		}
+		if result.Status == "error" || result.Status == "pages_changed" {
+			report.Passed = false
+		} else {
+			pages[fixture.Name] = result.Pages
+		}
+		report.Fixtures = append(report.Fixtures, result)
+	}
+
+	if update {
+		for _, result := range report.Fixtures {
+			if result.Status == "pages_changed" {
+				pages[result.Name] = result.Pages
+			}
+		}
+		if err := s.templates.recordFixturePages(name, fixtures, pages); err != nil {
+			Errorf("template fixture record error: %v", err)
+			http.Error(w, "recording fixtures failed", http.StatusInternalServerError)
+			return
+		}
+		report.Updated = true
+	}
+	Infof("template %s@v%d: %d fixtures tested, passed=%t", tmpl.Name, tmpl.Version, len(fixtures), report.Passed)
+	writeJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,638 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// templateDeletedMarker records the soft-deletion time of a template.
+const templateDeletedMarker = "deleted"
+
+var templateNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,127}$`)
+
+// templateRequest is the JSON body accepted by /api/v1/pdf in template mode:
+// either an inline html/template source or a reference to a stored template,
+// plus the data to execute it with.
+type templateRequest struct {
+	Template        string          `json:"template,omitempty"`
+	TemplateName    string          `json:"template_name,omitempty"`
+	TemplateVersion int             `json:"template_version,omitempty"`
+	Data            json.RawMessage `json:"data,omitempty"`
+}
+
+// storedTemplate is one version of a stored template. DeletedAt and PurgeAt
+// are only set in listings of soft-deleted templates.
+type storedTemplate struct {
+	Name      string     `json:"name"`
+	Version   int        `json:"version"`
+	CreatedAt time.Time  `json:"created_at"`
+	Source    string     `json:"source,omitempty"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	PurgeAt   *time.Time `json:"purge_at,omitempty"`
+}
+
+// templateError carries the HTTP status for template failures.
+type templateError struct {
+	status int
+	msg    string
+}
+
+func (e *templateError) Error() string {
+	return e.msg
+}
+
+// templateStore keeps versioned templates in memory and, when dir is set,
+// persists every version as <dir>/<name>/v<version>.json.
+//
+// Deleting a template is soft: it disappears from lookups but is kept, and can
+// be restored, until the retention window has passed (a <dir>/<name>/deleted
+// marker records when). A zero retention keeps deleted templates forever.
+//
+// Each template also has its fixtures (see templateFixture), kept across
+// versions and persisted under <dir>/<name>/fixtures.
+type templateStore struct {
+	dir       string
+	retention time.Duration
+
+	mu        sync.RWMutex
+	templates map[string][]storedTemplate
+	deleted   map[string]time.Time
+	fixtures  map[string]map[string]templateFixture
+}
+
+// newTemplateStore creates a store and loads any templates found in dir.
+func newTemplateStore(dir string, retention time.Duration) (*templateStore, error) {
+	store := &templateStore{
+		dir:       dir,
+		retention: retention,
+		templates: map[string][]storedTemplate{},
+		deleted:   map[string]time.Time{},
+		fixtures:  map[string]map[string]templateFixture{},
+	}
+	if dir == "" {
+		return store, nil
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("create template dir: %w", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*", "v*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("read template: %w", err)
+		}
+		var tmpl storedTemplate
+		if err := json.Unmarshal(data, &tmpl); err != nil {
+			return nil, fmt.Errorf("parse template %s: %w", file, err)
+		}
+		store.templates[tmpl.Name] = append(store.templates[tmpl.Name], tmpl)
+	}
+	for name := range store.templates {
+		versions := store.templates[name]
+		sort.Slice(versions, func(i, j int) bool { return versions[i].Version < versions[j].Version })
+
+		marker, err := os.ReadFile(filepath.Join(dir, name, templateDeletedMarker))
+		if err == nil {
+			deletedAt, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(string(marker)))
+			if err != nil {
+				return nil, fmt.Errorf("parse deletion marker of %s: %w", name, err)
+			}
+			store.deleted[name] = deletedAt
+		}
+	}
+	if err := store.loadFixtures(); err != nil {
+		return nil, err
+	}
+	store.mu.Lock()
+	store.purgeExpired(time.Now())
+	store.mu.Unlock()
+	Infof("loaded %d stored templates from %s", len(store.templates), dir)
+	return store, nil
+}
+
+// put validates source and stores it as the next version of name.
+func (s *templateStore) put(name, source string) (storedTemplate, error) {
+	if !templateNameRe.MatchString(name) {
+		return storedTemplate{}, &templateError{status: http.StatusBadRequest, msg: "invalid template name"}
+	}
+	if _, err := template.New(name).Funcs(templateFuncs(nil)).Parse(source); err != nil {
+		return storedTemplate{}, &templateError{status: http.StatusUnprocessableEntity, msg: fmt.Sprintf("template parse error: %v", err)}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Storing a new version brings a soft-deleted template back.
+	if _, ok := s.deleted[name]; ok {
+		if err := s.setDeleted(name, time.Time{}); err != nil {
+			return storedTemplate{}, err
+		}
+	}
+
+	versions := s.templates[name]
+	tmpl := storedTemplate{
+		Name:      name,
+		Version:   1,
+		CreatedAt: time.Now().UTC(),
+		Source:    source,
+	}
+	if len(versions) > 0 {
+		tmpl.Version = versions[len(versions)-1].Version + 1
+	}
+
+	if s.dir != "" {
+		if err := s.persist(tmpl); err != nil {
+			return storedTemplate{}, err
+		}
+	}
+	s.templates[name] = append(versions, tmpl)
+	return tmpl, nil
+}
+
+// persist writes a template version to disk.
+func (s *templateStore) persist(tmpl storedTemplate) error {
+	dir := filepath.Join(s.dir, tmpl.Name)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return err
+	}
+	data, err := json.Marshal(tmpl)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, fmt.Sprintf("v%d.json", tmpl.Version)), data, 0o640)
+}
+
+// get returns the given version of name; version 0 means the latest.
+// Soft-deleted templates are not found.
+func (s *templateStore) get(name string, version int) (storedTemplate, bool) {
+	if s == nil {
+		return storedTemplate{}, false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.deleted[name]; ok {
+		return storedTemplate{}, false
+	}
+	versions := s.templates[name]
+	if len(versions) == 0 {
+		return storedTemplate{}, false
+	}
+	if version == 0 {
+		return versions[len(versions)-1], true
+	}
+	for _, tmpl := range versions {
+		if tmpl.Version == version {
+			return tmpl, true
+		}
+	}
+	return storedTemplate{}, false
+}
+
+// list returns the latest version of every template, without sources. With
+// deleted set it lists the soft-deleted templates instead, with their
+// deletion and purge times.
+func (s *templateStore) list(deleted bool) []storedTemplate {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.purgeExpired(time.Now())
+
+	list := make([]storedTemplate, 0, len(s.templates))
+	for name, versions := range s.templates {
+		deletedAt, isDeleted := s.deleted[name]
+		if isDeleted != deleted {
+			continue
+		}
+		latest := versions[len(versions)-1]
+		latest.Source = ""
+		if isDeleted {
+			latest.DeletedAt = &deletedAt
+			if s.retention > 0 {
+				purgeAt := deletedAt.Add(s.retention)
+				latest.PurgeAt = &purgeAt
+			}
+		}
+		list = append(list, latest)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// export returns every version of every live template, with sources.
+func (s *templateStore) export() []storedTemplate {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var all []storedTemplate
+	for name, versions := range s.templates {
+		if _, deleted := s.deleted[name]; deleted {
+			continue
+		}
+		all = append(all, versions...)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Name != all[j].Name {
+			return all[i].Name < all[j].Name
+		}
+		return all[i].Version < all[j].Version
+	})
+	return all
+}
+
+// importVersions adds exported template versions, keeping their version
+// numbers. Versions already present with the same source are skipped; a
+// different source under an existing version number is a conflict, and
+// nothing is imported in that case. It returns the number of new versions.
+func (s *templateStore) importVersions(list []storedTemplate) (int, error) {
+	for _, tmpl := range list {
+		if !templateNameRe.MatchString(tmpl.Name) || tmpl.Version < 1 {
+			return 0, &templateError{status: http.StatusBadRequest, msg: fmt.Sprintf("invalid template %q version %d", tmpl.Name, tmpl.Version)}
+		}
+		if _, err := template.New(tmpl.Name).Funcs(templateFuncs(nil)).Parse(tmpl.Source); err != nil {
+			return 0, &templateError{status: http.StatusUnprocessableEntity, msg: fmt.Sprintf("template %s v%d: %v", tmpl.Name, tmpl.Version, err)}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var added []storedTemplate
+	seen := map[string]string{}
+	for _, tmpl := range list {
+		key := tmpl.Name + "@" + strconv.Itoa(tmpl.Version)
+		existing, ok := s.version(tmpl.Name, tmpl.Version)
+		if source, dup := seen[key]; dup {
+			existing, ok = storedTemplate{Source: source}, true
+		}
+		if ok && existing.Source != tmpl.Source {
+			return 0, &templateError{status: http.StatusConflict, msg: fmt.Sprintf("template %s v%d already exists with a different source", tmpl.Name, tmpl.Version)}
+		}
+		if !ok {
+			added = append(added, tmpl)
+			seen[key] = tmpl.Source
+		}
+	}
+
+	for _, tmpl := range added {
+		tmpl.DeletedAt, tmpl.PurgeAt = nil, nil
+		if tmpl.CreatedAt.IsZero() {
+			tmpl.CreatedAt = time.Now().UTC()
+		}
+		if s.dir != "" {
+			if err := s.persist(tmpl); err != nil {
+				return 0, err
+			}
+		}
+		if _, deleted := s.deleted[tmpl.Name]; deleted {
+			if err := s.setDeleted(tmpl.Name, time.Time{}); err != nil {
+				return 0, err
+			}
+		}
+		versions := append(s.templates[tmpl.Name], tmpl)
+		sort.Slice(versions, func(i, j int) bool { return versions[i].Version < versions[j].Version })
+		s.templates[tmpl.Name] = versions
+	}
+	return len(added), nil
+}
+
+// pinned returns a specific version of name even if the template has been
+// soft-deleted (but not yet purged).
+func (s *templateStore) pinned(name string, version int) (storedTemplate, bool) {
+	if s == nil {
+		return storedTemplate{}, false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.version(name, version)
+}
+
+// version returns a specific version of name, deleted or not. Callers hold s.mu.
+func (s *templateStore) version(name string, version int) (storedTemplate, bool) {
+	for _, tmpl := range s.templates[name] {
+		if tmpl.Version == version {
+			return tmpl, true
+		}
+	}
+	return storedTemplate{}, false
+}
+
+// delete soft-deletes every version of name.
+func (s *templateStore) delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, deleted := s.deleted[name]; deleted || s.templates[name] == nil {
+		return &templateError{status: http.StatusNotFound, msg: "template not found"}
+	}
+	return s.setDeleted(name, time.Now().UTC())
+}
+
+// restore undoes a soft delete and returns the latest version.
+func (s *templateStore) restore(name string) (storedTemplate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, deleted := s.deleted[name]; !deleted {
+		return storedTemplate{}, &templateError{status: http.StatusNotFound, msg: "deleted template not found"}
+	}
+	if err := s.setDeleted(name, time.Time{}); err != nil {
+		return storedTemplate{}, err
+	}
+	versions := s.templates[name]
+	return versions[len(versions)-1], nil
+}
+
+// purgeNow removes every version of name immediately, deleted or not.
+func (s *templateStore) purgeNow(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.purge(name)
+}
+
+// purge removes every version of name immediately. Callers hold s.mu.
+func (s *templateStore) purge(name string) error {
+	if _, ok := s.templates[name]; !ok {
+		return &templateError{status: http.StatusNotFound, msg: "template not found"}
+	}
+	if s.dir != "" {
+		if err := os.RemoveAll(filepath.Join(s.dir, name)); err != nil {
+			return err
+		}
+	}
+	delete(s.templates, name)
+	delete(s.deleted, name)
+	delete(s.fixtures, name)
+	return nil
+}
+
+// purgeExpired removes soft-deleted templates older than the retention
+// window. Callers hold s.mu.
+func (s *templateStore) purgeExpired(now time.Time) {
+	if s.retention <= 0 {
+		return
+	}
+	for name, deletedAt := range s.deleted {
+		if now.Sub(deletedAt) < s.retention {
+			continue
+		}
+		if err := s.purge(name); err != nil {
+			Warnf("purge template %s: %v", name, err)
+			continue
+		}
+		Infof("purged template %s (deleted %s)", name, deletedAt.Format(time.RFC3339))
+	}
+}
+
+// setDeleted marks name as deleted at the given time, or clears the mark for
+// a zero time, keeping the on-disk marker in sync. Callers hold s.mu.
+func (s *templateStore) setDeleted(name string, at time.Time) error {
+	marker := filepath.Join(s.dir, name, templateDeletedMarker)
+	if at.IsZero() {
+		if s.dir != "" {
+			if err := os.Remove(marker); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+		delete(s.deleted, name)
+		return nil
+	}
+	if s.dir != "" {
+		if err := os.WriteFile(marker, []byte(at.Format(time.RFC3339Nano)), 0o640); err != nil {
+			return err
+		}
+	}
+	s.deleted[name] = at
+	return nil
+}
+
+// isJSONRequest reports whether the request body is JSON (template mode).
+func isJSONRequest(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/json"
+}
+
+// renderTemplateBody decodes a template-mode body and executes the template.
+// It returns the resulting HTML and the request with the stored template
+// version pinned, so the render can be reproduced later.
+func renderTemplateBody(store *templateStore, body []byte, branding *tenantBranding) (string, templateRequest, error) {
+	var req templateRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return "", req, &templateError{status: http.StatusBadRequest, msg: "invalid json body"}
+	}
+	html, tmpl, err := renderTemplate(store, req, branding)
+	if err != nil {
+		return "", req, err
+	}
+	if req.TemplateName != "" {
+		req.TemplateVersion = tmpl.Version
+	}
+	return html, req, nil
+}
+
+// renderTemplate resolves and executes the template described by req.
+func renderTemplate(store *templateStore, req templateRequest, branding *tenantBranding) (string, storedTemplate, error) {
+	tmpl := storedTemplate{Name: "inline", Source: req.Template}
+	switch {
+	case req.Template != "" && req.TemplateName != "":
+		return "", storedTemplate{}, &templateError{status: http.StatusBadRequest, msg: "template and template_name are mutually exclusive"}
+	case req.TemplateName != "":
+		stored, ok := store.get(req.TemplateName, req.TemplateVersion)
+		if !ok {
+			return "", storedTemplate{}, &templateError{status: http.StatusNotFound, msg: "template not found"}
+		}
+		tmpl = stored
+	case req.Template == "":
+		return "", storedTemplate{}, &templateError{status: http.StatusBadRequest, msg: "missing template"}
+	}
+
+	var data any
+	if len(req.Data) > 0 {
+		decoder := json.NewDecoder(bytes.NewReader(req.Data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&data); err != nil {
+			return "", storedTemplate{}, &templateError{status: http.StatusBadRequest, msg: "invalid template data"}
+		}
+	}
+
+	html, err := executeTemplate(tmpl.Name, tmpl.Source, data, branding)
+	if err != nil {
+		return "", storedTemplate{}, &templateError{status: http.StatusUnprocessableEntity, msg: err.Error()}
+	}
+	return html, tmpl, nil
+}
+
+// executeTemplate parses and executes an html/template source with data.
+// Missing map keys are reported as errors instead of printing "<no value>".
+func executeTemplate(name, source string, data any, branding *tenantBranding) (string, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs(branding)).Option("missingkey=error").Parse(source)
+	if err != nil {
+		return "", fmt.Errorf("template parse error: %v", err)
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("template execute error: %v", err)
+	}
+	return out.String(), nil
+}
+
+// templateFuncs returns the functions available to templates:
+//   - brand: the caller's tenant branding (empty fields without one).
+func templateFuncs(branding *tenantBranding) template.FuncMap {
+	brand := tenantBranding{}
+	if branding != nil {
+		brand = *branding
+	}
+	return template.FuncMap{
+		"brand": func() tenantBranding { return brand },
+	}
+}
+
+// templatesHandler serves GET /api/v1/templates (list; ?deleted=true lists
+// the soft-deleted templates).
+func templatesHandler(store *templateStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		deleted := false
+		if value := r.URL.Query().Get("deleted"); value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				http.Error(w, "invalid deleted", http.StatusBadRequest)
+				return
+			}
+			deleted = parsed
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"templates": store.list(deleted)})
+	}
+}
+
+// templateHandler serves /api/v1/templates/{name}:
+//   - PUT stores the body as a new version,
+//   - GET returns a version (latest, or ?version=N),
+//   - DELETE soft-deletes the template (?purge=true removes it immediately).
+func templateHandler(store *templateStore, maxBodyBytes int64) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+
+		switch r.Method {
+		case http.MethodPut:
+			r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+			source, err := io.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, "invalid request body", mapBodyReadErrorToStatus(err))
+				return
+			}
+			if len(source) == 0 {
+				http.Error(w, "empty template", http.StatusBadRequest)
+				return
+			}
+			tmpl, err := store.put(name, string(source))
+			if err != nil {
+				writeTemplateError(w, err)
+				return
+			}
+			tmpl.Source = ""
+			writeJSON(w, http.StatusCreated, tmpl)
+
+		case http.MethodGet:
+			version := 0
+			if value := r.URL.Query().Get("version"); value != "" {
+				parsed, err := strconv.Atoi(value)
+				if err != nil || parsed < 1 {
+					http.Error(w, "invalid version", http.StatusBadRequest)
+					return
+				}
+				version = parsed
+			}
+			tmpl, ok := store.get(name, version)
+			if !ok {
+				http.Error(w, "template not found", http.StatusNotFound)
+				return
+			}
+			writeJSON(w, http.StatusOK, tmpl)
+
+		case http.MethodDelete:
+			remove := store.delete
+			if purge, _ := strconv.ParseBool(r.URL.Query().Get("purge")); purge {
+				remove = store.purgeNow
+			}
+			if err := remove(name); err != nil {
+				writeTemplateError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// templateRestoreHandler serves POST /api/v1/templates/{name}/restore.
+func templateRestoreHandler(store *templateStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		tmpl, err := store.restore(r.PathValue("name"))
+		if err != nil {
+			writeTemplateError(w, err)
+			return
+		}
+		tmpl.Source = ""
+		writeJSON(w, http.StatusOK, tmpl)
+	}
+}
+
+// writeTemplateError maps template errors to HTTP responses.
+func writeTemplateError(w http.ResponseWriter, err error) {
+	var tmplErr *templateError
+	if errors.As(err, &tmplErr) {
+		http.Error(w, tmplErr.msg, tmplErr.status)
+		return
+	}
+	Errorf("template store error: %v", err)
+	http.Error(w, "template store failed", http.StatusInternalServerError)
+}
+
+// writeJSON writes v as a JSON response with the given status.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		Warnf("json encode error: %v", err)
+	}
+}
+
+// templateLabel formats a template reference for logs.
+func templateLabel(req templateRequest) string {
+	if req.TemplateName == "" {
+		return "inline"
+	}
+	return req.TemplateName + "@v" + strconv.Itoa(req.TemplateVersion)
+}
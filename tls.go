@@ -0,0 +1,100 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// loadServerTLSConfig builds the TLS configuration for the HTTP listener.
+// It returns nil when TLS is not configured (plain HTTP).
+//
+// Both TLS_CERT_FILE and TLS_KEY_FILE must be set to enable TLS. When
+// TLS_CLIENT_CA_FILE is also set, clients must present a certificate signed by
+// one of the CAs in that bundle (mTLS).
+func loadServerTLSConfig(cfg config) (*tls.Config, error) {
+	if cfg.TLSCertFile == "" && cfg.TLSKeyFile == "" {
+		if cfg.TLSClientCAFile != "" {
+			return nil, errors.New("TLS_CLIENT_CA_FILE requires TLS_CERT_FILE and TLS_KEY_FILE")
+		}
+		return nil, nil
+	}
+	if cfg.TLSCertFile == "" || cfg.TLSKeyFile == "" {
+		return nil, errors.New("both TLS_CERT_FILE and TLS_KEY_FILE must be set")
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load tls key pair: %w", err)
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+
+	if cfg.TLSClientCAFile != "" {
+		pool, err := loadCertPool(cfg.TLSClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return tlsConfig, nil
+}
+
+// loadChromeTLSConfig builds the TLS configuration of wss:// and https://
+// Chrome connections. It returns nil when none is configured (system roots).
+//
+// CHROME_TLS_CA_FILE adds a CA bundle to trust instead of the system roots,
+// CHROME_TLS_CERT_FILE and CHROME_TLS_KEY_FILE present a client certificate,
+// and CHROME_TLS_INSECURE_SKIP_VERIFY disables verification altogether.
+func loadChromeTLSConfig(cfg config) (*tls.Config, error) {
+	if cfg.ChromeTLSCAFile == "" && cfg.ChromeTLSCertFile == "" && cfg.ChromeTLSKeyFile == "" && !cfg.ChromeTLSInsecureSkipVerify {
+		return nil, nil
+	}
+	if (cfg.ChromeTLSCertFile == "") != (cfg.ChromeTLSKeyFile == "") {
+		return nil, errors.New("both CHROME_TLS_CERT_FILE and CHROME_TLS_KEY_FILE must be set")
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.ChromeTLSInsecureSkipVerify,
+	}
+	if cfg.ChromeTLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.ChromeTLSCertFile, cfg.ChromeTLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load chrome tls key pair: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if cfg.ChromeTLSCAFile != "" {
+		pool, err := loadCertPool(cfg.ChromeTLSCAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
+
+// loadCertPool reads a PEM bundle into a new certificate pool.
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read ca bundle: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+	return pool, nil
+}
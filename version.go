@@ -0,0 +1,108 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// Build metadata, injected at build time:
+//
+//	go build -ldflags "-X main.version=1.2.0 -X main.gitCommit=$(git rev-parse HEAD) -X main.buildDate=$(date -u +%FT%TZ)"
+var (
+	version   = ""
+	gitCommit = ""
+	buildDate = ""
+)
+
+// buildInfo describes the running binary.
+type buildInfo struct {
+	Version   string `json:"version"`
+	GitCommit string `json:"git_commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+}
+
+// versionResponseBody is the JSON body of GET /api/v1/version.
+type versionResponseBody struct {
+	buildInfo
+	Chrome chromeStatus `json:"chrome"`
+}
+
+// currentBuildInfo returns the build metadata, falling back to the VERSION
+// file and the Go toolchain's VCS stamping when ldflags were not provided.
+func currentBuildInfo() buildInfo {
+	info := buildInfo{
+		Version:   version,
+		GitCommit: gitCommit,
+		BuildDate: buildDate,
+		GoVersion: runtime.Version(),
+	}
+
+	if info.Version == "" {
+		if data, err := os.ReadFile("VERSION"); err == nil {
+			info.Version = strings.TrimSpace(string(data))
+		}
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range bi.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.GitCommit == "" {
+					info.GitCommit = setting.Value
+				}
+			case "vcs.time":
+				if info.BuildDate == "" {
+					info.BuildDate = setting.Value
+				}
+			}
+		}
+	}
+
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+	if info.GitCommit == "" {
+		info.GitCommit = "unknown"
+	}
+	if info.BuildDate == "" {
+		info.BuildDate = "unknown"
+	}
+	return info
+}
+
+// versionHandler returns build metadata and the connected Chrome version.
+// Chrome being unreachable does not fail the request; the error is reported
+// in the chrome section instead.
+func versionHandler(resolver wsResolver) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), defaultChromeClientTimeout)
+		defer cancel()
+
+		body := versionResponseBody{buildInfo: currentBuildInfo()}
+		if reporter, ok := resolver.(interface {
+			status(ctx context.Context) chromeStatus
+		}); ok {
+			body.Chrome = reporter.status(ctx)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(body); err != nil {
+			Warnf("version encode error: %v", err)
+		}
+	}
+}
@@ -0,0 +1,525 @@
+// Copyright 2026 - Giacomo Failla <failla.giacomo@gmail.com>
+// MIT License. See LICENSE file for details.
+
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"  // register decoders for watermark images
+	_ "image/jpeg" // register decoders for watermark images
+	_ "image/png"  // register decoders for watermark images
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Watermark defaults.
+const (
+	defaultWatermarkOpacity  = 0.3
+	defaultWatermarkFontSize = 48
+	defaultWatermarkRotation = 45
+	defaultWatermarkColor    = "#808080"
+	watermarkMargin          = 36 // points
+	watermarkMaxPixels       = 25_000_000
+
+	// Resource names added to the watermarked pages.
+	watermarkGStateName = "PdfrestWmGS"
+	watermarkFontName   = "PdfrestWmF"
+	watermarkImageName  = "PdfrestWmIm"
+)
+
+var watermarkPositions = []string{
+	"center", "top", "bottom", "left", "right",
+	"top-left", "top-right", "bottom-left", "bottom-right",
+}
+
+// watermarkOptions describes a text or image stamp overlaid on the rendered
+// pages by the watermark post-processing stage.
+type watermarkOptions struct {
+	Text  string
+	Image string // allowlisted URL or data: URI
+
+	Position  string
+	Opacity   float64
+	Rotation  float64
+	Size      float64 // font size (text) or width in points (image); 0 = default
+	Color     string
+	FirstPage bool
+}
+
+// parseWatermarkOptions reads the watermark_* query parameters. It returns
+// nil when no watermark is requested.
+func parseWatermarkOptions(values map[string][]string) (*watermarkOptions, error) {
+	wm := &watermarkOptions{
+		Text:     getQueryValue(values, "watermark_text"),
+		Image:    getQueryValue(values, "watermark_image"),
+		Position: "center",
+		Opacity:  defaultWatermarkOpacity,
+		Color:    defaultWatermarkColor,
+	}
+	if wm.Text == "" && wm.Image == "" {
+		for key := range values {
+			if strings.HasPrefix(key, "watermark_") {
+				return nil, fmt.Errorf("invalid %s: watermark_text or watermark_image required", key)
+			}
+		}
+		return nil, nil
+	}
+	if wm.Text != "" && wm.Image != "" {
+		return nil, errors.New("invalid watermark: use either watermark_text or watermark_image")
+	}
+	if wm.Text != "" {
+		wm.Rotation = defaultWatermarkRotation
+	}
+
+	if value := getQueryValue(values, "watermark_position"); value != "" {
+		if !containsString(watermarkPositions, value) {
+			return nil, errors.New("invalid watermark_position")
+		}
+		wm.Position = value
+	}
+	if value := getQueryValue(values, "watermark_opacity"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed < 0 || parsed > 1 {
+			return nil, errors.New("invalid watermark_opacity")
+		}
+		wm.Opacity = parsed
+	}
+	if value := getQueryValue(values, "watermark_rotation"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(parsed) || math.IsInf(parsed, 0) {
+			return nil, errors.New("invalid watermark_rotation")
+		}
+		wm.Rotation = parsed
+	}
+	if value := getQueryValue(values, "watermark_size"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed <= 0 || parsed > 10000 {
+			return nil, errors.New("invalid watermark_size")
+		}
+		wm.Size = parsed
+	}
+	if value := getQueryValue(values, "watermark_color"); value != "" {
+		if _, err := parseHexColor(value); err != nil {
+			return nil, errors.New("invalid watermark_color")
+		}
+		wm.Color = value
+	}
+	switch getQueryValue(values, "watermark_pages") {
+	case "", "all":
+	case "first":
+		wm.FirstPage = true
+	default:
+		return nil, errors.New("invalid watermark_pages")
+	}
+	return wm, nil
+}
+
+// watermarker implements the built-in watermark post-processing stage. Image
+// watermarks are fetched like inlined assets (ASSET_ALLOWED_HOSTS).
+type watermarker struct {
+	assets *assetInliner
+}
+
+func newWatermarker(cfg config) *watermarker {
+	return &watermarker{assets: newAssetInliner(cfg)}
+}
+
+func (w *watermarker) process(ctx context.Context, pdf []byte, options pdfOptions) ([]byte, error) {
+	wm := options.Watermark
+	if wm == nil {
+		return pdf, nil
+	}
+
+	var img *watermarkImage
+	if wm.Image != "" {
+		data, err := w.loadImage(ctx, wm.Image)
+		if err != nil {
+			return nil, &postProcessError{status: http.StatusBadRequest, err: fmt.Errorf("watermark image: %w", err)}
+		}
+		if img, err = decodeWatermarkImage(data); err != nil {
+			return nil, &postProcessError{status: http.StatusBadRequest, err: fmt.Errorf("watermark image: %w", err)}
+		}
+	}
+	return stampPDF(pdf, wm, img)
+}
+
+// loadImage returns the bytes of a data: URI or an allowlisted URL.
+func (w *watermarker) loadImage(ctx context.Context, ref string) ([]byte, error) {
+	if rest, ok := strings.CutPrefix(ref, "data:"); ok {
+		meta, payload, ok := strings.Cut(rest, ",")
+		if !ok || !strings.HasSuffix(meta, ";base64") {
+			return nil, errors.New("only base64 data URIs are supported")
+		}
+		return base64.StdEncoding.DecodeString(payload)
+	}
+	target, err := url.Parse(ref)
+	if err != nil || !target.IsAbs() {
+		return nil, errors.New("invalid URL")
+	}
+	if !w.assets.allow.allowed(target) {
+		return nil, fmt.Errorf("host %s not allowed", target.Hostname())
+	}
+	run := &inlineRun{inliner: w.assets, ctx: ctx}
+	data, _, ok := run.fetch(target)
+	if !ok {
+		return nil, fmt.Errorf("fetch %s failed", target.Redacted())
+	}
+	return data, nil
+}
+
+// watermarkImage is a decoded image ready to be embedded: 8-bit RGB samples
+// and, when the image is not opaque, an alpha channel (soft mask).
+type watermarkImage struct {
+	Width, Height int
+	RGB           []byte
+	Alpha         []byte
+}
+
+func decodeWatermarkImage(data []byte) (*watermarkImage, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > watermarkMaxPixels {
+		return nil, fmt.Errorf("unsupported dimensions %dx%d", cfg.Width, cfg.Height)
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := src.Bounds()
+	img := &watermarkImage{
+		Width:  bounds.Dx(),
+		Height: bounds.Dy(),
+		RGB:    make([]byte, 0, bounds.Dx()*bounds.Dy()*3),
+	}
+	alpha := make([]byte, 0, bounds.Dx()*bounds.Dy())
+	opaque := true
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(src.At(x, y)).(color.NRGBA)
+			img.RGB = append(img.RGB, c.R, c.G, c.B)
+			alpha = append(alpha, c.A)
+			opaque = opaque && c.A == 0xff
+		}
+	}
+	if !opaque {
+		img.Alpha = alpha
+	}
+	return img, nil
+}
+
+// stampPDF overlays the watermark on the pages of pdf as an incremental
+// update. Each page gets its original content wrapped in q/Q (so a leftover
+// graphics state cannot move the stamp) followed by the stamp.
+func stampPDF(pdf []byte, wm *watermarkOptions, img *watermarkImage) ([]byte, error) {
+	reader, err := newPDFReader(pdf)
+	if err != nil {
+		return nil, err
+	}
+	pages, err := reader.pages()
+	if err != nil {
+		return nil, err
+	}
+	if len(pages) == 0 {
+		return pdf, nil
+	}
+	if wm.FirstPage {
+		pages = pages[:1]
+	}
+	update, err := newPDFUpdate(pdf)
+	if err != nil {
+		return nil, err
+	}
+
+	resources := map[string]map[string]int{
+		"ExtGState": {watermarkGStateName: update.addObject([]byte(fmt.Sprintf(
+			"<< /Type /ExtGState /ca %s /CA %s >>", formatPDFNumber(wm.Opacity), formatPDFNumber(wm.Opacity))))},
+	}
+	if img != nil {
+		resources["XObject"] = map[string]int{watermarkImageName: addImageObjects(update, img)}
+	} else {
+		resources["Font"] = map[string]int{watermarkFontName: update.addObject([]byte(
+			"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>"))}
+	}
+
+	save := update.addObject(pdfStreamObject([]byte("q\n")))
+	stamps := map[[4]float64]int{}
+	for _, page := range pages {
+		stamp, ok := stamps[page.MediaBox]
+		if !ok {
+			ops, err := watermarkOps(page.MediaBox, wm, img)
+			if err != nil {
+				return nil, err
+			}
+			stamp = update.addObject(pdfStreamObject(append([]byte("Q\n"), ops...)))
+			stamps[page.MediaBox] = stamp
+		}
+
+		contents, err := reader.resolve(pdfDictGet(page.Dict, "Contents"))
+		if err != nil {
+			return nil, err
+		}
+		refs := []string{fmt.Sprintf("%d 0 R", save)}
+		for _, ref := range pdfRefsRe.FindAll(contents, -1) {
+			refs = append(refs, string(ref))
+		}
+		refs = append(refs, fmt.Sprintf("%d 0 R", stamp))
+
+		merged, err := mergePDFResources(reader, page.Resources, resources)
+		if err != nil {
+			return nil, fmt.Errorf("pdf: page %d resources: %w", page.Num, err)
+		}
+		dict := pdfDictSet(page.Dict, "Contents", []byte("["+strings.Join(refs, " ")+"]"))
+		dict = pdfDictSet(dict, "Resources", merged)
+		update.setObject(page.Num, formatPDFDict(dict))
+	}
+	return update.bytes(), nil
+}
+
+// addImageObjects adds the image XObject (and its soft mask) to update and
+// returns the image object number.
+func addImageObjects(update *pdfUpdate, img *watermarkImage) int {
+	header := fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /BitsPerComponent 8 /Filter /FlateDecode", img.Width, img.Height)
+	smask := ""
+	if img.Alpha != nil {
+		mask := update.addObject(pdfStreamObjectDict(header+" /ColorSpace /DeviceGray", deflate(img.Alpha)))
+		smask = fmt.Sprintf(" /SMask %d 0 R", mask)
+	}
+	return update.addObject(pdfStreamObjectDict(header+" /ColorSpace /DeviceRGB"+smask, deflate(img.RGB)))
+}
+
+// mergePDFResources returns a resource dictionary with the given named
+// objects added to its categories (ExtGState, Font, XObject).
+func mergePDFResources(reader *pdfReader, resources []byte, add map[string]map[string]int) ([]byte, error) {
+	if len(bytes.TrimSpace(resources)) == 0 {
+		resources = []byte("<< >>")
+	}
+	entries, err := pdfDictEntries(resources)
+	if err != nil {
+		return nil, err
+	}
+	for category, names := range add {
+		sub := []byte("<< >>")
+		if value := pdfDictGet(entries, category); value != nil {
+			if sub, err = reader.resolve(value); err != nil {
+				return nil, err
+			}
+		}
+		subEntries, err := pdfDictEntries(sub)
+		if err != nil {
+			return nil, err
+		}
+		for name, num := range names {
+			subEntries = pdfDictSet(subEntries, name, []byte(fmt.Sprintf("%d 0 R", num)))
+		}
+		entries = pdfDictSet(entries, category, formatPDFDict(subEntries))
+	}
+	return formatPDFDict(entries), nil
+}
+
+// watermarkOps returns the content stream operators drawing the watermark on
+// a page with the given media box.
+func watermarkOps(box [4]float64, wm *watermarkOptions, img *watermarkImage) ([]byte, error) {
+	var ops bytes.Buffer
+	fmt.Fprintf(&ops, "q /%s gs\n", watermarkGStateName)
+
+	if img != nil {
+		width := wm.Size
+		if width == 0 {
+			width = math.Abs(box[2]-box[0]) / 3
+		}
+		height := width * float64(img.Height) / float64(img.Width)
+		m := watermarkMatrix(box, width, height, wm.Position, wm.Rotation)
+		fmt.Fprintf(&ops, "%s %s %s %s %s %s cm /%s Do\n",
+			formatPDFNumber(m[0]*width), formatPDFNumber(m[1]*width),
+			formatPDFNumber(m[2]*height), formatPDFNumber(m[3]*height),
+			formatPDFNumber(m[4]), formatPDFNumber(m[5]), watermarkImageName)
+	} else {
+		rgb, err := parseHexColor(wm.Color)
+		if err != nil {
+			return nil, err
+		}
+		size := wm.Size
+		if size == 0 {
+			size = defaultWatermarkFontSize
+		}
+		text := winAnsiEncode(wm.Text)
+		m := watermarkMatrix(box, helveticaBoldWidth(text)*size/1000, helveticaBoldCapHeight*size/1000, wm.Position, wm.Rotation)
+		fmt.Fprintf(&ops, "%s %s %s rg BT /%s %s Tf %s %s %s %s %s %s Tm %s Tj ET\n",
+			formatPDFNumber(rgb[0]), formatPDFNumber(rgb[1]), formatPDFNumber(rgb[2]),
+			watermarkFontName, formatPDFNumber(size),
+			formatPDFNumber(m[0]), formatPDFNumber(m[1]), formatPDFNumber(m[2]),
+			formatPDFNumber(m[3]), formatPDFNumber(m[4]), formatPDFNumber(m[5]),
+			pdfLiteralString(text))
+	}
+	ops.WriteString("Q\n")
+	return ops.Bytes(), nil
+}
+
+// watermarkMatrix returns the rotation/translation matrix placing a
+// width×height box (origin at its lower-left corner) at position on the page:
+// the bounding box of the rotated box is centered or kept watermarkMargin
+// away from the page edges.
+func watermarkMatrix(box [4]float64, width, height float64, position string, rotation float64) [6]float64 {
+	rad := rotation * math.Pi / 180
+	cos, sin := math.Cos(rad), math.Sin(rad)
+
+	xs := []float64{0, width * cos, -height * sin, width*cos - height*sin}
+	ys := []float64{0, width * sin, height * cos, width*sin + height*cos}
+	minX, maxX := minMax(xs)
+	minY, maxY := minMax(ys)
+
+	x0, x1 := math.Min(box[0], box[2]), math.Max(box[0], box[2])
+	y0, y1 := math.Min(box[1], box[3]), math.Max(box[1], box[3])
+	tx := (x0 + x1 - (maxX - minX)) / 2
+	ty := (y0 + y1 - (maxY - minY)) / 2
+	for _, part := range strings.Split(position, "-") {
+		switch part {
+		case "left":
+			tx = x0 + watermarkMargin
+		case "right":
+			tx = x1 - watermarkMargin - (maxX - minX)
+		case "top":
+			ty = y1 - watermarkMargin - (maxY - minY)
+		case "bottom":
+			ty = y0 + watermarkMargin
+		}
+	}
+	return [6]float64{cos, sin, -sin, cos, tx - minX, ty - minY}
+}
+
+func minMax(values []float64) (float64, float64) {
+	lo, hi := values[0], values[0]
+	for _, v := range values[1:] {
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	return lo, hi
+}
+
+// parseHexColor parses #rgb or #rrggbb into 0..1 components.
+func parseHexColor(value string) ([3]float64, error) {
+	var rgb [3]float64
+	hex, ok := strings.CutPrefix(value, "#")
+	if !ok || (len(hex) != 3 && len(hex) != 6) {
+		return rgb, errors.New("invalid color")
+	}
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	for i := range rgb {
+		v, err := strconv.ParseUint(hex[2*i:2*i+2], 16, 8)
+		if err != nil {
+			return rgb, errors.New("invalid color")
+		}
+		rgb[i] = float64(v) / 255
+	}
+	return rgb, nil
+}
+
+// winAnsiEncode converts s to WinAnsiEncoding for the standard font. Latin-1
+// characters are kept; anything else becomes '?'.
+func winAnsiEncode(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r >= 0x20 && r < 0x7f, r >= 0xa0 && r <= 0xff:
+			out = append(out, byte(r))
+		case r < 0x20:
+		default:
+			out = append(out, '?')
+		}
+	}
+	return out
+}
+
+// pdfLiteralString encodes raw bytes as a PDF literal string.
+func pdfLiteralString(data []byte) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, c := range data {
+		switch {
+		case c == '\\' || c == '(' || c == ')':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c >= 0x80:
+			fmt.Fprintf(&b, "\\%03o", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// Helvetica-Bold metrics (AFM), in thousandths of the font size.
+const helveticaBoldCapHeight = 718
+
+var helveticaBoldWidths = [95]int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278, // ' ' .. '/'
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611, // '0' .. '?'
+	975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778, // '@' .. 'O'
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556, // 'P' .. '_'
+	333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611, // '`' .. 'o'
+	611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584, //      'p' .. '~'
+}
+
+// helveticaBoldWidth returns the width of WinAnsi text in thousandths of the
+// font size. Non-ASCII characters are approximated.
+func helveticaBoldWidth(text []byte) float64 {
+	width := 0
+	for _, c := range text {
+		if c >= 0x20 && c < 0x7f {
+			width += helveticaBoldWidths[c-0x20]
+		} else {
+			width += 556
+		}
+	}
+	return float64(width)
+}
+
+// pdfStreamObject returns a stream object body for data.
+func pdfStreamObject(data []byte) []byte {
+	return pdfStreamObjectDict("", data)
+}
+
+// pdfStreamObjectDict returns a stream object body with extra dictionary
+// entries.
+func pdfStreamObjectDict(entries string, data []byte) []byte {
+	if entries != "" {
+		entries += " "
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "<< %s/Length %d >>\nstream\n", entries, len(data))
+	b.Write(data)
+	b.WriteString("\nendstream")
+	return b.Bytes()
+}
+
+func deflate(data []byte) []byte {
+	var b bytes.Buffer
+	zw := zlib.NewWriter(&b)
+	_, _ = zw.Write(data)
+	_ = zw.Close()
+	return b.Bytes()
+}
+
+// formatPDFNumber formats a real number for a content stream.
+func formatPDFNumber(v float64) string {
+	v = math.Round(v*10000) / 10000
+	if v == 0 {
+		return "0"
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}